@@ -0,0 +1,161 @@
+package monocr
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+const altoNamespace = "http://www.loc.gov/standards/alto/ns-v4#"
+
+type altoDoc struct {
+	XMLName        xml.Name        `xml:"alto"`
+	Xmlns          string          `xml:"xmlns,attr"`
+	XmlnsXsi       string          `xml:"xmlns:xsi,attr"`
+	SchemaLocation string          `xml:"xsi:schemaLocation,attr"`
+	Description    altoDescription `xml:"Description"`
+	Pages          []altoPage      `xml:"Layout>Page"`
+}
+
+type altoDescription struct {
+	MeasurementUnit string `xml:"MeasurementUnit"`
+	FileName        string `xml:"sourceImageInformation>fileName"`
+	Software        string `xml:"OCRProcessing>ocrProcessingStep>processingSoftware>softwareName"`
+}
+
+type altoPage struct {
+	ID         string         `xml:"ID,attr"`
+	PhysicalNr int            `xml:"PHYSICAL_IMG_NR,attr"`
+	Width      int            `xml:"WIDTH,attr"`
+	Height     int            `xml:"HEIGHT,attr"`
+	PC         string         `xml:"PC,attr,omitempty"`
+	PrintSpace altoPrintSpace `xml:"PrintSpace"`
+}
+
+type altoPrintSpace struct {
+	altoBox
+	Blocks []altoTextBlock `xml:"TextBlock"`
+}
+
+type altoTextBlock struct {
+	ID string `xml:"ID,attr"`
+	altoBox
+	Lines []altoTextLine `xml:"TextLine"`
+}
+
+type altoTextLine struct {
+	ID string `xml:"ID,attr"`
+	altoBox
+	// Strings and spaces interleave, so they share one slice.
+	Items []any
+}
+
+type altoString struct {
+	XMLName xml.Name `xml:"String"`
+	ID      string   `xml:"ID,attr"`
+	Content string   `xml:"CONTENT,attr"`
+	altoBox
+	WC string `xml:"WC,attr"`
+	CC string `xml:"CC,attr,omitempty"`
+}
+
+type altoSpace struct {
+	XMLName xml.Name `xml:"SP"`
+}
+
+type altoBox struct {
+	HPos   int `xml:"HPOS,attr"`
+	VPos   int `xml:"VPOS,attr"`
+	Width  int `xml:"WIDTH,attr"`
+	Height int `xml:"HEIGHT,attr"`
+}
+
+func boxOf(r image.Rectangle) altoBox {
+	return altoBox{HPos: r.Min.X, VPos: r.Min.Y, Width: r.Dx(), Height: r.Dy()}
+}
+
+// EncodeALTO writes r as an ALTO 4 document with one Page per page, one
+// TextBlock holding its lines, and a String per word carrying the word
+// confidence (WC) and character confidences (CC). source names the input
+// file in the description. Coordinates are in pixels of the page image.
+func EncodeALTO(w io.Writer, r *Result, source string) error {
+	doc := altoDoc{
+		Xmlns:          altoNamespace,
+		XmlnsXsi:       "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: altoNamespace + " http://www.loc.gov/standards/alto/v4/alto-4-2.xsd",
+		Description: altoDescription{
+			MeasurementUnit: "pixel",
+			FileName:        filepath.Base(source),
+			Software:        "monocr",
+		},
+	}
+
+	for _, page := range r.Pages {
+		pageID := fmt.Sprintf("p%d", page.Number)
+		full := image.Rect(0, 0, page.Width, page.Height)
+		ap := altoPage{
+			ID:         pageID,
+			PhysicalNr: page.Number,
+			Width:      page.Width,
+			Height:     page.Height,
+			PrintSpace: altoPrintSpace{altoBox: boxOf(full)},
+		}
+		if len(page.Lines) > 0 {
+			ap.PC = fmt.Sprintf("%.4f", page.Confidence())
+		}
+
+		block := altoTextBlock{ID: pageID + "_b1"}
+		var blockRect image.Rectangle
+		for i, line := range page.Lines {
+			lineID := fmt.Sprintf("%s_l%d", pageID, i+1)
+			al := altoTextLine{ID: lineID, altoBox: boxOf(line.BBox)}
+			for j, word := range line.Words() {
+				if j > 0 {
+					al.Items = append(al.Items, altoSpace{})
+				}
+				al.Items = append(al.Items, altoString{
+					ID:      fmt.Sprintf("%s_w%d", lineID, j+1),
+					Content: word.Text,
+					altoBox: boxOf(word.BBox),
+					WC:      fmt.Sprintf("%.4f", word.Confidence),
+					CC:      charConfidences(word),
+				})
+			}
+			block.Lines = append(block.Lines, al)
+			blockRect = blockRect.Union(line.BBox)
+		}
+		if len(block.Lines) > 0 {
+			block.altoBox = boxOf(blockRect)
+			ap.PrintSpace.Blocks = append(ap.PrintSpace.Blocks, block)
+		}
+		doc.Pages = append(doc.Pages, ap)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// charConfidences renders ALTO's CC attribute: one digit per character
+// from 0 (certain) to 9 (unsure).
+func charConfidences(word Word) string {
+	if len(word.Chars) == 0 {
+		return ""
+	}
+	digits := make([]string, len(word.Chars))
+	for i, c := range word.Chars {
+		digits[i] = fmt.Sprint(int(math.Round(9 * (1 - c.Confidence))))
+	}
+	return strings.Join(digits, " ")
+}
@@ -0,0 +1,31 @@
+package monocr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCancelled reports that a read stopped because its context was done.
+// Errors from cancelled reads wrap both ErrCancelled and the context's
+// error, so errors.Is works with either.
+var ErrCancelled = errors.New("monocr: read cancelled")
+
+// CancelledError is returned when a context-aware read is cancelled part
+// way through. Partial holds the pages recognized before cancellation.
+type CancelledError struct {
+	Partial *Result
+	Cause   error
+}
+
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("%v after %d pages: %v", ErrCancelled, len(e.Partial.Pages), e.Cause)
+}
+
+func (e *CancelledError) Unwrap() []error {
+	return []error{ErrCancelled, e.Cause}
+}
+
+// cancelled wraps the context error cause with the partial result.
+func cancelled(partial *Result, cause error) error {
+	return &CancelledError{Partial: partial, Cause: cause}
+}
@@ -0,0 +1,120 @@
+package monocr
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/imgproc"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+)
+
+// CapabilityReport describes what the host offers for recognition: the ONNX
+// Runtime library and its execution providers, the PDF renderer, the CPU
+// and the model cache. Problems are recorded in the *Error fields rather
+// than returned, so the report can back a health endpoint as is.
+type CapabilityReport struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	CPUs int    `json:"cpus"`
+	// CPUFeatures lists the SIMD extensions the CPU reports, such as avx2
+	// or asimd (NEON), in the kernel's spelling.
+	CPUFeatures []string `json:"cpu_features,omitempty"`
+	// Preprocessing names the kernels chosen for grayscale conversion,
+	// thresholding and normalization on this CPU: "avx2" or "generic".
+	Preprocessing string `json:"preprocessing"`
+
+	// Runtime is nil if ONNX Runtime could not be loaded; RuntimeError
+	// says why.
+	Runtime      *predictor.RuntimeInfo `json:"runtime,omitempty"`
+	RuntimeError string                 `json:"runtime_error,omitempty"`
+
+	// Poppler is the path of pdftoppm, needed to read PDFs, or "" if it
+	// was not found; PopplerError says where to get it.
+	Poppler      string `json:"poppler,omitempty"`
+	PopplerError string `json:"poppler_error,omitempty"`
+
+	Cache CacheStatus `json:"cache"`
+}
+
+// CacheStatus describes the model cache.
+type CacheStatus struct {
+	Dir string `json:"dir"`
+	// Model is where the default model is, or would be downloaded to, in
+	// the version and variant the options select.
+	Model   string `json:"model"`
+	Present bool   `json:"present"`
+	Size    int64  `json:"size,omitempty"`
+	// Models counts every cached model, of any version or variant.
+	Models  int    `json:"models"`
+	Offline bool   `json:"offline"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Capabilities reports on the host without loading a model, the checks
+// behind "monocr doctor". opts select the model version and variant whose
+// cache status is reported.
+func Capabilities(opts ...Option) *CapabilityReport {
+	o := newOptions(opts)
+	c := &CapabilityReport{
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		CPUs:          runtime.NumCPU(),
+		CPUFeatures:   cpuFeatures(),
+		Preprocessing: imgproc.Kernels(),
+	}
+
+	if info, err := predictor.Runtime(); err != nil {
+		c.RuntimeError = err.Error()
+	} else {
+		c.Runtime = info
+	}
+
+	if path, err := findPoppler("pdftoppm"); err != nil {
+		c.PopplerError = err.Error()
+	} else {
+		c.Poppler = path
+	}
+
+	c.Cache = o.cacheStatus()
+	return c
+}
+
+func (o *options) cacheStatus() CacheStatus {
+	manager, err := o.modelManager()
+	if err != nil {
+		return CacheStatus{Error: err.Error()}
+	}
+
+	status := CacheStatus{Dir: manager.CacheDir, Model: manager.ModelPath(), Offline: manager.Offline}
+	if info, err := os.Stat(status.Model); err == nil {
+		status.Present, status.Size = true, info.Size()
+	}
+	cached, err := manager.Cached()
+	if err != nil {
+		status.Error = err.Error()
+	}
+	status.Models = len(cached)
+	return status
+}
+
+// simdFeatures are the CPU extensions worth reporting for inference and
+// image preprocessing, in /proc/cpuinfo spelling.
+var simdFeatures = []string{
+	// x86
+	"sse2", "ssse3", "sse4_1", "sse4_2", "avx", "avx2", "fma", "f16c",
+	"avx512f", "avx512bw", "avx512vl", "avx512_vnni", "avx_vnni",
+	// ARM
+	"asimd", "asimdhp", "asimddp", "i8mm", "bf16", "sve", "sve2",
+}
+
+// baselineCPUFeatures returns the SIMD extensions the Go port requires
+// of its architecture.
+func baselineCPUFeatures() []string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return []string{"sse2"}
+	case "arm64":
+		return []string{"asimd"}
+	}
+	return nil
+}
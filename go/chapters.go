@@ -0,0 +1,210 @@
+package monocr
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Heading heuristics: a heading's line is at least headingScale times as
+// tall as the document's typical line and at most maxHeadingRunes long.
+const (
+	headingScale    = 1.6
+	maxHeadingRunes = 80
+)
+
+// Chapter is the part of a document from one heading or bookmark to the
+// next.
+type Chapter struct {
+	// Title is the heading or bookmark text; it is empty for the front
+	// matter before the first chapter.
+	Title string `json:"title"`
+	// Page is the number of the page the chapter starts on.
+	Page int `json:"page"`
+	// Pages holds the chapter's share of each page it spans.
+	Pages []Page `json:"pages"`
+}
+
+// Text returns the chapter's lines, with pages separated by blank lines.
+func (c Chapter) Text() string {
+	texts := make([]string, len(c.Pages))
+	for i, page := range c.Pages {
+		texts[i] = page.Text()
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// Markdown returns the chapter as Markdown: the title as a heading, then
+// the text reflowed into paragraphs by Line.Break. Running headers and
+// footers are left out.
+func (c Chapter) Markdown() string {
+	var sb strings.Builder
+	if c.Title != "" {
+		sb.WriteString("# " + c.Title + "\n\n")
+	}
+	var para strings.Builder
+	flush := func() {
+		if text := strings.TrimSpace(para.String()); text != "" {
+			sb.WriteString(text + "\n\n")
+		}
+		para.Reset()
+	}
+	for _, page := range c.Pages {
+		for _, line := range page.Lines {
+			text := strings.TrimSpace(line.Text)
+			if line.Running != "" || text == "" {
+				continue
+			}
+			switch line.Break {
+			case BreakHyphen:
+				para.WriteString(strings.TrimRight(text, hyphens))
+			case BreakParagraph:
+				para.WriteString(text)
+				flush()
+			case BreakNatural:
+				para.WriteString(text + " ")
+			default:
+				// Without layout, keep the lines as they are
+				para.WriteString(text + "\n")
+			}
+		}
+	}
+	flush()
+	return strings.TrimRight(sb.String(), "\n") + "\n"
+}
+
+// Bookmark is an entry of a PDF's outline.
+type Bookmark struct {
+	Title string `json:"title"`
+	// Page is the number of the page the bookmark points at.
+	Page int `json:"page"`
+	// Level is 0 for top-level bookmarks, 1 for their children and so on.
+	Level int `json:"level"`
+}
+
+// ReadBookmarks returns the outline of pdfPath in document order, or
+// none if it has no outline.
+func ReadBookmarks(pdfPath string) ([]Bookmark, error) {
+	return ReadBookmarksContext(context.Background(), pdfPath)
+}
+
+// ReadBookmarksContext is ReadBookmarks with cancellation.
+func ReadBookmarksContext(ctx context.Context, pdfPath string) ([]Bookmark, error) {
+	// Images aren't needed for the outline
+	layout, err := readLayout(ctx, pdfPath, "-i")
+	if err != nil {
+		return nil, err
+	}
+	var bookmarks []Bookmark
+	var walk func(n *outlineNode, level int)
+	walk = func(n *outlineNode, level int) {
+		for i := range n.Children {
+			child := &n.Children[i]
+			switch child.XMLName.Local {
+			case "item":
+				title := strings.Join(strings.Fields(child.Text), " ")
+				if title != "" && child.Page > 0 {
+					bookmarks = append(bookmarks, Bookmark{Title: title, Page: child.Page, Level: level})
+				}
+			case "outline":
+				walk(child, level+1)
+			}
+		}
+	}
+	if layout.Outline != nil {
+		walk(layout.Outline, 0)
+	}
+	return bookmarks, nil
+}
+
+// Chapters splits the result at its headings: short lines much taller
+// than the document's typical line, such as chapter titles set in large
+// type. Consecutive heading lines form one title. Results without line
+// boxes, as in text-only mode, come back as a single chapter.
+func (r *Result) Chapters() []Chapter {
+	var heights []int
+	for _, page := range r.Pages {
+		for _, line := range page.Lines {
+			if !line.BBox.Empty() && line.Running == "" {
+				heights = append(heights, line.BBox.Dy())
+			}
+		}
+	}
+	isHeading := func(line Line) bool { return false }
+	if len(heights) > 0 {
+		sort.Ints(heights)
+		typical := float64(heights[len(heights)/2])
+		isHeading = func(line Line) bool {
+			text := strings.TrimSpace(line.Text)
+			return line.Running == "" && text != "" &&
+				float64(line.BBox.Dy()) >= headingScale*typical &&
+				utf8.RuneCountInString(text) <= maxHeadingRunes
+		}
+	}
+
+	var chapters []Chapter
+	var title []string
+	var titlePage int
+	for _, page := range r.Pages {
+		start := 0
+		for i, line := range page.Lines {
+			if !isHeading(line) {
+				if title != nil {
+					chapters = append(chapters, Chapter{Title: strings.Join(title, " "), Page: titlePage})
+					title = nil
+					start = i
+				}
+				continue
+			}
+			if title == nil {
+				appendLines(&chapters, page, start, i)
+				titlePage = page.Number
+			}
+			title = append(title, strings.TrimSpace(line.Text))
+			start = i + 1
+		}
+		if title == nil {
+			appendLines(&chapters, page, start, len(page.Lines))
+		}
+	}
+	if title != nil {
+		// A heading on the last lines of the document starts an empty chapter
+		chapters = append(chapters, Chapter{Title: strings.Join(title, " "), Page: titlePage})
+	}
+	return chapters
+}
+
+// ChaptersAt splits the result into chapters starting at the pages of
+// bookmarks, such as the top-level entries of ReadBookmarks. Pages before
+// the first bookmark form an untitled chapter.
+func (r *Result) ChaptersAt(bookmarks []Bookmark) []Chapter {
+	marks := append([]Bookmark(nil), bookmarks...)
+	sort.SliceStable(marks, func(i, j int) bool { return marks[i].Page < marks[j].Page })
+
+	var chapters []Chapter
+	next := 0
+	for _, page := range r.Pages {
+		for next < len(marks) && marks[next].Page <= page.Number {
+			chapters = append(chapters, Chapter{Title: marks[next].Title, Page: marks[next].Page})
+			next++
+		}
+		appendLines(&chapters, page, 0, len(page.Lines))
+	}
+	return chapters
+}
+
+// appendLines adds lines [from, to) of page to the last chapter, starting
+// an untitled one if there is none yet.
+func appendLines(chapters *[]Chapter, page Page, from, to int) {
+	if from >= to {
+		return
+	}
+	if len(*chapters) == 0 {
+		*chapters = append(*chapters, Chapter{Page: page.Number})
+	}
+	part := page
+	part.Lines = page.Lines[from:to:to]
+	c := &(*chapters)[len(*chapters)-1]
+	c.Pages = append(c.Pages, part)
+}
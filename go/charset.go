@@ -0,0 +1,55 @@
+package monocr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/model"
+)
+
+// resolveModel returns the model file and charset to load for modelPath,
+// which may be a model card naming both. An empty charset comes from
+// WithCharsetFile, then the card next to the model, then the bundled
+// charset, so retrained models are loaded the same way as the default.
+func (o *options) resolveModel(modelPath, charset string) (string, string, error) {
+	if strings.EqualFold(filepath.Ext(modelPath), ".json") {
+		card, err := model.LoadCard(modelPath)
+		if err != nil {
+			return "", "", err
+		}
+		if card.Model == "" {
+			return "", "", fmt.Errorf("model card %s names no model", modelPath)
+		}
+		modelPath = card.Model
+		if charset == "" && o.charsetFile == "" {
+			if charset, err = card.LoadCharset(); err != nil {
+				return "", "", err
+			}
+		}
+	}
+	if charset != "" {
+		return modelPath, charset, nil
+	}
+
+	if o.charsetFile != "" {
+		charset, err := model.LoadCharset(o.charsetFile)
+		return modelPath, charset, err
+	}
+	if path := model.CardPath(modelPath); fileExists(path) {
+		card, err := model.LoadCard(path)
+		if err != nil {
+			return "", "", err
+		}
+		if charset, err = card.LoadCharset(); err != nil || charset != "" {
+			return modelPath, charset, err
+		}
+	}
+	return modelPath, strings.TrimSpace(embeddedCharset), nil
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
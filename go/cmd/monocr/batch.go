@@ -0,0 +1,441 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/dedup"
+	"github.com/spf13/cobra"
+)
+
+func newBatchCmd() *cobra.Command {
+	var dedupMode string
+	var dedupReport string
+	var manifestPath string
+	var format string
+	var output, outputDir string
+	var workers int
+	var jobManifestPath string
+	var statePath string
+	var allowPartial bool
+
+	cmd := &cobra.Command{
+		Use:   "batch [directory]",
+		Short: "Process all images in a directory",
+		Long: `Recognizes every image in a directory, or the images and PDFs listed in a
+--manifest. Manifest entries may carry arbitrary metadata, which is echoed
+verbatim next to each result with --format json:
+
+  [{"path": "box-12/0001.png", "metadata": {"collection": "MS-44", "box": 12}}]`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if format != "text" && format != "json" {
+				fmt.Fprintf(os.Stderr, "Error: unknown format %q: must be text or json\n", format)
+				os.Exit(1)
+			}
+			if output != "" && outputDir != "" {
+				fmt.Fprintln(os.Stderr, "Error: use either --output or --output-dir")
+				os.Exit(1)
+			}
+			var manifest *jobManifest
+			if jobManifestPath != "" {
+				manifest = newJobManifest(cmd.Flags())
+				// Deferred first so it runs after the output file is closed
+				defer func() {
+					if err := manifest.write(jobManifestPath, output); err != nil {
+						fmt.Fprintf(os.Stderr, "Failed to write job manifest: %v\n", err)
+					}
+				}()
+			}
+			defer openOutput(output)()
+
+			var inputs []monocr.BatchInput
+			var err error
+			switch {
+			case manifestPath != "" && len(args) == 0:
+				inputs, err = monocr.LoadBatchManifest(manifestPath)
+			case manifestPath == "" && len(args) == 1:
+				inputs, err = listImages(args[0])
+			default:
+				err = fmt.Errorf("give either a directory or --manifest")
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			paths := make([]string, len(inputs))
+			metadata := make(map[string]json.RawMessage, len(inputs))
+			for i, in := range inputs {
+				paths[i] = in.Path
+				metadata[in.Path] = in.Metadata
+			}
+
+			var state *batchState
+			if statePath != "" {
+				state, err = openState(statePath)
+				if err == nil {
+					defer state.Close()
+					var todo []string
+					todo, err = state.pending(paths)
+					if skipped := len(paths) - len(todo); err == nil && skipped > 0 {
+						fmt.Fprintf(os.Stderr, "Skipping %d files already processed with this model\n", skipped)
+					}
+					paths = todo
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: state file: %v\n", err)
+					os.Exit(1)
+				}
+			}
+
+			groups, err := groupInputs(paths, dedupMode)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			bar := newProgress("files", len(groups))
+			outcomes, release := runBatch(groups, workers, format == "json", bar)
+
+			results := []batchResult{}
+			for i, group := range groups {
+				name := filepath.Base(group.Path)
+				out := outcomes[i]
+				<-out.done
+				// Release the result once written, and let the workers
+				// start another file
+				outcomes[i] = nil
+				release()
+				if allowPartial && errors.Is(out.err, monocr.ErrPartial) {
+					// The failure is kept in the result's warnings
+					bar.Printf("Kept partial result for %s: %v\n", name, out.err)
+					out.err = nil
+				}
+				manifest.addGroup(group, out.elapsed, out.err)
+				// recordGroup marks the group processed once its output is written
+				recordGroup := func() {
+					for _, path := range append([]string{group.Path}, group.Duplicates...) {
+						if err := state.record(path); err != nil {
+							fmt.Fprintf(os.Stderr, "Error: state file: %v\n", err)
+							os.Exit(1)
+						}
+					}
+				}
+				writeFile := func(path, name string, write func(w io.Writer) error) {
+					writeOutputFile(outputDir, name, write)
+					manifest.addOutput(path, filepath.Join(outputDir, name))
+				}
+
+				if format == "json" {
+					result, err := out.result, out.err
+					if err != nil {
+						bar.Printf("Failed to process %s: %v\n", name, err)
+					}
+					for _, path := range append([]string{group.Path}, group.Duplicates...) {
+						entry := batchResult{Path: path, Metadata: metadata[path], Result: result}
+						if path != group.Path {
+							entry.DuplicateOf = group.Path
+						}
+						if err != nil {
+							entry.Error = err.Error()
+						}
+						if outputDir != "" {
+							writeFile(path, outputName(path, 0, ".json"), func(w io.Writer) error {
+								return encodeJSON(w, entry)
+							})
+							continue
+						}
+						results = append(results, entry)
+					}
+					if err == nil {
+						recordGroup()
+					}
+					continue
+				}
+
+				text, err := out.text, out.err
+				if err != nil {
+					bar.Printf("Failed to process %s: %v\n", name, err)
+					continue
+				}
+				if outputDir != "" {
+					for _, path := range append([]string{group.Path}, group.Duplicates...) {
+						writeFile(path, outputName(path, 0, ".txt"), func(w io.Writer) error {
+							_, err := fmt.Fprintln(w, text)
+							return err
+						})
+					}
+					recordGroup()
+					continue
+				}
+				bar.Above(func() {
+					fmt.Fprintf(stdout, "--- %s ---\n%s\n\n", name, text)
+					for _, dup := range group.Duplicates {
+						fmt.Fprintf(stdout, "--- %s (duplicate of %s) ---\n%s\n\n", filepath.Base(dup), name, text)
+					}
+				})
+				recordGroup()
+			}
+			bar.Finish()
+
+			if format == "json" && outputDir == "" {
+				writeJSON(results)
+			}
+			if dedupMode != "" {
+				writeDedupReport(groups, len(paths), dedupReport)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&statePath, "state", "", "Skip inputs this state file records as processed with the same model, and record new ones")
+	cmd.Flags().StringVar(&jobManifestPath, "job-manifest", "", "Write a JSON record of the run (inputs, outputs and model with SHA-256, options, durations) to this file")
+	cmd.Flags().StringVar(&dedupMode, "dedup", "", "Process duplicate images once: exact (identical bytes) or perceptual (visually identical, confirmed pixel by pixel)")
+	cmd.Flags().StringVar(&dedupReport, "dedup-report", "", "Write the duplicate groups as JSON to this file")
+	cmd.Flags().StringVar(&manifestPath, "manifest", "", "JSON list of inputs with optional per-file metadata, instead of a directory")
+	cmd.Flags().IntVar(&workers, "workers", 1, "Files processed in parallel, each worker with its own model session")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write the results to this file instead of standard output")
+	cmd.Flags().StringVar(&outputDir, "output-dir", "", "Write one NAME.txt (or NAME.json) per input into this directory")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text, or json for structured results with manifest metadata")
+	cmd.Flags().BoolVar(&allowPartial, "allow-partial", false, "Count a PDF that failed part way as processed, writing the pages completed before the failure")
+	addReadFlags(cmd)
+	addRenderFlags(cmd)
+	addProgressFlag(cmd)
+	addServerFlag(cmd)
+	addMarkFlags(cmd)
+	return cmd
+}
+
+// batchResult is one input of a batch run in --format json output.
+type batchResult struct {
+	Path        string          `json:"path"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+	DuplicateOf string          `json:"duplicate_of,omitempty"`
+	Result      *monocr.Result  `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// batchOutcome is the recognition output for one group of a batch. done
+// is closed once it is filled in.
+type batchOutcome struct {
+	text    string
+	result  *monocr.Result
+	err     error
+	elapsed time.Duration
+	done    chan struct{}
+}
+
+// batchReader recognizes batch inputs, with a local model or a server.
+type batchReader interface {
+	ReadText(path string) (string, error)
+	ReadResult(path string) (*monocr.Result, error)
+}
+
+// localReader reads batch inputs with a model session of its own.
+type localReader struct {
+	*monocr.Reader
+}
+
+func (r localReader) ReadText(path string) (string, error) {
+	if markingUncertain() {
+		result, err := readResult(r.Reader, path)
+		if err != nil {
+			return "", err
+		}
+		return resultText(result), nil
+	}
+	return readText(r.Reader, path)
+}
+
+func (r localReader) ReadResult(path string) (*monocr.Result, error) {
+	return readResult(r.Reader, path)
+}
+
+// runBatch recognizes groups in the background with a pool of workers,
+// each owning a Reader and so its own model session, which bounds memory
+// to one model and one input per worker. With --server the workers
+// instead submit inputs to the server concurrently. Outcomes are returned in input
+// order, so callers can write each as soon as it is done, and must call
+// release once they are done with each: workers run at most workers
+// outcomes ahead of the caller, so a slow file doesn't let finished
+// results behind it pile up in memory. bar, if not nil, counts completed
+// files and replaces the per-file messages.
+func runBatch(groups []dedup.Group, workers int, structured bool, bar *progressBar) (outcomes []*batchOutcome, release func()) {
+	if len(groups) == 0 {
+		return nil, func() {}
+	}
+	if workers > len(groups) {
+		workers = len(groups)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Without a thread count, sessions would each start a thread per core
+	opts := readOptions()
+	if serverURL == "" {
+		declareGPUSessions(workers)
+	}
+	if workers > 1 && intraThreads == 0 && !tuneThreads {
+		opts = append(opts, monocr.WithThreads(max(1, runtime.NumCPU()/workers), 0))
+	}
+
+	readers := make([]batchReader, workers)
+	for i := range readers {
+		if serverURL != "" {
+			client, err := newRemoteClient(serverURL)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			readers[i] = client
+			continue
+		}
+		reader, err := monocr.NewReader(opts...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		readers[i] = localReader{reader}
+	}
+
+	outcomes = make([]*batchOutcome, len(groups))
+	for i := range outcomes {
+		outcomes[i] = &batchOutcome{done: make(chan struct{})}
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for _, reader := range readers {
+		wg.Add(1)
+		go func(reader batchReader) {
+			defer wg.Done()
+			for i := range next {
+				out, path := outcomes[i], groups[i].Path
+				if bar == nil {
+					fmt.Fprintf(os.Stderr, "Processing %s...\n", filepath.Base(path))
+				}
+				start := time.Now()
+				if structured {
+					out.result, out.err = reader.ReadResult(path)
+				} else {
+					out.text, out.err = reader.ReadText(path)
+				}
+				out.elapsed = time.Since(start)
+				close(out.done)
+				bar.Add()
+			}
+		}(reader)
+	}
+
+	slots := make(chan struct{}, workers)
+	go func() {
+		for i := range groups {
+			slots <- struct{}{}
+			next <- i
+		}
+		close(next)
+		wg.Wait()
+		for _, reader := range readers {
+			if local, ok := reader.(localReader); ok {
+				local.Close()
+			}
+		}
+	}()
+	return outcomes, func() { <-slots }
+}
+
+// listImages returns the images directly inside dir as batch inputs.
+func listImages(dir string) ([]monocr.BatchInput, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %v", err)
+	}
+
+	var inputs []monocr.BatchInput
+	for _, file := range files {
+		ext := filepath.Ext(file.Name())
+		if ext == ".jpg" || ext == ".png" || ext == ".jpeg" {
+			inputs = append(inputs, monocr.BatchInput{Path: filepath.Join(dir, file.Name())})
+		}
+	}
+	return inputs, nil
+}
+
+func isPDF(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".pdf")
+}
+
+// readText recognizes an image or a PDF, whose pages are joined by blank
+// lines.
+func readText(reader *monocr.Reader, path string) (string, error) {
+	if !isPDF(path) {
+		return reader.ReadImage(path)
+	}
+	// A failed PDF may still carry its first pages, for --allow-partial
+	pages, err := reader.ReadPDF(path)
+	return strings.Join(pages, "\n\n"), err
+}
+
+// readResult recognizes an image or a PDF into a structured result.
+func readResult(reader *monocr.Reader, path string) (*monocr.Result, error) {
+	if isPDF(path) {
+		return reader.ReadPDFResult(path)
+	}
+	return reader.ReadImageResult(path)
+}
+
+// groupInputs collapses duplicate inputs according to mode. With no mode
+// every path is its own group.
+func groupInputs(paths []string, mode string) ([]dedup.Group, error) {
+	switch mode {
+	case "":
+		groups := make([]dedup.Group, len(paths))
+		for i, path := range paths {
+			groups[i] = dedup.Group{Path: path}
+		}
+		return groups, nil
+	case "exact":
+		return dedup.Find(paths, dedup.Exact)
+	case "perceptual":
+		return dedup.Find(paths, dedup.Perceptual)
+	}
+	return nil, fmt.Errorf("unknown dedup mode %q: use exact or perceptual", mode)
+}
+
+func writeDedupReport(groups []dedup.Group, total int, reportPath string) {
+	suspected := 0
+	for _, g := range groups {
+		suspected += len(g.Suspected)
+	}
+	fmt.Fprintf(os.Stderr, "Dedup: %d files, %d unique, %d duplicates, %d suspected\n", total, len(groups), total-len(groups), suspected)
+
+	if reportPath == "" {
+		return
+	}
+
+	// Suspected files were processed on their own; they are listed for
+	// review only
+	var dups []dedup.Group
+	for _, g := range groups {
+		if len(g.Duplicates) > 0 || len(g.Suspected) > 0 {
+			dups = append(dups, g)
+		}
+	}
+	data, err := json.MarshalIndent(dups, "", "  ")
+	if err == nil {
+		err = os.WriteFile(reportPath, data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write dedup report: %v\n", err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/bench"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/model"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+	"github.com/spf13/cobra"
+)
+
+func newBenchCmd() *cobra.Command {
+	var duration time.Duration
+	var asJSON, failSlow bool
+
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Time recognition on fixed inputs",
+		Long: `Recognizes a fixed line and a fixed page of 30 lines repeatedly and reports
+how many per second this host reads, with median and 95th percentile
+latencies. When measured reference numbers exist for the host's class of
+hardware (its cores, the execution provider asked for and the model
+variant), the rates are compared with them and flagged when they fall
+below half, which points at a misconfigured install: a GPU build running
+on the CPU, a container limited to fewer cores than it reports, or
+competing processes. With --fail-slow it then exits with status 1.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			reader, err := monocr.NewReader(readOptions()...)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer reader.Close()
+
+			host := bench.LocalHost(benchProvider(), os.Getenv(model.VariantEnv))
+			report, err := bench.Run(reader, host, bench.Workloads(), duration)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			ref := bench.Classify(host)
+			var comparisons []bench.Comparison
+			if ref != nil {
+				comparisons = report.Compare(ref)
+			}
+			if asJSON {
+				writeJSON(struct {
+					*bench.Report
+					Class       string             `json:"class,omitempty"`
+					Comparisons []bench.Comparison `json:"comparisons,omitempty"`
+				}{report, benchClass(ref), comparisons})
+			} else {
+				printBench(report, ref, comparisons)
+			}
+			if failSlow {
+				for _, c := range comparisons {
+					if c.Slow {
+						os.Exit(1)
+					}
+				}
+			}
+		},
+	}
+	addReadFlags(cmd)
+	cmd.Flags().DurationVar(&duration, "duration", bench.DefaultDuration, "How long to time each workload")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the results as JSON")
+	cmd.Flags().BoolVar(&failSlow, "fail-slow", false, "Exit with status 1 if a rate is below half its reference")
+	return cmd
+}
+
+// benchProvider returns the execution provider the flags ask for.
+func benchProvider() string {
+	switch {
+	case useTensorRT:
+		return predictor.ProviderTensorRT
+	case useGPU:
+		return predictor.ProviderCUDA
+	case useCoreML:
+		return predictor.ProviderCoreML
+	}
+	return predictor.ProviderCPU
+}
+
+func benchClass(ref *bench.Reference) string {
+	if ref == nil {
+		return ""
+	}
+	return ref.Class
+}
+
+func printBench(report *bench.Report, ref *bench.Reference, comparisons []bench.Comparison) {
+	h := report.Host
+	fmt.Printf("Host:  %s/%s, %d CPUs, %s", h.OS, h.Arch, h.CPUs, h.Provider)
+	if h.Variant != "" {
+		fmt.Printf(", %s model", h.Variant)
+	}
+	fmt.Println()
+	if ref != nil {
+		fmt.Printf("Class: %s\n", ref.Class)
+	} else {
+		fmt.Println("Class: no measured reference matches this host, so there is nothing to compare with")
+	}
+
+	expected := map[string]bench.Comparison{}
+	for _, c := range comparisons {
+		expected[c.Workload] = c
+	}
+	slow := false
+	for _, r := range report.Results {
+		fmt.Printf("%-5s %8.2f/s  p50 %-9v p95 %-9v", r.Workload, r.Rate, r.P50.Round(time.Microsecond*100), r.P95.Round(time.Microsecond*100))
+		if c, ok := expected[r.Workload]; ok {
+			fmt.Printf(" expected %.4g/s (%.0f%%)", c.Expected, c.Ratio*100)
+			if c.Slow {
+				fmt.Print(" SLOW")
+				slow = true
+			}
+		}
+		fmt.Println()
+	}
+	if slow {
+		fmt.Println("\nThis host is far slower than expected for its class. Check \"monocr doctor\"\nfor the execution providers in use, the cores the process may use and\n--threads, and whether other processes compete for the CPU or GPU.")
+	}
+}
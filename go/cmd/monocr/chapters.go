@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+)
+
+// Flags splitting pdf output into chapters.
+var (
+	splitChapters string
+	chapterFormat string
+)
+
+// maxSlugRunes bounds the part of a chapter file name taken from its title.
+const maxSlugRunes = 40
+
+// writeChapters writes one file per chapter of result into dir. Chapters
+// follow the PDF's top-level bookmarks when it has any, and its headings
+// otherwise.
+func writeChapters(dir, pdfPath string, result *monocr.Result) error {
+	var chapters []monocr.Chapter
+	bookmarks, err := monocr.ReadBookmarks(pdfPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: bookmarks not read, splitting by headings: %v\n", err)
+	}
+	var top []monocr.Bookmark
+	for _, b := range bookmarks {
+		if b.Level == 0 {
+			top = append(top, b)
+		}
+	}
+	if len(top) > 0 {
+		chapters = result.ChaptersAt(top)
+	} else {
+		chapters = result.Chapters()
+	}
+
+	width := len(fmt.Sprint(len(chapters)))
+	for i, c := range chapters {
+		name := fmt.Sprintf("%0*d-%s.%s", width, i+1, chapterSlug(c.Title), chapterFormat)
+		writeOutputFile(dir, name, func(w io.Writer) error {
+			text := c.Markdown()
+			if chapterFormat == "txt" {
+				text = c.Text() + "\n"
+				if c.Title != "" {
+					text = c.Title + "\n\n" + text
+				}
+			}
+			_, err := io.WriteString(w, text)
+			return err
+		})
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d chapters to %s\n", len(chapters), dir)
+	return nil
+}
+
+// chapterSlug turns a chapter title into a file name part, keeping
+// letters, digits and the combining marks Mon is written with.
+func chapterSlug(title string) string {
+	if title == "" {
+		return "front-matter"
+	}
+	var sb strings.Builder
+	n, dash := 0, false
+	for _, r := range title {
+		if n == maxSlugRunes {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+				n++
+			}
+			sb.WriteRune(unicode.ToLower(r))
+			n++
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	if sb.Len() == 0 {
+		return "chapter"
+	}
+	return sb.String()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+// daemonRequest is one line sent to the daemon socket.
+type daemonRequest struct {
+	// Op is "image" or "pdf".
+	Op   string `json:"op"`
+	Path string `json:"path"`
+}
+
+// daemonResponse is the daemon's reply line.
+type daemonResponse struct {
+	Pages []string `json:"pages,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+var daemonSocket string
+
+// defaultSocket returns the per-user socket path.
+func defaultSocket() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, fmt.Sprintf("monocr-%d.sock", os.Getuid()))
+}
+
+func newDaemonCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Keep the model loaded and serve requests over a Unix socket",
+		Long: `Loads the model once and answers recognition requests on a Unix domain
+socket, so scripts calling "monocr image --use-daemon" skip the model load.
+Recognition options (rotation, interpolation, ...) are taken from the
+daemon's own flags.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			reader, err := monocr.NewReader(readOptions()...)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer reader.Close()
+			if err := reader.Warmup(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to warm up the model: %v\n", err)
+				os.Exit(1)
+			}
+
+			// A socket left behind by a killed daemon would block Listen
+			if conn, err := net.Dial("unix", daemonSocket); err == nil {
+				conn.Close()
+				fmt.Fprintf(os.Stderr, "Error: a daemon is already listening on %s\n", daemonSocket)
+				os.Exit(1)
+			}
+			os.Remove(daemonSocket)
+
+			ln, err := net.Listen("unix", daemonSocket)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			os.Chmod(daemonSocket, 0o600)
+
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+			go func() {
+				<-sigs
+				ln.Close()
+			}()
+
+			fmt.Fprintf(os.Stderr, "Listening on %s\n", daemonSocket)
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					if errors.Is(err, net.ErrClosed) {
+						return
+					}
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					continue
+				}
+				go serveDaemonConn(reader, conn)
+			}
+		},
+	}
+
+	addReadFlags(cmd)
+	addRenderFlags(cmd)
+	return cmd
+}
+
+// serveDaemonConn answers newline-delimited JSON requests until the client
+// disconnects.
+func serveDaemonConn(reader *monocr.Reader, conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	enc := json.NewEncoder(conn)
+	enc.SetEscapeHTML(false)
+
+	for scanner.Scan() {
+		var req daemonRequest
+		var resp daemonResponse
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = fmt.Sprintf("invalid request: %v", err)
+		} else {
+			switch req.Op {
+			case "image":
+				text, err := reader.ReadImage(req.Path)
+				if err != nil {
+					resp.Error = err.Error()
+				} else {
+					resp.Pages = []string{text}
+				}
+			case "pdf":
+				pages, err := reader.ReadPDF(req.Path)
+				if err != nil {
+					resp.Error = err.Error()
+				} else {
+					resp.Pages = pages
+				}
+			default:
+				resp.Error = fmt.Sprintf("unknown op %q", req.Op)
+			}
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// callDaemon sends one request to the daemon and returns its pages.
+func callDaemon(op, path string) ([]string, error) {
+	// The daemon may run in another working directory
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("unix", daemonSocket)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach daemon (start it with \"monocr daemon\"): %v", err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(daemonRequest{Op: op, Path: abs}); err != nil {
+		return nil, err
+	}
+
+	var resp daemonResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read daemon response: %v", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Pages, nil
+}
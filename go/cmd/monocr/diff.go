@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+// fileDiff compares one input's text between two runs.
+type fileDiff struct {
+	name       string
+	cerA, cerB float64
+	a, b, want string
+}
+
+func (d fileDiff) delta() float64 { return d.cerB - d.cerA }
+
+func newDiffCmd() *cobra.Command {
+	var truthDir string
+	var examples int
+
+	cmd := &cobra.Command{
+		Use:   "diff [runA] [runB]",
+		Short: "Compare two OCR result directories",
+		Long: `Compares the outputs of two runs (e.g. old model vs new model) file by
+file. Each directory holds one result per input, as NAME.txt or NAME.json
+(from --format json). With --truth, character error rates are measured
+against NAME.txt or NAME.gt.txt ground truth files and regressions are
+listed; without it, the CER of runB relative to runA shows where the runs
+disagree.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			runA, err := loadRun(args[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			runB, err := loadRun(args[1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			var truth map[string]string
+			if truthDir != "" {
+				truth, err = loadRun(truthDir)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+
+			var diffs []fileDiff
+			for name, a := range runA {
+				b, ok := runB[name]
+				if !ok {
+					fmt.Fprintf(os.Stderr, "Skipping %s: missing from %s\n", name, args[1])
+					continue
+				}
+				d := fileDiff{name: name, a: a, b: b}
+				if truth != nil {
+					want, ok := truth[name]
+					if !ok {
+						fmt.Fprintf(os.Stderr, "Skipping %s: no ground truth\n", name)
+						continue
+					}
+					d.want = want
+					d.cerA = monocr.CharErrorRate(a, want)
+					d.cerB = monocr.CharErrorRate(b, want)
+				} else {
+					d.cerB = monocr.CharErrorRate(b, a)
+				}
+				diffs = append(diffs, d)
+			}
+			sort.Slice(diffs, func(i, j int) bool { return diffs[i].name < diffs[j].name })
+
+			if truth == nil {
+				fmt.Printf("%-40s %8s\n", "FILE", "CER(B|A)")
+				var sum float64
+				for _, d := range diffs {
+					fmt.Printf("%-40s %8.4f\n", d.name, d.cerB)
+					sum += d.cerB
+				}
+				if len(diffs) > 0 {
+					fmt.Printf("\n%d files, mean disagreement %.4f\n", len(diffs), sum/float64(len(diffs)))
+				}
+				return
+			}
+
+			fmt.Printf("%-40s %8s %8s %8s\n", "FILE", "CER A", "CER B", "DELTA")
+			var sumA, sumB float64
+			var regressions []fileDiff
+			for _, d := range diffs {
+				fmt.Printf("%-40s %8.4f %8.4f %+8.4f\n", d.name, d.cerA, d.cerB, d.delta())
+				sumA += d.cerA
+				sumB += d.cerB
+				if d.delta() > 0 {
+					regressions = append(regressions, d)
+				}
+			}
+			if len(diffs) == 0 {
+				return
+			}
+			n := float64(len(diffs))
+			fmt.Printf("\n%d files, mean CER %.4f -> %.4f (%+.4f), %d regressed\n",
+				len(diffs), sumA/n, sumB/n, (sumB-sumA)/n, len(regressions))
+
+			sort.Slice(regressions, func(i, j int) bool { return regressions[i].delta() > regressions[j].delta() })
+			if len(regressions) > examples {
+				regressions = regressions[:examples]
+			}
+			for _, d := range regressions {
+				fmt.Printf("\n--- %s (%+.4f) ---\n", d.name, d.delta())
+				printRegression(d)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&truthDir, "truth", "", "Directory of ground truth texts (NAME.txt or NAME.gt.txt)")
+	cmd.Flags().IntVar(&examples, "examples", 5, "Number of worst regressions to show")
+	return cmd
+}
+
+// printRegression shows the first line that got worse.
+func printRegression(d fileDiff) {
+	linesA := strings.Split(d.a, "\n")
+	linesB := strings.Split(d.b, "\n")
+	want := strings.Split(d.want, "\n")
+	for i := range want {
+		var a, b string
+		if i < len(linesA) {
+			a = linesA[i]
+		}
+		if i < len(linesB) {
+			b = linesB[i]
+		}
+		if monocr.CharErrorRate(b, want[i]) > monocr.CharErrorRate(a, want[i]) {
+			fmt.Printf("line %d\n  truth: %s\n  A:     %s\n  B:     %s\n", i+1, want[i], a, b)
+			return
+		}
+	}
+	fmt.Println("(line breaks differ; compare the files directly)")
+}
+
+// loadRun reads the texts of a result directory, keyed by input name.
+func loadRun(dir string) (map[string]string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make(map[string]string)
+	for _, file := range files {
+		name := file.Name()
+		path := filepath.Join(dir, name)
+		switch {
+		case strings.HasSuffix(name, ".gt.txt"):
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			texts[strings.TrimSuffix(name, ".gt.txt")] = strings.TrimSpace(string(data))
+		case strings.HasSuffix(name, ".txt"):
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			texts[strings.TrimSuffix(name, ".txt")] = strings.TrimSpace(string(data))
+		case strings.HasSuffix(name, ".json"):
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			var result monocr.Result
+			if err := json.Unmarshal(data, &result); err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			texts[strings.TrimSuffix(name, ".json")] = strings.Join(result.Texts(), "\n")
+		}
+	}
+	return texts, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+func newDoctorCmd() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check ONNX Runtime, poppler and the model cache on this host",
+		Long: `Reports the ONNX Runtime version and execution providers, whether
+pdftoppm was found, the CPU's SIMD extensions and the model cache. It exits
+with status 1 if ONNX Runtime can't be loaded.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			c := monocr.Capabilities()
+			if asJSON {
+				writeJSON(c)
+			} else {
+				printCapabilities(c)
+			}
+			if c.Runtime == nil {
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the report as JSON")
+	return cmd
+}
+
+func printCapabilities(c *monocr.CapabilityReport) {
+	fmt.Printf("Platform:     %s/%s, %d CPUs\n", c.OS, c.Arch, c.CPUs)
+	fmt.Printf("SIMD:         %s\n", orNone(strings.Join(c.CPUFeatures, " ")))
+	fmt.Printf("Preprocess:   %s kernels\n", c.Preprocessing)
+	if c.Runtime != nil {
+		fmt.Printf("ONNX Runtime: %s\n", c.Runtime.Version)
+		fmt.Printf("Providers:    %s\n", strings.Join(c.Runtime.Providers, ", "))
+	} else {
+		fmt.Printf("ONNX Runtime: not available: %s\n", c.RuntimeError)
+	}
+	if c.Poppler != "" {
+		fmt.Printf("Poppler:      %s\n", c.Poppler)
+	} else {
+		fmt.Printf("Poppler:      not available: %s\n", c.PopplerError)
+	}
+
+	cache := c.Cache
+	if cache.Error != "" {
+		fmt.Printf("Model cache:  %s\n", cache.Error)
+		return
+	}
+	state := "not downloaded"
+	if cache.Present {
+		state = formatSize(cache.Size)
+	}
+	if cache.Offline {
+		state += ", offline"
+	}
+	fmt.Printf("Model:        %s (%s)\n", cache.Model, state)
+	fmt.Printf("Model cache:  %s, %d models\n", cache.Dir, cache.Models)
+}
+
+// orNone returns s, or "none" if it is empty.
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+func newSamplesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "samples",
+		Short: "Manage the evaluation samples",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "download",
+		Short: "Download the public Mon evaluation set to the cache",
+		Long: `Downloads a small public set of Mon line images with their ground truth
+into the model cache, for "monocr eval --samples". Set ` + model.SamplesURLEnv + `
+to download from a mirror.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			manager, err := model.NewManager()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if _, err := manager.DownloadSamples(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	})
+	return cmd
+}
+
+// errorTally sums an error rate over images, for the mean and for the
+// overall rate, which weighs images by their length.
+type errorTally struct {
+	sum, edits    float64
+	images, units int
+}
+
+// add counts an image's rate over a truth of n units and returns the
+// rate.
+func (t *errorTally) add(rate float64, n int) float64 {
+	t.sum += rate
+	t.edits += rate * float64(n)
+	t.images++
+	t.units += n
+	return rate
+}
+
+func (t *errorTally) print(name string) {
+	fmt.Printf("%s: mean %.4f", name, t.sum/float64(t.images))
+	if t.units > 0 {
+		fmt.Printf(", overall %.4f", t.edits/float64(t.units))
+	}
+	fmt.Println()
+}
+
+func newEvalCmd() *cobra.Command {
+	var samples bool
+
+	cmd := &cobra.Command{
+		Use:   "eval [directory]",
+		Short: "Measure accuracy against images with ground truth",
+		Long: `Recognizes every image in a directory that has a NAME.gt.txt ground truth
+next to it and reports the character error rate (CER), the grapheme
+cluster error rate (GER, which counts a stacked consonant with its marks
+as one character, as readers do) and the word error rate (WER) per image
+and overall.
+With --samples it runs on the evaluation set from "monocr samples
+download", downloading it first if needed, to check an installation.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if samples == (len(args) == 1) {
+				fmt.Fprintln(os.Stderr, "Error: give a directory or --samples")
+				os.Exit(1)
+			}
+			var dir string
+			if samples {
+				manager, err := model.NewManager()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				dir = manager.SamplesDir()
+				if _, err := os.Stat(dir); err != nil {
+					if dir, err = manager.DownloadSamples(); err != nil {
+						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+						os.Exit(1)
+					}
+				}
+			} else {
+				dir = args[0]
+			}
+
+			cases, err := loadEvalSet(dir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if len(cases) == 0 {
+				fmt.Fprintf(os.Stderr, "Error: no images with ground truth in %s\n", dir)
+				os.Exit(1)
+			}
+
+			reader, err := monocr.NewReader(readOptions()...)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer reader.Close()
+
+			fmt.Printf("%-40s %8s %8s %8s\n", "FILE", "CER", "GER", "WER")
+			var cer, ger, wer errorTally
+			for _, c := range cases {
+				text, err := reader.ReadImage(c.path)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to process %s: %v\n", c.path, err)
+					os.Exit(1)
+				}
+				text = strings.TrimSpace(text)
+				fmt.Printf("%-40s %8.4f %8.4f %8.4f\n", filepath.Base(c.path),
+					cer.add(monocr.CharErrorRate(text, c.truth), len([]rune(c.truth))),
+					ger.add(monocr.GraphemeErrorRate(text, c.truth), monocr.GraphemeCount(c.truth)),
+					wer.add(monocr.WordErrorRate(text, c.truth), monocr.WordCount(c.truth)))
+			}
+
+			fmt.Printf("\n%d images\n", len(cases))
+			cer.print("CER")
+			ger.print("GER")
+			wer.print("WER")
+		},
+	}
+
+	cmd.Flags().BoolVar(&samples, "samples", false, "Evaluate on the downloaded evaluation samples")
+	addReadFlags(cmd)
+	return cmd
+}
+
+// evalCase is an image and its expected text.
+type evalCase struct {
+	path, truth string
+}
+
+// loadEvalSet finds the images in dir with a NAME.gt.txt ground truth.
+func loadEvalSet(dir string) ([]evalCase, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []evalCase
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !isImage(name) {
+			continue
+		}
+		truthPath := filepath.Join(dir, strings.TrimSuffix(name, filepath.Ext(name))+".gt.txt")
+		data, err := os.ReadFile(truthPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, evalCase{path: filepath.Join(dir, name), truth: strings.TrimSpace(string(data))})
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].path < cases[j].path })
+	return cases, nil
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/model"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+	"github.com/spf13/cobra"
+)
+
+// Flags shared by the recognition commands.
+var (
+	rotate        int
+	autoRotate    bool
+	extraChars    string
+	charsetFile   string
+	preprocess    []string
+	keepRendered  string
+	interpolation string
+	renderTimeout time.Duration
+	renderCPU     uint64
+	renderMemory  uint64
+	retryFloor    float64
+	minConfidence float64
+	placeholder   string
+	renderDPI     int
+	adaptiveDPI   float64
+	mmapModel     bool
+	widthBucket   int
+	batchSize     int
+	lmPath        string
+	lmWeight      float64
+	tuneThreads   bool
+	intraThreads  int
+	interThreads  int
+	useGPU        bool
+	gpuDevice     int
+	useCoreML     bool
+	useTensorRT   bool
+	gpuPreprocess bool
+	trtCache      string
+	pureGo        bool
+	runningLines  string
+	pageTypes     bool
+	pageWorkers   int
+	textLayer     bool
+)
+
+// Bounds of --dpi: below 72 text is unreadable and above 1200 pages take
+// gigabytes to rasterize.
+const (
+	minDPI = 72
+	maxDPI = 1200
+)
+
+// declareGPUSessions splits the GPU memory budget between the n model
+// sessions a command loads, when they run on the GPU.
+func declareGPUSessions(n int) {
+	if useGPU || useTensorRT {
+		if err := predictor.SetGPUSessions(n); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// readOptions collects the library options selected by shared flags.
+func readOptions() []monocr.Option {
+	opts := []monocr.Option{monocr.WithRetryFloor(retryFloor)}
+	if minConfidence > 0 {
+		opts = append(opts, monocr.WithMinConfidence(minConfidence, placeholder))
+	}
+	if autoRotate {
+		opts = append(opts, monocr.WithAutoRotate())
+	} else if rotate != 0 {
+		opts = append(opts, monocr.WithRotation(rotate))
+	}
+	if charsetFile != "" {
+		opts = append(opts, monocr.WithCharsetFile(charsetFile))
+	}
+	if extraChars != "" {
+		opts = append(opts, monocr.WithExtraChars(extraChars))
+	}
+	if len(preprocess) > 0 {
+		var preprocessors []monocr.Preprocessor
+		for _, name := range preprocess {
+			p, err := lookupPreprocessor(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			preprocessors = append(preprocessors, p)
+		}
+		opts = append(opts, monocr.WithPreprocessors(preprocessors...))
+	}
+	if keepRendered != "" {
+		opts = append(opts, monocr.WithRenderCache(keepRendered))
+	}
+	if renderTimeout > 0 || renderCPU > 0 || renderMemory > 0 {
+		opts = append(opts, monocr.WithRenderLimits(monocr.RenderLimits{
+			Timeout:     renderTimeout,
+			CPUSeconds:  renderCPU,
+			MemoryBytes: renderMemory << 20,
+		}))
+	}
+	if renderDPI != 0 {
+		if renderDPI < minDPI || renderDPI > maxDPI {
+			fmt.Fprintf(os.Stderr, "Error: --dpi %d out of range: use %d to %d\n", renderDPI, minDPI, maxDPI)
+			os.Exit(1)
+		}
+		opts = append(opts, monocr.WithDPI(renderDPI))
+	}
+	if adaptiveDPI > 0 {
+		opts = append(opts, monocr.WithAdaptiveDPI(adaptiveDPI))
+	}
+	switch runningLines {
+	case "":
+	case "tag":
+		opts = append(opts, monocr.WithRunningLines(monocr.TagRunningLines))
+	case "strip":
+		opts = append(opts, monocr.WithRunningLines(monocr.StripRunningLines))
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown --running-lines %q: use tag or strip\n", runningLines)
+		os.Exit(1)
+	}
+	if pageWorkers > 1 {
+		opts = append(opts, monocr.WithPageWorkers(pageWorkers))
+	}
+	if textLayer {
+		opts = append(opts, monocr.WithTextLayer())
+	}
+	if pageTypes {
+		opts = append(opts, monocr.WithPageTypes())
+	}
+	if mmapModel {
+		opts = append(opts, monocr.WithMemoryMap())
+	}
+	if pureGo {
+		opts = append(opts, monocr.WithBackend(predictor.PureGo))
+	}
+	if useTensorRT {
+		opts = append(opts, monocr.WithTensorRT(gpuDevice, trtCache))
+	} else if useGPU {
+		opts = append(opts, monocr.WithCUDA(gpuDevice))
+	} else if useCoreML {
+		opts = append(opts, monocr.WithCoreML())
+	}
+	if gpuPreprocess {
+		opts = append(opts, monocr.WithGPUPreprocessing())
+	}
+	if tuneThreads {
+		opts = append(opts, monocr.WithThreadTuning())
+	}
+	if intraThreads > 0 || interThreads > 0 {
+		opts = append(opts, monocr.WithThreads(intraThreads, interThreads))
+	}
+	if widthBucket > 0 {
+		opts = append(opts, monocr.WithWidthBucket(widthBucket))
+	}
+	if batchSize > 1 {
+		opts = append(opts, monocr.WithBatchSize(batchSize))
+	}
+	if lmPath != "" {
+		lm, err := predictor.LoadNGram(lmPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, monocr.WithLanguageModel(lm, lmWeight))
+	}
+	if interpolation != "" {
+		interp, err := predictor.ParseInterpolation(interpolation)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, monocr.WithInterpolation(interp))
+	}
+	return opts
+}
+
+// addReadFlags registers the flags shared by every recognition command.
+func addReadFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&rotate, "rotate", 0, "Rotate input clockwise by 90, 180 or 270 degrees before recognition")
+	cmd.Flags().BoolVar(&autoRotate, "autorotate", false, "Detect page orientation and rotate upright automatically")
+	cmd.Flags().StringVar(&charsetFile, "charset", "", "Charset of a retrained model: a text file of its output classes, or its model card (.json)")
+	cmd.Flags().StringVar(&extraChars, "extra-chars", "", "Characters for the extra output classes of a fine-tuned model, appended to the charset")
+	cmd.Flags().StringArrayVar(&preprocess, "preprocess", nil, "Run this preprocessor plugin on every page before segmentation (repeatable, in order; see monocr plugins)")
+	cmd.Flags().StringVar(&interpolation, "interpolation", "", "Resampling filter for line images: catmullrom (default), bilinear or nearest")
+	cmd.Flags().StringVar(&lmPath, "lm", "", "Rescore decoding with this character or syllable n-gram model (ARPA format)")
+	cmd.Flags().Float64Var(&lmWeight, "lm-weight", predictor.DefaultLMWeight, "Weight of the --lm language model against the image evidence")
+	cmd.Flags().Float64Var(&retryFloor, "retry-floor", -1, "Retry empty lines and lines below this confidence, e.g. 0.5, with alternate preprocessing (off when negative)")
+	cmd.Flags().Float64Var(&minConfidence, "min-confidence", 0, "Drop lines and characters read below this confidence (e.g. 0.5)")
+	cmd.Flags().StringVar(&placeholder, "placeholder", "", "With --min-confidence, replace what is dropped with this text instead, e.g. '?'")
+	cmd.Flags().BoolVar(&mmapModel, "mmap", false, "Memory-map the model file to lower peak memory at startup (not for models with a float16 output)")
+	cmd.Flags().BoolVar(&pureGo, "pure-go", false, "Run recognition with the built-in Go runtime instead of ONNX Runtime: several times slower, but needs no native library (the default in builds without cgo)")
+	cmd.Flags().BoolVar(&useGPU, "gpu", false, "Run recognition on a CUDA GPU, falling back to the CPU if none is usable")
+	cmd.Flags().IntVar(&gpuDevice, "gpu-device", 0, "CUDA device ID for --gpu and --tensorrt")
+	cmd.Flags().BoolVar(&useTensorRT, "tensorrt", false, "Run recognition with TensorRT on the GPU, falling back to the CPU if unavailable")
+	cmd.Flags().StringVar(&trtCache, "tensorrt-cache", "", "Directory for built TensorRT engines, reused by later runs (default: tensorrt in the model cache)")
+	cmd.Flags().BoolVar(&gpuPreprocess, "gpu-preprocess", false, "With --gpu or --tensorrt, also scale line images on the GPU instead of the CPU")
+	cmd.Flags().BoolVar(&useCoreML, "coreml", false, "Run recognition through CoreML on macOS (Neural Engine or GPU), falling back to the CPU")
+	cmd.Flags().BoolVar(&tuneThreads, "tune-threads", false, "Benchmark inference thread counts on first use (about 2s) and cache the fastest for this host")
+	cmd.Flags().IntVar(&intraThreads, "threads", 0, "ONNX Runtime threads per operator (default one per core; batch --workers divides the cores)")
+	cmd.Flags().IntVar(&interThreads, "inter-threads", 0, "ONNX Runtime threads for running independent operators in parallel (default: runtime's choice)")
+	cmd.Flags().IntVar(&batchSize, "batch-size", 1, "Recognize up to this many lines in one inference run, e.g. 16; may change decodes slightly (1 reads lines one at a time)")
+	cmd.Flags().IntVar(&widthBucket, "width-bucket", 0, "Pad line widths to a multiple of this, e.g. 32, to reuse inference buffers; may change decodes slightly (0 disables)")
+}
+
+// addRenderFlags registers the flags controlling PDF rasterization.
+func addRenderFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&keepRendered, "keep-rendered", "", "Keep rendered page images in this directory and reuse them on later runs")
+	cmd.Flags().DurationVar(&renderTimeout, "render-timeout", 0, "Kill the PDF renderer if it runs longer than this (e.g. 5m)")
+	cmd.Flags().Uint64Var(&renderCPU, "render-cpu", 0, "CPU time limit for the PDF renderer in seconds (Linux)")
+	cmd.Flags().IntVar(&renderDPI, "dpi", 0, "Resolution to render PDF pages at (default 300; 400-600 helps poor scans, 150 is a fast preview)")
+	cmd.Flags().Float64Var(&adaptiveDPI, "adaptive-dpi", 0, "Render pages at 150 DPI and re-render those below this confidence at --dpi (e.g. 0.8)")
+	cmd.Flags().StringVar(&runningLines, "running-lines", "", "Detect headers, footers and page numbers repeated across pages and tag or strip them")
+	cmd.Flags().IntVar(&pageWorkers, "page-workers", 1, "Render and recognize this many PDF pages at once")
+	cmd.Flags().BoolVar(&textLayer, "text-layer", false, "Use the PDF's own text where pages have it and recognize only the others (needs pdftohtml)")
+	cmd.Flags().BoolVar(&pageTypes, "page-types", false, "Classify PDF pages as scanned, vector or mixed and extract vector text instead of recognizing it (needs pdftohtml)")
+	cmd.Flags().Uint64Var(&renderMemory, "render-memory", 0, "Memory limit for the PDF renderer in MB (Linux)")
+}
+
+// Where the model is downloaded from and cached, overriding the
+// environment.
+var modelURL, cacheDir string
+var modelMirrors []string
+var modelSources string
+var modelVersion, modelVariant string
+var offline bool
+
+// addModelSourceFlags registers --model-url, --model-mirror,
+// --model-sources, --cache-dir, --model-version, --model-variant and
+// --offline on every command.
+func addModelSourceFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&modelURL, "model-url", "", "URL to download the model from, e.g. an internal mirror (env "+model.URLEnv+")")
+	cmd.PersistentFlags().StringSliceVar(&modelMirrors, "model-mirror", nil, "Fallback URL to download the model from when --model-url fails (repeatable, env "+model.MirrorsEnv+")")
+	cmd.PersistentFlags().StringVar(&modelSources, "model-sources", "", "JSON file listing the model sources to try in order, with tokens, timeouts and health checks (env "+model.SourcesEnv+")")
+	cmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "Directory the model is cached in (default ~/.monocr/models, env "+model.CacheDirEnv+")")
+	cmd.PersistentFlags().StringVar(&modelVersion, "model-version", "", "Pin the model to a published version, e.g. v1.2 (env "+model.VersionEnv+")")
+	cmd.PersistentFlags().StringVar(&modelVariant, "model-variant", "", "Use a model variant: int8 for the quantized model that is smaller and faster on CPUs, fp16 for GPUs (env "+model.VariantEnv+")")
+	cmd.PersistentFlags().BoolVar(&offline, "offline", false, "Never download the model; fail if it isn't cached (env "+model.OfflineEnv+")")
+}
+
+// applyModelSource passes the model source flags on through the
+// environment, so every model.Manager the command creates
+// honours them.
+func applyModelSource() error {
+	if modelURL != "" {
+		if err := os.Setenv(model.URLEnv, modelURL); err != nil {
+			return err
+		}
+	}
+	if len(modelMirrors) > 0 {
+		if err := os.Setenv(model.MirrorsEnv, strings.Join(modelMirrors, ",")); err != nil {
+			return err
+		}
+	}
+	if modelSources != "" {
+		if err := os.Setenv(model.SourcesEnv, modelSources); err != nil {
+			return err
+		}
+	}
+	if cacheDir != "" {
+		if err := os.Setenv(model.CacheDirEnv, cacheDir); err != nil {
+			return err
+		}
+	}
+	if modelVersion != "" {
+		if err := os.Setenv(model.VersionEnv, modelVersion); err != nil {
+			return err
+		}
+	}
+	if modelVariant != "" {
+		if err := os.Setenv(model.VariantEnv, modelVariant); err != nil {
+			return err
+		}
+	}
+	if offline {
+		if err := os.Setenv(model.OfflineEnv, "1"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
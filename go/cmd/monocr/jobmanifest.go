@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/dedup"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/model"
+	"github.com/spf13/pflag"
+)
+
+// jobManifest records what a batch run read and wrote, with checksums,
+// so the run can be audited and reproduced.
+type jobManifest struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	// Model identifies the local model by content; Server is set instead
+	// when recognition ran on a monocr server.
+	Model   *manifestFile     `json:"model,omitempty"`
+	Server  string            `json:"server,omitempty"`
+	Options map[string]string `json:"options"`
+	// Output is the -o file, if results went to one.
+	Output *manifestFile   `json:"output,omitempty"`
+	Inputs []manifestInput `json:"inputs"`
+}
+
+// manifestFile is a file with its SHA-256.
+type manifestFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Error explains a missing checksum.
+	Error string `json:"error,omitempty"`
+}
+
+// manifestInput is one batch input and the files written for it.
+type manifestInput struct {
+	manifestFile
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// DurationMS is the recognition time; duplicates share their
+	// original's.
+	DurationMS int64          `json:"duration_ms"`
+	Failed     string         `json:"failed,omitempty"`
+	Outputs    []manifestFile `json:"outputs,omitempty"`
+}
+
+// newJobManifest starts a manifest for a run with the flags set on the
+// command line.
+func newJobManifest(flags *pflag.FlagSet) *jobManifest {
+	m := &jobManifest{Started: time.Now().UTC(), Options: make(map[string]string)}
+	flags.Visit(func(f *pflag.Flag) {
+		m.Options[f.Name] = f.Value.String()
+	})
+	return m
+}
+
+// addGroup records the inputs of a batch group and how reading them went.
+// A nil manifest records nothing.
+func (m *jobManifest) addGroup(group dedup.Group, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	for _, path := range append([]string{group.Path}, group.Duplicates...) {
+		in := manifestInput{manifestFile: manifestFile{Path: path}, DurationMS: elapsed.Milliseconds()}
+		if path != group.Path {
+			in.DuplicateOf = group.Path
+		}
+		if err != nil {
+			in.Failed = err.Error()
+		}
+		m.Inputs = append(m.Inputs, in)
+	}
+}
+
+// addOutput records a file written for input path.
+func (m *jobManifest) addOutput(path, file string) {
+	if m == nil {
+		return
+	}
+	for i := len(m.Inputs) - 1; i >= 0; i-- {
+		if m.Inputs[i].Path == path {
+			m.Inputs[i].Outputs = append(m.Inputs[i].Outputs, manifestFile{Path: file})
+			return
+		}
+	}
+}
+
+// write finishes the manifest, hashing the model, inputs and outputs,
+// and saves it to path.
+func (m *jobManifest) write(path, output string) error {
+	m.Finished = time.Now().UTC()
+	if serverURL != "" {
+		m.Server = serverURL
+	} else if manager, err := model.NewManager(); err == nil {
+		f := hashFile(manager.ModelPath())
+		m.Model = &f
+	}
+	if output != "" {
+		f := hashFile(output)
+		m.Output = &f
+	}
+	for i := range m.Inputs {
+		in := &m.Inputs[i]
+		in.manifestFile = hashFile(in.Path)
+		for j := range in.Outputs {
+			in.Outputs[j] = hashFile(in.Outputs[j].Path)
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encodeJSON(f, m); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// hashFile returns path with its SHA-256, or the error that kept it from
+// being read.
+func hashFile(path string) manifestFile {
+	mf := manifestFile{Path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		mf.Error = err.Error()
+		return mf
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		mf.Error = fmt.Sprintf("failed to hash: %v", err)
+		return mf
+	}
+	mf.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return mf
+}
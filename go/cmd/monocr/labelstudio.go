@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+func newLabelStudioCmd() *cobra.Command {
+	var urlPrefix string
+
+	cmd := &cobra.Command{
+		Use:   "labelstudio [files...]",
+		Short: "Export OCR output as Label Studio pre-annotated tasks",
+		Long: `Recognizes images and PDFs and prints Label Studio tasks (JSON) with line
+boxes and predicted text, ready to import for human correction.
+
+PDF pages are annotated on the rendered page images, so PDFs need
+--keep-rendered DIR, which must also be reachable by Label Studio.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Boxes must line up with the image files Label Studio shows
+			if rotate != 0 || autoRotate {
+				fmt.Fprintln(os.Stderr, "Error: rotation is not supported when exporting annotations")
+				os.Exit(1)
+			}
+
+			reader, err := monocr.NewReader(readOptions()...)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer reader.Close()
+
+			imageURL := func(page monocr.Page) string {
+				return urlPrefix + filepath.ToSlash(page.Image)
+			}
+
+			tasks := []monocr.LabelStudioTask{}
+			for _, path := range args {
+				var result *monocr.Result
+				if strings.EqualFold(filepath.Ext(path), ".pdf") {
+					if keepRendered == "" {
+						fmt.Fprintf(os.Stderr, "Error: %s: PDFs need --keep-rendered DIR so the page images outlive the export\n", path)
+						os.Exit(1)
+					}
+					result, err = reader.ReadPDFResult(path)
+				} else {
+					result, err = reader.ReadImageResult(path)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to process %s: %v\n", path, err)
+					continue
+				}
+				tasks = append(tasks, result.LabelStudioTasks(imageURL)...)
+			}
+			writeJSON(tasks)
+		},
+	}
+
+	cmd.Flags().StringVar(&urlPrefix, "url-prefix", "/data/local-files/?d=", "Prefix turning image paths into URLs Label Studio can load")
+	addReadFlags(cmd)
+	addRenderFlags(cmd)
+	return cmd
+}
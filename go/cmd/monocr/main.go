@@ -2,8 +2,10 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/MonDevHub/monocr-onnx/go"
 	"github.com/MonDevHub/monocr-onnx/go/pkg/model"
@@ -15,86 +17,337 @@ func main() {
 		Use:   "monocr",
 		Short: "Mon language OCR",
 		Long:  `MonOCR is a tool for recognizing Mon language text from images and PDFs using ONNX Runtime.`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return applyModelSource()
+		},
 	}
 
+	var bandHeight int
+	var useDaemon bool
+	var output, outputDir string
+
 	var imageCmd = &cobra.Command{
 		Use:   "image [path]",
 		Short: "Recognize text from an image file",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			text, err := monocr.ReadImage(args[0])
+			if proofPath != "" {
+				if useDaemon || serverURL != "" {
+					fmt.Fprintln(os.Stderr, "Error: --proof is not supported with --use-daemon or --server")
+					os.Exit(1)
+				}
+				writeProof(args[0])
+				return
+			}
+			defer openOutput(output)()
+
+			var text string
+			var err error
+			if useDaemon {
+				var pages []string
+				pages, err = callDaemon("image", args[0])
+				if err == nil && len(pages) > 0 {
+					text = pages[0]
+				}
+			} else if serverURL != "" {
+				var client *remoteClient
+				if client, err = newRemoteClient(serverURL); err == nil {
+					text, err = client.ReadText(args[0])
+				}
+			} else if markingUncertain() {
+				var result *monocr.Result
+				if result, err = monocr.ReadImageResult(args[0], readOptions()...); err == nil {
+					text = resultText(result)
+				}
+			} else if bandHeight > 0 {
+				text, err = monocr.ReadLargeImage(args[0], bandHeight, readOptions()...)
+			} else {
+				text, err = monocr.ReadImage(args[0], readOptions()...)
+			}
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
-			fmt.Println(text)
+			fmt.Fprintln(stdout, escapeText(text))
 		},
 	}
 
+	imageCmd.Flags().BoolVar(&useDaemon, "use-daemon", false, "Send the request to a running \"monocr daemon\" instead of loading the model")
+	imageCmd.Flags().StringVarP(&output, "output", "o", "", "Write the result to this file instead of standard output")
+	imageCmd.Flags().IntVar(&bandHeight, "band-height", 0, "Segment very large images in horizontal bands of this many pixels to bound memory use")
+
+	var metadata bool
+	var format, searchIndex, searchable string
+	var extracts []string
+
 	var pdfCmd = &cobra.Command{
 		Use:   "pdf [path]",
 		Short: "Recognize text from a PDF file",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			pages, err := monocr.ReadPDF(args[0])
+			if output != "" && outputDir != "" {
+				fmt.Fprintln(os.Stderr, "Error: use either --output or --output-dir")
+				os.Exit(1)
+			}
+			defer openOutput(output)()
+
+			// readPDF reads a structured result locally with opts, or on
+			// the server with its own settings
+			readPDF := func(opts ...monocr.Option) (*monocr.Result, error) {
+				return monocr.ReadPDFResult(args[0], opts...)
+			}
+			var progress []monocr.Option
+			if serverURL != "" {
+				if metadata || searchable != "" || proofPath != "" {
+					fmt.Fprintln(os.Stderr, "Error: --metadata, --searchable and --proof are not supported with --server")
+					os.Exit(1)
+				}
+				client, err := newRemoteClient(serverURL)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				readPDF = func(...monocr.Option) (*monocr.Result, error) {
+					return client.ReadResult(args[0])
+				}
+			} else {
+				progress = pdfProgress()
+			}
+
+			if metadata {
+				meta, err := monocr.ExtractMetadata(args[0], readOptions()...)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				writeJSON(meta)
+				return
+			}
+			if searchable != "" {
+				if err := monocr.SearchablePDF(args[0], searchable, readOptions()...); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				return
+			}
+			if proofPath != "" {
+				writeProof(args[0])
+				return
+			}
+
+			opts := append(readOptions(), progress...)
+			if splitChapters != "" {
+				if chapterFormat != "txt" && chapterFormat != "md" {
+					fmt.Fprintf(os.Stderr, "Error: unknown chapter format %q: must be txt or md\n", chapterFormat)
+					os.Exit(1)
+				}
+				result, err := readPDF(opts...)
+				if err == nil {
+					err = writeChapters(splitChapters, args[0], result)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				return
+			}
+			switch format {
+			case "text":
+				// Page files and running line tags need page numbers and
+				// lines, so read a structured result
+				if outputDir == "" && runningLines != "tag" && !markingUncertain() {
+					break
+				}
+				// Marking needs character confidences
+				if !markingUncertain() {
+					opts = append(opts, monocr.WithTextOnly())
+				}
+				result, err := readPDF(opts...)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				for _, page := range result.Pages {
+					if outputDir != "" {
+						writeOutputFile(outputDir, outputName(args[0], page.Number, ".txt"), func(w io.Writer) error {
+							return writePageText(w, page)
+						})
+						continue
+					}
+					fmt.Fprintf(stdout, "--- Page %d ---\n", page.Number)
+					writePageText(stdout, page)
+					fmt.Fprintln(stdout)
+				}
+				return
+			case "json":
+				if extractors := parseExtractors(extracts); len(extractors) > 0 {
+					opts = append(opts, monocr.WithExtractors(extractors...))
+				}
+				result, err := readPDF(opts...)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				if e := outputEscaper(); e != nil {
+					result.Escape(e)
+				}
+				if outputDir == "" {
+					writeJSON(result)
+					return
+				}
+				for _, page := range result.Pages {
+					writeOutputFile(outputDir, outputName(args[0], page.Number, ".json"), func(w io.Writer) error {
+						return encodeJSON(w, page)
+					})
+				}
+				return
+			case "bulk":
+				result, err := readPDF(opts...)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				docID := strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+				write := func(w io.Writer) error {
+					return result.WriteBulk(w, searchIndex, docID)
+				}
+				if outputDir != "" {
+					writeOutputFile(outputDir, outputName(args[0], 0, ".ndjson"), write)
+				} else if err := write(stdout); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				return
+			case "alto":
+				result, err := readPDF(opts...)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				write := func(w io.Writer) error {
+					return monocr.EncodeALTO(w, result, args[0])
+				}
+				if outputDir != "" {
+					writeOutputFile(outputDir, outputName(args[0], 0, ".xml"), write)
+				} else if err := write(stdout); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				return
+			default:
+				writer := lookupWriter(format)
+				if writer == nil {
+					fmt.Fprintf(os.Stderr, "Error: unknown format %q: must be text, json, bulk, alto or a format plugin (monocr plugins)\n", format)
+					os.Exit(1)
+				}
+				result, err := readPDF(opts...)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				write := func(w io.Writer) error {
+					return writer(w, result, args[0])
+				}
+				if outputDir != "" {
+					writeOutputFile(outputDir, outputName(args[0], 0, "."+format), write)
+				} else if err := write(stdout); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				return
+			}
+
+			var pages []string
+			var err error
+			if serverURL != "" {
+				var result *monocr.Result
+				if result, err = readPDF(); err == nil {
+					pages = result.Texts()
+				}
+			} else {
+				pages, err = monocr.ReadPDF(args[0], opts...)
+			}
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
 			for i, page := range pages {
-				fmt.Printf("--- Page %d ---\n", i+1)
-				fmt.Println(page)
-				fmt.Println()
+				fmt.Fprintf(stdout, "--- Page %d ---\n", i+1)
+				fmt.Fprintln(stdout, escapeText(page))
+				fmt.Fprintln(stdout)
 			}
 		},
 	}
 
-	var downloadCmd = &cobra.Command{
-		Use:   "download",
-		Short: "Download model to local cache",
+	pdfCmd.Flags().BoolVar(&metadata, "metadata", false, "Print title, author and year guessed from PDF metadata and the first pages as JSON")
+	pdfCmd.Flags().StringVar(&format, "format", "text", "Output format: text, json for lines with confidence and boxes, bulk for an Elasticsearch bulk file (NDJSON), alto for ALTO 4 XML, or one from a plugin")
+	pdfCmd.Flags().StringArrayVar(&extracts, "extract", nil, "Report matches of kind=regexp in --format json output (repeatable), e.g. date='[0-9]{4}-[0-9]{2}-[0-9]{2}'")
+	pdfCmd.Flags().StringVar(&searchable, "searchable", "", "Write the pages with an invisible text layer to this searchable PDF instead of printing text")
+	pdfCmd.Flags().StringVarP(&output, "output", "o", "", "Write the result to this file instead of standard output")
+	pdfCmd.Flags().StringVar(&outputDir, "output-dir", "", "Write one file per page (text and json) or per document (bulk and alto) into this directory")
+	pdfCmd.Flags().StringVar(&searchIndex, "index", "monocr", "Index name used in --format bulk actions")
+	pdfCmd.Flags().StringVar(&splitChapters, "split-chapters", "", "Write one file per chapter into this directory, split at the PDF's bookmarks or else its headings")
+	pdfCmd.Flags().StringVar(&chapterFormat, "chapter-format", "txt", "Chapter file format with --split-chapters: txt or md")
+	addRenderFlags(pdfCmd)
+	addProgressFlag(pdfCmd)
+	addServerFlag(pdfCmd)
+	addServerFlag(imageCmd)
+	addMarkFlags(imageCmd)
+	addMarkFlags(pdfCmd)
+	addEscapeFlags(imageCmd)
+	addEscapeFlags(pdfCmd)
+	addProofFlag(imageCmd)
+	addProofFlag(pdfCmd)
+
+	var regionsCmd = &cobra.Command{
+		Use:   "regions [image] [manifest]",
+		Short: "Recognize regions of interest listed in a JSON manifest",
+		Args:  cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
-			manager, err := model.NewManager()
+			regions, err := monocr.LoadRegions(args[1])
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
-			if err := manager.DownloadModel(); err != nil {
+			results, err := monocr.ReadRegions(args[0], regions, readOptions()...)
+			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
+			for _, r := range results {
+				fmt.Printf("--- %s ---\n", r.Name)
+				fmt.Println(r.Text())
+				fmt.Println()
+			}
 		},
 	}
-	
-	var batchCmd = &cobra.Command{
-		Use: "batch [directory]",
-		Short: "Process all images in a directory",
-		Args: cobra.ExactArgs(1),
+
+	var downloadCmd = &cobra.Command{
+		Use:   "download",
+		Short: "Download model to local cache",
 		Run: func(cmd *cobra.Command, args []string) {
-			dir := args[0]
-			files, err := os.ReadDir(dir)
+			manager, err := model.NewManager()
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error reading directory: %v\n", err)
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
-			
-			for _, file := range files {
-				ext := filepath.Ext(file.Name())
-				if ext == ".jpg" || ext == ".png" || ext == ".jpeg" {
-					path := filepath.Join(dir, file.Name())
-					fmt.Fprintf(os.Stderr, "Processing %s...\n", file.Name())
-					text, err := monocr.ReadImage(path)
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "Failed to process %s: %v\n", file.Name(), err)
-					} else {
-						fmt.Printf("--- %s ---\n%s\n\n", file.Name(), text)
-					}
-				}
+			if err := manager.DownloadModel(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
 			}
 		},
 	}
 
-	rootCmd.AddCommand(imageCmd, pdfCmd, downloadCmd, batchCmd)
+	for _, cmd := range []*cobra.Command{imageCmd, pdfCmd, regionsCmd} {
+		addReadFlags(cmd)
+	}
+
+	rootCmd.AddCommand(imageCmd, pdfCmd, downloadCmd, newBatchCmd(), regionsCmd, newQualityCmd(), newRedactCmd(), newLabelStudioCmd(), newPageXMLCmd(), newTrainsetCmd(), newDaemonCmd(), newServeCmd(), newWatchCmd(), newSequenceCmd(), newDiffCmd(), newSamplesCmd(), newEvalCmd(), newModelCmd(), newDoctorCmd(), newBenchCmd(), newScanCmd(), newPluginsCmd())
+
+	addModelSourceFlags(rootCmd)
+	rootCmd.PersistentFlags().StringVar(&daemonSocket, "socket", defaultSocket(), "Unix socket used by the daemon and --use-daemon")
 
+	runPluginCommand(rootCmd, os.Args[1:])
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
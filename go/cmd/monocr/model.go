@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/model"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+	"github.com/spf13/cobra"
+)
+
+func newModelCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "model",
+		Short: "Inspect and manage cached models",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List cached models with their size and checksum",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			manager := newManager()
+			models, err := manager.Cached()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if len(models) == 0 {
+				fmt.Fprintf(os.Stderr, "No models cached in %s\n", manager.CacheDir)
+				return
+			}
+			current := manager.ModelPath()
+			fmt.Printf("  %-10s %-8s %10s  %-64s  %s\n", "VERSION", "VARIANT", "SIZE", "SHA256", "PATH")
+			for _, m := range models {
+				mark := " "
+				if m.Path == current {
+					mark = "*"
+				}
+				version := m.Version
+				if version == "" {
+					version = "latest"
+				}
+				variant := m.Variant
+				if variant == "" {
+					variant = "fp32"
+				}
+				fmt.Printf("%s %-10s %-8s %10s  %-64s  %s\n", mark, version, variant, formatSize(m.Size), hashFile(m.Path).SHA256, m.Path)
+			}
+		},
+	}
+
+	var asJSON bool
+	info := &cobra.Command{
+		Use:   "info [model.onnx]",
+		Short: "Print a model's metadata, inputs and outputs",
+		Long: `Prints the metadata, inputs and outputs of the given model file, or of
+the model in use (see --model-version and --model-variant) if none is
+given.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var path string
+			if len(args) == 1 {
+				path = args[0]
+			} else {
+				path = newManager().ModelPath()
+			}
+			meta, err := predictor.Inspect(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if asJSON {
+				writeJSON(meta)
+				return
+			}
+
+			fmt.Printf("Path:        %s\n", path)
+			if f, err := os.Stat(path); err == nil {
+				fmt.Printf("Size:        %s\n", formatSize(f.Size()))
+			}
+			fmt.Printf("SHA256:      %s\n", hashFile(path).SHA256)
+			for _, field := range [][2]string{
+				{"Producer", meta.Producer}, {"Graph", meta.Graph}, {"Domain", meta.Domain}, {"Description", meta.Description},
+			} {
+				if field[1] != "" {
+					fmt.Printf("%-12s %s\n", field[0]+":", field[1])
+				}
+			}
+			fmt.Printf("Version:     %d\n", meta.Version)
+			for _, key := range slices.Sorted(maps.Keys(meta.Custom)) {
+				fmt.Printf("  %s: %s\n", key, meta.Custom[key])
+			}
+			for _, t := range meta.Inputs {
+				fmt.Printf("Input:       %s %s %v\n", t.Name, t.Type, t.Shape)
+			}
+			for _, t := range meta.Outputs {
+				fmt.Printf("Output:      %s %s %v\n", t.Name, t.Type, t.Shape)
+			}
+		},
+	}
+	info.Flags().BoolVar(&asJSON, "json", false, "Print the metadata as JSON")
+
+	var dryRun bool
+	clean := &cobra.Command{
+		Use:   "clean",
+		Short: "Remove cached models other than the one in use",
+		Long: `Removes every cached model version except the one in use (the latest
+model, or the version pinned with --model-version), every other variant
+of it, and the leftovers of interrupted downloads.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			stale, err := newManager().Stale()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			for _, path := range stale {
+				if dryRun {
+					fmt.Printf("Would remove %s\n", path)
+					continue
+				}
+				if err := os.RemoveAll(path); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("Removed %s\n", path)
+			}
+			if len(stale) == 0 {
+				fmt.Println("Nothing to clean")
+			}
+		},
+	}
+	clean.Flags().BoolVar(&dryRun, "dry-run", false, "Only list what would be removed")
+
+	cmd.AddCommand(list, info, clean)
+	return cmd
+}
+
+// newManager returns the model manager, exiting on error.
+func newManager() *model.Manager {
+	manager, err := model.NewManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return manager
+}
+
+// formatSize formats a byte count for people.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
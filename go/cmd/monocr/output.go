@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+// stdout receives command results: standard output, or the file given
+// with -o.
+var stdout io.Writer = os.Stdout
+
+// openOutput sends results to path instead of standard output when path
+// is set. The returned function closes the file, exiting on failure.
+func openOutput(path string) func() {
+	if path == "" {
+		return func() {}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	stdout = f
+	return func() {
+		stdout = os.Stdout
+		if err := f.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// writeOutputFile writes one result file into dir through write, exiting
+// on failure.
+func writeOutputFile(dir, name string, write func(w io.Writer) error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	f, err := os.Create(filepath.Join(dir, name))
+	if err == nil {
+		err = write(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// outputName names the result file for input, or for one of its pages
+// when page is positive: book.pdf page 7 becomes book-007.txt.
+func outputName(input string, page int, ext string) string {
+	base := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	if page > 0 {
+		return fmt.Sprintf("%s-%03d%s", base, page, ext)
+	}
+	return base + ext
+}
+
+// encodeJSON writes v to w as indented JSON.
+func encodeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// writeJSON prints v to stdout as indented JSON, exiting on failure.
+func writeJSON(v any) {
+	if err := encodeJSON(stdout, v); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseExtractors turns "kind=regexp" flag values into extractors,
+// exiting on a malformed definition.
+func parseExtractors(defs []string) []monocr.Extractor {
+	var extractors []monocr.Extractor
+	for _, def := range defs {
+		kind, expr, ok := strings.Cut(def, "=")
+		if !ok || kind == "" {
+			fmt.Fprintf(os.Stderr, "Error: invalid extractor %q: expected kind=regexp\n", def)
+			os.Exit(1)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid extractor %q: %v\n", def, err)
+			os.Exit(1)
+		}
+		extractors = append(extractors, monocr.RegexExtractor(kind, re))
+	}
+	return extractors
+}
+
+// writePageText writes a page's lines, prefixing running headers and
+// footers tagged with --running-lines tag.
+func writePageText(w io.Writer, page monocr.Page) error {
+	for _, line := range page.Lines {
+		if line.Running != "" {
+			fmt.Fprintf(w, "[%s] ", line.Running)
+		}
+		if _, err := fmt.Fprintln(w, lineText(line)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flags marking uncertain words in text output.
+var (
+	uncertainBelow   float64
+	uncertainMarkers []string
+)
+
+// addMarkFlags registers --mark-uncertain and --markers.
+func addMarkFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64Var(&uncertainBelow, "mark-uncertain", 0, "Wrap words below this confidence in --markers in text output (e.g. 0.6)")
+	cmd.Flags().StringSliceVar(&uncertainMarkers, "markers", []string{"⟦", "⟧"}, "Opening and closing marker for --mark-uncertain")
+}
+
+// markingUncertain reports whether text output marks uncertain words,
+// which needs character confidences.
+func markingUncertain() bool {
+	if uncertainBelow <= 0 {
+		return false
+	}
+	if len(uncertainMarkers) != 2 {
+		fmt.Fprintln(os.Stderr, "Error: --markers takes an opening and a closing marker, e.g. --markers '[[,]]'")
+		os.Exit(1)
+	}
+	return true
+}
+
+// proofPath is where --proof writes an HTML proof sheet.
+var proofPath string
+
+// addProofFlag registers --proof.
+func addProofFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&proofPath, "proof", "", "Write an HTML proof sheet of each line's image, text and confidence to this file instead of printing text; words below --mark-uncertain (default 0.8) are highlighted")
+}
+
+// writeProof writes the --proof sheet for input and exits on failure.
+func writeProof(input string) {
+	threshold := monocr.DefaultProofThreshold
+	if uncertainBelow > 0 {
+		threshold = uncertainBelow
+	}
+	if err := monocr.ProofSheet(input, proofPath, threshold, readOptions()...); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Flags escaping output for sinks that can't store Myanmar script.
+var (
+	escapeMode       string
+	romanizationPath string
+	escaper          monocr.Escaper
+)
+
+// addEscapeFlags registers --escape and --romanization.
+func addEscapeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&escapeMode, "escape", "", "Rewrite output for sinks without Myanmar-script support: ncr (numeric character references) or romanize")
+	cmd.Flags().StringVar(&romanizationPath, "romanization", "", "Table for --escape romanize, one character and its spelling per line (default: built-in Mon romanization)")
+}
+
+// outputEscaper returns the escaper selected by --escape, or nil for raw
+// Unicode, exiting on a bad flag or table.
+func outputEscaper() monocr.Escaper {
+	if escaper != nil || escapeMode == "" {
+		return escaper
+	}
+	switch escapeMode {
+	case "ncr":
+		escaper = monocr.EscapeNCR
+	case "romanize":
+		escaper = monocr.MonRomanization
+		if romanizationPath != "" {
+			table, err := monocr.LoadRomanization(romanizationPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			escaper = table
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown --escape %q: use ncr or romanize\n", escapeMode)
+		os.Exit(1)
+	}
+	return escaper
+}
+
+// escapeText applies --escape to text output.
+func escapeText(text string) string {
+	if e := outputEscaper(); e != nil {
+		return e.Escape(text)
+	}
+	return text
+}
+
+// lineText returns the line's text for text output.
+func lineText(line monocr.Line) string {
+	if markingUncertain() {
+		return escapeText(line.MarkUncertain(uncertainBelow, uncertainMarkers[0], uncertainMarkers[1]))
+	}
+	return escapeText(line.Text)
+}
+
+// resultText returns the text of every page, separated by blank lines.
+func resultText(result *monocr.Result) string {
+	pages := make([]string, len(result.Pages))
+	for i, page := range result.Pages {
+		if markingUncertain() {
+			pages[i] = page.MarkUncertain(uncertainBelow, uncertainMarkers[0], uncertainMarkers[1])
+		} else {
+			pages[i] = page.Text()
+		}
+	}
+	return strings.Join(pages, "\n\n")
+}
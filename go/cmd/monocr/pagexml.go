@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+func newPageXMLCmd() *cobra.Command {
+	var outDir string
+
+	cmd := &cobra.Command{
+		Use:   "pagexml [files...]",
+		Short: "Export OCR output as PAGE XML for correction tools",
+		Long: `Recognizes images and PDFs and writes one PRImA PAGE XML file per page, with
+regions, lines and words, for Transkribus or Aletheia.
+
+Each NAME.xml is written to a page/ directory next to its image, as those
+tools expect, or to -o DIR. PDF pages refer to the rendered page images,
+so PDFs need --keep-rendered DIR.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Coordinates must line up with the image files the tool shows
+			if rotate != 0 || autoRotate {
+				fmt.Fprintln(os.Stderr, "Error: rotation is not supported when exporting annotations")
+				os.Exit(1)
+			}
+
+			reader, err := monocr.NewReader(readOptions()...)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer reader.Close()
+
+			failed := false
+			for _, path := range args {
+				var result *monocr.Result
+				if strings.EqualFold(filepath.Ext(path), ".pdf") {
+					if keepRendered == "" {
+						fmt.Fprintf(os.Stderr, "Error: %s: PDFs need --keep-rendered DIR so the page images outlive the export\n", path)
+						os.Exit(1)
+					}
+					result, err = reader.ReadPDFResult(path)
+				} else {
+					result, err = reader.ReadImageResult(path)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to process %s: %v\n", path, err)
+					failed = true
+					continue
+				}
+
+				for _, page := range result.Pages {
+					if err := writePageXML(page, outDir); err != nil {
+						fmt.Fprintf(os.Stderr, "Failed to write %s page %d: %v\n", path, page.Number, err)
+						failed = true
+					}
+				}
+			}
+			if failed {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&outDir, "output", "o", "", "Directory for the XML files (default: page/ next to each image)")
+	addReadFlags(cmd)
+	addRenderFlags(cmd)
+	return cmd
+}
+
+// writePageXML stores page as NAME.xml, named after its image.
+func writePageXML(page monocr.Page, outDir string) error {
+	dir := outDir
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(page.Image), "page")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(page.Image), filepath.Ext(page.Image)) + ".xml"
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	if err := monocr.EncodePAGE(f, page); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+// Executables named with these prefixes on the PATH extend the CLI:
+// monocr-NAME runs as "monocr NAME", monocr-format-NAME is --format NAME
+// and monocr-preprocess-NAME is --preprocess NAME.
+const (
+	pluginPrefix       = "monocr-"
+	formatPrefix       = pluginPrefix + "format-"
+	preprocessorPrefix = pluginPrefix + "preprocess-"
+)
+
+// pluginEnv names the monocr executable for plugins, so subcommands can
+// call back into it with the user's model and settings.
+const pluginEnv = "MONOCR"
+
+// plugin is an executable found on the PATH.
+type plugin struct {
+	Kind string `json:"kind"` // command, format or preprocess
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// findPlugins lists the plugin executables on the PATH. Like the shell,
+// the first of several with the same name wins.
+func findPlugins() []plugin {
+	var plugins []plugin
+	seen := make(map[string]bool)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			file := entry.Name()
+			if !strings.HasPrefix(file, pluginPrefix) || seen[file] {
+				continue
+			}
+			path := filepath.Join(dir, file)
+			if !isExecutable(path) {
+				continue
+			}
+			seen[file] = true
+			if runtime.GOOS == "windows" {
+				file = strings.TrimSuffix(file, filepath.Ext(file))
+			}
+			p := plugin{Kind: "command", Name: strings.TrimPrefix(file, pluginPrefix), Path: path}
+			if name, ok := strings.CutPrefix(file, formatPrefix); ok {
+				p.Kind, p.Name = "format", name
+			} else if name, ok := strings.CutPrefix(file, preprocessorPrefix); ok {
+				p.Kind, p.Name = "preprocess", name
+			}
+			plugins = append(plugins, p)
+		}
+	}
+	return plugins
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(path), ".exe")
+	}
+	return info.Mode()&0o111 != 0
+}
+
+// runPluginCommand runs monocr-NAME for "monocr NAME ARGS" when NAME is
+// not a built-in command, and exits with its status. It returns if there
+// is no such plugin.
+func runPluginCommand(root *cobra.Command, args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return
+	}
+	name := args[0]
+	if strings.HasPrefix(name, "format-") || strings.HasPrefix(name, "preprocess-") {
+		return
+	}
+	if cmd, _, err := root.Find(args); err == nil && cmd != root {
+		return
+	}
+	path, err := exec.LookPath(pluginPrefix + name)
+	if err != nil {
+		return
+	}
+
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = os.Environ()
+	if self, err := os.Executable(); err == nil {
+		cmd.Env = append(cmd.Env, pluginEnv+"="+self)
+	}
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// lookupWriter returns the writer for an output format provided by a
+// plugin, registered in this build or on the PATH, or nil.
+func lookupWriter(format string) monocr.Writer {
+	if w := monocr.LookupWriter(format); w != nil {
+		return w
+	}
+	if path, err := exec.LookPath(formatPrefix + format); err == nil {
+		return monocr.ExecWriter(path)
+	}
+	return nil
+}
+
+// lookupPreprocessor returns the preprocessor called name, registered in
+// this build or on the PATH.
+func lookupPreprocessor(name string) (monocr.Preprocessor, error) {
+	if p := monocr.LookupPreprocessor(name); p != nil {
+		return p, nil
+	}
+	if path, err := exec.LookPath(preprocessorPrefix + name); err == nil {
+		return monocr.ExecPreprocessor(path), nil
+	}
+	return nil, fmt.Errorf("unknown preprocessor %q: no plugin registers it and %s%s is not on the PATH", name, preprocessorPrefix, name)
+}
+
+func newPluginsCmd() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "plugins",
+		Short: "List the installed plugins",
+		Long: `Lists the plugins that extend this CLI. Executables on the PATH named
+
+  monocr-NAME              run as "monocr NAME ARGS..."
+  monocr-format-NAME       write "monocr pdf --format NAME": gets the source
+                           file as its argument and the result as JSON on
+                           standard input, and writes the output to
+                           standard output
+  monocr-preprocess-NAME   run by --preprocess NAME on every page: gets a
+                           PNG on standard input and writes an image to
+                           standard output
+
+Subcommands find the monocr executable in $MONOCR. Go packages can
+register formats and preprocessors in builds that import them, with
+monocr.RegisterWriter and monocr.RegisterPreprocessor.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			plugins := findPlugins()
+			for _, name := range monocr.Writers() {
+				plugins = append(plugins, plugin{Kind: "format", Name: name, Path: "(built in)"})
+			}
+			for _, name := range monocr.Preprocessors() {
+				plugins = append(plugins, plugin{Kind: "preprocess", Name: name, Path: "(built in)"})
+			}
+			slices.SortStableFunc(plugins, func(a, b plugin) int {
+				return strings.Compare(a.Kind, b.Kind)
+			})
+
+			if asJSON {
+				writeJSON(plugins)
+				return
+			}
+			if len(plugins) == 0 {
+				fmt.Printf("No plugins found. Install executables named %sNAME on the PATH.\n", pluginPrefix)
+				return
+			}
+			for _, p := range plugins {
+				fmt.Printf("%-10s  %-20s  %s\n", p.Kind, p.Name, p.Path)
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the list as JSON")
+	return cmd
+}
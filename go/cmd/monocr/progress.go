@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+// noProgress disables the progress bar of long-running commands.
+var noProgress bool
+
+// addProgressFlag registers --no-progress.
+func addProgressFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&noProgress, "no-progress", false, "Don't show the progress bar")
+}
+
+// progressWidth is the width of the bar itself in characters.
+const progressWidth = 30
+
+// progressBar draws completed items, percentage, throughput and ETA on
+// one stderr line. A nil *progressBar is valid and draws nothing.
+type progressBar struct {
+	unit  string
+	start time.Time
+
+	mu    sync.Mutex
+	done  int
+	total int
+	drawn bool
+}
+
+// newProgress returns a bar for total items named unit, or nil when
+// progress is disabled or stderr is not a terminal.
+func newProgress(unit string, total int) *progressBar {
+	if noProgress {
+		return nil
+	}
+	if fi, err := os.Stderr.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return &progressBar{unit: unit, total: total, start: time.Now()}
+}
+
+// Set records done of total items and redraws the bar.
+func (p *progressBar) Set(done, total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done, p.total = done, total
+	p.draw()
+}
+
+// Add records one more completed item.
+func (p *progressBar) Add() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.draw()
+}
+
+// Printf prints a message above the bar.
+func (p *progressBar) Printf(format string, args ...any) {
+	p.Above(func() {
+		fmt.Fprintf(os.Stderr, format, args...)
+	})
+}
+
+// Above runs print, which writes to the terminal, with the bar removed
+// and redraws it below the output.
+func (p *progressBar) Above(print func()) {
+	if p == nil {
+		print()
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	print()
+	p.draw()
+}
+
+// Finish removes the bar.
+func (p *progressBar) Finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+}
+
+func (p *progressBar) clear() {
+	if p.drawn {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+		p.drawn = false
+	}
+}
+
+func (p *progressBar) draw() {
+	if p.total <= 0 {
+		return
+	}
+	frac := float64(p.done) / float64(p.total)
+	filled := int(frac * progressWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled)
+
+	line := fmt.Sprintf("[%s] %d/%d %s %3.0f%%", bar, p.done, p.total, p.unit, 100*frac)
+	if elapsed := time.Since(p.start); p.done > 0 && elapsed > 0 {
+		rate := float64(p.done) / elapsed.Seconds()
+		eta := time.Duration(float64(p.total-p.done) / rate * float64(time.Second))
+		line += fmt.Sprintf("  %.2f %s/s  ETA %s", rate, p.unit, eta.Round(time.Second))
+	}
+	fmt.Fprint(os.Stderr, "\r\033[K"+line)
+	p.drawn = true
+}
+
+// pdfProgress returns the option drawing a bar of recognized PDF pages,
+// removed once the last page is done, or nothing when disabled.
+func pdfProgress() []monocr.Option {
+	bar := newProgress("pages", 0)
+	if bar == nil {
+		return nil
+	}
+	return []monocr.Option{monocr.WithProgress(func(done, total int) {
+		if done == total {
+			bar.Finish()
+			return
+		}
+		bar.Set(done, total)
+	})}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+func newQualityCmd() *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "quality [path]",
+		Short: "Report pages of an image or PDF that are likely to need re-scanning",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var result *monocr.Result
+			var err error
+			if strings.EqualFold(filepath.Ext(args[0]), ".pdf") {
+				result, err = monocr.ReadPDFResult(args[0], readOptions()...)
+			} else {
+				result, err = monocr.ReadImageResult(args[0], readOptions()...)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			flagged := 0
+			for _, page := range result.Pages {
+				q := page.Quality
+				if q == nil || (!all && !q.NeedsRescan()) {
+					continue
+				}
+				if q.NeedsRescan() {
+					flagged++
+				}
+
+				status := "ok"
+				if q.NeedsRescan() {
+					status = strings.Join(q.Issues, ", ")
+				}
+				fmt.Printf("Page %d: score %.2f (sharpness %.0f, contrast %.0f, text height %dpx) %s\n",
+					page.Number, q.Score, q.Sharpness, q.Contrast, q.TextHeight, status)
+			}
+			fmt.Printf("%d of %d pages may need re-scanning\n", flagged, len(result.Pages))
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "List every page, not just flagged ones")
+	addReadFlags(cmd)
+	addRenderFlags(cmd)
+	return cmd
+}
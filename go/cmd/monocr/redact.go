@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+func newRedactCmd() *cobra.Command {
+	var strs []string
+	var patterns []string
+
+	cmd := &cobra.Command{
+		Use:   "redact [input] [output]",
+		Short: "Black out matching text in an image or PDF",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var targets []*regexp.Regexp
+			for _, s := range strs {
+				targets = append(targets, regexp.MustCompile(regexp.QuoteMeta(s)))
+			}
+			for _, p := range patterns {
+				re, err := regexp.Compile(p)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: invalid pattern %q: %v\n", p, err)
+					os.Exit(1)
+				}
+				targets = append(targets, re)
+			}
+			if len(targets) == 0 {
+				fmt.Fprintln(os.Stderr, "Error: give at least one --target or --pattern")
+				os.Exit(1)
+			}
+
+			count, err := monocr.Redact(args[0], args[1], targets, readOptions()...)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "Redacted %d regions into %s\n", count, args[1])
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&strs, "target", nil, "Literal text to redact (repeatable)")
+	cmd.Flags().StringArrayVar(&patterns, "pattern", nil, "Regular expression to redact (repeatable)")
+	addReadFlags(cmd)
+	addRenderFlags(cmd)
+	return cmd
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+// serverURL is the "monocr serve" instance to send files to instead of
+// recognizing them locally.
+var serverURL string
+
+// addServerFlag registers --server.
+func addServerFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&serverURL, "server", "", "Send files to this \"monocr serve\" URL instead of loading the model (e.g. http://ocr:8080)")
+}
+
+// Polling interval bounds while waiting for a remote job.
+const (
+	minPoll = 200 * time.Millisecond
+	maxPoll = 2 * time.Second
+)
+
+// remoteClient submits files as jobs to a monocr server and waits for
+// their results. Recognition settings are the server's, except for --dpi,
+// which the server checks against its allowlist.
+type remoteClient struct {
+	base   string
+	client *http.Client
+}
+
+func newRemoteClient(server string) (*remoteClient, error) {
+	u, err := url.Parse(server)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid --server %q: use http://host:port", server)
+	}
+	return &remoteClient{base: strings.TrimSuffix(server, "/"), client: &http.Client{}}, nil
+}
+
+// ReadResult uploads the image or PDF at path, waits for the job and
+// returns its result.
+func (c *remoteClient) ReadResult(path string) (*monocr.Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	q := url.Values{"name": {filepath.Base(path)}}
+	if renderDPI != 0 {
+		q.Set("dpi", strconv.Itoa(renderDPI))
+	}
+	resp, err := c.client.Post(c.base+"/jobs?"+q.Encode(), "application/octet-stream", f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach server: %v", err)
+	}
+	var j job
+	if err := decodeResponse(resp, http.StatusAccepted, &j); err != nil {
+		return nil, err
+	}
+
+	poll := minPoll
+	for j.Status != jobDone {
+		if j.Status == jobFailed {
+			return nil, fmt.Errorf("server failed to process %s: %s", filepath.Base(path), j.Error)
+		}
+		time.Sleep(poll)
+		poll = min(2*poll, maxPoll)
+
+		resp, err := c.client.Get(c.base + "/jobs/" + url.PathEscape(j.ID))
+		if err != nil {
+			return nil, fmt.Errorf("cannot reach server: %v", err)
+		}
+		if err := decodeResponse(resp, http.StatusOK, &j); err != nil {
+			return nil, err
+		}
+	}
+	if j.Result == nil {
+		return nil, errors.New("server returned no result")
+	}
+	return j.Result, nil
+}
+
+// ReadText returns the recognized text of path, pages joined by blank
+// lines as in local batch output.
+func (c *remoteClient) ReadText(path string) (string, error) {
+	result, err := c.ReadResult(path)
+	if err != nil {
+		return "", err
+	}
+	return resultText(result), nil
+}
+
+// decodeResponse reads a JSON response into v, turning any status other
+// than want into the server's error message.
+func decodeResponse(resp *http.Response, want int, v any) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return fmt.Errorf("server: %s", e.Error)
+		}
+		return fmt.Errorf("server: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to read server response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// janitorInterval is how often the retention policy is applied.
+const janitorInterval = time.Minute
+
+// abandonAfter is how long an unfinished upload may go without data
+// before it counts as abandoned and gives up its reserved space.
+const abandonAfter = 30 * time.Minute
+
+// janitor applies the retention policy until the process exits: finished
+// jobs, with their results, and uploads untouched for longer than ttl
+// are dropped, and with a maxDisk limit abandoned uploads are evicted so
+// that their reserved space goes to new ones. Zero disables either limit.
+func (s *server) janitor(ttl time.Duration, maxDisk int64) {
+	if ttl <= 0 && maxDisk <= 0 {
+		return
+	}
+	for range time.Tick(janitorInterval) {
+		if ttl > 0 {
+			s.expireJobs(time.Now().Add(-ttl))
+			s.uploads.expire(time.Now().Add(-ttl))
+		}
+		if maxDisk > 0 {
+			s.uploads.evict()
+		}
+	}
+}
+
+// expireJobs forgets jobs that finished before cutoff, along with their
+// results.
+func (s *server) expireJobs(cutoff time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, j := range s.jobs {
+		if j.Finished != nil && j.Finished.Before(cutoff) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// diskBudget caps the space the server's data may take together:
+// uploads by declared length, job inputs on disk and the results of
+// finished jobs until they expire.
+type diskBudget struct {
+	// max is the limit in bytes; 0 is unlimited.
+	max int64
+	// used returns the bytes held now.
+	used func() int64
+
+	// mu makes checking and reserving space one step, so two requests
+	// can't both take the last of it.
+	mu sync.Mutex
+	// pending is space reserved for data still being written, which used
+	// doesn't see yet.
+	pending int64
+}
+
+// reserve sets n bytes aside for data about to be written, or fails if
+// they don't fit. release gives the reservation back, once the data is
+// on disk and counted by used or when writing it failed.
+func (b *diskBudget) reserve(n int64) (release func(), err error) {
+	if b.max <= 0 {
+		return func() {}, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used()+b.pending+n > b.max {
+		return nil, errors.New("not enough disk space left, retry later")
+	}
+	b.pending += n
+	return func() {
+		b.mu.Lock()
+		b.pending -= n
+		b.mu.Unlock()
+	}, nil
+}
+
+// diskUsed returns the bytes held by uploads, job inputs and kept
+// results.
+func (s *server) diskUsed() int64 {
+	total := s.uploads.reserved() + dirSize(filepath.Join(s.dataDir, "jobs"))
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		total += j.resultSize
+	}
+	s.mu.Unlock()
+	return total
+}
+
+// dirSize returns the total size of the files in dir.
+func dirSize(dir string) int64 {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && info.Mode().IsRegular() {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// storedUpload is an upload's data file as seen by the janitor.
+type storedUpload struct {
+	id string
+	// size is the bytes received and length the bytes declared at
+	// creation.
+	size, length int64
+	modified     time.Time
+}
+
+// list returns the uploads on disk, oldest first.
+func (u *uploadStore) list() []storedUpload {
+	entries, err := os.ReadDir(u.dir)
+	if err != nil {
+		return nil
+	}
+	var uploads []storedUpload
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".part")
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		up := storedUpload{id: id, size: info.Size(), modified: info.ModTime()}
+		if declared, _, err := u.stat(id); err == nil {
+			up.length = declared.Length
+		}
+		uploads = append(uploads, up)
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].modified.Before(uploads[j].modified)
+	})
+	return uploads
+}
+
+// reserved returns the bytes uploads hold or have declared they will,
+// whichever is more.
+func (u *uploadStore) reserved() int64 {
+	var total int64
+	for _, up := range u.list() {
+		total += max(up.size, up.length)
+	}
+	return total
+}
+
+// expire removes uploads that received no data since cutoff. Completed
+// uploads never submitted as a job expire the same way.
+func (u *uploadStore) expire(cutoff time.Time) {
+	for _, up := range u.list() {
+		if up.modified.Before(cutoff) {
+			u.remove(up.id, "expired")
+		}
+	}
+}
+
+// evict removes abandoned uploads: unfinished ones that received no data
+// for abandonAfter. Uploads in progress and completed ones waiting to be
+// submitted are left to expire.
+func (u *uploadStore) evict() {
+	cutoff := time.Now().Add(-abandonAfter)
+	for _, up := range u.list() {
+		if up.size < up.length && up.modified.Before(cutoff) {
+			u.remove(up.id, "abandoned, evicted to free disk space")
+		}
+	}
+}
+
+func (u *uploadStore) remove(id, reason string) {
+	// Data left without its info file has no lock to take
+	if unlock, ok := u.lock(id); ok {
+		defer unlock()
+	}
+	os.Remove(u.dataPath(id))
+	os.Remove(u.infoPath(id))
+	fmt.Fprintf(os.Stderr, "Upload %s %s\n", filepath.Base(id), reason)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/signal"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+func newScanCmd() *cobra.Command {
+	var so monocr.ScanOptions
+	var listDevices, webcam, asJSON bool
+	var camera, saveImage, output string
+
+	cmd := &cobra.Command{
+		Use:   "scan",
+		Short: "Scan a page from a scanner or webcam and recognize it",
+		Long: `Captures a page from a SANE scanner with scanimage, or a frame from a
+webcam with ffmpeg when built with -tags webcam, and recognizes it in one
+step. --save-image keeps the captured page next to the text, so a stack of
+pages can be digitized one "monocr scan" at a time.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			if listDevices {
+				devices, err := monocr.ScanDevices(ctx)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				if len(devices) == 0 {
+					fmt.Fprintln(os.Stderr, "No scanners found")
+				}
+				for _, d := range devices {
+					fmt.Printf("%s\t%s\n", d.Name, d.Model)
+				}
+				return
+			}
+
+			var img image.Image
+			var err error
+			if webcam {
+				img, err = monocr.CaptureWebcam(ctx, camera)
+			} else {
+				fmt.Fprintln(os.Stderr, "Scanning...")
+				img, err = monocr.Scan(ctx, so)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// The page is read back from a file, like any image
+			path := saveImage
+			if path == "" {
+				f, err := os.CreateTemp("", "monocr-scan-*.png")
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				f.Close()
+				path = f.Name()
+				defer os.Remove(path)
+			}
+			if err := writePNG(path, img); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			result, err := monocr.ReadImageResult(path, readOptions()...)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer openOutput(output)()
+			if asJSON {
+				writeJSON(result)
+				return
+			}
+			fmt.Fprintln(stdout, escapeText(resultText(result)))
+		},
+	}
+	addReadFlags(cmd)
+	addMarkFlags(cmd)
+	addEscapeFlags(cmd)
+	cmd.Flags().StringVar(&so.Device, "device", "", "SANE scanner to use, as listed by --list-devices (default: the first found)")
+	cmd.Flags().IntVar(&so.DPI, "resolution", 300, "Scan resolution in DPI")
+	cmd.Flags().BoolVar(&so.Color, "color", false, "Scan in color instead of grayscale")
+	cmd.Flags().BoolVar(&listDevices, "list-devices", false, "List the scanners SANE finds and exit")
+	cmd.Flags().BoolVar(&webcam, "webcam", false, "Capture a webcam frame instead of scanning (needs a build with -tags webcam and ffmpeg)")
+	cmd.Flags().StringVar(&camera, "camera", "", "Webcam to capture from: /dev/videoN on Linux, an index on macOS, a device name on Windows")
+	cmd.Flags().StringVar(&saveImage, "save-image", "", "Keep the captured page as a PNG at this path")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write the result to this file instead of standard output")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the full result as JSON")
+	return cmd
+}
+
+// writePNG encodes img to path.
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
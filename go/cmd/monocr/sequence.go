@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+func newSequenceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sequence [frames...]",
+		Short: "Recognize overlapping screenshots of a scrolled page as one text",
+		Long: `Recognizes screenshots taken while scrolling (chat exports, long web
+pages) and merges them, keeping lines repeated between consecutive frames
+only once. Frames are read in the order given.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			text, err := monocr.ReadSequence(args, readOptions()...)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(text)
+		},
+	}
+
+	addReadFlags(cmd)
+	return cmd
+}
@@ -0,0 +1,709 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+// Job states reported by GET /jobs/{id}.
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// job is one recognition request accepted by the server.
+type job struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Created time.Time `json:"created"`
+	// Finished is when the job finished; results are kept for the
+	// retention period after it.
+	Finished *time.Time `json:"finished,omitempty"`
+	// Pages is the number of result pages, for paging with /pages.
+	Pages   int            `json:"pages,omitempty"`
+	Options jobOptions     `json:"options"`
+	Result  *monocr.Result `json:"result,omitempty"`
+
+	// path is the stored input file.
+	path string
+	// resultSize is the encoded size of Result, which counts against the
+	// disk budget while the job is kept.
+	resultSize int64
+}
+
+// jobOptions are the per-request settings of a job, given as query
+// parameters when it is created.
+type jobOptions struct {
+	DPI   int    `json:"dpi,omitempty"`
+	Model string `json:"model,omitempty"`
+	// Format is what GET /jobs/{id}/output returns: json, text or alto.
+	Format    string `json:"format"`
+	FirstPage int    `json:"first_page,omitempty"`
+	LastPage  int    `json:"last_page,omitempty"`
+	// MinConfidence drops result lines, and characters of the lines kept,
+	// below it, as WithMinConfidence does.
+	MinConfidence float64 `json:"min_confidence,omitempty"`
+}
+
+// allowlist is what requests may ask for; anything else is rejected.
+type allowlist struct {
+	dpi     []int
+	formats []string
+}
+
+// server holds the loaded models, the job table and the upload store.
+type server struct {
+	// readers maps model names requests may select to their loaded
+	// models; "" is the default model.
+	readers map[string]*monocr.Reader
+	allow   allowlist
+	dataDir string
+	maxSize int64
+	budget  *diskBudget
+	uploads *uploadStore
+
+	mu    sync.Mutex
+	jobs  map[string]*job
+	queue chan *job
+}
+
+func newServeCmd() *cobra.Command {
+	var addr, dataDir string
+	var maxSize, maxDisk int64
+	var sessions int
+	var retention time.Duration
+	var models, formats []string
+	var dpis []int
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run an HTTP OCR service",
+		Long: `Loads the model once and serves recognition jobs over HTTP:
+
+  POST /jobs?name=scan.pdf    submit a file (raw body or multipart "file")
+  POST /jobs?upload=ID        submit a completed resumable upload
+  GET  /jobs/{id}             job status and, when done, the result
+  GET  /jobs/{id}/pages?from=N&to=M
+                              a range of result pages, for huge documents
+
+Jobs accept per-request settings as query parameters, checked against
+the server's allowlists:
+
+  dpi=400                     PDF render resolution (--allow-dpi)
+  model=NAME                  a model loaded with --model NAME=PATH
+  format=text                 what /output returns (--allow-format)
+  pages=3-7                   PDF page range
+  min_confidence=0.6          drop lines and characters below this confidence
+
+  GET  /jobs/{id}/output      the result in the job's format
+
+JSON responses are gzip-compressed for clients that accept it.
+
+Finished jobs and abandoned uploads are removed after --retention, and
+--max-disk caps the space uploads, job inputs and kept results may take
+together: an upload or job that doesn't fit is refused when it is
+created.
+
+Large files can be sent in resumable chunks with the tus 1.0 protocol
+(creation extension) at /uploads, so an interrupted upload continues from
+the last received byte instead of starting over:
+
+  POST  /uploads              Upload-Length: N, returns Location
+  PATCH /uploads/{id}         Upload-Offset: K, body is the next chunk
+  HEAD  /uploads/{id}         reports Upload-Offset to resume from`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if dataDir == "" {
+				dataDir = filepath.Join(os.TempDir(), "monocr-serve")
+			}
+			budget := &diskBudget{max: maxDisk}
+			uploads, err := newUploadStore(filepath.Join(dataDir, "uploads"), maxSize, budget)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if err := os.MkdirAll(filepath.Join(dataDir, "jobs"), 0o700); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			for _, format := range formats {
+				if !slices.Contains(serveFormats, format) {
+					fmt.Fprintf(os.Stderr, "Error: unknown format %q in --allow-format: use %s\n", format, strings.Join(serveFormats, ", "))
+					os.Exit(1)
+				}
+			}
+			if sessions < 1 {
+				fmt.Fprintf(os.Stderr, "Error: --sessions must be at least 1\n")
+				os.Exit(1)
+			}
+			readers, err := loadModels(models, sessions)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer func() {
+				for _, reader := range readers {
+					reader.Close()
+				}
+			}()
+
+			s := &server{
+				readers: readers,
+				allow:   allowlist{dpi: dpis, formats: formats},
+				dataDir: dataDir,
+				maxSize: maxSize,
+				budget:  budget,
+				uploads: uploads,
+				jobs:    make(map[string]*job),
+				queue:   make(chan *job, 1024),
+			}
+			budget.used = s.diskUsed
+			for i := 0; i < sessions; i++ {
+				go s.work()
+			}
+			go s.janitor(retention, maxDisk)
+
+			srv := &http.Server{Addr: addr, Handler: s.routes()}
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+			go func() {
+				<-sigs
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				srv.Shutdown(ctx)
+			}()
+
+			fmt.Fprintf(os.Stderr, "Listening on %s\n", addr)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", ":8080", "Address to listen on")
+	cmd.Flags().StringVar(&dataDir, "data-dir", "", "Directory for uploads and job inputs (default: monocr-serve in the temp directory)")
+	cmd.Flags().Int64Var(&maxSize, "max-upload", 1<<30, "Largest accepted file in bytes")
+	cmd.Flags().DurationVar(&retention, "retention", 24*time.Hour, "Drop finished jobs and abandoned uploads after this long (0 keeps them)")
+	cmd.Flags().Int64Var(&maxDisk, "max-disk", 0, "Disk space in bytes for uploads (counted at their declared length), job inputs and kept results together; uploads and jobs beyond it are refused and abandoned uploads evicted (0 is unlimited)")
+	cmd.Flags().IntVar(&sessions, "sessions", 1, "Model sessions to load, and so jobs to run at once; the cores are divided between them unless --threads is set")
+	cmd.Flags().StringArrayVar(&models, "model", nil, "Additional model requests may select, as NAME=MODEL.onnx or NAME=CARD.json (repeatable)")
+	cmd.Flags().IntSliceVar(&dpis, "allow-dpi", []int{150, 200, 300, 400, 600}, "PDF render resolutions requests may ask for")
+	cmd.Flags().StringSliceVar(&formats, "allow-format", serveFormats, "Output formats requests may ask for")
+	addReadFlags(cmd)
+	addRenderFlags(cmd)
+	return cmd
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.createJob)
+	mux.HandleFunc("GET /jobs/{id}", compress(s.getJob))
+	mux.HandleFunc("GET /jobs/{id}/pages", compress(s.getPages))
+	mux.HandleFunc("GET /jobs/{id}/output", compress(s.getOutput))
+	s.uploads.register(mux)
+	return mux
+}
+
+// work runs queued jobs one at a time. serve starts a worker per
+// --sessions, and the workers' lines are recognized on whichever session
+// of the model's pool is free.
+func (s *server) work() {
+	for j := range s.queue {
+		s.setStatus(j, jobRunning, nil, "")
+
+		// Options are fixed once the job is created
+		reader := s.readers[j.Options.Model].With(j.Options.readOptions()...)
+		var result *monocr.Result
+		var err error
+		if isPDF(j.Name) {
+			result, err = reader.ReadPDFResult(j.path)
+		} else {
+			result, err = reader.ReadImageResult(j.path)
+		}
+		os.Remove(j.path)
+		if err != nil {
+			s.setStatus(j, jobFailed, nil, err.Error())
+			continue
+		}
+		for i := range result.Pages {
+			// Page images point into the data directory, which clients can't see
+			result.Pages[i].Image = ""
+		}
+		s.setStatus(j, jobDone, result, "")
+	}
+}
+
+func (s *server) setStatus(j *job, status string, result *monocr.Result, msg string) {
+	var size int64
+	if result != nil {
+		data, _ := json.Marshal(result)
+		size = int64(len(data))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.Status, j.Result, j.Error = status, result, msg
+	j.resultSize = size
+	if status == jobDone || status == jobFailed {
+		now := time.Now().UTC()
+		j.Finished = &now
+	}
+	if result != nil {
+		j.Pages = len(result.Pages)
+	}
+}
+
+// createJob stores the submitted file and queues it.
+func (s *server) createJob(w http.ResponseWriter, r *http.Request) {
+	id, err := newID()
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	opts, err := s.parseOptions(r.URL.Query())
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	j := &job{ID: id, Created: time.Now().UTC(), Options: opts}
+	j.path = filepath.Join(s.dataDir, "jobs", id)
+
+	if upload := r.URL.Query().Get("upload"); upload != "" {
+		name, err := s.uploads.claim(upload, j.path)
+		if err != nil {
+			httpError(w, http.StatusConflict, err)
+			return
+		}
+		j.Name = name
+	} else {
+		// The body is bounded by its declared length, or by --max-upload
+		// when it has none
+		size := s.maxSize
+		if r.ContentLength >= 0 {
+			size = min(size, r.ContentLength)
+		}
+		release, err := s.budget.reserve(size)
+		if err != nil {
+			httpError(w, http.StatusInsufficientStorage, err)
+			return
+		}
+		name, err := s.receiveFile(w, r, j.path)
+		release()
+		if err != nil {
+			os.Remove(j.path)
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			httpError(w, status, err)
+			return
+		}
+		j.Name = name
+	}
+	if q := r.URL.Query().Get("name"); q != "" {
+		j.Name = q
+	}
+	if !isPDF(j.Name) && !isImage(j.Name) {
+		os.Remove(j.path)
+		httpError(w, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported file %q: name must end in .pdf, .png, .jpg or .jpeg", j.Name))
+		return
+	}
+
+	j.Status = jobQueued
+	// The worker owns j once queued
+	accepted := *j
+	s.mu.Lock()
+	s.jobs[id] = j
+	s.mu.Unlock()
+	select {
+	case s.queue <- j:
+	default:
+		os.Remove(j.path)
+		s.setStatus(j, jobFailed, nil, "server busy")
+		httpError(w, http.StatusServiceUnavailable, errors.New("too many queued jobs"))
+		return
+	}
+
+	w.Header().Set("Location", "/jobs/"+id)
+	writeResponse(w, http.StatusAccepted, &accepted)
+}
+
+// parseOptions reads a job's settings from the query parameters of its
+// creation request, rejecting values outside the server's allowlists.
+func (s *server) parseOptions(q url.Values) (jobOptions, error) {
+	opts := jobOptions{Model: q.Get("model"), Format: "json"}
+	if v := q.Get("dpi"); v != "" {
+		dpi, err := strconv.Atoi(v)
+		if err != nil || !slices.Contains(s.allow.dpi, dpi) {
+			return opts, fmt.Errorf("dpi %q not allowed: use one of %v", v, s.allow.dpi)
+		}
+		opts.DPI = dpi
+	}
+	if _, ok := s.readers[opts.Model]; !ok {
+		return opts, fmt.Errorf("unknown model %q", opts.Model)
+	}
+	if v := q.Get("format"); v != "" {
+		if !slices.Contains(s.allow.formats, v) {
+			return opts, fmt.Errorf("format %q not allowed: use one of %s", v, strings.Join(s.allow.formats, ", "))
+		}
+		opts.Format = v
+	}
+	if v := q.Get("pages"); v != "" {
+		first, last, err := parsePageRange(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.FirstPage, opts.LastPage = first, last
+	}
+	if v := q.Get("min_confidence"); v != "" {
+		conf, err := strconv.ParseFloat(v, 64)
+		if err != nil || conf < 0 || conf > 1 {
+			return opts, fmt.Errorf("invalid min_confidence %q: must be between 0 and 1", v)
+		}
+		opts.MinConfidence = conf
+	}
+	return opts, nil
+}
+
+// parsePageRange parses "N", "N-M", "N-" or "-M", with pages numbered
+// from 1 and 0 meaning an open end.
+func parsePageRange(v string) (int, int, error) {
+	from, to, isRange := strings.Cut(v, "-")
+	if !isRange {
+		to = from
+	}
+	var bounds [2]int
+	for i, s := range []string{from, to} {
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return 0, 0, fmt.Errorf("invalid pages %q: use N, N-M, N- or -M", v)
+		}
+		bounds[i] = n
+	}
+	if bounds[1] > 0 && bounds[0] > bounds[1] {
+		return 0, 0, fmt.Errorf("invalid pages %q: range is reversed", v)
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// readOptions converts the job's settings to reader options.
+func (o jobOptions) readOptions() []monocr.Option {
+	var opts []monocr.Option
+	if o.DPI > 0 {
+		opts = append(opts, monocr.WithDPI(o.DPI))
+	}
+	if o.FirstPage > 0 || o.LastPage > 0 {
+		opts = append(opts, monocr.WithPages(o.FirstPage, o.LastPage))
+	}
+	if o.MinConfidence > 0 {
+		opts = append(opts, monocr.WithMinConfidence(o.MinConfidence, ""))
+	}
+	return opts
+}
+
+// loadModels loads and warms up the default model and the NAME=PATH
+// models given with --model.
+func loadModels(models []string, sessions int) (map[string]*monocr.Reader, error) {
+	readers := make(map[string]*monocr.Reader)
+	closeAll := func() {
+		for _, reader := range readers {
+			reader.Close()
+		}
+	}
+
+	// Without a thread count, sessions would each start a thread per core
+	opts := readOptions()
+	declareGPUSessions((1 + len(models)) * sessions)
+	if sessions > 1 {
+		opts = append(opts, monocr.WithSessions(sessions))
+		if intraThreads == 0 && !tuneThreads {
+			opts = append(opts, monocr.WithThreads(max(1, runtime.NumCPU()/sessions), 0))
+		}
+	}
+
+	reader, err := monocr.NewReader(opts...)
+	if err != nil {
+		return nil, err
+	}
+	readers[""] = reader
+	for _, model := range models {
+		name, path, ok := strings.Cut(model, "=")
+		if !ok || name == "" || path == "" {
+			closeAll()
+			return nil, fmt.Errorf("invalid --model %q: use NAME=MODEL.onnx", model)
+		}
+		if _, dup := readers[name]; dup {
+			closeAll()
+			return nil, fmt.Errorf("model %q given twice", name)
+		}
+		// --charset is the default model's; the others bring a model card
+		// or use the bundled charset
+		reader, err := monocr.NewReaderWithModel(path, "", append(opts, monocr.WithCharsetFile(""))...)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to load model %q: %v", name, err)
+		}
+		readers[name] = reader
+	}
+
+	// Pay for the first, slow inference before taking requests
+	for name, reader := range readers {
+		if err := reader.Warmup(); err != nil {
+			closeAll()
+			if name == "" {
+				return nil, fmt.Errorf("failed to warm up the model: %v", err)
+			}
+			return nil, fmt.Errorf("failed to warm up model %q: %v", name, err)
+		}
+	}
+	return readers, nil
+}
+
+// receiveFile writes the request's file, sent as the raw body or as the
+// "file" field of a multipart form, to path and returns its name.
+func (s *server) receiveFile(w http.ResponseWriter, r *http.Request, path string) (string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxSize)
+	var body io.Reader = r.Body
+	name := ""
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return "", err
+		}
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				return "", fmt.Errorf("no \"file\" field in form: %v", err)
+			}
+			if part.FormName() == "file" {
+				name = part.FileName()
+				body = part
+				break
+			}
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return "", err
+	}
+	return name, f.Close()
+}
+
+func (s *server) getJob(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	j, ok := s.jobs[r.PathValue("id")]
+	var snapshot job
+	if ok {
+		snapshot = *j
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		httpError(w, http.StatusNotFound, errors.New("no such job"))
+		return
+	}
+	writeResponse(w, http.StatusOK, &snapshot)
+}
+
+// pageRange is a slice of a job's result returned by /jobs/{id}/pages.
+type pageRange struct {
+	ID    string        `json:"id"`
+	From  int           `json:"from"`
+	To    int           `json:"to"`
+	Total int           `json:"total"`
+	Pages []monocr.Page `json:"pages"`
+}
+
+// getPages returns the result pages numbered from..to (inclusive, both
+// optional), so clients can fetch a huge document piece by piece.
+func (s *server) getPages(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	j, ok := s.jobs[r.PathValue("id")]
+	var result *monocr.Result
+	var status string
+	if ok {
+		result, status = j.Result, j.Status
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		httpError(w, http.StatusNotFound, errors.New("no such job"))
+		return
+	}
+	if result == nil {
+		httpError(w, http.StatusConflict, fmt.Errorf("job is %s", status))
+		return
+	}
+
+	resp := pageRange{ID: j.ID, From: 1, To: math.MaxInt, Total: len(result.Pages), Pages: []monocr.Page{}}
+	for _, bound := range []struct {
+		name string
+		dst  *int
+	}{{"from", &resp.From}, {"to", &resp.To}} {
+		if v := r.URL.Query().Get(bound.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				httpError(w, http.StatusBadRequest, fmt.Errorf("invalid %s %q", bound.name, v))
+				return
+			}
+			*bound.dst = n
+		}
+	}
+	// The result is not modified once done, so it can be read unlocked
+	for _, page := range result.Pages {
+		if page.Number >= resp.From && page.Number <= resp.To {
+			resp.Pages = append(resp.Pages, page)
+		}
+	}
+	if resp.To == math.MaxInt {
+		resp.To = 0
+		if n := len(result.Pages); n > 0 {
+			resp.To = result.Pages[n-1].Number
+		}
+	}
+	writeResponse(w, http.StatusOK, &resp)
+}
+
+// getOutput writes a done job's result in the format it asked for.
+func (s *server) getOutput(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	j, ok := s.jobs[r.PathValue("id")]
+	var snapshot job
+	if ok {
+		snapshot = *j
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		httpError(w, http.StatusNotFound, errors.New("no such job"))
+		return
+	}
+	result := snapshot.Result
+	if result == nil {
+		httpError(w, http.StatusConflict, fmt.Errorf("job is %s", snapshot.Status))
+		return
+	}
+
+	switch snapshot.Options.Format {
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, page := range result.Pages {
+			if len(result.Pages) > 1 {
+				fmt.Fprintf(w, "--- Page %d ---\n", page.Number)
+			}
+			writePageText(w, page)
+		}
+	case "alto":
+		w.Header().Set("Content-Type", "application/xml")
+		monocr.EncodeALTO(w, result, snapshot.Name)
+	default:
+		writeResponse(w, http.StatusOK, result)
+	}
+}
+
+// compress gzips h's responses for clients that accept it.
+func compress(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			h(w, r)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		h(gzipResponseWriter{ResponseWriter: w, w: gz}, r)
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, enc := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.TrimSpace(name) == "gzip" {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	w io.Writer
+}
+
+func (g gzipResponseWriter) Write(p []byte) (int, error) {
+	return g.w.Write(p)
+}
+
+func isImage(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png", ".jpg", ".jpeg":
+		return true
+	}
+	return false
+}
+
+// serveFormats are the output formats GET /jobs/{id}/output can return.
+var serveFormats = []string{"json", "text", "alto"}
+
+// newID returns a random identifier for jobs and uploads.
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func writeResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+func httpError(w http.ResponseWriter, status int, err error) {
+	writeResponse(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/model"
+)
+
+// stateEntry is one line of a batch state file: an input, by content,
+// that was processed with a model.
+type stateEntry struct {
+	SHA256    string    `json:"sha256"`
+	Model     string    `json:"model"`
+	Path      string    `json:"path"`
+	Processed time.Time `json:"processed"`
+}
+
+// batchState remembers which inputs were already processed by which
+// model, so batch runs over a growing archive only read new or changed
+// files and files last read by another model. It is a JSON Lines file
+// that is only appended to, so an interrupted run keeps its progress. A
+// nil *batchState remembers nothing.
+type batchState struct {
+	model string
+	// done maps input hashes to the model they were last processed with.
+	done   map[string]string
+	hashes map[string]string
+	f      *os.File
+}
+
+// openState loads the state file at path, creating it if needed.
+func openState(path string) (*batchState, error) {
+	s := &batchState{model: modelID(), done: make(map[string]string), hashes: make(map[string]string)}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		var e stateEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		s.done[e.SHA256] = e.Model
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.f = f
+	return s, nil
+}
+
+// modelID identifies what recognizes inputs: the server with --server,
+// otherwise the SHA-256 of the local model.
+func modelID() string {
+	if serverURL != "" {
+		return serverURL
+	}
+	manager, err := model.NewManager()
+	if err != nil {
+		return ""
+	}
+	if f := hashFile(manager.ModelPath()); f.SHA256 != "" {
+		return "sha256:" + f.SHA256
+	}
+	return ""
+}
+
+// pending returns the paths that still need processing.
+func (s *batchState) pending(paths []string) ([]string, error) {
+	if s == nil {
+		return paths, nil
+	}
+	var todo []string
+	for _, path := range paths {
+		f := hashFile(path)
+		if f.SHA256 == "" {
+			return nil, fmt.Errorf("failed to read %s: %s", path, f.Error)
+		}
+		s.hashes[path] = f.SHA256
+		if model, ok := s.done[f.SHA256]; !ok || model != s.model || s.model == "" {
+			todo = append(todo, path)
+		}
+	}
+	return todo, nil
+}
+
+// record marks path as processed with the current model.
+func (s *batchState) record(path string) error {
+	if s == nil {
+		return nil
+	}
+	line, err := json.Marshal(stateEntry{SHA256: s.hashes[path], Model: s.model, Path: path, Processed: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	_, err = s.f.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the state file.
+func (s *batchState) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.f.Close()
+}
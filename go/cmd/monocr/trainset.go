@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+func newTrainsetCmd() *cobra.Command {
+	var outDir string
+	var urlPrefix string
+
+	cmd := &cobra.Command{
+		Use:   "trainset [corrections...]",
+		Short: "Build a fine-tuning set from corrected OCR output",
+		Long: `Pairs line crops with corrected transcriptions and writes them as
+NNNNNN.png + NNNNNN.gt.txt files, plus a labels.tsv manifest.
+
+Each input is either a Label Studio JSON export or a hand-corrected result
+from "monocr pdf --format json" (run with --keep-rendered so page images
+are kept).`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			imagePath := func(url string) string {
+				return strings.TrimPrefix(url, urlPrefix)
+			}
+
+			var lines []monocr.TrainingLine
+			for _, path := range args {
+				found, err := loadCorrections(path, imagePath)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+					os.Exit(1)
+				}
+				lines = append(lines, found...)
+			}
+
+			n, err := monocr.WriteTrainingSet(outDir, lines)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "Wrote %d line pairs to %s\n", n, outDir)
+		},
+	}
+
+	cmd.Flags().StringVarP(&outDir, "output", "o", "trainset", "Directory to write line images and transcriptions to")
+	cmd.Flags().StringVar(&urlPrefix, "url-prefix", "/data/local-files/?d=", "Prefix stripped from Label Studio image URLs to get local paths")
+	return cmd
+}
+
+// loadCorrections reads a Label Studio export (a JSON array of tasks) or a
+// corrected result (a JSON object).
+func loadCorrections(path string, imagePath func(string) string) ([]monocr.TrainingLine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return monocr.LoadLabelStudioExport(path, imagePath)
+	}
+
+	var result monocr.Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %v", err)
+	}
+	return result.TrainingLines(), nil
+}
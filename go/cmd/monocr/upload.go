@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// tusVersion is the resumable upload protocol version served at /uploads.
+const tusVersion = "1.0.0"
+
+// upload is the state of one resumable upload, kept next to its data so
+// uploads survive a server restart.
+type upload struct {
+	Length int64  `json:"length"`
+	Name   string `json:"name"`
+}
+
+// uploadStore implements the core tus protocol and its creation
+// extension on top of a directory: ID.part holds the bytes received so
+// far and ID.json the upload's declared length and file name.
+type uploadStore struct {
+	dir     string
+	maxSize int64
+	// budget is the server's disk space, which uploads count against by
+	// declared length.
+	budget *diskBudget
+
+	// locks serializes requests to the same upload. Entries exist only
+	// while a request holds or waits for them.
+	mu    sync.Mutex
+	locks map[string]*uploadLock
+}
+
+// uploadLock is an upload's lock and the number of requests using it.
+type uploadLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newUploadStore(dir string, maxSize int64, budget *diskBudget) (*uploadStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &uploadStore{dir: dir, maxSize: maxSize, budget: budget, locks: make(map[string]*uploadLock)}, nil
+}
+
+func (u *uploadStore) register(mux *http.ServeMux) {
+	mux.HandleFunc("OPTIONS /uploads", u.options)
+	mux.HandleFunc("POST /uploads", u.create)
+	mux.HandleFunc("HEAD /uploads/{id}", u.head)
+	mux.HandleFunc("PATCH /uploads/{id}", u.patch)
+}
+
+// lock serializes requests to upload id, and reports false without
+// locking if there is no such upload, so unknown ids take no memory. The
+// lock's entry is dropped once the last request using it unlocks.
+func (u *uploadStore) lock(id string) (unlock func(), ok bool) {
+	u.mu.Lock()
+	l := u.locks[id]
+	if l == nil {
+		if _, err := os.Stat(u.infoPath(id)); err != nil {
+			u.mu.Unlock()
+			return nil, false
+		}
+		l = &uploadLock{}
+		u.locks[id] = l
+	}
+	l.refs++
+	u.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		u.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(u.locks, id)
+		}
+		u.mu.Unlock()
+	}, true
+}
+
+func (u *uploadStore) options(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", "creation")
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(u.maxSize, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// create starts an upload of Upload-Length bytes. The file name is taken
+// from the "filename" entry of Upload-Metadata.
+func (u *uploadStore) create(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		httpError(w, http.StatusBadRequest, errors.New("missing or invalid Upload-Length"))
+		return
+	}
+	if length > u.maxSize {
+		httpError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("upload of %d bytes exceeds the %d byte limit", length, u.maxSize))
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	info := upload{Length: length, Name: uploadFilename(r.Header.Get("Upload-Metadata"))}
+	if status, err := u.reserve(id, info); err != nil {
+		httpError(w, status, err)
+		return
+	}
+
+	w.Header().Set("Location", "/uploads/"+id)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// reserve records a new upload, whose declared length counts against
+// the disk budget from now on, and returns the HTTP status to fail with
+// if it can't.
+func (u *uploadStore) reserve(id string, info upload) (int, error) {
+	release, err := u.budget.reserve(info.Length)
+	if err != nil {
+		return http.StatusInsufficientStorage, err
+	}
+	// Once its files exist, the upload is counted by reserved
+	defer release()
+
+	data, _ := json.Marshal(info)
+	if err := os.WriteFile(u.infoPath(id), data, 0o600); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err := os.WriteFile(u.dataPath(id), nil, 0o600); err != nil {
+		os.Remove(u.infoPath(id))
+		return http.StatusInternalServerError, err
+	}
+	return 0, nil
+}
+
+// head reports how much of an upload has arrived, so clients know where
+// to resume.
+func (u *uploadStore) head(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Cache-Control", "no-store")
+	unlock, ok := u.lock(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	defer unlock()
+
+	info, offset, err := u.stat(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(info.Length, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+// patch appends a chunk at Upload-Offset. Bytes received before a broken
+// connection are kept, so the client resumes from the reported offset.
+func (u *uploadStore) patch(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		httpError(w, http.StatusUnsupportedMediaType, errors.New("chunks must be sent as application/offset+octet-stream"))
+		return
+	}
+	unlock, ok := u.lock(id)
+	if !ok {
+		httpError(w, http.StatusNotFound, errors.New("no such upload"))
+		return
+	}
+	defer unlock()
+
+	info, offset, err := u.stat(id)
+	if err != nil {
+		httpError(w, http.StatusNotFound, errors.New("no such upload"))
+		return
+	}
+	claimed, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || claimed != offset {
+		httpError(w, http.StatusConflict, fmt.Errorf("Upload-Offset must be %d", offset))
+		return
+	}
+
+	f, err := os.OpenFile(u.dataPath(id), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	n, copyErr := io.Copy(f, io.LimitReader(r.Body, info.Length-offset))
+	if err := f.Close(); copyErr == nil {
+		copyErr = err
+	}
+	if copyErr != nil {
+		// The client sees the connection fail; what arrived is kept
+		httpError(w, http.StatusInternalServerError, copyErr)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset+n, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// claim moves a completed upload to path for processing and returns its
+// file name.
+func (u *uploadStore) claim(id, path string) (string, error) {
+	if strings.ContainsAny(id, `/\.`) {
+		return "", errors.New("no such upload")
+	}
+	unlock, ok := u.lock(id)
+	if !ok {
+		return "", errors.New("no such upload")
+	}
+	defer unlock()
+
+	info, offset, err := u.stat(id)
+	if err != nil {
+		return "", errors.New("no such upload")
+	}
+	if offset != info.Length {
+		return "", fmt.Errorf("upload incomplete: %d of %d bytes received", offset, info.Length)
+	}
+	if err := os.Rename(u.dataPath(id), path); err != nil {
+		return "", err
+	}
+	os.Remove(u.infoPath(id))
+	return info.Name, nil
+}
+
+// stat returns an upload's declared length and the bytes received so far.
+func (u *uploadStore) stat(id string) (upload, int64, error) {
+	var info upload
+	data, err := os.ReadFile(u.infoPath(id))
+	if err != nil {
+		return info, 0, err
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, 0, err
+	}
+	fi, err := os.Stat(u.dataPath(id))
+	if err != nil {
+		return info, 0, err
+	}
+	return info, fi.Size(), nil
+}
+
+func (u *uploadStore) infoPath(id string) string {
+	return filepath.Join(u.dir, filepath.Base(id)+".json")
+}
+
+func (u *uploadStore) dataPath(id string) string {
+	return filepath.Join(u.dir, filepath.Base(id)+".part")
+}
+
+// uploadFilename extracts the "filename" entry from a tus Upload-Metadata
+// header: comma-separated keys with base64-encoded values.
+func uploadFilename(metadata string) string {
+	for _, pair := range strings.Split(metadata, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key != "filename" {
+			continue
+		}
+		name, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return ""
+		}
+		return filepath.Base(string(name))
+	}
+	return ""
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/MonDevHub/monocr-onnx/go"
+	"github.com/spf13/cobra"
+)
+
+// fileState is what a watched file looked like at the last scan. A file
+// is read once its state holds still between two scans, so files still
+// being copied in by a scanner are left alone.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func newWatchCmd() *cobra.Command {
+	var outputDir, moveTo, format string
+	var interval time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "watch [directory]",
+		Short: "Recognize images and PDFs as they arrive in a directory",
+		Long: `Watches a hot folder and recognizes each image or PDF placed in it,
+writing one result file per input to --output-dir. A file is read once its
+size and modification time stop changing, so partly written scans are not
+picked up. Inputs whose result is already newer than them are skipped, so
+the watcher can be restarted at any time.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			dir := args[0]
+			if format != "text" && format != "json" {
+				fmt.Fprintf(os.Stderr, "Error: unknown format %q: must be text or json\n", format)
+				os.Exit(1)
+			}
+			if outputDir == "" {
+				outputDir = filepath.Join(dir, "ocr")
+			}
+			ext := ".txt"
+			if format == "json" {
+				ext = ".json"
+			}
+			for _, d := range []string{outputDir, moveTo} {
+				if d == "" {
+					continue
+				}
+				if err := os.MkdirAll(d, 0o755); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+
+			var reader batchReader
+			if serverURL != "" {
+				client, err := newRemoteClient(serverURL)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				reader = client
+			} else {
+				local, err := monocr.NewReader(readOptions()...)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				defer local.Close()
+				reader = localReader{local}
+			}
+
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			fmt.Fprintf(os.Stderr, "Watching %s\n", dir)
+			seen := make(map[string]fileState)
+			// failed remembers inputs that failed, until they change
+			failed := make(map[string]fileState)
+			for {
+				files, err := os.ReadDir(dir)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+
+				current := make(map[string]fileState)
+				for _, file := range files {
+					name := file.Name()
+					if file.IsDir() || (!isPDF(name) && !isImage(name)) {
+						continue
+					}
+					info, err := file.Info()
+					if err != nil {
+						continue
+					}
+					state := fileState{size: info.Size(), modTime: info.ModTime()}
+					current[name] = state
+					if prev, ok := seen[name]; !ok || prev != state || failed[name] == state {
+						continue
+					}
+
+					path := filepath.Join(dir, name)
+					outPath := filepath.Join(outputDir, outputName(name, 0, ext))
+					if out, err := os.Stat(outPath); err == nil && out.ModTime().After(state.modTime) {
+						continue
+					}
+
+					fmt.Fprintf(os.Stderr, "Processing %s...\n", name)
+					if err := watchFile(reader, path, outPath, format); err != nil {
+						fmt.Fprintf(os.Stderr, "Failed to process %s: %v\n", name, err)
+						failed[name] = state
+						continue
+					}
+					delete(failed, name)
+					if moveTo != "" {
+						if err := os.Rename(path, filepath.Join(moveTo, name)); err != nil {
+							fmt.Fprintf(os.Stderr, "Failed to move %s: %v\n", name, err)
+						}
+					}
+
+					// Stop between files rather than after a whole scan
+					select {
+					case <-sigs:
+						return
+					default:
+					}
+				}
+				seen = current
+
+				select {
+				case <-sigs:
+					return
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&outputDir, "output-dir", "", "Directory for result files (default: ocr inside the watched directory)")
+	cmd.Flags().StringVar(&moveTo, "move-to", "", "Move inputs to this directory once recognized")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "How often to scan the directory")
+	addReadFlags(cmd)
+	addRenderFlags(cmd)
+	addServerFlag(cmd)
+	addMarkFlags(cmd)
+	return cmd
+}
+
+// watchFile recognizes path and writes its result to outPath through a
+// temporary file, so consumers of the output directory never see a
+// partial result.
+func watchFile(reader batchReader, path, outPath, format string) error {
+	var write func(w io.Writer) error
+	if format == "json" {
+		result, err := reader.ReadResult(path)
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer) error {
+			return encodeJSON(w, result)
+		}
+	} else {
+		text, err := reader.ReadText(path)
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer) error {
+			_, err := fmt.Fprintln(w, text)
+			return err
+		}
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(outPath), ".monocr-*")
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), outPath)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package monocr
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+)
+
+// scored reports whether lines are read with their confidences: always
+// but in text-only mode, where only WithMinConfidence needs them.
+func (o *options) scored() bool {
+	return !o.textOnly || o.minConfidence > 0
+}
+
+// maskLine applies WithMinConfidence within line: a line below the
+// threshold becomes the placeholder, and characters below it are removed
+// or replaced by the placeholder. Lines to drop are left to keepLine.
+func (o *options) maskLine(line Line) Line {
+	if o.minConfidence <= 0 || line.Text == "" {
+		return line
+	}
+	if line.Confidence < o.minConfidence {
+		if o.placeholder != "" {
+			line.Text, line.Chars = o.placeholder, nil
+		}
+		return line
+	}
+
+	kept := make([]predictor.Char, 0, len(line.Chars))
+	var sb strings.Builder
+	for _, c := range line.Chars {
+		if c.Confidence < o.minConfidence && strings.TrimFunc(c.Text, unicode.IsSpace) != "" {
+			if o.placeholder == "" {
+				continue
+			}
+			c.Text = o.placeholder
+		}
+		kept = append(kept, c)
+		sb.WriteString(c.Text)
+	}
+	if len(line.Chars) > 0 {
+		line.Text, line.Chars = sb.String(), kept
+	}
+	return line
+}
+
+// keepLine reports whether line survives WithMinConfidence.
+func (o *options) keepLine(line Line) bool {
+	return o.minConfidence <= 0 || o.placeholder != "" || line.Text == "" || line.Confidence >= o.minConfidence
+}
+
+// keepLines returns the lines that survive WithMinConfidence.
+func (o *options) keepLines(lines []Line) []Line {
+	kept := lines[:0]
+	for _, line := range lines {
+		if o.keepLine(line) {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,40 @@
+package monocr
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// cpuFeatures returns the SIMD extensions listed in /proc/cpuinfo for the
+// first CPU: its "flags" on x86 and "Features" on ARM.
+func cpuFeatures() []string {
+	f, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return baselineCPUFeatures()
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		if key = strings.TrimSpace(key); key != "flags" && key != "Features" {
+			continue
+		}
+		have := make(map[string]bool)
+		for _, flag := range strings.Fields(value) {
+			have[flag] = true
+		}
+		var features []string
+		for _, feature := range simdFeatures {
+			if have[feature] {
+				features = append(features, feature)
+			}
+		}
+		return features
+	}
+	return baselineCPUFeatures()
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package monocr
+
+// cpuFeatures returns the SIMD extensions every CPU of the architecture
+// has; finer detection needs /proc/cpuinfo.
+func cpuFeatures() []string {
+	return baselineCPUFeatures()
+}
@@ -0,0 +1,128 @@
+package monocr
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Escaper rewrites text for output sinks that can't store Myanmar-script
+// codepoints, such as legacy latin1 database columns.
+type Escaper interface {
+	Escape(text string) string
+}
+
+// EscaperFunc adapts a function to an Escaper.
+type EscaperFunc func(text string) string
+
+func (f EscaperFunc) Escape(text string) string {
+	return f(text)
+}
+
+// EscapeNCR replaces every non-ASCII character with an HTML/XML numeric
+// character reference, so "မန်" becomes "&#x1019;&#x1014;&#x103A;".
+var EscapeNCR Escaper = EscaperFunc(escapeNCR)
+
+func escapeNCR(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		writeNCR(&sb, r)
+	}
+	return sb.String()
+}
+
+func writeNCR(sb *strings.Builder, r rune) {
+	if r < utf8.RuneSelf {
+		sb.WriteRune(r)
+	} else {
+		fmt.Fprintf(sb, "&#x%X;", r)
+	}
+}
+
+// Romanization is an Escaper spelling each character in Latin letters.
+// Characters it has no spelling for are kept when ASCII and escaped as
+// numeric character references otherwise, so the output is always ASCII.
+type Romanization map[rune]string
+
+func (t Romanization) Escape(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		if s, ok := t[r]; ok {
+			sb.WriteString(s)
+		} else {
+			writeNCR(&sb, r)
+		}
+	}
+	return sb.String()
+}
+
+// MonRomanization is a letter-by-letter romanization of Mon text. It is
+// meant for search and storage, not as a scholarly transcription: vowel
+// signs are spelled where they are stored, after their consonant, and
+// the virama and asat are dropped.
+var MonRomanization = Romanization{
+	// Consonants
+	'က': "k", 'ခ': "kh", 'ဂ': "g", 'ဃ': "gh", 'င': "ng",
+	'စ': "c", 'ဆ': "ch", 'ဇ': "j", 'ဈ': "jh", 'ဉ': "ny", 'ည': "ny",
+	'ဋ': "tt", 'ဌ': "tth", 'ဍ': "dd", 'ဎ': "ddh", 'ဏ': "nn",
+	'တ': "t", 'ထ': "th", 'ဒ': "d", 'ဓ': "dh", 'န': "n",
+	'ပ': "p", 'ဖ': "ph", 'ဗ': "b", 'ဘ': "bh", 'မ': "m",
+	'ယ': "y", 'ရ': "r", 'လ': "l", 'ဝ': "w", 'သ': "s", 'ဟ': "h",
+	'ဠ': "ll", 'အ': "'", 'ၚ': "ng", 'ၛ': "jh", 'ၜ': "bb", 'ၝ': "bb",
+	// Independent vowels
+	'ဣ': "i", 'ဥ': "u", 'ဦ': "uu", 'ဧ': "e", 'ဨ': "e", 'ဩ': "o", 'ဪ': "au",
+	// Vowel signs and finals
+	'ါ': "a", 'ာ': "a", 'ိ': "i", 'ီ': "ii", 'ု': "u", 'ူ': "uu",
+	'ေ': "e", 'ဲ': "ai", 'ဳ': "ii", 'ဴ': "o", 'ံ': "m", '့': "", 'း': "h",
+	'္': "", '်': "",
+	// Medials
+	'ျ': "y", 'ြ': "r", 'ွ': "w", 'ှ': "h", 'ၞ': "n", 'ၟ': "m", 'ၠ': "l",
+	// Digits and punctuation
+	'၀': "0", '၁': "1", '၂': "2", '၃': "3", '၄': "4",
+	'၅': "5", '၆': "6", '၇': "7", '၈': "8", '၉': "9",
+	'၊': ",", '။': ".",
+}
+
+// LoadRomanization reads a romanization table from path: one character
+// per line followed by whitespace and its spelling, which may be empty to
+// drop the character. Blank lines and lines starting with # are ignored.
+func LoadRomanization(path string) (Romanization, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	table := make(Romanization)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRightFunc(scanner.Text(), unicode.IsSpace)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line)
+		rest := line[size:]
+		if r == utf8.RuneError || (rest != "" && !unicode.IsSpace(rune(rest[0]))) {
+			return nil, fmt.Errorf("%s:%d: expected one character, then its spelling", path, n)
+		}
+		table[r] = strings.TrimSpace(rest)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Escape fills in Line.Escaped for every line of r with e, keeping the
+// raw Unicode in Line.Text.
+func (r *Result) Escape(e Escaper) {
+	for i := range r.Pages {
+		for j := range r.Pages[i].Lines {
+			line := &r.Pages[i].Lines[j]
+			line.Escaped = e.Escape(line.Text)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package monocr
+
+import (
+	"image"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+)
+
+// Match is a piece of structured data found in recognized text, such as a
+// date or a registration number.
+type Match struct {
+	// Kind is the extractor's label for the match, e.g. "date".
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+	Page int    `json:"page"`
+	// BBox is the match's approximate location on the page; empty in
+	// text-only mode.
+	BBox image.Rectangle `json:"bbox"`
+}
+
+// Extractor finds matches in a recognized page. Extractors run after each
+// page is recognized and can use line and word coordinates.
+type Extractor interface {
+	Extract(page Page) []Match
+}
+
+// ExtractorFunc adapts a function to the Extractor interface.
+type ExtractorFunc func(page Page) []Match
+
+// Extract calls f(page).
+func (f ExtractorFunc) Extract(page Page) []Match {
+	return f(page)
+}
+
+// RegexExtractor returns an Extractor that reports every match of re within
+// a line as a Match of the given kind.
+func RegexExtractor(kind string, re *regexp.Regexp) Extractor {
+	return ExtractorFunc(func(page Page) []Match {
+		var matches []Match
+		for _, line := range page.Lines {
+			for _, m := range re.FindAllStringIndex(line.Text, -1) {
+				matches = append(matches, Match{
+					Kind: kind,
+					Text: line.Text[m[0]:m[1]],
+					Page: page.Number,
+					BBox: line.TextRect(m[0], m[1]),
+				})
+			}
+		}
+		return matches
+	})
+}
+
+// Word is a space-separated run of a line's text.
+type Word struct {
+	Text string
+	BBox image.Rectangle
+	// Confidence is the mean of the word's character confidences, or the
+	// line's confidence without character data.
+	Confidence float64
+	// Chars are the word's characters; empty in text-only mode.
+	Chars []predictor.Char
+}
+
+// Words splits the line at spaces. Word boxes span the line's height and
+// are approximate horizontally; they are empty in text-only mode.
+func (l Line) Words() []Word {
+	var words []Word
+	start, first := -1, 0
+	runeIdx := 0
+	for i, r := range l.Text + " " {
+		if r == ' ' {
+			if start >= 0 {
+				w := Word{Text: l.Text[start:i], BBox: l.TextRect(start, i), Confidence: l.Confidence}
+				if runeIdx <= len(l.Chars) {
+					w.Chars = l.Chars[first:runeIdx]
+					var sum float64
+					for _, c := range w.Chars {
+						sum += c.Confidence
+					}
+					w.Confidence = sum / float64(len(w.Chars))
+				}
+				words = append(words, w)
+				start = -1
+			}
+		} else if start < 0 {
+			start, first = i, runeIdx
+		}
+		runeIdx++
+	}
+	return words
+}
+
+// MarkUncertain returns the line's text with each word whose confidence
+// is below threshold wrapped in open and close, such as "⟦" and "⟧", so
+// proofreaders can jump straight to the uncertain spots. Spacing is kept
+// as recognized.
+func (l Line) MarkUncertain(threshold float64, open, close string) string {
+	var b strings.Builder
+	rest := l.Text
+	for _, w := range l.Words() {
+		i := strings.Index(rest, w.Text)
+		b.WriteString(rest[:i])
+		if w.Confidence < threshold {
+			b.WriteString(open + w.Text + close)
+		} else {
+			b.WriteString(w.Text)
+		}
+		rest = rest[i+len(w.Text):]
+	}
+	b.WriteString(rest)
+	return b.String()
+}
+
+// TextRect returns the page area covering Text[start:end], given as byte
+// offsets. It is empty if the line has no character positions.
+func (l Line) TextRect(start, end int) image.Rectangle {
+	first := utf8.RuneCountInString(l.Text[:start])
+	last := utf8.RuneCountInString(l.Text[:end]) - 1
+	if last < first || last >= len(l.Chars) {
+		return image.Rectangle{}
+	}
+	return image.Rect(l.Chars[first].Start, l.BBox.Min.Y, l.Chars[last].End, l.BBox.Max.Y)
+}
+
+// extract runs the configured extractors over page.
+func (o *options) extract(page Page) []Match {
+	var matches []Match
+	for _, ex := range o.extractors {
+		matches = append(matches, ex.Extract(page)...)
+	}
+	return matches
+}
@@ -0,0 +1,111 @@
+package monocr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+)
+
+// lineHeightPerEm is the height of a line's box in ems. Mon stacks
+// medials and vowel signs above and below the base consonant, so a
+// line's ink spans well beyond the em of the font it was set in.
+const lineHeightPerEm = 1.4
+
+// setFontSizes estimates the point size of each line of a page scanned
+// at dpi from its height. Lines without a box are left at 0.
+func (p *Page) setFontSizes(dpi int) {
+	if dpi <= 0 {
+		return
+	}
+	p.DPI = dpi
+	for i := range p.Lines {
+		h := p.Lines[i].BBox.Dy()
+		if h <= 0 {
+			continue
+		}
+		pt := float64(h) / lineHeightPerEm * 72 / float64(dpi)
+		// Half points are as fine as type sizes go
+		p.Lines[i].FontSize = math.Round(pt*2) / 2
+	}
+}
+
+// imageDPI returns the resolution recorded in a PNG's pHYs chunk or a
+// JPEG's JFIF header, or 0 if the file records none.
+func imageDPI(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	magic, err := r.Peek(8)
+	if err != nil {
+		return 0
+	}
+	switch {
+	case bytes.Equal(magic, []byte("\x89PNG\r\n\x1a\n")):
+		return pngDPI(r)
+	case magic[0] == 0xff && magic[1] == 0xd8:
+		return jpegDPI(r)
+	}
+	return 0
+}
+
+// pngDPI reads the chunks before the image data looking for pHYs.
+func pngDPI(r *bufio.Reader) int {
+	if _, err := r.Discard(8); err != nil {
+		return 0
+	}
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return 0
+		}
+		length := binary.BigEndian.Uint32(header[:4])
+		switch string(header[4:]) {
+		case "pHYs":
+			var phys [9]byte
+			if length != 9 {
+				return 0
+			}
+			if _, err := io.ReadFull(r, phys[:]); err != nil {
+				return 0
+			}
+			// Unit 1 is pixels per metre; otherwise only the aspect ratio
+			// is known
+			if phys[8] != 1 {
+				return 0
+			}
+			return int(math.Round(float64(binary.BigEndian.Uint32(phys[:4])) * 0.0254))
+		case "IDAT", "IEND":
+			return 0
+		}
+		if _, err := r.Discard(int(length) + 4); err != nil {
+			return 0
+		}
+	}
+}
+
+// jpegDPI reads the JFIF APP0 segment that follows the start of image.
+func jpegDPI(r *bufio.Reader) int {
+	var app0 [18]byte
+	if _, err := io.ReadFull(r, app0[:]); err != nil {
+		return 0
+	}
+	// SOI, APP0 marker, length, "JFIF\0", version, units, X density
+	if app0[2] != 0xff || app0[3] != 0xe0 || string(app0[6:11]) != "JFIF\x00" {
+		return 0
+	}
+	density := float64(binary.BigEndian.Uint16(app0[14:16]))
+	switch app0[13] {
+	case 1:
+		return int(density)
+	case 2:
+		return int(math.Round(density * 2.54))
+	}
+	return 0
+}
@@ -0,0 +1,119 @@
+package monocr
+
+import (
+	"fmt"
+)
+
+// LabelStudioTask is a Label Studio task for one page, carrying the OCR
+// output as a prediction so annotators correct it instead of typing from
+// scratch. It targets the standard OCR labeling config:
+//
+//	<View>
+//	  <Image name="image" value="$image"/>
+//	  <Rectangle name="bbox" toName="image"/>
+//	  <TextArea name="transcription" toName="image" editable="true" perRegion="true"/>
+//	</View>
+type LabelStudioTask struct {
+	Data        LabelStudioData         `json:"data"`
+	Predictions []LabelStudioPrediction `json:"predictions"`
+	// Annotations holds the human-corrected results in Label Studio
+	// exports; it is empty in tasks produced by LabelStudioTasks.
+	Annotations []LabelStudioAnnotation `json:"annotations,omitempty"`
+}
+
+// LabelStudioAnnotation is an annotator's submitted result for a task.
+type LabelStudioAnnotation struct {
+	Result []LabelStudioRegion `json:"result"`
+}
+
+// LabelStudioData is the task's input data.
+type LabelStudioData struct {
+	Image string `json:"image"`
+	Page  int    `json:"page"`
+}
+
+// LabelStudioPrediction is a set of pre-annotations for a task.
+type LabelStudioPrediction struct {
+	ModelVersion string              `json:"model_version"`
+	Score        float64             `json:"score"`
+	Result       []LabelStudioRegion `json:"result"`
+}
+
+// LabelStudioRegion is one annotation result. Each line yields a rectangle
+// and a transcription sharing the same ID.
+type LabelStudioRegion struct {
+	ID             string           `json:"id"`
+	FromName       string           `json:"from_name"`
+	ToName         string           `json:"to_name"`
+	Type           string           `json:"type"`
+	OriginalWidth  int              `json:"original_width"`
+	OriginalHeight int              `json:"original_height"`
+	ImageRotation  int              `json:"image_rotation"`
+	Value          LabelStudioValue `json:"value"`
+	Score          float64          `json:"score,omitempty"`
+}
+
+// LabelStudioValue holds a region's geometry, in percent of the image
+// size, and for transcriptions the text.
+type LabelStudioValue struct {
+	X        float64  `json:"x"`
+	Y        float64  `json:"y"`
+	Width    float64  `json:"width"`
+	Height   float64  `json:"height"`
+	Rotation float64  `json:"rotation"`
+	Text     []string `json:"text,omitempty"`
+}
+
+// labelStudioModel is reported as the predictions' model version.
+const labelStudioModel = "monocr"
+
+// LabelStudioTasks converts r into one Label Studio task per page.
+// imageURL returns the URL Label Studio should load the page image from,
+// e.g. "/data/local-files/?d=" plus the page's Image path. Pages without
+// a size (text-only results) are skipped.
+func (r *Result) LabelStudioTasks(imageURL func(page Page) string) []LabelStudioTask {
+	var tasks []LabelStudioTask
+	for _, page := range r.Pages {
+		if page.Width == 0 || page.Height == 0 {
+			continue
+		}
+
+		pred := LabelStudioPrediction{
+			ModelVersion: labelStudioModel,
+			Score:        page.Confidence(),
+			Result:       []LabelStudioRegion{},
+		}
+		for i, line := range page.Lines {
+			b := line.BBox
+			value := LabelStudioValue{
+				X:      100 * float64(b.Min.X) / float64(page.Width),
+				Y:      100 * float64(b.Min.Y) / float64(page.Height),
+				Width:  100 * float64(b.Dx()) / float64(page.Width),
+				Height: 100 * float64(b.Dy()) / float64(page.Height),
+			}
+			region := LabelStudioRegion{
+				ID:             fmt.Sprintf("p%dl%d", page.Number, i+1),
+				ToName:         "image",
+				OriginalWidth:  page.Width,
+				OriginalHeight: page.Height,
+			}
+
+			rect := region
+			rect.FromName, rect.Type, rect.Value = "bbox", "rectangle", value
+
+			text := region
+			text.FromName, text.Type = "transcription", "textarea"
+			text.Value = value
+			text.Value.Text = []string{line.Text}
+			text.Score = line.Confidence
+
+			pred.Result = append(pred.Result, rect, text)
+		}
+
+		tasks = append(tasks, LabelStudioTask{
+			Data:        LabelStudioData{Image: imageURL(page), Page: page.Number},
+			Predictions: []LabelStudioPrediction{pred},
+		})
+	}
+	return tasks
+}
@@ -0,0 +1,32 @@
+package monocr
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// applyLimits sets CPU time and address space limits on a running process
+// with prlimit(2).
+func applyLimits(pid int, limits RenderLimits) error {
+	if limits.CPUSeconds > 0 {
+		if err := prlimit(pid, syscall.RLIMIT_CPU, limits.CPUSeconds); err != nil {
+			return fmt.Errorf("failed to set CPU limit: %v", err)
+		}
+	}
+	if limits.MemoryBytes > 0 {
+		if err := prlimit(pid, syscall.RLIMIT_AS, limits.MemoryBytes); err != nil {
+			return fmt.Errorf("failed to set memory limit: %v", err)
+		}
+	}
+	return nil
+}
+
+func prlimit(pid, resource int, value uint64) error {
+	rlim := syscall.Rlimit{Cur: value, Max: value}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&rlim)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
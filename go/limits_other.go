@@ -0,0 +1,9 @@
+//go:build !linux
+
+package monocr
+
+// applyLimits is a no-op where prlimit(2) is unavailable; only the timeout
+// in RenderLimits is enforced on these platforms.
+func applyLimits(pid int, limits RenderLimits) error {
+	return nil
+}
@@ -0,0 +1,83 @@
+package monocr
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// How a line ends, reported in Line.Break so renderers can reflow text.
+const (
+	// BreakNatural lines wrap mid-paragraph between words: joining them
+	// to the next line takes a space.
+	BreakNatural = "natural"
+	// BreakHyphen lines end in a hyphen splitting a word: joining them
+	// drops the hyphen and takes no space.
+	BreakHyphen = "hyphen"
+	// BreakParagraph lines end their paragraph.
+	BreakParagraph = "paragraph"
+)
+
+// hyphens are the characters a word split across lines may end with: the
+// hyphen-minus, the soft hyphen and the Unicode hyphen.
+const hyphens = "-\u00ad\u2010"
+
+// shortLine is the share of the text block's width below which a line is
+// taken to end its paragraph.
+const shortLine = 0.8
+
+// markBreaks sets Break on lines in reading order. A line ends its
+// paragraph when it stops well short of the right edge of the text, is
+// followed by a gap of at least a line's height, or the next line is
+// indented. Without boxes, as in text-only mode, only hyphens are found.
+func markBreaks(lines []Line) {
+	var heights []int
+	var left, right int
+	for _, l := range lines {
+		if l.BBox.Empty() {
+			continue
+		}
+		if len(heights) == 0 {
+			left, right = l.BBox.Min.X, l.BBox.Max.X
+		}
+		heights = append(heights, l.BBox.Dy())
+		left = min(left, l.BBox.Min.X)
+		right = max(right, l.BBox.Max.X)
+	}
+	geometry := len(heights) > 0
+	var lineHeight int
+	if geometry {
+		sort.Ints(heights)
+		lineHeight = heights[len(heights)/2]
+	}
+
+	for i := range lines {
+		l := &lines[i]
+		text := strings.TrimSpace(l.Text)
+		switch {
+		case text != "" && strings.ContainsRune(hyphens, last(text)):
+			l.Break = BreakHyphen
+		case !geometry || l.BBox.Empty():
+			l.Break = ""
+		case float64(l.BBox.Max.X-left) < shortLine*float64(right-left):
+			l.Break = BreakParagraph
+		case i+1 < len(lines) && !lines[i+1].BBox.Empty() && paragraphStarts(l.BBox.Min.X, l.BBox.Max.Y, lines[i+1], lineHeight):
+			l.Break = BreakParagraph
+		default:
+			l.Break = BreakNatural
+		}
+	}
+}
+
+// paragraphStarts reports whether next, following a line at x ending at
+// y, starts a paragraph: it is a line's height further down or indented
+// by half of one.
+func paragraphStarts(x, y int, next Line, lineHeight int) bool {
+	return next.BBox.Min.Y-y >= lineHeight || next.BBox.Min.X-x >= lineHeight/2
+}
+
+// last returns the last rune of s.
+func last(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
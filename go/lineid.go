@@ -0,0 +1,53 @@
+package monocr
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// Line IDs hash where a line's center falls on a grid laid over its page,
+// idGridX cells across and idGridY down. Lines are much taller than a
+// row, so neighbours land in different rows, while the small shifts in
+// segmentation between runs or render resolutions stay within a cell.
+const (
+	idGridX = 50
+	idGridY = 100
+)
+
+// setLineIDs fills in Line.ID for every line of the page with a box. It
+// must run once the page's number and size are final.
+func (p *Page) setLineIDs() {
+	if p.Width <= 0 || p.Height <= 0 {
+		return
+	}
+	seen := make(map[string]int)
+	for i := range p.Lines {
+		line := &p.Lines[i]
+		if line.BBox.Empty() {
+			continue
+		}
+		center := line.BBox.Min.Add(line.BBox.Max).Div(2)
+		h := fnv.New32a()
+		fmt.Fprintf(h, "%d,%d", center.X*idGridX/p.Width, center.Y*idGridY/p.Height)
+		id := fmt.Sprintf("p%d-%08x", p.Number, h.Sum32())
+		// Lines sharing a cell are told apart by reading order
+		if seen[id]++; seen[id] > 1 {
+			id = fmt.Sprintf("%s-%d", id, seen[id])
+		}
+		line.ID = id
+	}
+}
+
+// LineByID returns the line of r with the given Line.ID, or nil if there
+// is none, for example to re-apply a correction made against an earlier
+// run to the same document read again.
+func (r *Result) LineByID(id string) *Line {
+	for i := range r.Pages {
+		for j := range r.Pages[i].Lines {
+			if r.Pages[i].Lines[j].ID == id {
+				return &r.Pages[i].Lines[j]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package monocr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// BatchInput is one file of a batch manifest. Metadata is arbitrary JSON
+// from the caller's catalog (collection id, box and folder numbers, ...)
+// that is carried through to the output untouched, so results can be
+// joined back to their records.
+type BatchInput struct {
+	Path     string          `json:"path"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// LoadBatchManifest reads a JSON batch manifest of the form
+//
+//	[{"path": "box-12/folder-3/0001.png", "metadata": {"collection": "MS-44", "box": 12}}]
+//
+// Relative paths are resolved against the manifest's directory.
+func LoadBatchManifest(manifestPath string) ([]BatchInput, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []BatchInput
+	if err := json.Unmarshal(data, &inputs); err != nil {
+		return nil, fmt.Errorf("failed to parse batch manifest: %v", err)
+	}
+
+	base := filepath.Dir(manifestPath)
+	for i, in := range inputs {
+		if in.Path == "" {
+			return nil, fmt.Errorf("batch manifest entry %d has no path", i)
+		}
+		if !filepath.IsAbs(in.Path) {
+			inputs[i].Path = filepath.Join(base, in.Path)
+		}
+	}
+	return inputs, nil
+}
@@ -0,0 +1,147 @@
+package monocr
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metadataPages is how many leading pages are read to find the title page
+// and imprint.
+const metadataPages = 4
+
+// BookMetadata is a cataloguing guess for a scanned book. Fields are left
+// empty when nothing plausible was found.
+type BookMetadata struct {
+	Title  string `json:"title,omitempty"`
+	Author string `json:"author,omitempty"`
+	Year   int    `json:"year,omitempty"`
+	Pages  int    `json:"pages,omitempty"`
+	// Source records where each field came from: "pdf" or "ocr".
+	Source map[string]string `json:"source,omitempty"`
+	// PDF is the document information dictionary reported by pdfinfo.
+	PDF map[string]string `json:"pdf,omitempty"`
+}
+
+// ExtractMetadata combines the PDF's information dictionary with OCR of its
+// first pages to guess title, author and publication year. The OCR
+// heuristics assume a conventional title page: the largest type is the
+// title and the line below it names the author.
+func ExtractMetadata(pdfPath string, opts ...Option) (*BookMetadata, error) {
+	info, err := pdfInfo(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewReader(opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	o := *r.o
+	o.textOnly = false
+	o.lastPage = metadataPages
+	result, err := readPDFResult(context.Background(), r.pred, pdfPath, &o)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &BookMetadata{PDF: info, Source: make(map[string]string)}
+	meta.Pages, _ = strconv.Atoi(info["Pages"])
+
+	if title := info["Title"]; title != "" && !placeholderTitle(title) {
+		meta.Title = title
+		meta.Source["title"] = "pdf"
+	}
+	if author := info["Author"]; author != "" {
+		meta.Author = author
+		meta.Source["author"] = "pdf"
+	}
+
+	title, author := titlePage(result)
+	if meta.Title == "" && title != "" {
+		meta.Title = title
+		meta.Source["title"] = "ocr"
+	}
+	if meta.Author == "" && author != "" {
+		meta.Author = author
+		meta.Source["author"] = "ocr"
+	}
+
+	// The PDF's dates describe the scan, not the edition, so the year only
+	// comes from the printed imprint.
+	if year := findYear(strings.Join(result.Texts(), "\n")); year > 0 {
+		meta.Year = year
+		meta.Source["year"] = "ocr"
+	}
+
+	return meta, nil
+}
+
+// titlePage picks the tallest confidently read line on the leading pages as
+// the title, and the next line on the same page as the author.
+func titlePage(result *Result) (title, author string) {
+	bestHeight := 0
+	for _, page := range result.Pages {
+		for i, line := range page.Lines {
+			if strings.TrimSpace(line.Text) == "" || line.Confidence < lowConfidence {
+				continue
+			}
+			if h := line.BBox.Dy(); h > bestHeight {
+				bestHeight = h
+				title = strings.TrimSpace(line.Text)
+				author = ""
+				for _, next := range page.Lines[i+1:] {
+					if text := strings.TrimSpace(next.Text); text != "" && next.Confidence >= lowConfidence {
+						author = text
+						break
+					}
+				}
+			}
+		}
+	}
+	return title, author
+}
+
+// placeholderTitle reports whether a PDF title was filled in by the
+// authoring or scanning software rather than the publisher.
+func placeholderTitle(title string) bool {
+	lower := strings.ToLower(title)
+	for _, marker := range []string{"untitled", "microsoft word", ".doc", ".pdf", ".tif", "scan"} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+var yearPattern = regexp.MustCompile(`(^|[^0-9])([12][0-9]{3})($|[^0-9])`)
+
+// findYear returns the earliest plausible year in text, reading Myanmar
+// script digits (used by Mon) as well as ASCII ones.
+func findYear(text string) int {
+	text = asciiDigits(text)
+
+	latest := time.Now().Year() + 1
+	year := 0
+	for _, m := range yearPattern.FindAllStringSubmatch(text, -1) {
+		y, _ := strconv.Atoi(m[2])
+		if y >= 1800 && y <= latest && (year == 0 || y < year) {
+			year = y
+		}
+	}
+	return year
+}
+
+// asciiDigits replaces Myanmar script digits in text with ASCII ones.
+func asciiDigits(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '၀' && r <= '၉' {
+			return '0' + (r - '၀')
+		}
+		return r
+	}, text)
+}
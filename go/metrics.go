@@ -0,0 +1,114 @@
+package monocr
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Myanmar-script code points that join graphemes beyond the general
+// combining mark rule.
+const (
+	myanmarVirama = '\u1039' // stacks the next consonant below
+	zeroWidthJoin = '\u200d'
+)
+
+// GraphemeErrorRate is CharErrorRate over grapheme clusters instead of
+// runes: a consonant with its vowel signs, medials and stacked consonant
+// counts as one unit, so a single misread mark doesn't cost several
+// errors.
+func GraphemeErrorRate(pred, truth string) float64 {
+	return errorRate(graphemes(pred), graphemes(truth))
+}
+
+// WordErrorRate returns the word-level edit distance of pred against
+// truth divided by the number of words in truth. Words are separated by
+// spaces, and punctuation such as ၊ and ။ counts as a word of its own.
+func WordErrorRate(pred, truth string) float64 {
+	return errorRate(words(pred), words(truth))
+}
+
+// GraphemeCount returns the number of grapheme clusters in s, the
+// length GraphemeErrorRate divides by.
+func GraphemeCount(s string) int {
+	return len(graphemes(s))
+}
+
+// WordCount returns the number of words in s, the length WordErrorRate
+// divides by.
+func WordCount(s string) int {
+	return len(words(s))
+}
+
+// errorRate returns the edit distance of pred from truth divided by the
+// length of truth, or 0 or 1 for an empty truth.
+func errorRate[T comparable](pred, truth []T) float64 {
+	if len(truth) == 0 {
+		if len(pred) == 0 {
+			return 0
+		}
+		return 1
+	}
+	return float64(levenshtein(pred, truth)) / float64(len(truth))
+}
+
+// graphemes splits s into user-perceived characters: the extended
+// grapheme clusters of Unicode Standard Annex #29, simplified to the
+// rules that matter for Mon and Burmese text. A cluster continues over
+// combining marks, zero width joiners and variation selectors, keeps CR
+// LF together, and also joins a Myanmar virama to the consonant it
+// stacks.
+func graphemes(s string) []string {
+	var clusters []string
+	start := 0
+	var prev rune = -1
+	for i, r := range s {
+		if prev >= 0 && !joins(prev, r) {
+			clusters = append(clusters, s[start:i])
+			start = i
+		}
+		prev = r
+	}
+	if start < len(s) {
+		clusters = append(clusters, s[start:])
+	}
+	return clusters
+}
+
+// joins reports whether r continues the grapheme cluster ending in prev.
+func joins(prev, r rune) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return true
+	case prev == myanmarVirama || prev == zeroWidthJoin:
+		return unicode.IsLetter(r) || unicode.IsMark(r)
+	case r == zeroWidthJoin || unicode.Is(unicode.Variation_Selector, r):
+		return true
+	case unicode.IsControl(prev):
+		return false
+	}
+	return unicode.IsMark(r)
+}
+
+// words splits s into words for WordErrorRate.
+func words(s string) []string {
+	var out []string
+	for _, field := range strings.Fields(s) {
+		start := 0
+		for i, r := range field {
+			if !unicode.IsPunct(r) {
+				continue
+			}
+			if start < i {
+				out = append(out, field[start:i])
+			}
+			end := i + utf8.RuneLen(r)
+			out = append(out, field[i:end])
+			start = end
+		}
+		if start < len(field) {
+			out = append(out, field[start:])
+		}
+	}
+	return out
+}
@@ -1,18 +1,23 @@
 package monocr
 
 import (
+	"bytes"
+	"context"
 	_ "embed"
 	"fmt"
 	"image"
 	_ "image/jpeg"
 	_ "image/png"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
-	"github.com/MonDevHub/monocr-onnx/go/pkg/model"
 	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/quality"
 	"github.com/MonDevHub/monocr-onnx/go/pkg/segmenter"
 )
 
@@ -21,41 +26,62 @@ var embeddedCharset string
 
 // ReadImage recognizes text from an image file.
 // It automatically downloads the model if not present.
-func ReadImage(imagePath string) (string, error) {
-	manager, err := model.NewManager()
+func ReadImage(imagePath string, opts ...Option) (string, error) {
+	return ReadImageContext(context.Background(), imagePath, opts...)
+}
+
+// ReadImageContext is ReadImage with cancellation. Loading the model is not
+// interruptible; ctx is checked before inference starts.
+func ReadImageContext(ctx context.Context, imagePath string, opts ...Option) (string, error) {
+	r, err := NewReader(opts...)
 	if err != nil {
 		return "", err
 	}
+	defer r.Close()
+
+	return r.ReadImageContext(ctx, imagePath)
+}
 
-	modelPath, err := manager.GetModelPath()
+// ReadImageFrom recognizes text from an encoded PNG or JPEG image read
+// from r, such as an upload body, without touching the disk.
+func ReadImageFrom(r io.Reader, opts ...Option) (string, error) {
+	reader, err := NewReader(opts...)
 	if err != nil {
 		return "", err
 	}
+	defer reader.Close()
 
-	return ReadImageWithModel(imagePath, modelPath, strings.TrimSpace(embeddedCharset))
+	return reader.ReadImageFrom(r)
 }
 
-// ReadImages recognizes text from multiple image files.
-func ReadImages(imagePaths []string) ([]string, error) {
-	manager, err := model.NewManager()
-	if err != nil {
-		return nil, err
-	}
+// ReadImageBytes recognizes text from an encoded PNG or JPEG image.
+func ReadImageBytes(data []byte, opts ...Option) (string, error) {
+	return ReadImageFrom(bytes.NewReader(data), opts...)
+}
 
-	modelPath, err := manager.GetModelPath()
+// RecognizeWithLayout segments an already decoded image into lines and
+// returns them with their rectangles, text and confidence.
+func RecognizeWithLayout(img image.Image, opts ...Option) (Page, error) {
+	r, err := NewReader(opts...)
 	if err != nil {
-		return nil, err
+		return Page{}, err
 	}
+	defer r.Close()
+
+	return r.RecognizeWithLayout(img)
+}
 
-	pred, err := predictor.NewPredictor(modelPath, embeddedCharset)
+// ReadImages recognizes text from multiple image files.
+func ReadImages(imagePaths []string, opts ...Option) ([]string, error) {
+	r, err := NewReader(opts...)
 	if err != nil {
 		return nil, err
 	}
-	defer pred.Close()
+	defer r.Close()
 
 	var results []string
 	for _, path := range imagePaths {
-		text, err := predictFile(pred, path)
+		text, err := r.ReadImage(path)
 		if err != nil {
 			return nil, err
 		}
@@ -65,8 +91,8 @@ func ReadImages(imagePaths []string) ([]string, error) {
 }
 
 // ReadImageWithAccuracy recognizes text and calculates accuracy against ground truth.
-func ReadImageWithAccuracy(imagePath, groundTruth string) (string, float64, error) {
-	text, err := ReadImage(imagePath)
+func ReadImageWithAccuracy(imagePath, groundTruth string, opts ...Option) (string, float64, error) {
+	text, err := ReadImage(imagePath, opts...)
 	if err != nil {
 		return "", 0, err
 	}
@@ -75,153 +101,623 @@ func ReadImageWithAccuracy(imagePath, groundTruth string) (string, float64, erro
 }
 
 // ReadImageWithModel allows specifying custom model and charset paths.
-func ReadImageWithModel(imagePath, modelPath, charset string) (string, error) {
-	pred, err := predictor.NewPredictor(modelPath, charset)
+func ReadImageWithModel(imagePath, modelPath, charset string, opts ...Option) (string, error) {
+	r, err := NewReaderWithModel(modelPath, charset, opts...)
 	if err != nil {
 		return "", err
 	}
-	defer pred.Close()
+	defer r.Close()
 
-	return predictFile(pred, imagePath)
+	return r.ReadImage(imagePath)
 }
 
-func predictFile(pred *predictor.Predictor, imagePath string) (string, error) {
-	f, err := os.Open(imagePath)
+// ReadImageResult recognizes an image file as a single line and returns a
+// one-page Result carrying the line's confidence.
+func ReadImageResult(imagePath string, opts ...Option) (*Result, error) {
+	r, err := NewReader(opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return r.ReadImageResult(imagePath)
+}
+
+// ReadLargeImage recognizes a very large page image (e.g. a map scan) by
+// segmenting it in horizontal bands of bandHeight pixels, so that only one
+// band's worth of intermediate buffers is alive at any time. Non-interlaced
+// PNGs are also decoded band by band, in grayscale; other formats, and
+// pages that are preprocessed, are decoded whole first. Rotation isn't
+// supported and returns an error.
+func ReadLargeImage(imagePath string, bandHeight int, opts ...Option) (string, error) {
+	r, err := NewReader(opts...)
 	if err != nil {
 		return "", err
 	}
-	defer f.Close()
+	defer r.Close()
+
+	return r.ReadLargeImage(imagePath, bandHeight)
+}
 
-	img, _, err := image.Decode(f)
+func decodeFile(imagePath string) (image.Image, error) {
+	f, err := os.Open(imagePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode image: %v", err)
+		return nil, err
 	}
+	defer f.Close()
 
-	return pred.Predict(img)
+	return decodeReader(f)
 }
 
-// ReadPDF recognizes text from a PDF file (requires pdftoppm/poppler-utils).
-func ReadPDF(pdfPath string) ([]string, error) {
-	// Check for pdftoppm
-	_, err := exec.LookPath("pdftoppm")
+func decodeReader(r io.Reader) (image.Image, error) {
+	img, _, err := image.Decode(r)
 	if err != nil {
-		return nil, fmt.Errorf("pdftoppm not found: please install poppler-utils")
+		return nil, fmt.Errorf("failed to decode image: %v", err)
 	}
+	return img, nil
+}
 
-	manager, err := model.NewManager()
+func predictFile(ctx context.Context, pred predictor.Recognizer, imagePath string, o *options) (string, error) {
+	img, err := decodeFile(imagePath)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	return predictImage(ctx, pred, img, o)
+}
 
-	modelPath, err := manager.GetModelPath()
+func predictImage(ctx context.Context, pred predictor.Recognizer, img image.Image, o *options) (string, error) {
+	img, err := o.orientImage(pred, img)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	return readPDFWithModel(pdfPath, modelPath, strings.TrimSpace(embeddedCharset))
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return pred.Predict(img)
 }
 
-// ReadPDFs recognizes text from multiple PDF files.
-func ReadPDFs(pdfPaths []string) ([][]string, error) {
+// ReadPDF recognizes text from a PDF file (requires pdftoppm/poppler-utils).
+func ReadPDF(pdfPath string, opts ...Option) ([]string, error) {
+	return ReadPDFContext(context.Background(), pdfPath, opts...)
+}
+
+// ReadPDFContext is ReadPDF with cancellation and deadlines. When ctx is
+// done, the pdftoppm process group is killed or recognition stops before
+// the next line; the pages read so far are returned with an error wrapping
+// ErrCancelled and ctx's error.
+func ReadPDFContext(ctx context.Context, pdfPath string, opts ...Option) ([]string, error) {
 	// Check for pdftoppm
-	_, err := exec.LookPath("pdftoppm")
+	_, err := findPoppler("pdftoppm")
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewReader(opts...)
 	if err != nil {
-		return nil, fmt.Errorf("pdftoppm not found: please install poppler-utils")
+		return nil, err
 	}
+	defer r.Close()
+
+	return r.ReadPDFContext(ctx, pdfPath)
+}
 
-	manager, err := model.NewManager()
+// ReadPDFs recognizes text from multiple PDF files. It stops at the first
+// file that fails, returning the files read so far, and that file's
+// finished pages if any, with an error naming it.
+func ReadPDFs(pdfPaths []string, opts ...Option) ([][]string, error) {
+	// Check for pdftoppm
+	_, err := findPoppler("pdftoppm")
 	if err != nil {
 		return nil, err
 	}
 
-	modelPath, err := manager.GetModelPath()
+	r, err := NewReader(opts...)
 	if err != nil {
 		return nil, err
 	}
+	defer r.Close()
 
 	var results [][]string
 	for _, path := range pdfPaths {
-		pages, err := readPDFWithModel(path, modelPath, strings.TrimSpace(embeddedCharset))
+		pages, err := r.ReadPDF(path)
 		if err != nil {
-			return nil, err
+			// Keep what was read, including the failed file's first pages
+			if pages != nil {
+				results = append(results, pages)
+			}
+			return results, fmt.Errorf("%s: %w", path, err)
 		}
 		results = append(results, pages)
 	}
 	return results, nil
 }
 
-func readPDFWithModel(pdfPath, modelPath, charset string) ([]string, error) {
-	// Create temp dir
-	tempDir, err := os.MkdirTemp("", "monocr-go-")
+// ReadPDFResult recognizes a PDF file and returns per-line text, confidence
+// and bounding boxes for every page. If a page fails, the pages before it
+// are returned along with a *PartialError.
+func ReadPDFResult(pdfPath string, opts ...Option) (*Result, error) {
+	// Check for pdftoppm
+	_, err := findPoppler("pdftoppm")
 	if err != nil {
 		return nil, err
 	}
-	defer os.RemoveAll(tempDir)
 
-	// Convert PDF to images
-	cmd := exec.Command("pdftoppm", "-png", "-r", "300", pdfPath, filepath.Join(tempDir, "page"))
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to convert PDF: %v", err)
+	r, err := NewReader(opts...)
+	if err != nil {
+		return nil, err
 	}
+	defer r.Close()
 
-	// Read all generated images
-	files, err := os.ReadDir(tempDir)
+	return r.ReadPDFResult(pdfPath)
+}
+
+// readPDFResult renders and recognizes pdfPath. If ctx is done or a page
+// fails part way through, it returns the pages finished so far together
+// with a *CancelledError or *PartialError holding the same partial result.
+func readPDFResult(ctx context.Context, pred predictor.Recognizer, pdfPath string, o *options) (*Result, error) {
+	result := &Result{}
+
+	// With adaptive DPI, pages are first rendered at low resolution and
+	// judged by their confidence, even if only text was asked for.
+	render, recognize := o, o
+	if o.adaptiveDPI > 0 {
+		low := *o
+		low.dpi = lowDPI
+		render = &low
+
+		scored := *o
+		scored.textOnly = false
+		recognize = &scored
+	}
+	// Running lines are found by their position, so they need line boxes
+	if o.runningLines != 0 && recognize.textOnly {
+		positioned := *recognize
+		positioned.textOnly = false
+		recognize = &positioned
+	}
+
+	var layers map[int]Page
+	if o.textLayer {
+		var complete bool
+		var err error
+		layers, complete, err = readTextLayer(ctx, pdfPath, o)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, cancelled(result, ctx.Err())
+			}
+			result.warn(0, "text layer not read: %v", err)
+		} else if complete {
+			// Every page has text, so nothing needs rendering
+			numbers := make([]int, 0, len(layers))
+			for n := range layers {
+				numbers = append(numbers, n)
+			}
+			sort.Ints(numbers)
+			for _, n := range numbers {
+				result.Pages = append(result.Pages, layers[n])
+				result.Matches = append(result.Matches, o.extract(layers[n])...)
+			}
+			o.reportProgress(len(numbers), len(numbers))
+			finishPDFResult(result, o)
+			return result, nil
+		}
+	}
+
+	// Convert PDF to images
+	pageDir, cleanup, err := renderPDF(ctx, pdfPath, render)
 	if err != nil {
+		if ctx.Err() != nil {
+			return result, cancelled(result, ctx.Err())
+		}
 		return nil, err
 	}
+	defer cleanup()
 
-	pred, err := predictor.NewPredictor(modelPath, charset)
+	// Read all generated images
+	files, err := os.ReadDir(pageDir)
 	if err != nil {
 		return nil, err
 	}
-	defer pred.Close()
 
 	seg := segmenter.NewLineSegmenter(10, 3)
 
-	var results []string
+	var layouts map[int]pdfPageLayout
+	if o.pageTypes {
+		layouts, err = analyzePDF(ctx, pdfPath, o, false)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, cancelled(result, ctx.Err())
+			}
+			result.warn(0, "page types not detected: %v", err)
+		}
+	}
+
+	// Pages go by the number in their file name, not listing order
+	type renderedPage struct {
+		name   string
+		number int
+	}
+	var pages []renderedPage
 	for _, file := range files {
 		if strings.HasSuffix(file.Name(), ".png") {
-			imgPath := filepath.Join(tempDir, file.Name())
+			pages = append(pages, renderedPage{file.Name(), pageNumber(file.Name(), len(pages)+1)})
+		}
+	}
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].number < pages[j].number })
+
+	// readPage decodes, orients and recognizes the i-th rendered page
+	readPage := func(ctx context.Context, i int) *pageOutcome {
+		out := &pageOutcome{}
+		imgPath := filepath.Join(pageDir, pages[i].name)
+		number := pages[i].number
+		if page, ok := layers[number]; ok {
+			out.page = page
+			return out
+		}
 
-			// Open image for segmentation
-			f, err := os.Open(imgPath)
-			if err != nil {
-				continue
+		// Open image for segmentation
+		img, err := decodeFile(imgPath)
+		if err != nil {
+			out.skipped = err
+			return out
+		}
+
+		img, err = o.orientImage(pred, img)
+		if err != nil {
+			out.fatal = err
+			return out
+		}
+
+		var page Page
+		var warnings []string
+		layout, hasLayout := layouts[number]
+		// Rotated pages no longer match the layout's coordinates
+		fullOCR := !hasLayout || o.rotates() || layout.Type() == PageScanned
+		if fullOCR {
+			page, warnings, err = recognizePage(ctx, pred, seg, img, recognize)
+		} else {
+			page, warnings, err = recognizeLayoutPage(ctx, pred, seg, img, layout, recognize)
+		}
+		if o.renderCache != "" && o.firstPage == 0 && o.lastPage == 0 && !o.rotates() {
+			page.Image = imgPath
+		}
+		if err == nil && fullOCR && o.adaptiveDPI > 0 && page.Confidence() < o.adaptiveDPI {
+			// A re-rendered page comes back without Image, since its
+			// boxes no longer match the cached render
+			page, img, warnings, err = rerenderPage(ctx, pred, seg, pdfPath, number, page, img, warnings, recognize)
+		}
+		if hasLayout {
+			page.Type = layout.Type()
+		}
+		page.Number = number
+		// Re-rendered pages carry their own DPI
+		dpi := page.DPI
+		if dpi == 0 {
+			dpi = render.renderDPI()
+		}
+		page.setFontSizes(dpi)
+		page.setLineIDs()
+		if err == nil && !o.textOnly {
+			page.Quality = assessPage(img, page)
+		}
+		out.page, out.warnings, out.err = page, warnings, err
+		return out
+	}
+
+	// Pages are read ahead by the workers and collected in order. Workers
+	// still running when this returns are stopped and waited for, since
+	// they read from pageDir and run the session, which the caller may
+	// remove and close as soon as this returns.
+	workCtx, stop := context.WithCancel(ctx)
+	next, wait := readPages(workCtx, len(pages), o.pageWorkers, readPage)
+	defer func() {
+		stop()
+		wait()
+	}()
+
+	for i := range pages {
+		if err := ctx.Err(); err != nil {
+			return result, cancelled(result, err)
+		}
+		o.reportProgress(i, len(pages))
+		out := next(i)
+		if out == nil {
+			return result, cancelled(result, ctx.Err())
+		}
+		number := pages[i].number
+
+		if out.skipped != nil {
+			result.warn(number, "page skipped: %v", out.skipped)
+			continue
+		}
+		if out.fatal != nil {
+			return result, failedAt(result, number, out.fatal)
+		}
+		page := out.page
+		if out.err != nil {
+			// Keep the lines read before cancellation
+			result.warn(number, "recognition cancelled after %d lines", len(page.Lines))
+			result.Pages = append(result.Pages, page)
+			return result, cancelled(result, out.err)
+		}
+		for _, w := range out.warnings {
+			result.warn(number, "%s", w)
+		}
+		if !o.textOnly {
+			result.warnPage(page)
+		}
+		result.Pages = append(result.Pages, page)
+		result.Matches = append(result.Matches, o.extract(page)...)
+	}
+
+	o.reportProgress(len(pages), len(pages))
+	finishPDFResult(result, o)
+	return result, nil
+}
+
+// finishPDFResult runs the document-level passes over a complete result.
+func finishPDFResult(result *Result, o *options) {
+	// Page numbers are read before running lines, which include them,
+	// may be stripped
+	if !o.textOnly {
+		result.DetectPageNumbers()
+	}
+	o.applyRunningLines(result)
+}
+
+// pageOutcome is the reading of one rendered PDF page.
+type pageOutcome struct {
+	page     Page
+	warnings []string
+	// skipped is why the page image could not be read; the document
+	// goes on without it.
+	skipped error
+	// fatal stops the whole document, which is returned incomplete.
+	fatal error
+	// err is the cancellation that cut page short.
+	err error
+}
+
+// readPages runs read for pages 0 to n-1 on workers goroutines, at least
+// one, and returns a function that waits for page i's outcome, so the
+// caller collects pages in order while later ones are read ahead, and a
+// function that waits for the workers to exit. Each outcome can be
+// collected once. Workers stop taking pages once ctx is done, and next
+// returns nil for a page that was never read.
+func readPages(ctx context.Context, n, workers int, read func(ctx context.Context, i int) *pageOutcome) (next func(i int) *pageOutcome, wait func()) {
+	workers = max(1, min(workers, n))
+	outcomes := make([]*pageOutcome, n)
+	dones := make([]chan struct{}, n)
+	for i := range dones {
+		dones[i] = make(chan struct{})
+	}
+
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for i := 0; i < n; i++ {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				// Pages no worker took are done without an outcome
+				for ; i < n; i++ {
+					close(dones[i])
+				}
+				return
 			}
-			img, _, err := image.Decode(f)
-			f.Close()
-			if err != nil {
-				continue
+		}
+	}()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				outcomes[i] = read(ctx, i)
+				close(dones[i])
 			}
+		}()
+	}
+	next = func(i int) *pageOutcome {
+		<-dones[i]
+		out := outcomes[i]
+		// Release the page once collected
+		outcomes[i] = nil
+		return out
+	}
+	return next, wg.Wait
+}
 
-			// Segment lines
-			lines, err := seg.Segment(img)
-			if err != nil || len(lines) == 0 {
-				// Fallback to full page prediction (single line assumption)
-				text, err := pred.Predict(img)
-				if err == nil {
-					results = append(results, text)
-				}
+// rerenderPage renders page number of pdfPath again at full resolution and
+// recognizes it, returning whichever of the two readings is more confident.
+func rerenderPage(ctx context.Context, pred predictor.Recognizer, seg *segmenter.LineSegmenter, pdfPath string, number int, page Page, img image.Image, warnings []string, o *options) (Page, image.Image, []string, error) {
+	high := *o
+	high.firstPage, high.lastPage = number, number
+
+	dir, cleanup, err := renderPDF(ctx, pdfPath, &high)
+	if err != nil {
+		if ctx.Err() != nil {
+			return page, img, warnings, ctx.Err()
+		}
+		return page, img, append(warnings, fmt.Sprintf("re-render at %d DPI failed: %v", high.renderDPI(), err)), nil
+	}
+	defer cleanup()
+
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) == 0 {
+		return page, img, warnings, nil
+	}
+	hiImg, err := decodeFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		return page, img, warnings, nil
+	}
+	hiImg, err = o.orientImage(pred, hiImg)
+	if err != nil {
+		return page, img, warnings, nil
+	}
+
+	hiPage, hiWarnings, err := recognizePage(ctx, pred, seg, hiImg, o)
+	if err != nil {
+		return page, img, warnings, err
+	}
+	if hiPage.Confidence() <= page.Confidence() {
+		return page, img, warnings, nil
+	}
+	hiPage.DPI = high.renderDPI()
+	hiWarnings = append(hiWarnings, fmt.Sprintf("re-rendered at %d DPI (confidence %.2f -> %.2f)", high.renderDPI(), page.Confidence(), hiPage.Confidence()))
+	return hiPage, hiImg, hiWarnings, nil
+}
+
+// recognizePage segments img into lines and recognizes each of them with
+// the segment and recognize stages of the default Pipeline. The returned
+// warnings describe anything that degraded the result. It stops early
+// with ctx's error if ctx is done.
+func recognizePage(ctx context.Context, pred predictor.Recognizer, seg *segmenter.LineSegmenter, img image.Image, o *options) (Page, []string, error) {
+	s := &pageStages{pred: pred, seg: seg, o: o}
+	doc := &Document{Image: img}
+	err := s.segment(ctx, doc)
+	if err == nil {
+		err = s.recognize(ctx, doc)
+	}
+	return doc.Page, doc.Warnings, err
+}
+
+// recognizeBatches recognizes lines o.batchSize at a time, grouping lines
+// of similar width. A batch that fails is read again line by line so one
+// bad line doesn't fail its neighbors. It returns the lines read, in no
+// particular order, and how many failed.
+func recognizeBatches(ctx context.Context, pred predictor.BatchRecognizer, lines []Segment, o *options) ([]Line, int, error) {
+	sorted := make([]Segment, len(lines))
+	copy(sorted, lines)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Image.Bounds().Dx() < sorted[j].Image.Bounds().Dx()
+	})
+
+	var out []Line
+	failed := 0
+	for start := 0; start < len(sorted); start += o.batchSize {
+		if err := ctx.Err(); err != nil {
+			return out, failed, err
+		}
+		chunk := sorted[start:min(start+o.batchSize, len(sorted))]
+		imgs := make([]image.Image, len(chunk))
+		for i, l := range chunk {
+			imgs[i] = l.Image
+		}
+
+		preds, batchErr := pred.PredictBatch(imgs)
+		for i, l := range chunk {
+			if batchErr == nil {
+				out = append(out, finishLine(pred, l.Image, l.BBox, preds[i], o))
 				continue
 			}
-
-			// Predict each line
-			var pageLines []string
-			for _, line := range lines {
-				text, err := pred.Predict(line.Img)
-				if err == nil {
-					pageLines = append(pageLines, text)
-				}
+			line, err := recognizeLine(pred, l.Image, l.BBox, o)
+			if err != nil {
+				failed++
+				continue
 			}
-			results = append(results, strings.Join(pageLines, "\n"))
+			out = append(out, line)
 		}
 	}
+	return out, failed, nil
+}
 
-	return results, nil
+// orderLines sorts lines into reading order, top to bottom and then left
+// to right, numbers them in Line.Order and marks how each ends in
+// Line.Break.
+func orderLines(lines []Line) {
+	sort.SliceStable(lines, func(i, j int) bool {
+		a, b := lines[i].BBox, lines[j].BBox
+		if a.Min.Y != b.Min.Y {
+			return a.Min.Y < b.Min.Y
+		}
+		return a.Min.X < b.Min.X
+	})
+	for i := range lines {
+		lines[i].Order = i
+	}
+	markBreaks(lines)
+}
+
+// assessPage measures the quality of a page image using its line heights.
+func assessPage(img image.Image, page Page) *quality.Metrics {
+	heights := make([]int, 0, len(page.Lines))
+	for _, line := range page.Lines {
+		heights = append(heights, line.BBox.Dy())
+	}
+	m := quality.Assess(img, heights)
+	return &m
 }
 
-// Levenshtein distance calculation
-func levenshtein(s1, s2 []rune) int {
+// recognizeLine recognizes a single line image, retrying with alternate
+// preprocessing when the reading is empty or weak, and masks what is
+// below WithMinConfidence. In text-only mode the confidence and box are
+// left empty.
+func recognizeLine(pred predictor.Recognizer, img image.Image, bbox image.Rectangle, o *options) (Line, error) {
+	var p predictor.Prediction
+	var err error
+	if !o.scored() {
+		p.Text, err = pred.Predict(img)
+	} else {
+		p.Text, p.Confidence, p.Chars, err = pred.PredictChars(img)
+	}
+	if err != nil {
+		return Line{}, err
+	}
+	return finishLine(pred, img, bbox, p, o), nil
+}
+
+// finishLine builds the Line for the reading p of img, retrying and
+// masking it as recognizeLine does.
+func finishLine(pred predictor.Recognizer, img image.Image, bbox image.Rectangle, p predictor.Prediction, o *options) Line {
+	line := Line{Text: p.Text}
+	if o.scored() {
+		line = Line{Text: p.Text, Confidence: p.Confidence, BBox: bbox, Chars: pageChars(p.Chars, img, bbox)}
+	}
+	if o.needsRetry(line) {
+		line = retryLine(pred, img, line, o)
+	}
+	return o.maskLine(line)
+}
+
+// pageChars maps character spans read from img onto the page, where img
+// covers bbox. img may be a rescaled copy of the line, as for retry
+// variants.
+func pageChars(chars []predictor.Char, img image.Image, bbox image.Rectangle) []predictor.Char {
+	b := img.Bounds()
+	if b.Dx() == 0 {
+		return nil
+	}
+	column := func(x int) int {
+		return bbox.Min.X + (x-b.Min.X)*bbox.Dx()/b.Dx()
+	}
+
+	mapped := make([]predictor.Char, len(chars))
+	for i, c := range chars {
+		mapped[i] = c
+		mapped[i].Span = predictor.Span{Start: column(c.Start), End: column(c.End)}
+	}
+	return mapped
+}
+
+// pageNumber extracts the page number from a pdftoppm output name such as
+// "page-07.png", falling back to def.
+func pageNumber(name string, def int) int {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	idx := strings.LastIndex(name, "-")
+	if idx < 0 {
+		return def
+	}
+	n, err := strconv.Atoi(name[idx+1:])
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// Levenshtein distance calculation, over runes, graphemes or words
+func levenshtein[T comparable](s1, s2 []T) int {
 	len1, len2 := len(s1), len(s2)
 	column := make([]int, len1+1)
 
@@ -252,9 +748,19 @@ func min(a, b int) int {
 	return b
 }
 
+// CharErrorRate returns the character error rate of pred against truth:
+// the edit distance divided by the length of truth, in runes. An empty
+// truth gives 0 if pred is also empty and 1 otherwise. See
+// GraphemeErrorRate for a measure closer to what readers see.
+func CharErrorRate(pred, truth string) float64 {
+	return errorRate([]rune(pred), []rune(truth))
+}
+
+// calculateAccuracy compares grapheme clusters, so a wrong mark in a
+// stacked consonant costs one error as it does to a reader.
 func calculateAccuracy(pred, truth string) float64 {
-	p := []rune(pred)
-	t := []rune(truth)
+	p := graphemes(pred)
+	t := graphemes(truth)
 
 	if len(t) == 0 {
 		if len(p) == 0 {
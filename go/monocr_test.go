@@ -0,0 +1,45 @@
+package monocr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestReadPagesCancel cancels the document while a page is being read and
+// checks that collecting every page returns instead of waiting on pages
+// no worker took. How many pages are taken before the workers see the
+// cancellation is up to the scheduler.
+func TestReadPagesCancel(t *testing.T) {
+	for _, workers := range []int{1, 2, 4} {
+		ctx, cancel := context.WithCancel(context.Background())
+		read := func(ctx context.Context, i int) *pageOutcome {
+			if i == 2 {
+				cancel()
+			}
+			return &pageOutcome{err: ctx.Err()}
+		}
+		next, wait := readPages(ctx, 50, workers, read)
+
+		done := make(chan int)
+		go func() {
+			read := 0
+			for i := 0; i < 50; i++ {
+				if next(i) != nil {
+					read++
+				}
+			}
+			wait()
+			done <- read
+		}()
+		select {
+		case read := <-done:
+			if read < 3 {
+				t.Errorf("workers %d: read %d of 50 pages after cancelling at page 2", workers, read)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("workers %d: collecting pages hung after cancellation", workers)
+		}
+		cancel()
+	}
+}
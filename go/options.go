@@ -0,0 +1,438 @@
+package monocr
+
+import (
+	"path/filepath"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/model"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+)
+
+// Option configures how images and PDFs are read.
+type Option func(*options)
+
+type options struct {
+	rotation     int
+	autoRotate   bool
+	textOnly     bool
+	extraChars   string
+	charsetFile  string
+	renderCache  string
+	renderLimits RenderLimits
+	retryFloor   float64
+	// minConfidence drops or, with placeholder, masks what is read below
+	// it.
+	minConfidence float64
+	placeholder   string
+	extractors    []Extractor
+	preprocessors []Preprocessor
+	// firstPage and lastPage limit PDF rendering to a page range; 0
+	// means from the start or to the end.
+	firstPage int
+	lastPage  int
+	// dpi overrides defaultDPI when set.
+	dpi          int
+	adaptiveDPI  float64
+	runningLines RunningLines
+	pageTypes    bool
+	textLayer    bool
+	pageWorkers  int
+	batchSize    int
+	sessions     int
+	progress     func(done, total int)
+	// modelVersion and modelVariant select the default model.
+	modelVersion string
+	modelVariant string
+	// tuneThreads picks the thread count with WithThreadTuning, unless
+	// WithThreads set intraThreads.
+	tuneThreads  bool
+	intraThreads int
+	backend      predictor.Backend
+	predictor    []predictor.Option
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{retryFloor: -1, batchSize: 1}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// defaultModel returns the path of the default model, downloading it if
+// needed.
+func (o *options) defaultModel() (string, error) {
+	manager, err := o.modelManager()
+	if err != nil {
+		return "", err
+	}
+	return manager.GetModelPath()
+}
+
+// modelManager returns the model manager for the version WithModelVersion
+// pins and the variant WithModelVariant selects.
+func (o *options) modelManager() (*model.Manager, error) {
+	manager, err := model.NewManager()
+	if err != nil {
+		return nil, err
+	}
+	if o.modelVersion != "" {
+		manager.Version = o.modelVersion
+	}
+	if o.modelVariant != "" {
+		manager.Variant = o.modelVariant
+	}
+	return manager, nil
+}
+
+// newPredictor loads a recognizer for modelPath configured by o.
+func (o *options) newPredictor(modelPath, charset string) (predictor.Recognizer, error) {
+	if o.tuneThreads && o.intraThreads <= 0 {
+		n, err := o.tunedThreads(modelPath, charset+o.extraChars)
+		if err != nil {
+			return nil, err
+		}
+		tuned := *o
+		tuned.predictor = append(o.predictor[:len(o.predictor):len(o.predictor)], predictor.WithIntraOpThreads(n))
+		return tuned.loadModel(modelPath, charset)
+	}
+	return o.loadModel(modelPath, charset)
+}
+
+// loadModel loads modelPath with the configured backend, by default ONNX
+// Runtime or, without cgo, the pure-Go runtime, into a pool with
+// WithSessions, with the charset extended by WithExtraChars.
+func (o *options) loadModel(modelPath, charset string) (predictor.Recognizer, error) {
+	backend := o.backend
+	if backend == nil {
+		backend = predictor.DefaultBackend
+	}
+	opts := o.predictor
+	if o.extraChars != "" {
+		opts = append(opts[:len(opts):len(opts)], predictor.WithExtraChars(o.extraChars))
+	}
+	load := func() (predictor.Recognizer, error) {
+		return backend(modelPath, charset, opts...)
+	}
+	if o.sessions > 1 {
+		return predictor.NewPool(o.sessions, load)
+	}
+	return load()
+}
+
+// WithBackend replaces the default recognition runtime, ONNX Runtime or
+// in builds without cgo predictor.PureGo, with another. Predictor options
+// such as the interpolation filter are passed on to it.
+func WithBackend(backend predictor.Backend) Option {
+	return func(o *options) {
+		o.backend = backend
+	}
+}
+
+// WithRotation rotates every page clockwise by deg degrees (90, 180 or 270)
+// before recognition, for scans that are consistently rotated.
+func WithRotation(deg int) Option {
+	return func(o *options) {
+		o.rotation = deg
+	}
+}
+
+// WithAutoRotate detects the orientation of each page and rotates it
+// upright before recognition. It takes precedence over WithRotation.
+func WithAutoRotate() Option {
+	return func(o *options) {
+		o.autoRotate = true
+	}
+}
+
+// WithMinConfidence filters out what the model isn't sure of: lines
+// whose confidence is below threshold are dropped, and so are characters
+// below it in the lines kept. With a placeholder such as "?", each is
+// replaced by it instead, so readers see where text was lost; a whole
+// line then becomes a single placeholder. Spaces are always kept. Text-only
+// reads still score lines when a threshold is set.
+func WithMinConfidence(threshold float64, placeholder string) Option {
+	return func(o *options) {
+		o.minConfidence = threshold
+		o.placeholder = placeholder
+	}
+}
+
+// WithTextOnly skips confidence scoring and line boxes in structured
+// results, for bulk full-text indexing where only the text matters. The
+// plain-text Read functions always take this path.
+func WithTextOnly() Option {
+	return func(o *options) {
+		o.textOnly = true
+	}
+}
+
+// WithExtraChars maps the additional output classes of a fine-tuned model
+// to chars, in order, after the bundled charset, with
+// predictor.WithExtraChars. The total class count is checked against the
+// model's output dimension when the model is loaded.
+func WithExtraChars(chars string) Option {
+	return func(o *options) {
+		o.extraChars = chars
+	}
+}
+
+// WithCharsetFile reads the model's charset from path instead of using
+// the bundled one or a model card's: a text file listing the output
+// classes after blank, in order, or a model card (.json). It applies
+// when no charset is passed explicitly, as with NewReader.
+func WithCharsetFile(path string) Option {
+	return func(o *options) {
+		o.charsetFile = path
+	}
+}
+
+// WithRenderCache keeps rasterized PDF pages in dir so later runs on the
+// same PDF (with a new model or options) skip rendering. Entries are keyed
+// by the PDF's content hash and the render DPI.
+func WithRenderCache(dir string) Option {
+	return func(o *options) {
+		o.renderCache = dir
+	}
+}
+
+// WithInterpolation selects the resampling filter used to scale lines to the
+// model's input height. CatmullRom is the default; Bilinear is faster on
+// large batches and avoids ringing on bilevel scans.
+func WithInterpolation(i predictor.Interpolation) Option {
+	return func(o *options) {
+		o.predictor = append(o.predictor, predictor.WithInterpolation(i))
+	}
+}
+
+// WithMemoryMap loads the model through a read-only memory mapping to
+// reduce the memory peak while the session is created on small devices.
+// Models with a float16 output, such as the fp16 variant, are still read
+// into memory, since their graph is rewritten before the session is
+// created.
+func WithMemoryMap() Option {
+	return func(o *options) {
+		o.predictor = append(o.predictor, predictor.WithMemoryMap())
+	}
+}
+
+// WithWidthBucket sets the step line widths are padded to so lines of
+// similar width reuse the model's input and output tensors. It is off by
+// default, since the padding can change how a line decodes.
+func WithWidthBucket(step int) Option {
+	return func(o *options) {
+		o.predictor = append(o.predictor, predictor.WithWidthBucket(step))
+	}
+}
+
+// WithLanguageModel rescores decoding with lm, such as an n-gram model
+// from predictor.LoadNGram, to correct common Mon confusions. weight is
+// how much the language model counts against the image evidence;
+// predictor.DefaultLMWeight is a good start. Decoding becomes a beam
+// search, which is slower than the default. See
+// predictor.WithLanguageModel.
+func WithLanguageModel(lm predictor.LanguageModel, weight float64) Option {
+	return func(o *options) {
+		o.predictor = append(o.predictor, predictor.WithLanguageModel(lm, weight))
+	}
+}
+
+// WithThreads sets ONNX Runtime's intra-op and inter-op thread counts;
+// zero keeps the runtime's default for either. By default every model
+// session starts a thread per core, so processes running several sessions
+// side by side oversubscribe the CPU unless the cores are divided between
+// them. An intra-op count takes precedence over WithThreadTuning. See
+// predictor.WithIntraOpThreads and predictor.WithInterOpThreads.
+func WithThreads(intra, inter int) Option {
+	return func(o *options) {
+		o.intraThreads = intra
+		o.predictor = append(o.predictor, predictor.WithIntraOpThreads(intra), predictor.WithInterOpThreads(inter))
+	}
+}
+
+// WithCUDA runs recognition on the CUDA GPU with the given device ID,
+// falling back to the CPU with a warning when ONNX Runtime has no CUDA
+// support or no GPU is usable. See predictor.WithCUDA.
+func WithCUDA(deviceID int) Option {
+	return func(o *options) {
+		o.predictor = append(o.predictor, predictor.WithCUDA(deviceID))
+	}
+}
+
+// WithTensorRT runs recognition with TensorRT on the GPU with the given
+// device ID, keeping built engines in cacheDir so only the first run pays
+// for building them. An empty cacheDir keeps them in "tensorrt" under the
+// model cache directory. See predictor.WithTensorRT.
+func WithTensorRT(deviceID int, cacheDir string) Option {
+	return func(o *options) {
+		if cacheDir == "" {
+			if manager, err := model.NewManager(); err == nil {
+				cacheDir = filepath.Join(manager.CacheDir, "tensorrt")
+			}
+		}
+		o.predictor = append(o.predictor, predictor.WithTensorRT(deviceID, cacheDir))
+	}
+}
+
+// WithGPUPreprocessing also scales line images on the GPU when recognition
+// runs there with WithCUDA or WithTensorRT. See
+// predictor.WithGPUPreprocessing.
+func WithGPUPreprocessing() Option {
+	return func(o *options) {
+		o.predictor = append(o.predictor, predictor.WithGPUPreprocessing())
+	}
+}
+
+// WithCoreML runs recognition through CoreML on macOS, using the Neural
+// Engine or the GPU, and falls back to the CPU with a log message where
+// CoreML isn't available. See predictor.WithCoreML.
+func WithCoreML() Option {
+	return func(o *options) {
+		o.predictor = append(o.predictor, predictor.WithCoreML())
+	}
+}
+
+// WithModelVersion pins the default model to a published version such as
+// "v1.2", downloaded and cached next to other versions, so upgrading the
+// library doesn't change recognition. It takes precedence over
+// MONOCR_MODEL_VERSION.
+func WithModelVersion(version string) Option {
+	return func(o *options) {
+		o.modelVersion = version
+	}
+}
+
+// WithModelVariant selects a published variant of the default model, such
+// as model.VariantInt8 ("int8"), the quantized model that is about four
+// times smaller and much faster on CPU-only servers, or model.VariantFP16
+// ("fp16") for GPUs. It takes precedence over MONOCR_MODEL_VARIANT.
+func WithModelVariant(variant string) Option {
+	return func(o *options) {
+		o.modelVariant = variant
+	}
+}
+
+// WithProgress calls fn as PDF pages are recognized with the number of
+// pages done so far and the page count, starting at 0 once the document
+// is rendered. fn is called from the reading goroutine.
+func WithProgress(fn func(done, total int)) Option {
+	return func(o *options) {
+		o.progress = fn
+	}
+}
+
+func (o *options) reportProgress(done, total int) {
+	if o.progress != nil {
+		o.progress(done, total)
+	}
+}
+
+// WithRenderLimits bounds the time, CPU and memory the PDF renderer may
+// use. The renderer runs in its own process group, which is killed as a
+// whole when the timeout expires.
+func WithRenderLimits(limits RenderLimits) Option {
+	return func(o *options) {
+		o.renderLimits = limits
+	}
+}
+
+// WithRetryFloor retries lines read below floor, and empty lines, with
+// alternate preprocessing (inverted and re-binarized), keeping the
+// best-scoring reading. An empty line only takes a retry's text read at
+// floor or above, and at least 0.5, so blank segments stay blank. Retries
+// are off by default, or with a negative floor.
+func WithRetryFloor(floor float64) Option {
+	return func(o *options) {
+		o.retryFloor = floor
+	}
+}
+
+// WithExtractors runs the given extractors over every recognized page and
+// collects their findings in Result.Matches.
+func WithExtractors(extractors ...Extractor) Option {
+	return func(o *options) {
+		o.extractors = append(o.extractors, extractors...)
+	}
+}
+
+// WithDPI sets the resolution PDF pages are rasterized at, 300 by default.
+// With WithAdaptiveDPI it is the resolution low-confidence pages are
+// re-rendered at.
+func WithDPI(dpi int) Option {
+	return func(o *options) {
+		o.dpi = dpi
+	}
+}
+
+// WithPages limits PDF recognition to pages first through last, numbered
+// from 1. Either may be 0 to read from the start or to the end.
+func WithPages(first, last int) Option {
+	return func(o *options) {
+		o.firstPage, o.lastPage = first, last
+	}
+}
+
+// WithPageTypes classifies each PDF page as scanned, vector or mixed in
+// Page.Type. Text drawn by the PDF itself is extracted rather than
+// recognized: vector pages skip OCR, and on mixed pages only the embedded
+// images are recognized. It needs poppler's pdftohtml; without it every
+// page is recognized as before and a warning is added. Pages that are
+// rotated keep full OCR.
+func WithPageTypes() Option {
+	return func(o *options) {
+		o.pageTypes = true
+	}
+}
+
+// WithTextLayer reads PDF pages that already carry text, drawn or in an
+// invisible layer left by an earlier OCR pass, from the PDF itself and
+// recognizes only the pages without. Text whose characters are mostly
+// unmapped glyphs is not trusted and those pages are recognized too. When
+// every page has text nothing is rendered. It needs poppler's pdftohtml.
+func WithTextLayer() Option {
+	return func(o *options) {
+		o.textLayer = true
+	}
+}
+
+// WithSessions loads n sessions of the model into a predictor.Pool, so
+// concurrent reads, such as an HTTP service's handlers or WithPageWorkers,
+// each run on a session of their own instead of contending for one. Each
+// session holds its own copy of the model and threads, so divide the cores
+// between them with WithThreads. The default of 1 loads a single session.
+func WithSessions(n int) Option {
+	return func(o *options) {
+		o.sessions = n
+	}
+}
+
+// WithPageWorkers recognizes up to n PDF pages at once, sharing the model
+// session. Pages are still returned in order. The default of 1 reads one
+// page at a time; more workers cut the wall time of long books on
+// multi-core machines at the cost of holding n page images in memory.
+func WithPageWorkers(n int) Option {
+	return func(o *options) {
+		o.pageWorkers = n
+	}
+}
+
+// WithBatchSize recognizes up to n lines of a page in one inference run
+// when the recognizer supports it (see predictor.BatchRecognizer). It is
+// off by default: lines are padded to the widest in their batch, which can
+// change how a line decodes. Lines are batched by width so little work is
+// spent on padding. Larger batches are faster on GPUs and many-core CPUs
+// at the cost of memory for the batch tensors.
+func WithBatchSize(n int) Option {
+	return func(o *options) {
+		o.batchSize = n
+	}
+}
+
+// WithAdaptiveDPI renders PDF pages at a lower resolution first and
+// re-renders only the pages whose mean confidence falls below threshold at
+// the full resolution, keeping the better reading. On books where most
+// pages are clean this saves most of the rendering time.
+func WithAdaptiveDPI(threshold float64) Option {
+	return func(o *options) {
+		o.adaptiveDPI = threshold
+	}
+}
@@ -0,0 +1,91 @@
+package monocr
+
+import (
+	"fmt"
+	"image"
+	"sort"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/orient"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/segmenter"
+)
+
+// sampleLines is how many lines are recognized per candidate orientation
+// when auto-rotating.
+const sampleLines = 3
+
+// orientImage applies the rotation requested in o to img, then the
+// preprocessors.
+func (o *options) orientImage(pred predictor.Recognizer, img image.Image) (image.Image, error) {
+	var err error
+	if o.autoRotate {
+		img, err = autoRotate(pred, img)
+	} else {
+		img, err = orient.Rotate(img, o.rotation)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range o.preprocessors {
+		if img, err = p(img); err != nil {
+			return nil, fmt.Errorf("preprocessing failed: %v", err)
+		}
+	}
+	return img, nil
+}
+
+// rotates reports whether o may rotate pages before recognition.
+func (o *options) rotates() bool {
+	return o.autoRotate || o.rotation%360 != 0
+}
+
+// autoRotate finds the text axis from the ink profile, then recognizes a few
+// lines both ways up and keeps the orientation the model is most sure of.
+func autoRotate(pred predictor.Recognizer, img image.Image) (image.Image, error) {
+	axis := orient.DetectAxis(img)
+
+	// The upside-down candidate is turned from the first, so only one
+	// rotated copy of the page is held besides the input
+	upright, err := orient.Rotate(img, axis)
+	if err != nil {
+		return nil, err
+	}
+	conf := sampleConfidence(pred, upright)
+	flipped, err := orient.Rotate(upright, 180)
+	if err != nil {
+		return nil, err
+	}
+	if sampleConfidence(pred, flipped) > conf {
+		return flipped, nil
+	}
+	return upright, nil
+}
+
+func sampleConfidence(pred predictor.Recognizer, img image.Image) float64 {
+	seg := segmenter.NewLineSegmenter(10, 3)
+	lines, err := seg.Segment(img)
+	if err != nil || len(lines) == 0 {
+		_, conf, err := pred.PredictWithConfidence(img)
+		if err != nil {
+			return 0
+		}
+		return conf
+	}
+
+	// The widest lines carry the most characters to judge by.
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].BBox.Dx() > lines[j].BBox.Dx()
+	})
+	if len(lines) > sampleLines {
+		lines = lines[:sampleLines]
+	}
+
+	var sum float64
+	for _, line := range lines {
+		_, conf, err := pred.PredictWithConfidence(line.Img)
+		if err == nil {
+			sum += conf
+		}
+	}
+	return sum / float64(len(lines))
+}
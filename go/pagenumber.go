@@ -0,0 +1,88 @@
+package monocr
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// pageNumberWindow is how many pages apart two printed page numbers may be
+// and still confirm each other.
+const pageNumberWindow = 3
+
+// pageNumberPattern matches a line holding only a page number, possibly
+// decorated as in "- 12 -" or "(12)".
+var pageNumberPattern = regexp.MustCompile(`^[\s\-–—·•.(\[]*([0-9]{1,4})[\s\-–—·•.)\]]*$`)
+
+// DetectPageNumbers sets Page.Logical to the page number printed on each
+// page, read from a line holding only a number at the top or bottom of
+// the page. A reading counts only when another page within a few pages
+// agrees on the offset between physical and printed numbers, which
+// filters out misread digits and stray figures. Pages between two
+// agreeing readings, such as one whose number was not found, are filled
+// in; covers and unnumbered inserts break the run and stay at 0.
+func (r *Result) DetectPageNumbers() {
+	// offsets[i] is the printed minus the physical number, if one was read
+	offsets := make([]*int, len(r.Pages))
+	for i, page := range r.Pages {
+		if n, ok := printedPageNumber(page); ok {
+			offset := n - page.Number
+			offsets[i] = &offset
+		}
+	}
+
+	confirmed := make([]*int, len(r.Pages))
+	for i, offset := range offsets {
+		if offset == nil {
+			continue
+		}
+		for j := i - pageNumberWindow; j <= i+pageNumberWindow; j++ {
+			if j != i && j >= 0 && j < len(offsets) && offsets[j] != nil && *offsets[j] == *offset {
+				confirmed[i] = offset
+				break
+			}
+		}
+	}
+
+	// Fill each page from the nearest confirmed readings on either side
+	// when they agree
+	var before *int
+	for i := range r.Pages {
+		r.Pages[i].Logical = 0
+		if confirmed[i] != nil {
+			before = confirmed[i]
+		}
+		offset := confirmed[i]
+		if offset == nil && before != nil {
+			for j := i + 1; j < len(confirmed); j++ {
+				if confirmed[j] != nil {
+					if *confirmed[j] == *before {
+						offset = before
+					}
+					break
+				}
+			}
+		}
+		if offset != nil && r.Pages[i].Number+*offset > 0 {
+			r.Pages[i].Logical = r.Pages[i].Number + *offset
+		}
+	}
+}
+
+// printedPageNumber looks for a page number among the first and last two
+// lines of page, within the top or bottom margin.
+func printedPageNumber(page Page) (int, bool) {
+	for i, line := range page.Lines {
+		edge := (i < runningDepth && float64(line.BBox.Max.Y) <= runningMargin*float64(page.Height)) ||
+			(i >= len(page.Lines)-runningDepth && float64(line.BBox.Min.Y) >= (1-runningMargin)*float64(page.Height))
+		if !edge {
+			continue
+		}
+		if m := pageNumberPattern.FindStringSubmatch(asciiDigits(line.Text)); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			if n > 0 {
+				return n, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,238 @@
+package monocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/segmenter"
+)
+
+// Page types reported in Page.Type with WithPageTypes.
+const (
+	// PageScanned pages hold only images, and are recognized by OCR.
+	PageScanned = "scanned"
+	// PageVector pages hold only text drawn by the PDF, which is
+	// extracted instead of recognized.
+	PageVector = "vector"
+	// PageMixed pages hold both: their images are recognized and the
+	// text outside them is extracted.
+	PageMixed = "mixed"
+)
+
+// pdfLayout is the pdftohtml -xml description of a PDF.
+type pdfLayout struct {
+	Pages []pdfPageLayout `xml:"page"`
+	// Outline holds the document's bookmarks, if it has any.
+	Outline *outlineNode `xml:"outline"`
+}
+
+// outlineNode is an <outline> holding <item> bookmarks and nested
+// <outline>s of sub-bookmarks, or one of those items.
+type outlineNode struct {
+	XMLName  xml.Name
+	Page     int           `xml:"page,attr"`
+	Text     string        `xml:",chardata"`
+	Children []outlineNode `xml:",any"`
+}
+
+// pdfPageLayout is one page of a pdfLayout, in PDF points from the top
+// left corner.
+type pdfPageLayout struct {
+	Number int          `xml:"number,attr"`
+	Width  float64      `xml:"width,attr"`
+	Height float64      `xml:"height,attr"`
+	Images []pdfElement `xml:"image"`
+	Texts  []pdfElement `xml:"text"`
+}
+
+// pdfElement is an image or a run of text placed on a page.
+type pdfElement struct {
+	Top    float64 `xml:"top,attr"`
+	Left   float64 `xml:"left,attr"`
+	Width  float64 `xml:"width,attr"`
+	Height float64 `xml:"height,attr"`
+	// Inner is the text with pdftohtml's <b>, <i> and <a> markup.
+	Inner string `xml:",innerxml"`
+}
+
+// markup matches the tags pdftohtml wraps styled text in.
+var markup = regexp.MustCompile(`<[^>]*>`)
+
+// text returns the element's text without markup.
+func (e pdfElement) text() string {
+	return strings.TrimSpace(html.UnescapeString(markup.ReplaceAllString(e.Inner, "")))
+}
+
+// rect returns the element's box in an image scale pixels per point.
+func (e pdfElement) rect(scale float64) image.Rectangle {
+	return image.Rect(
+		int(math.Floor(e.Left*scale)), int(math.Floor(e.Top*scale)),
+		int(math.Ceil((e.Left+e.Width)*scale)), int(math.Ceil((e.Top+e.Height)*scale)),
+	)
+}
+
+// Type classifies the page by what it draws. Pages with neither text nor
+// images are treated as scanned so they still go through OCR.
+func (p pdfPageLayout) Type() string {
+	hasText := false
+	for _, t := range p.Texts {
+		if t.text() != "" {
+			hasText = true
+			break
+		}
+	}
+	switch {
+	case !hasText:
+		return PageScanned
+	case len(p.Images) == 0:
+		return PageVector
+	}
+	return PageMixed
+}
+
+// analyzePDF runs pdftohtml over the pages of pdfPath o selects and
+// returns their layouts by page number. Hidden text, such as the text
+// layer of an already OCRed scan, is only reported if hidden is set;
+// otherwise such pages count as scanned.
+func analyzePDF(ctx context.Context, pdfPath string, o *options, hidden bool) (map[int]pdfPageLayout, error) {
+	var args []string
+	if hidden {
+		args = append(args, "-hidden")
+	}
+	if o.firstPage > 0 {
+		args = append(args, "-f", strconv.Itoa(o.firstPage))
+	}
+	if o.lastPage > 0 {
+		args = append(args, "-l", strconv.Itoa(o.lastPage))
+	}
+	layout, err := readLayout(ctx, pdfPath, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make(map[int]pdfPageLayout, len(layout.Pages))
+	for _, page := range layout.Pages {
+		pages[page.Number] = page
+	}
+	return pages, nil
+}
+
+// readLayout runs pdftohtml -xml over pdfPath with the extra args.
+func readLayout(ctx context.Context, pdfPath string, extra ...string) (*pdfLayout, error) {
+	pdftohtml, err := findPoppler("pdftohtml")
+	if err != nil {
+		return nil, err
+	}
+	// pdftohtml also extracts the images, which are not needed
+	dir, err := os.MkdirTemp("", "monocr-layout-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	args := append([]string{"-xml", "-q", "-enc", "UTF-8", "-zoom", "1"}, extra...)
+	args = append(args, pdfPath, filepath.Join(dir, "layout"))
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, pdftohtml, args...)
+	cmd.Stderr = &stderr
+	isolateProcess(cmd)
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("failed to analyze PDF: %s", msg)
+		}
+		return nil, fmt.Errorf("failed to analyze PDF: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "layout.xml"))
+	if err != nil {
+		return nil, err
+	}
+	var layout pdfLayout
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	if err := dec.Decode(&layout); err != nil {
+		return nil, fmt.Errorf("failed to read PDF layout: %v", err)
+	}
+	return &layout, nil
+}
+
+// recognizeLayoutPage reads a rendered vector or mixed page according to
+// its layout: vector pages are extracted without OCR, and on mixed pages
+// only the image regions are recognized while the text around them is
+// extracted. Extracted lines have confidence 1.
+func recognizeLayoutPage(ctx context.Context, pred predictor.Recognizer, seg *segmenter.LineSegmenter, img image.Image, layout pdfPageLayout, o *options) (Page, []string, error) {
+	if layout.Width <= 0 {
+		return recognizePage(ctx, pred, seg, img, o)
+	}
+
+	b := img.Bounds()
+	scale := float64(b.Dx()) / layout.Width
+	var regions []image.Rectangle
+	for _, im := range layout.Images {
+		if r := im.rect(scale).Add(b.Min).Intersect(b); !r.Empty() {
+			regions = append(regions, r)
+		}
+	}
+
+	page := Page{Width: b.Dx(), Height: b.Dy()}
+	var warnings []string
+	if len(regions) > 0 {
+		// Blank everything but the images so segmentation only finds
+		// their lines
+		masked := image.NewGray(b)
+		draw.Draw(masked, b, image.NewUniform(color.White), image.Point{}, draw.Src)
+		for _, r := range regions {
+			draw.Draw(masked, r, img, r.Min, draw.Src)
+		}
+		var err error
+		page, warnings, err = recognizePage(ctx, pred, seg, masked, o)
+		if err != nil {
+			return page, warnings, err
+		}
+	}
+
+	for _, t := range layout.Texts {
+		text := t.text()
+		if text == "" {
+			continue
+		}
+		bbox := t.rect(scale).Add(b.Min)
+		// Text over an image, like a caption stamped on a photo, was
+		// already recognized with it
+		if insideAny(bbox, regions) {
+			continue
+		}
+		page.Lines = append(page.Lines, Line{Text: text, Confidence: 1, BBox: bbox})
+	}
+	orderLines(page.Lines)
+	return page, warnings, nil
+}
+
+// insideAny reports whether the centre of r lies in one of regions.
+func insideAny(r image.Rectangle, regions []image.Rectangle) bool {
+	c := image.Pt((r.Min.X+r.Max.X)/2, (r.Min.Y+r.Max.Y)/2)
+	for _, region := range regions {
+		if c.In(region) {
+			return true
+		}
+	}
+	return false
+}
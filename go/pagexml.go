@@ -0,0 +1,133 @@
+package monocr
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const pageNamespace = "http://schema.primaresearch.org/PAGE/gts/pagecontent/2019-07-15"
+
+type pageDoc struct {
+	XMLName        xml.Name     `xml:"PcGts"`
+	Xmlns          string       `xml:"xmlns,attr"`
+	XmlnsXsi       string       `xml:"xmlns:xsi,attr"`
+	SchemaLocation string       `xml:"xsi:schemaLocation,attr"`
+	Metadata       pageMetadata `xml:"Metadata"`
+	Page           pagePage     `xml:"Page"`
+}
+
+type pageMetadata struct {
+	Creator    string `xml:"Creator"`
+	Created    string `xml:"Created"`
+	LastChange string `xml:"LastChange"`
+}
+
+type pagePage struct {
+	ImageFilename string           `xml:"imageFilename,attr"`
+	ImageWidth    int              `xml:"imageWidth,attr"`
+	ImageHeight   int              `xml:"imageHeight,attr"`
+	Regions       []pageTextRegion `xml:"TextRegion"`
+}
+
+type pageTextRegion struct {
+	ID        string         `xml:"id,attr"`
+	Coords    pageCoords     `xml:"Coords"`
+	Lines     []pageTextLine `xml:"TextLine"`
+	TextEquiv pageTextEquiv  `xml:"TextEquiv"`
+}
+
+type pageTextLine struct {
+	ID        string        `xml:"id,attr"`
+	Coords    pageCoords    `xml:"Coords"`
+	Words     []pageWord    `xml:"Word"`
+	TextEquiv pageTextEquiv `xml:"TextEquiv"`
+}
+
+type pageWord struct {
+	ID        string        `xml:"id,attr"`
+	Coords    pageCoords    `xml:"Coords"`
+	TextEquiv pageTextEquiv `xml:"TextEquiv"`
+}
+
+type pageCoords struct {
+	Points string `xml:"points,attr"`
+}
+
+type pageTextEquiv struct {
+	Conf    string `xml:"conf,attr,omitempty"`
+	Unicode string `xml:"Unicode"`
+}
+
+// pointsOf renders a rectangle as a PAGE polygon, clockwise from the top
+// left corner.
+func pointsOf(r image.Rectangle) pageCoords {
+	return pageCoords{Points: fmt.Sprintf("%d,%d %d,%d %d,%d %d,%d",
+		r.Min.X, r.Min.Y, r.Max.X, r.Min.Y, r.Max.X, r.Max.Y, r.Min.X, r.Max.Y)}
+}
+
+func pageConf(c float64) string {
+	return fmt.Sprintf("%.4f", c)
+}
+
+// EncodePAGE writes one page as a PRImA PAGE XML document (2019 schema)
+// for correction tools such as Transkribus and Aletheia: a TextRegion
+// holding the page's lines, and Words with their boxes, text and
+// confidence. imageFilename refers to the page image by base name, so the
+// XML should be stored where the tool expects it next to that image.
+func EncodePAGE(w io.Writer, page Page) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	doc := pageDoc{
+		Xmlns:          pageNamespace,
+		XmlnsXsi:       "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: pageNamespace + " " + pageNamespace + "/pagecontent.xsd",
+		Metadata:       pageMetadata{Creator: "monocr", Created: now, LastChange: now},
+		Page: pagePage{
+			ImageFilename: filepath.Base(page.Image),
+			ImageWidth:    page.Width,
+			ImageHeight:   page.Height,
+		},
+	}
+
+	region := pageTextRegion{ID: "r1"}
+	var regionRect image.Rectangle
+	texts := make([]string, 0, len(page.Lines))
+	for i, line := range page.Lines {
+		lineID := fmt.Sprintf("r1_l%d", i+1)
+		pl := pageTextLine{
+			ID:        lineID,
+			Coords:    pointsOf(line.BBox),
+			TextEquiv: pageTextEquiv{Conf: pageConf(line.Confidence), Unicode: line.Text},
+		}
+		for j, word := range line.Words() {
+			pl.Words = append(pl.Words, pageWord{
+				ID:        fmt.Sprintf("%s_w%d", lineID, j+1),
+				Coords:    pointsOf(word.BBox),
+				TextEquiv: pageTextEquiv{Conf: pageConf(word.Confidence), Unicode: word.Text},
+			})
+		}
+		region.Lines = append(region.Lines, pl)
+		regionRect = regionRect.Union(line.BBox)
+		texts = append(texts, line.Text)
+	}
+	if len(region.Lines) > 0 {
+		region.Coords = pointsOf(regionRect)
+		region.TextEquiv = pageTextEquiv{Unicode: strings.Join(texts, "\n")}
+		doc.Page.Regions = append(doc.Page.Regions, region)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,37 @@
+package monocr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrPartial reports that a document read failed part way through. Errors
+// from such reads wrap both ErrPartial and the cause, so errors.Is works
+// with either.
+var ErrPartial = errors.New("monocr: read incomplete")
+
+// PartialError is returned when recognizing a document fails on a page,
+// for example with an inference error. Partial holds the pages completed
+// before it, and its warnings record the failure, so callers can keep
+// them instead of starting over.
+type PartialError struct {
+	Partial *Result
+	// Page is the 1-based number of the page that failed.
+	Page  int
+	Cause error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%v: page %d failed after %d pages: %v", ErrPartial, e.Page, len(e.Partial.Pages), e.Cause)
+}
+
+func (e *PartialError) Unwrap() []error {
+	return []error{ErrPartial, e.Cause}
+}
+
+// failedAt records that page failed with cause and wraps it with the
+// partial result.
+func failedAt(partial *Result, page int, cause error) error {
+	partial.warn(page, "recognition failed, document incomplete: %v", cause)
+	return &PartialError{Partial: partial, Page: page, Cause: cause}
+}
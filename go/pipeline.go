@@ -0,0 +1,335 @@
+package monocr
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"slices"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/segmenter"
+)
+
+// Names of the stages of a default Pipeline, in order.
+const (
+	StageDecode      = "decode"
+	StagePreprocess  = "preprocess"
+	StageSegment     = "segment"
+	StageRecognize   = "recognize"
+	StagePostprocess = "postprocess"
+	StageOutput      = "output"
+)
+
+// Stage is one step of a Pipeline. It reads and updates doc.
+type Stage interface {
+	Run(ctx context.Context, doc *Document) error
+}
+
+// StageFunc adapts a function to a Stage.
+type StageFunc func(ctx context.Context, doc *Document) error
+
+func (f StageFunc) Run(ctx context.Context, doc *Document) error {
+	return f(ctx, doc)
+}
+
+// Segment is a region of the image to recognize as one line.
+type Segment struct {
+	Image image.Image
+	BBox  image.Rectangle
+}
+
+// Document is the state a Pipeline passes from stage to stage.
+type Document struct {
+	// Path is the input file, read by the decode stage.
+	Path string
+	// Image is the decoded, then preprocessed, input.
+	Image image.Image
+	// Segments are the lines found by the segment stage. Without them the
+	// recognize stage reads the whole image as one line, as for
+	// pre-cropped line images.
+	Segments []Segment
+	// Page holds the recognized lines.
+	Page Page
+	// Matches are what the reader's extractors found on the page.
+	Matches []Match
+	// Warnings describe anything that degraded the result.
+	Warnings []string
+	// Text is the final text produced by the output stage.
+	Text string
+}
+
+type namedStage struct {
+	name  string
+	stage Stage
+}
+
+// Pipeline runs an image through named stages. The default stages
+// decode → preprocess → segment → recognize → postprocess → output are
+// the ones the Reader methods run on every page, and what
+// Reader.RecognizeWithLayout runs as is; they can be removed, reordered
+// or replaced, and new ones inserted between them. Builder methods
+// modify the pipeline and return it for chaining; a mistake such as an
+// unknown stage name is reported by Run.
+type Pipeline struct {
+	stages []namedStage
+	err    error
+}
+
+// Pipeline returns the default pipeline using r's model and options.
+func (r *Reader) Pipeline() *Pipeline {
+	// Layout is the point of a pipeline, so text-only mode doesn't apply
+	o := *r.o
+	o.textOnly = false
+	return newPipeline(r.pred, &o)
+}
+
+// newPipeline returns the default pipeline for pred configured by o.
+func newPipeline(pred predictor.Recognizer, o *options) *Pipeline {
+	s := &pageStages{pred: pred, seg: segmenter.NewLineSegmenter(10, 3), o: o}
+	return &Pipeline{stages: []namedStage{
+		{StageDecode, StageFunc(s.decode)},
+		{StagePreprocess, StageFunc(s.preprocess)},
+		{StageSegment, StageFunc(s.segment)},
+		{StageRecognize, StageFunc(s.recognize)},
+		{StagePostprocess, StageFunc(s.postprocess)},
+		{StageOutput, StageFunc(s.output)},
+	}}
+}
+
+// pageStages implements the default stages. Every path that reads a page
+// runs them, through a Pipeline or through recognizePage, which runs
+// segment and recognize alone.
+type pageStages struct {
+	pred predictor.Recognizer
+	seg  *segmenter.LineSegmenter
+	o    *options
+}
+
+func (s *pageStages) decode(ctx context.Context, doc *Document) error {
+	if doc.Image != nil {
+		return nil
+	}
+	img, err := decodeFile(doc.Path)
+	doc.Image = img
+	return err
+}
+
+func (s *pageStages) preprocess(ctx context.Context, doc *Document) error {
+	img, err := s.o.orientImage(s.pred, doc.Image)
+	doc.Image = img
+	return err
+}
+
+func (s *pageStages) segment(ctx context.Context, doc *Document) error {
+	lines, stats, err := s.seg.SegmentWithStats(doc.Image)
+	if stats.Discarded > 0 {
+		doc.Warnings = append(doc.Warnings, fmt.Sprintf("%d lines discarded below MinLineH", stats.Discarded))
+	}
+	doc.Segments = doc.Segments[:0]
+	if err != nil || len(lines) == 0 {
+		// The recognize stage falls back to reading the whole page as a
+		// single line
+		doc.Warnings = append(doc.Warnings, "fallback segmentation used")
+		return nil
+	}
+	for _, l := range lines {
+		doc.Segments = append(doc.Segments, Segment{Image: l.Img, BBox: l.BBox})
+	}
+	return nil
+}
+
+// recognize reads every segment, in batches when the recognizer supports
+// them, and leaves the lines kept by WithMinConfidence in reading order.
+// Lines read before ctx is done stay on the page.
+func (s *pageStages) recognize(ctx context.Context, doc *Document) error {
+	b := doc.Image.Bounds()
+	doc.Page.Width, doc.Page.Height = b.Dx(), b.Dy()
+	defer func() {
+		doc.Page.Lines = s.o.keepLines(doc.Page.Lines)
+		orderLines(doc.Page.Lines)
+	}()
+
+	if len(doc.Segments) == 0 {
+		line, err := recognizeLine(s.pred, doc.Image, b, s.o)
+		if err != nil {
+			doc.Warnings = append(doc.Warnings, fmt.Sprintf("recognition failed: %v", err))
+			return nil
+		}
+		doc.Page.Lines = append(doc.Page.Lines, line)
+		return nil
+	}
+
+	var lines []Line
+	var failed int
+	var err error
+	if batch, ok := s.pred.(predictor.BatchRecognizer); ok && s.o.batchSize > 1 {
+		lines, failed, err = recognizeBatches(ctx, batch, doc.Segments, s.o)
+	} else {
+		for _, seg := range doc.Segments {
+			if err = ctx.Err(); err != nil {
+				break
+			}
+			line, lineErr := recognizeLine(s.pred, seg.Image, seg.BBox, s.o)
+			if lineErr != nil {
+				failed++
+				continue
+			}
+			lines = append(lines, line)
+		}
+	}
+	doc.Page.Lines = append(doc.Page.Lines, lines...)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		doc.Warnings = append(doc.Warnings, fmt.Sprintf("%d lines failed recognition", failed))
+	}
+	return nil
+}
+
+func (s *pageStages) postprocess(ctx context.Context, doc *Document) error {
+	if doc.Page.Number == 0 {
+		doc.Page.Number = 1
+	}
+	if doc.Path != "" {
+		doc.Page.setFontSizes(imageDPI(doc.Path))
+	}
+	doc.Page.setLineIDs()
+	if !s.o.textOnly {
+		doc.Page.Quality = assessPage(doc.Image, doc.Page)
+	}
+	doc.Matches = append(doc.Matches, s.o.extract(doc.Page)...)
+	return nil
+}
+
+func (s *pageStages) output(ctx context.Context, doc *Document) error {
+	doc.Text = doc.Page.Text()
+	return nil
+}
+
+// Stages returns the names of the pipeline's stages in order.
+func (p *Pipeline) Stages() []string {
+	names := make([]string, len(p.stages))
+	for i, s := range p.stages {
+		names[i] = s.name
+	}
+	return names
+}
+
+// Stage returns the stage called name, or nil if there is none, e.g. to
+// wrap a default stage in a replacement.
+func (p *Pipeline) Stage(name string) Stage {
+	if i := p.index(name); i >= 0 {
+		return p.stages[i].stage
+	}
+	return nil
+}
+
+// Remove drops the stage called name.
+func (p *Pipeline) Remove(name string) *Pipeline {
+	if i := p.find(name); i >= 0 {
+		p.stages = slices.Delete(p.stages, i, i+1)
+	}
+	return p
+}
+
+// Replace swaps the stage called name for stage, keeping its name and
+// place.
+func (p *Pipeline) Replace(name string, stage Stage) *Pipeline {
+	if i := p.find(name); i >= 0 {
+		p.stages[i].stage = stage
+	}
+	return p
+}
+
+// InsertBefore adds stage, called name, before the stage called before.
+func (p *Pipeline) InsertBefore(before, name string, stage Stage) *Pipeline {
+	if i := p.find(before); i >= 0 && p.unique(name) {
+		p.stages = slices.Insert(p.stages, i, namedStage{name, stage})
+	}
+	return p
+}
+
+// InsertAfter adds stage, called name, after the stage called after.
+func (p *Pipeline) InsertAfter(after, name string, stage Stage) *Pipeline {
+	if i := p.find(after); i >= 0 && p.unique(name) {
+		p.stages = slices.Insert(p.stages, i+1, namedStage{name, stage})
+	}
+	return p
+}
+
+// Order rearranges the pipeline to run the named stages in the given
+// order. Stages left out are dropped.
+func (p *Pipeline) Order(names ...string) *Pipeline {
+	var stages []namedStage
+	for _, name := range names {
+		i := p.find(name)
+		if i < 0 {
+			return p
+		}
+		if slices.ContainsFunc(stages, func(s namedStage) bool { return s.name == name }) {
+			p.fail("stage %q given twice", name)
+			return p
+		}
+		stages = append(stages, p.stages[i])
+	}
+	p.stages = stages
+	return p
+}
+
+// Run reads the image at path through the pipeline.
+func (p *Pipeline) Run(ctx context.Context, path string) (*Document, error) {
+	return p.run(ctx, &Document{Path: path})
+}
+
+// RunImage runs an already decoded image through the pipeline; the
+// default decode stage leaves it as is.
+func (p *Pipeline) RunImage(ctx context.Context, img image.Image) (*Document, error) {
+	return p.run(ctx, &Document{Image: img})
+}
+
+func (p *Pipeline) run(ctx context.Context, doc *Document) (*Document, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	for _, s := range p.stages {
+		if err := ctx.Err(); err != nil {
+			return doc, err
+		}
+		if err := s.stage.Run(ctx, doc); err != nil {
+			return doc, fmt.Errorf("%s: %w", s.name, err)
+		}
+	}
+	return doc, nil
+}
+
+func (p *Pipeline) index(name string) int {
+	return slices.IndexFunc(p.stages, func(s namedStage) bool { return s.name == name })
+}
+
+// find returns the index of the stage called name, recording an error if
+// there is none.
+func (p *Pipeline) find(name string) int {
+	i := p.index(name)
+	if i < 0 {
+		p.fail("no stage %q", name)
+	}
+	return i
+}
+
+// unique reports whether no stage is called name yet, recording an error
+// otherwise.
+func (p *Pipeline) unique(name string) bool {
+	if p.index(name) >= 0 {
+		p.fail("stage %q already exists", name)
+		return false
+	}
+	return true
+}
+
+// fail records the first builder error, returned by Run.
+func (p *Pipeline) fail(format string, args ...any) {
+	if p.err == nil {
+		p.err = fmt.Errorf("pipeline: "+format, args...)
+	}
+}
@@ -0,0 +1,101 @@
+// Package bench measures recognition speed on fixed inputs and compares
+// it with reference numbers for classes of hardware, to spot installs that
+// run far slower than they should: a GPU build that fell back to the CPU,
+// a container limited to one core, or an oversubscribed host.
+package bench
+
+import (
+	"fmt"
+	"image"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// DefaultDuration is how long Run times each workload by default.
+const DefaultDuration = 5 * time.Second
+
+// minRuns is how many times every workload runs however slow it is, so
+// the latency percentiles mean something.
+const minRuns = 3
+
+// Recognizer is what the benchmark times. *monocr.Reader satisfies it.
+type Recognizer interface {
+	Recognize(img image.Image) (string, error)
+}
+
+// Host describes the setup a benchmark ran on. It selects the reference
+// class the results are compared with.
+type Host struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	CPUs int    `json:"cpus"`
+	// Provider is the execution provider asked for, such as
+	// predictor.ProviderCUDA; empty means the CPU.
+	Provider string `json:"provider,omitempty"`
+	// Variant is the model variant, such as model.VariantInt8; empty
+	// means the full-precision model.
+	Variant string `json:"variant,omitempty"`
+}
+
+// LocalHost returns the Host for this machine running on provider with
+// the model variant.
+func LocalHost(provider, variant string) Host {
+	return Host{OS: runtime.GOOS, Arch: runtime.GOARCH, CPUs: runtime.NumCPU(), Provider: provider, Variant: variant}
+}
+
+// Result is the timing of one workload.
+type Result struct {
+	Workload string `json:"workload"`
+	Runs     int    `json:"runs"`
+	// Rate is runs per second.
+	Rate float64       `json:"rate"`
+	P50  time.Duration `json:"p50"`
+	P95  time.Duration `json:"p95"`
+}
+
+// Report is the outcome of a benchmark.
+type Report struct {
+	Host    Host     `json:"host"`
+	Results []Result `json:"results"`
+}
+
+// Run recognizes each workload with rec repeatedly for about d, after one
+// untimed run that pays for allocation and graph optimization, and
+// reports the rates on host.
+func Run(rec Recognizer, host Host, workloads []Workload, d time.Duration) (*Report, error) {
+	report := &Report{Host: host}
+	for _, w := range workloads {
+		if _, err := rec.Recognize(w.Image); err != nil {
+			return nil, fmt.Errorf("%s: %v", w.Name, err)
+		}
+
+		var times []time.Duration
+		start := time.Now()
+		for len(times) < minRuns || time.Since(start) < d {
+			t := time.Now()
+			if _, err := rec.Recognize(w.Image); err != nil {
+				return nil, fmt.Errorf("%s: %v", w.Name, err)
+			}
+			times = append(times, time.Since(t))
+		}
+		elapsed := time.Since(start)
+
+		sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+		report.Results = append(report.Results, Result{
+			Workload: w.Name,
+			Runs:     len(times),
+			Rate:     float64(len(times)) / elapsed.Seconds(),
+			P50:      percentile(times, 0.50),
+			P95:      percentile(times, 0.95),
+		})
+	}
+	return report, nil
+}
+
+// percentile returns the p-th percentile of sorted times by the nearest
+// rank.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(p*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
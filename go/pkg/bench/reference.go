@@ -0,0 +1,92 @@
+package bench
+
+import (
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+)
+
+// SlowRatio is the fraction of the reference rate below which a result is
+// flagged as slow. Hosts within a class differ, but not by half.
+const SlowRatio = 0.5
+
+// Reference is the measured speed of a class of hardware on the published
+// model, for each workload.
+type Reference struct {
+	// Class names the hardware class.
+	Class string `json:"class"`
+	// Provider and Variant are as in Host.
+	Provider string `json:"provider"`
+	Variant  string `json:"variant,omitempty"`
+	// OS limits the class to one operating system when set.
+	OS string `json:"os,omitempty"`
+	// MinCPUs is the fewest cores a host needs to be in the class.
+	MinCPUs int `json:"min_cpus,omitempty"`
+	// Rates are the measured runs per second by workload name.
+	Rates map[string]float64 `json:"rates"`
+	// Source records where the rates were measured: the machine, the
+	// ONNX Runtime and model versions, and the date.
+	Source string `json:"source"`
+}
+
+// References are the measured reference numbers, one per class of
+// hardware. Each entry must come from running "monocr bench --json" with
+// the published model on hardware of that class, recorded in Source, so
+// that a host flagged as slow is slow against a real machine. It is empty
+// until such measurements are collected; until then hosts have no class
+// and bench only reports their rates.
+var References []Reference
+
+// Classify returns the reference class of host: the one for its provider
+// and model variant with the most cores the host has. It returns nil if
+// no class fits, e.g. for a single core.
+func Classify(host Host) *Reference {
+	provider := host.Provider
+	if provider == "" {
+		provider = predictor.ProviderCPU
+	}
+	var best *Reference
+	for i := range References {
+		ref := &References[i]
+		if ref.Provider != provider || ref.Variant != host.Variant || ref.OS != "" && ref.OS != host.OS {
+			continue
+		}
+		if ref.MinCPUs > host.CPUs {
+			continue
+		}
+		if best == nil || ref.MinCPUs > best.MinCPUs {
+			best = ref
+		}
+	}
+	return best
+}
+
+// Comparison is a result set against its reference rate.
+type Comparison struct {
+	Workload string  `json:"workload"`
+	Rate     float64 `json:"rate"`
+	Expected float64 `json:"expected"`
+	// Ratio is Rate over Expected.
+	Ratio float64 `json:"ratio"`
+	// Slow is set when Ratio is below SlowRatio.
+	Slow bool `json:"slow"`
+}
+
+// Compare sets each result of r against ref. Workloads ref has no number
+// for are left out.
+func (r *Report) Compare(ref *Reference) []Comparison {
+	var out []Comparison
+	for _, res := range r.Results {
+		expected, ok := ref.Rates[res.Workload]
+		if !ok || expected <= 0 {
+			continue
+		}
+		ratio := res.Rate / expected
+		out = append(out, Comparison{
+			Workload: res.Workload,
+			Rate:     res.Rate,
+			Expected: expected,
+			Ratio:    ratio,
+			Slow:     ratio < SlowRatio,
+		})
+	}
+	return out
+}
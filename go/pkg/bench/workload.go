@@ -0,0 +1,76 @@
+package bench
+
+import (
+	"image"
+	"image/color"
+)
+
+// Workload is a fixed input the benchmark times.
+type Workload struct {
+	// Name identifies the workload in results and reference numbers.
+	Name string
+	// Image is recognized once per run.
+	Image image.Image
+}
+
+// Workloads returns the fixed inputs of the benchmark: a single text line
+// and a page of 30 lines. They are drawn the same way every time, so
+// results from different hosts are comparable.
+func Workloads() []Workload {
+	return []Workload{
+		{Name: "line", Image: drawPage(800, 1)},
+		{Name: "page", Image: drawPage(1240, 30)},
+	}
+}
+
+// Geometry of the drawn text, in pixels.
+const (
+	margin     = 60
+	lineHeight = 40
+	lineGap    = 24
+)
+
+// drawPage draws lines of dark strokes grouped into words on a light
+// background, width pixels wide. The strokes don't spell anything, but
+// segment and recognize like text for timing.
+func drawPage(width, lines int) image.Image {
+	height := lines*(lineHeight+lineGap) + 2*margin - lineGap
+	if lines == 1 {
+		height = lineHeight + 24
+	}
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 235
+	}
+
+	top := (height - lineHeight) / 2
+	if lines > 1 {
+		top = margin
+	}
+	for n := 0; n < lines; n++ {
+		y0 := top + n*(lineHeight+lineGap)
+		// Lines vary in length like paragraph text, with the last one short
+		end := width - margin/2 - (n*37)%(width/6)
+		if n == lines-1 && lines > 1 {
+			end = width / 2
+		}
+		for x := margin / 2; x < end; x++ {
+			word := (x + n*53) % 97
+			if word > 84 {
+				continue // gap between words
+			}
+			for y := y0; y < y0+lineHeight; y++ {
+				// Strokes fill the x-height and some rise or descend
+				dy := y - y0
+				tall := (x/11+n)%5 == 0
+				if dy < 10 && !tall || dy >= 30 && (x/13+n)%7 != 0 {
+					continue
+				}
+				if (x*7+y*3)%23 < 4 {
+					img.SetGray(x, y, color.Gray{Y: 30})
+				}
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,208 @@
+package dedup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math/bits"
+	"os"
+
+	"golang.org/x/image/draw"
+)
+
+// Mode selects how duplicates are detected.
+type Mode int
+
+const (
+	// Exact groups byte-identical files.
+	Exact Mode = iota
+	// Perceptual additionally groups images whose difference hashes are
+	// within MaxDistance bits and whose pixels agree when compared at
+	// confirmWidth, catching re-encoded or re-saved copies. Pages that only
+	// share a layout hash alike too, so a hash match alone is never merged.
+	Perceptual
+)
+
+// MaxDistance is the largest Hamming distance between difference hashes
+// for two images to be compared pixel by pixel in Perceptual mode.
+const MaxDistance = 2
+
+const (
+	// confirmWidth is the width both images are scaled to for the pixel
+	// comparison; body text is still a few pixels tall there, so a changed
+	// word shows.
+	confirmWidth = 512
+	// confirmTolerance is how far apart two scaled pixels may be and still
+	// count as the same, which absorbs re-encoding noise.
+	confirmTolerance = 32
+	// confirmOutliers is the fraction of pixels that may differ by more
+	// than confirmTolerance.
+	confirmOutliers = 1e-4
+)
+
+// Group is a set of files with the same content. Path is processed; its
+// result is reused for every entry in Duplicates. Suspected lists later
+// files whose hash matched Path's but whose pixels didn't; they are
+// processed on their own and each also starts a group of its own.
+type Group struct {
+	Path       string   `json:"path"`
+	Duplicates []string `json:"duplicates,omitempty"`
+	Suspected  []string `json:"suspected,omitempty"`
+}
+
+// Find groups paths by content, preserving the input order of the first
+// occurrence of each group.
+func Find(paths []string, mode Mode) ([]Group, error) {
+	var groups []Group
+	bySum := make(map[string]int)
+	var hashes []*uint64 // difference hash per group, Perceptual mode only
+
+	for _, path := range paths {
+		sum, err := fileSum(path)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := bySum[sum]; ok {
+			groups[i].Duplicates = append(groups[i].Duplicates, path)
+			continue
+		}
+
+		if mode == Perceptual {
+			// Files that fail to decode are only matched exactly.
+			var hp *uint64
+			if img, err := decodeFile(path); err == nil {
+				h := dHash(img)
+				if i, confirmed := match(groups, hashes, h, img); confirmed {
+					groups[i].Duplicates = append(groups[i].Duplicates, path)
+					bySum[sum] = i
+					continue
+				} else if i >= 0 {
+					groups[i].Suspected = append(groups[i].Suspected, path)
+				}
+				hp = &h
+			}
+			hashes = append(hashes, hp)
+		}
+
+		bySum[sum] = len(groups)
+		groups = append(groups, Group{Path: path})
+	}
+	return groups, nil
+}
+
+// match returns the first group whose hash is within MaxDistance of h
+// and whose image agrees with img pixel by pixel, with confirmed set. If
+// hashes match but no image agrees it returns the first such group
+// unconfirmed, and -1 if no hash matches.
+func match(groups []Group, hashes []*uint64, h uint64, img image.Image) (int, bool) {
+	suspect := -1
+	var thumb *image.Gray
+	for i, other := range hashes {
+		if other == nil || bits.OnesCount64(h^*other) > MaxDistance {
+			continue
+		}
+		if suspect < 0 {
+			suspect = i
+		}
+		// Representatives are decoded again rather than kept, so memory
+		// doesn't grow with the number of unique files
+		rep, err := decodeFile(groups[i].Path)
+		if err != nil {
+			continue
+		}
+		if thumb == nil {
+			thumb = thumbnail(img)
+		}
+		if samePixels(thumb, thumbnail(rep)) {
+			return i, true
+		}
+	}
+	return suspect, false
+}
+
+// thumbnail scales img to confirmWidth wide in grayscale, keeping its
+// aspect ratio.
+func thumbnail(img image.Image) *image.Gray {
+	b := img.Bounds()
+	h := 1
+	if b.Dx() > 0 {
+		h = max(1, (b.Dy()*confirmWidth+b.Dx()/2)/b.Dx())
+	}
+	small := image.NewGray(image.Rect(0, 0, confirmWidth, h))
+	draw.BiLinear.Scale(small, small.Bounds(), img, b, draw.Src, nil)
+	return small
+}
+
+// samePixels reports whether two thumbnails have the same shape and at
+// most confirmOutliers of their pixels differ by more than
+// confirmTolerance.
+func samePixels(a, b *image.Gray) bool {
+	if a.Rect != b.Rect {
+		return false
+	}
+	limit := int(float64(len(a.Pix)) * confirmOutliers)
+	outliers := 0
+	for i, v := range a.Pix {
+		if d := int(v) - int(b.Pix[i]); d > confirmTolerance || d < -confirmTolerance {
+			if outliers++; outliers > limit {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func fileSum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// DHash computes a 64-bit difference hash of the image at path: the image
+// is shrunk to 9x8 grayscale and each bit records whether a pixel is
+// brighter than its right-hand neighbour.
+func DHash(path string) (uint64, error) {
+	img, err := decodeFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return dHash(img), nil
+}
+
+func dHash(img image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var h uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			h <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+func decodeFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
@@ -0,0 +1,33 @@
+package imgproc
+
+// The generic kernels are branch free so the compiler can keep them
+// tight; they also finish the tails the assembly leaves over.
+
+func countBelowGeneric(pix []byte, t uint8) int {
+	n := 0
+	for _, v := range pix {
+		n += int((uint32(v) - uint32(t)) >> 31)
+	}
+	return n
+}
+
+func thresholdGeneric(dst, src []byte, t uint8) {
+	dst = dst[:len(src)]
+	for i, v := range src {
+		dst[i] = byte((int32(t) - int32(v)) >> 31)
+	}
+}
+
+func invertGeneric(dst, src []byte) {
+	dst = dst[:len(src)]
+	for i, v := range src {
+		dst[i] = ^v
+	}
+}
+
+func normalizeGeneric(dst []float32, src []byte) {
+	dst = dst[:len(src)]
+	for i, v := range src {
+		dst[i] = float32(v) / 255
+	}
+}
@@ -0,0 +1,122 @@
+package imgproc
+
+import (
+	"image"
+	"image/color"
+)
+
+// Gray returns img in grayscale with the same bounds, converting each
+// pixel exactly as color.GrayModel does. An *image.Gray is returned as is,
+// so callers that modify the result should use ConvertGray instead.
+func Gray(img image.Image) *image.Gray {
+	if g, ok := img.(*image.Gray); ok {
+		return g
+	}
+	g := image.NewGray(img.Bounds())
+	ConvertGray(g, img)
+	return g
+}
+
+// ConvertGray writes src in grayscale to dst, which must be the same size;
+// their origins may differ. Gray, RGBA, NRGBA and YCbCr images are read
+// directly, other types through At.
+func ConvertGray(dst *image.Gray, src image.Image) {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if dst.Rect.Dx() != w || dst.Rect.Dy() != h {
+		panic("imgproc: ConvertGray size mismatch")
+	}
+
+	for y := 0; y < h; y++ {
+		row := dst.Pix[y*dst.Stride : y*dst.Stride+w]
+		sy := b.Min.Y + y
+		switch src := src.(type) {
+		case *image.Gray:
+			i := src.PixOffset(b.Min.X, sy)
+			copy(row, src.Pix[i:i+w])
+		case *image.RGBA:
+			i := src.PixOffset(b.Min.X, sy)
+			grayRGBA(row, src.Pix[i:i+4*w])
+		case *image.NRGBA:
+			i := src.PixOffset(b.Min.X, sy)
+			grayNRGBA(row, src.Pix[i:i+4*w])
+		case *image.YCbCr:
+			for x := range row {
+				r, g, bl, _ := src.YCbCrAt(b.Min.X+x, sy).RGBA()
+				row[x] = luma(r, g, bl)
+			}
+		default:
+			for x := range row {
+				row[x] = color.GrayModel.Convert(src.At(b.Min.X+x, sy)).(color.Gray).Y
+			}
+		}
+	}
+}
+
+// luma is color.GrayModel's conversion of 16-bit premultiplied RGB.
+func luma(r, g, b uint32) uint8 {
+	return uint8((19595*r + 38470*g + 7471*b + 1<<15) >> 24)
+}
+
+func grayRGBA(dst, pix []byte) {
+	pix = pix[:4*len(dst)]
+	for x := range dst {
+		p := pix[4*x : 4*x+4 : 4*x+4]
+		dst[x] = luma(uint32(p[0])*0x101, uint32(p[1])*0x101, uint32(p[2])*0x101)
+	}
+}
+
+func grayNRGBA(dst, pix []byte) {
+	pix = pix[:4*len(dst)]
+	for x := range dst {
+		p := pix[4*x : 4*x+4 : 4*x+4]
+		r, g, b, a := uint32(p[0])*0x101, uint32(p[1])*0x101, uint32(p[2])*0x101, uint32(p[3])
+		if a != 0xff {
+			r = r * a / 0xff
+			g = g * a / 0xff
+			b = b * a / 0xff
+		}
+		dst[x] = luma(r, g, b)
+	}
+}
+
+// CountBelow returns how many values in pix are less than t.
+func CountBelow(pix []byte, t uint8) int {
+	if t == 0 {
+		return 0
+	}
+	return countBelow(pix, t)
+}
+
+// Threshold sets dst[i] to 255 where src[i] > t and to 0 elsewhere. dst
+// must be at least as long as src and may be src itself.
+func Threshold(dst, src []byte, t uint8) {
+	dst = dst[:len(src)]
+	if t == 255 {
+		clear(dst)
+		return
+	}
+	threshold(dst, src, t)
+}
+
+// Invert sets dst[i] to 255-src[i]. dst must be at least as long as src
+// and may be src itself.
+func Invert(dst, src []byte) {
+	invert(dst[:len(src)], src)
+}
+
+// Normalize scales 8-bit values to [0, 1] floats, dst[i] = src[i]/255,
+// with the same rounding as the division in Go. dst must be at least as
+// long as src.
+func Normalize(dst []float32, src []byte) {
+	normalize(dst[:len(src)], src)
+}
+
+// Kernels names the instruction set the kernels selected at startup use,
+// "avx2" or "generic".
+func Kernels() string {
+	if useAVX2 {
+		return "avx2"
+	}
+	return "generic"
+}
@@ -0,0 +1,94 @@
+package imgproc
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// thresholds covers both ends of the byte range, where the AVX2 kernels'
+// off-by-one limits would wrap, and values around the middle.
+var thresholds = []uint8{0, 1, 127, 128, 254, 255}
+
+// testPixels returns n pixels starting at offset into a larger buffer, so
+// the kernels also see unaligned input. Every run of 256 holds each value
+// once, including 0 and 255.
+func testPixels(rng *rand.Rand, offset, n int) []byte {
+	buf := make([]byte, offset+n)
+	for i := range buf {
+		buf[i] = byte(i)
+	}
+	rng.Shuffle(len(buf), func(i, j int) { buf[i], buf[j] = buf[j], buf[i] })
+	return buf[offset:]
+}
+
+func TestKernelsMatchGeneric(t *testing.T) {
+	t.Logf("kernels: %s", Kernels())
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 300; n++ {
+		for _, offset := range []int{0, 1, 7} {
+			src := testPixels(rng, offset, n)
+
+			for _, th := range thresholds {
+				if got, want := CountBelow(src, th), countBelowGeneric(src, th); got != want {
+					t.Fatalf("CountBelow(len %d, offset %d, t %d) = %d, want %d", n, offset, th, got, want)
+				}
+
+				got, want := make([]byte, n), make([]byte, n)
+				Threshold(got, src, th)
+				thresholdGeneric(want, src, th)
+				if !slices.Equal(got, want) {
+					t.Fatalf("Threshold(len %d, offset %d, t %d) = %v, want %v", n, offset, th, got, want)
+				}
+				inPlace := slices.Clone(src)
+				Threshold(inPlace, inPlace, th)
+				if !slices.Equal(inPlace, want) {
+					t.Fatalf("Threshold in place (len %d, offset %d, t %d) = %v, want %v", n, offset, th, inPlace, want)
+				}
+			}
+
+			got, want := make([]byte, n), make([]byte, n)
+			Invert(got, src)
+			invertGeneric(want, src)
+			if !slices.Equal(got, want) {
+				t.Fatalf("Invert(len %d, offset %d) = %v, want %v", n, offset, got, want)
+			}
+			inPlace := slices.Clone(src)
+			Invert(inPlace, inPlace)
+			if !slices.Equal(inPlace, want) {
+				t.Fatalf("Invert in place (len %d, offset %d) = %v, want %v", n, offset, inPlace, want)
+			}
+
+			gotF, wantF := make([]float32, n), make([]float32, n)
+			Normalize(gotF, src)
+			normalizeGeneric(wantF, src)
+			if !slices.Equal(gotF, wantF) {
+				t.Fatalf("Normalize(len %d, offset %d) = %v, want %v", n, offset, gotF, wantF)
+			}
+		}
+	}
+}
+
+// TestKernelsInside checks the dispatching kernels directly for the
+// thresholds the exported functions pass through to them.
+func TestKernelsInside(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for n := 0; n < 300; n++ {
+		src := testPixels(rng, 3, n)
+		for _, th := range thresholds {
+			if th != 0 {
+				if got, want := countBelow(src, th), countBelowGeneric(src, th); got != want {
+					t.Fatalf("countBelow(len %d, t %d) = %d, want %d", n, th, got, want)
+				}
+			}
+			if th != 255 {
+				got, want := make([]byte, n), make([]byte, n)
+				threshold(got, src, th)
+				thresholdGeneric(want, src, th)
+				if !slices.Equal(got, want) {
+					t.Fatalf("threshold(len %d, t %d) = %v, want %v", n, th, got, want)
+				}
+			}
+		}
+	}
+}
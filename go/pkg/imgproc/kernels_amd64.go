@@ -0,0 +1,86 @@
+//go:build amd64 && !purego
+
+package imgproc
+
+// useAVX2 is set once at startup from CPUID: the CPU must have AVX2 and
+// POPCNT, and the OS must save the YMM registers on context switches.
+var useAVX2 = detectAVX2()
+
+func detectAVX2() bool {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 7 {
+		return false
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	const (
+		popcnt  = 1 << 23
+		osxsave = 1 << 27
+		avx     = 1 << 28
+	)
+	if ecx1&(popcnt|osxsave|avx) != popcnt|osxsave|avx {
+		return false
+	}
+	if xcr0, _ := xgetbv(); xcr0&6 != 6 { // XMM and YMM state
+		return false
+	}
+	_, ebx7, _, _ := cpuid(7, 0)
+	return ebx7&(1<<5) != 0
+}
+
+//go:noescape
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+//go:noescape
+func xgetbv() (eax, edx uint32)
+
+// The AVX2 kernels process whole 32-byte blocks (8 values for normalize)
+// and leave the rest to the generic code.
+
+//go:noescape
+func countBelowAVX2(src *byte, n int, limit uint8) int
+
+//go:noescape
+func thresholdAVX2(dst, src *byte, n int, limit uint8)
+
+//go:noescape
+func invertAVX2(dst, src *byte, n int)
+
+//go:noescape
+func normalizeAVX2(dst *float32, src *byte, n int)
+
+func countBelow(pix []byte, t uint8) int {
+	n := 0
+	if useAVX2 && len(pix) >= 32 {
+		m := len(pix) &^ 31
+		n = countBelowAVX2(&pix[0], m, t-1)
+		pix = pix[m:]
+	}
+	return n + countBelowGeneric(pix, t)
+}
+
+func threshold(dst, src []byte, t uint8) {
+	if useAVX2 && len(src) >= 32 {
+		m := len(src) &^ 31
+		thresholdAVX2(&dst[0], &src[0], m, t+1)
+		dst, src = dst[m:], src[m:]
+	}
+	thresholdGeneric(dst, src, t)
+}
+
+func invert(dst, src []byte) {
+	if useAVX2 && len(src) >= 32 {
+		m := len(src) &^ 31
+		invertAVX2(&dst[0], &src[0], m)
+		dst, src = dst[m:], src[m:]
+	}
+	invertGeneric(dst, src)
+}
+
+func normalize(dst []float32, src []byte) {
+	if useAVX2 && len(src) >= 8 {
+		m := len(src) &^ 7
+		normalizeAVX2(&dst[0], &src[0], m)
+		dst, src = dst[m:], src[m:]
+	}
+	normalizeGeneric(dst, src)
+}
@@ -0,0 +1,153 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
+
+// func countBelowAVX2(src *byte, n int, limit uint8) int
+//
+// Counts the bytes <= limit: min(v, limit) == v.
+TEXT ·countBelowAVX2(SB), NOSPLIT, $0-32
+	MOVQ    src+0(FP), SI
+	MOVQ    n+8(FP), CX
+	MOVBQZX limit+16(FP), AX
+	MOVQ    AX, X1
+	VPBROADCASTB X1, Y1
+	XORQ    DX, DX
+
+count:
+	CMPQ      CX, $32
+	JB        countDone
+	VMOVDQU   (SI), Y0
+	VPMINUB   Y1, Y0, Y2
+	VPCMPEQB  Y0, Y2, Y2
+	VPMOVMSKB Y2, AX
+	POPCNTL   AX, AX
+	ADDQ      AX, DX
+	ADDQ      $32, SI
+	SUBQ      $32, CX
+	JMP       count
+
+countDone:
+	VZEROUPPER
+	MOVQ DX, ret+24(FP)
+	RET
+
+// func thresholdAVX2(dst, src *byte, n int, limit uint8)
+//
+// Sets the bytes >= limit to 255 and the rest to 0: max(v, limit) == v.
+TEXT ·thresholdAVX2(SB), NOSPLIT, $0-25
+	MOVQ    dst+0(FP), DI
+	MOVQ    src+8(FP), SI
+	MOVQ    n+16(FP), CX
+	MOVBQZX limit+24(FP), AX
+	MOVQ    AX, X1
+	VPBROADCASTB X1, Y1
+
+threshold:
+	CMPQ     CX, $32
+	JB       thresholdDone
+	VMOVDQU  (SI), Y0
+	VPMAXUB  Y1, Y0, Y2
+	VPCMPEQB Y0, Y2, Y2
+	VMOVDQU  Y2, (DI)
+	ADDQ     $32, SI
+	ADDQ     $32, DI
+	SUBQ     $32, CX
+	JMP      threshold
+
+thresholdDone:
+	VZEROUPPER
+	RET
+
+// func invertAVX2(dst, src *byte, n int)
+TEXT ·invertAVX2(SB), NOSPLIT, $0-24
+	MOVQ     dst+0(FP), DI
+	MOVQ     src+8(FP), SI
+	MOVQ     n+16(FP), CX
+	VPCMPEQB Y1, Y1, Y1
+
+invert:
+	CMPQ    CX, $32
+	JB      invertDone
+	VMOVDQU (SI), Y0
+	VPXOR   Y1, Y0, Y0
+	VMOVDQU Y0, (DI)
+	ADDQ    $32, SI
+	ADDQ    $32, DI
+	SUBQ    $32, CX
+	JMP     invert
+
+invertDone:
+	VZEROUPPER
+	RET
+
+// func normalizeAVX2(dst *float32, src *byte, n int)
+//
+// Widens 8 bytes at a time to int32, converts them to float32 and divides
+// by 255 rather than multiplying by its reciprocal, which matches the
+// rounding of the generic code exactly.
+TEXT ·normalizeAVX2(SB), NOSPLIT, $0-24
+	MOVQ dst+0(FP), DI
+	MOVQ src+8(FP), SI
+	MOVQ n+16(FP), CX
+	MOVL $0x437f0000, AX // 255.0
+	MOVQ AX, X4
+	VBROADCASTSS X4, Y4
+
+normalize32:
+	CMPQ      CX, $32
+	JB        normalize8
+	VPMOVZXBD (SI), Y0
+	VPMOVZXBD 8(SI), Y1
+	VPMOVZXBD 16(SI), Y2
+	VPMOVZXBD 24(SI), Y3
+	VCVTDQ2PS Y0, Y0
+	VCVTDQ2PS Y1, Y1
+	VCVTDQ2PS Y2, Y2
+	VCVTDQ2PS Y3, Y3
+	VDIVPS    Y4, Y0, Y0
+	VDIVPS    Y4, Y1, Y1
+	VDIVPS    Y4, Y2, Y2
+	VDIVPS    Y4, Y3, Y3
+	VMOVUPS   Y0, (DI)
+	VMOVUPS   Y1, 32(DI)
+	VMOVUPS   Y2, 64(DI)
+	VMOVUPS   Y3, 96(DI)
+	ADDQ      $32, SI
+	ADDQ      $128, DI
+	SUBQ      $32, CX
+	JMP       normalize32
+
+normalize8:
+	CMPQ      CX, $8
+	JB        normalizeDone
+	VPMOVZXBD (SI), Y0
+	VCVTDQ2PS Y0, Y0
+	VDIVPS    Y4, Y0, Y0
+	VMOVUPS   Y0, (DI)
+	ADDQ      $8, SI
+	ADDQ      $32, DI
+	SUBQ      $8, CX
+	JMP       normalize8
+
+normalizeDone:
+	VZEROUPPER
+	RET
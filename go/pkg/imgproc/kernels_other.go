@@ -0,0 +1,13 @@
+//go:build !amd64 || purego
+
+package imgproc
+
+const useAVX2 = false
+
+func countBelow(pix []byte, t uint8) int { return countBelowGeneric(pix, t) }
+
+func threshold(dst, src []byte, t uint8) { thresholdGeneric(dst, src, t) }
+
+func invert(dst, src []byte) { invertGeneric(dst, src) }
+
+func normalize(dst []float32, src []byte) { normalizeGeneric(dst, src) }
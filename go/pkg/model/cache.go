@@ -0,0 +1,125 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CachedModel is a model file in the cache.
+type CachedModel struct {
+	// Version is the pinned version, or "" for the latest model.
+	Version string `json:"version"`
+	// Variant is the model variant, or "" for the full-precision model.
+	Variant string    `json:"variant,omitempty"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Cached lists the models in the cache directory: the latest model and
+// every pinned version, with their variants, in version order.
+func (m *Manager) Cached() ([]CachedModel, error) {
+	var models []CachedModel
+	// add lists the variants of name found in dir
+	add := func(version, dir, name string) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		for _, e := range entries {
+			variant, ok := "", e.Name() == name
+			if !ok && strings.HasPrefix(e.Name(), base+"-") && strings.HasSuffix(e.Name(), ext) {
+				variant, ok = strings.TrimSuffix(strings.TrimPrefix(e.Name(), base+"-"), ext), true
+			}
+			info, err := e.Info()
+			if !ok || err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			models = append(models, CachedModel{
+				Version: version,
+				Variant: variant,
+				Path:    filepath.Join(dir, e.Name()),
+				Size:    info.Size(),
+				ModTime: info.ModTime(),
+			})
+		}
+		return nil
+	}
+
+	if err := add("", m.CacheDir, ModelFilename); err != nil {
+		return nil, err
+	}
+	versions, err := os.ReadDir(filepath.Join(m.CacheDir, "monocr"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, v := range versions {
+		if v.IsDir() {
+			if err := add(v.Name(), filepath.Join(m.CacheDir, "monocr", v.Name()), "model.onnx"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.SliceStable(models, func(i, j int) bool {
+		if models[i].Version != models[j].Version {
+			return models[i].Version < models[j].Version
+		}
+		return models[i].Variant < models[j].Variant
+	})
+	return models, nil
+}
+
+// Stale lists what can be removed from the cache: models other than the
+// one the Manager is set to use and leftovers of interrupted downloads.
+// Directories of other versions are listed whole; other variants of the
+// version in use are listed file by file.
+func (m *Manager) Stale() ([]string, error) {
+	current := m.ModelPath()
+	var stale []string
+
+	models, err := m.Cached()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, model := range models {
+		if model.Path == current {
+			continue
+		}
+		path := model.Path
+		if model.Version != "" && model.Version != m.Version {
+			path = filepath.Dir(model.Path)
+		}
+		if !seen[path] {
+			seen[path] = true
+			stale = append(stale, path)
+		}
+	}
+
+	// Downloads write to .tmp and .partial files and .tmp directories next
+	// to their target
+	dirs := []string{m.CacheDir}
+	if m.Version != "" {
+		dirs = append(dirs, filepath.Dir(current))
+	}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), ".tmp") || strings.HasSuffix(e.Name(), ".partial") {
+				stale = append(stale, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	return stale, nil
+}
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Card is a model card: a JSON manifest shipped with a retrained model so
+// that it carries its own charset, such as
+//
+//	{"name": "monocr-punct", "model": "monocr-punct.onnx", "charset_file": "charset.txt"}
+//
+// Paths in a card are relative to the card.
+type Card struct {
+	Name string `json:"name,omitempty"`
+	// Model is the model file.
+	Model string `json:"model,omitempty"`
+	// Charset lists the model's output classes after blank, in order.
+	// CharsetFile names a charset file instead.
+	Charset     string `json:"charset,omitempty"`
+	CharsetFile string `json:"charset_file,omitempty"`
+}
+
+// LoadCard reads a model card and resolves its paths against the card's
+// directory.
+func LoadCard(path string) (*Card, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model card: %v", err)
+	}
+	var card Card
+	if err := json.Unmarshal(data, &card); err != nil {
+		return nil, fmt.Errorf("invalid model card %s: %v", path, err)
+	}
+	if card.Charset != "" && card.CharsetFile != "" {
+		return nil, fmt.Errorf("invalid model card %s: give charset or charset_file, not both", path)
+	}
+
+	dir := filepath.Dir(path)
+	if card.Model != "" && !filepath.IsAbs(card.Model) {
+		card.Model = filepath.Join(dir, card.Model)
+	}
+	if card.CharsetFile != "" && !filepath.IsAbs(card.CharsetFile) {
+		card.CharsetFile = filepath.Join(dir, card.CharsetFile)
+	}
+	return &card, nil
+}
+
+// CardPath returns where the card of modelPath would be: the model's path
+// with .json in place of its extension.
+func CardPath(modelPath string) string {
+	return strings.TrimSuffix(modelPath, filepath.Ext(modelPath)) + ".json"
+}
+
+// LoadCharset returns the card's charset, reading CharsetFile if it names
+// one, or "" if the card gives neither.
+func (c *Card) LoadCharset() (string, error) {
+	if c.CharsetFile != "" {
+		return readCharset(c.CharsetFile)
+	}
+	return strings.TrimSpace(c.Charset), nil
+}
+
+// LoadCharset reads a charset from a text file holding the output classes
+// after blank, in order, or from a model card if path ends in .json.
+func LoadCharset(path string) (string, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		card, err := LoadCard(path)
+		if err != nil {
+			return "", err
+		}
+		charset, err := card.LoadCharset()
+		if err != nil {
+			return "", err
+		}
+		if charset == "" {
+			return "", fmt.Errorf("model card %s has no charset", path)
+		}
+		return charset, nil
+	}
+	return readCharset(path)
+}
+
+func readCharset(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read charset: %v", err)
+	}
+	charset := strings.TrimSpace(strings.TrimPrefix(string(data), "\ufeff"))
+	if charset == "" {
+		return "", fmt.Errorf("charset %s is empty", path)
+	}
+	return charset, nil
+}
@@ -0,0 +1,531 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	ModelFilename = "monocr.onnx"
+	ModelURL      = "https://huggingface.co/janakhpon/monocr/resolve/main/onnx/monocr.onnx"
+
+	// VersionedModelURL is where a pinned version is downloaded from.
+	// {version} in it, or in a custom URL, is replaced by the version.
+	VersionedModelURL = "https://huggingface.co/janakhpon/monocr/resolve/{version}/onnx/monocr.onnx"
+
+	// DefaultChunks is the number of parallel range requests used when the
+	// server supports them.
+	DefaultChunks = 8
+
+	// minChunkSize keeps small files on the sequential path where the extra
+	// connections would cost more than they save.
+	minChunkSize = 1 << 20
+)
+
+// Environment variables overriding where the model comes from and where it
+// is cached, for mirrored or air-gapped installs.
+const (
+	URLEnv      = "MONOCR_MODEL_URL"
+	CacheDirEnv = "MONOCR_CACHE_DIR"
+	// OfflineEnv set to a true value (1, true) forbids downloads.
+	OfflineEnv = "MONOCR_OFFLINE"
+	// VersionEnv pins a model version.
+	VersionEnv = "MONOCR_MODEL_VERSION"
+	// MirrorsEnv lists comma-separated fallback URLs for the model.
+	MirrorsEnv = "MONOCR_MODEL_MIRRORS"
+	// SourcesEnv names a JSON file of model sources, with credentials,
+	// replacing the URL and mirrors. See LoadSources.
+	SourcesEnv = "MONOCR_MODEL_SOURCES"
+	// VariantEnv selects a model variant such as VariantInt8 or
+	// VariantFP16.
+	VariantEnv = "MONOCR_MODEL_VARIANT"
+)
+
+// VariantInt8 is the int8-quantized model: about a quarter of the size
+// and much faster on CPU-only servers, at a small cost in accuracy.
+const VariantInt8 = "int8"
+
+// VariantFP16 is the half-precision model: half the size of the full
+// model, with tensors read and written as float16, which halves the
+// memory bandwidth of recognition on GPUs.
+const VariantFP16 = "fp16"
+
+// ErrOffline is returned when the model must be downloaded but the
+// Manager is offline.
+var ErrOffline = errors.New("offline mode")
+
+// Manager handles downloading and caching of the ONNX model.
+type Manager struct {
+	CacheDir string
+	URL      string
+	// Mirrors are tried in order when downloading from URL fails. Like
+	// URL, they may contain {version}.
+	Mirrors []string
+	Chunks  int
+	Client  *http.Client
+	// Offline makes the Manager fail instead of downloading a model that
+	// isn't cached.
+	Offline bool
+	// SHA256 is the expected hex SHA-256 of the model. When empty, the
+	// checksum the server reports, if any, is used.
+	SHA256 string
+	// Sources, when set, replace URL and Mirrors with an ordered list of
+	// places to download from, each with its own credentials, timeout
+	// and health check. Like URL, their URLs may contain {version}.
+	Sources []Source
+	// Version pins a published model version such as "v1.2", so
+	// upgrading the library doesn't change recognition. Versions are
+	// cached side by side as CacheDir/monocr/VERSION/model.onnx. Empty
+	// means the latest model, cached as CacheDir/monocr.onnx.
+	Version string
+	// Variant selects a published variant of the model, such as
+	// VariantInt8. Its files carry the variant as a suffix, as in
+	// monocr-int8.onnx, both in the cache and in the download URL. Empty
+	// means the full-precision model.
+	Variant string
+}
+
+// NewManager creates a Manager that caches the model in ~/.monocr/models.
+// MONOCR_MODEL_URL and MONOCR_CACHE_DIR override the download URL and the
+// cache directory, MONOCR_MODEL_MIRRORS adds fallback URLs,
+// MONOCR_MODEL_SOURCES names a sources file replacing both (see
+// LoadSources), MONOCR_MODEL_VERSION pins a model version,
+// MONOCR_MODEL_VARIANT selects a variant and MONOCR_OFFLINE turns on
+// offline mode.
+func NewManager() (*Manager, error) {
+	cacheDir := os.Getenv(CacheDirEnv)
+	if cacheDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %v", err)
+		}
+		cacheDir = filepath.Join(home, ".monocr", "models")
+	}
+	url := os.Getenv(URLEnv)
+	if url == "" {
+		url = ModelURL
+	}
+	var mirrors []string
+	for _, mirror := range strings.Split(os.Getenv(MirrorsEnv), ",") {
+		if mirror = strings.TrimSpace(mirror); mirror != "" {
+			mirrors = append(mirrors, mirror)
+		}
+	}
+
+	var sources []Source
+	if path := os.Getenv(SourcesEnv); path != "" {
+		var err error
+		if sources, err = LoadSources(path); err != nil {
+			return nil, fmt.Errorf("failed to load model sources: %v", err)
+		}
+	}
+
+	return &Manager{
+		CacheDir: cacheDir,
+		URL:      url,
+		Mirrors:  mirrors,
+		Sources:  sources,
+		Chunks:   DefaultChunks,
+		Client:   http.DefaultClient,
+		Offline:  isTrue(os.Getenv(OfflineEnv)),
+		Version:  os.Getenv(VersionEnv),
+		Variant:  os.Getenv(VariantEnv),
+	}, nil
+}
+
+// ModelPath returns the location of the cached model without downloading it.
+func (m *Manager) ModelPath() string {
+	if m.Version != "" {
+		return filepath.Join(m.CacheDir, "monocr", m.Version, variantFile("model.onnx", m.Variant))
+	}
+	return filepath.Join(m.CacheDir, variantFile(ModelFilename, m.Variant))
+}
+
+// variantFile inserts variant before the extension of name, turning
+// monocr.onnx into monocr-int8.onnx.
+func variantFile(name, variant string) string {
+	if variant == "" {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-" + variant + ext
+}
+
+// modelSources returns the sources to download the model version and
+// variant from, in order: Sources if set, or else URL and the mirrors.
+func (m *Manager) modelSources() []Source {
+	sources := m.Sources
+	if len(sources) == 0 {
+		for _, url := range append([]string{m.URL}, m.Mirrors...) {
+			sources = append(sources, Source{URL: url})
+		}
+	}
+	expanded := make([]Source, len(sources))
+	for i, src := range sources {
+		src.URL = m.modelURL(src.URL)
+		expanded[i] = src
+	}
+	return expanded
+}
+
+// modelURL returns url for the model version and variant. A variant
+// replaces {variant} in url with "-" and its name, or else is inserted
+// before the extension of the file url ends in.
+func (m *Manager) modelURL(url string) string {
+	if m.Version != "" {
+		if url == ModelURL {
+			url = VersionedModelURL
+		}
+		url = strings.ReplaceAll(url, "{version}", m.Version)
+	}
+	if strings.Contains(url, "{variant}") {
+		suffix := ""
+		if m.Variant != "" {
+			suffix = "-" + m.Variant
+		}
+		url = strings.ReplaceAll(url, "{variant}", suffix)
+	} else if m.Variant != "" {
+		dir, file := path.Split(url)
+		url = dir + variantFile(file, m.Variant)
+	}
+	return url
+}
+
+// checkVersion rejects versions and variants that would escape the cache
+// directory.
+func (m *Manager) checkVersion() error {
+	if m.Version == "." || m.Version == ".." || strings.ContainsAny(m.Version, `/\`) {
+		return fmt.Errorf("invalid model version %q", m.Version)
+	}
+	if strings.ContainsAny(m.Variant, `/\.`) {
+		return fmt.Errorf("invalid model variant %q", m.Variant)
+	}
+	return nil
+}
+
+// GetModelPath returns the path to the cached model, downloading it first if
+// it is not present.
+func (m *Manager) GetModelPath() (string, error) {
+	if err := m.checkVersion(); err != nil {
+		return "", err
+	}
+	modelPath := m.ModelPath()
+	if _, err := os.Stat(modelPath); err == nil {
+		return modelPath, nil
+	}
+
+	if m.Offline {
+		return "", m.offlineError()
+	}
+	fmt.Fprintf(os.Stderr, "Model not found at %s. Downloading...\n", modelPath)
+	if err := m.DownloadModel(); err != nil {
+		return "", err
+	}
+	return modelPath, nil
+}
+
+// DownloadModel fetches the model into the cache directory, trying each
+// source in turn (URL and then each mirror, unless Sources are set) until
+// one succeeds. Sources failing their health check are skipped. When the server accepts range
+// requests the file is fetched in parallel chunks, otherwise it falls
+// back to a single sequential download. Sequential downloads go to a
+// .partial file next to the model, which an interrupted download leaves
+// behind for the next call, or the next mirror, to resume. The finished
+// file is checked against the expected SHA-256 before it replaces the
+// cached model.
+func (m *Manager) DownloadModel() error {
+	if err := m.checkVersion(); err != nil {
+		return err
+	}
+	if m.Offline {
+		return m.offlineError()
+	}
+	dir := filepath.Dir(m.ModelPath())
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	var errs []string
+	for i, src := range m.modelSources() {
+		if i > 0 {
+			fmt.Fprintf(os.Stderr, "Trying mirror %s...\n", src.URL)
+		}
+		if err := m.checkHealth(src); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: health check failed: %v", src.URL, err))
+			continue
+		}
+		err := m.download(dir, src)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "Model downloaded successfully to %s\n", m.ModelPath())
+			return nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", src.URL, err))
+	}
+	msg := strings.Join(errs, "; ")
+	if info, err := os.Stat(m.partialPath()); err == nil {
+		if info.Size() > 0 {
+			msg += " (run again to resume)"
+		} else {
+			os.Remove(m.partialPath())
+		}
+	}
+	return fmt.Errorf("failed to download model: %s", msg)
+}
+
+// download fetches the model from src and moves it into place.
+func (m *Manager) download(dir string, src Source) error {
+	size, ranges, digest := m.probe(src)
+	want := m.SHA256
+	if want == "" {
+		want = digest
+	}
+	partial := m.partialPath()
+
+	var path string
+	var err error
+	if info, statErr := os.Stat(partial); statErr == nil && info.Size() > 0 {
+		fmt.Fprintf(os.Stderr, "Resuming download after %d bytes...\n", info.Size())
+		path, err = partial, m.downloadResumable(partial, src)
+	} else if ranges && m.Chunks > 1 && size >= minChunkSize {
+		path, err = m.downloadChunkedFile(dir, src, size)
+		if err != nil {
+			// Some CDNs advertise ranges but reject concurrent requests;
+			// start over with a plain download.
+			fmt.Fprintf(os.Stderr, "Chunked download failed (%v), retrying sequentially...\n", err)
+			path, err = partial, m.downloadResumable(partial, src)
+		}
+	} else {
+		path, err = partial, m.downloadResumable(partial, src)
+	}
+	if err != nil {
+		return err
+	}
+
+	if want != "" {
+		got, err := fileSHA256(path)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(got, want) {
+			// A corrupt file can't be resumed, so start over next time
+			os.Remove(path)
+			return fmt.Errorf("checksum mismatch: got sha256 %s, want %s", got, want)
+		}
+	}
+	return os.Rename(path, m.ModelPath())
+}
+
+// partialPath is where a sequential download is written until complete.
+func (m *Manager) partialPath() string {
+	return m.ModelPath() + ".partial"
+}
+
+// probe asks for the first byte of the model to learn its total size,
+// whether the server honours range requests and, if the server reports
+// it, the SHA-256 of the file.
+func (m *Manager) probe(src Source) (int64, bool, string) {
+	resp, err := m.get(src, "bytes=0-0")
+	if err != nil {
+		return 0, false, ""
+	}
+	defer resp.Body.Close()
+
+	digest := headerDigest(resp.Header)
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, false, digest
+	}
+
+	// Content-Range: bytes 0-0/12345
+	cr := resp.Header.Get("Content-Range")
+	idx := strings.LastIndex(cr, "/")
+	if idx < 0 {
+		return 0, false, digest
+	}
+	size, err := strconv.ParseInt(cr[idx+1:], 10, 64)
+	if err != nil || size <= 0 {
+		return 0, false, digest
+	}
+	return size, true, digest
+}
+
+// headerDigest returns the SHA-256 a response advertises for its file.
+// Hugging Face and other LFS hosts send it as the (linked) ETag.
+func headerDigest(h http.Header) string {
+	for _, key := range []string{"X-Linked-Etag", "ETag"} {
+		v := strings.Trim(strings.TrimPrefix(h.Get(key), "W/"), `"`)
+		if len(v) == 64 && strings.Trim(strings.ToLower(v), "0123456789abcdef") == "" {
+			return strings.ToLower(v)
+		}
+	}
+	return ""
+}
+
+// downloadResumable downloads the model from src into path, continuing
+// after whatever path already holds when the server honours range
+// requests.
+func (m *Manager) downloadResumable(path string, src Source) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	err = m.resume(f, src)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (m *Manager) resume(f *os.File, src Source) error {
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	var byteRange string
+	if offset > 0 {
+		byteRange = fmt.Sprintf("bytes=%d-", offset)
+	}
+
+	resp, err := m.get(src, byteRange)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+	case resp.StatusCode == http.StatusOK:
+		// The server sent the whole file
+		if err := resetFile(f); err != nil {
+			return err
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// Nothing is left to fetch; the checksum tells if the file is whole
+		return nil
+	default:
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	_, err = io.Copy(f, resp.Body)
+	return err
+}
+
+// downloadChunkedFile fetches the model from src in parallel chunks into
+// a temp file in dir and returns its path. A failed chunked download is
+// not resumable and leaves nothing behind.
+func (m *Manager) downloadChunkedFile(dir string, src Source, size int64) (string, error) {
+	tmp, err := os.CreateTemp(dir, ModelFilename+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	err = m.downloadChunked(tmp, src, size)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// fileSHA256 returns the hex SHA-256 of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (m *Manager) downloadChunked(f *os.File, src Source, size int64) error {
+	if err := f.Truncate(size); err != nil {
+		return err
+	}
+
+	chunks := int64(m.Chunks)
+	chunkSize := (size + chunks - 1) / chunks
+
+	var wg sync.WaitGroup
+	errs := make(chan error, chunks)
+	for start := int64(0); start < size; start += chunkSize {
+		end := start + chunkSize - 1
+		if end >= size {
+			end = size - 1
+		}
+
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			if err := m.downloadRange(f, src, start, end); err != nil {
+				errs <- err
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+func (m *Manager) downloadRange(f *os.File, src Source, start, end int64) error {
+	resp, err := m.get(src, fmt.Sprintf("bytes=%d-%d", start, end))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("range request returned %s", resp.Status)
+	}
+
+	n, err := io.Copy(io.NewOffsetWriter(f, start), io.LimitReader(resp.Body, end-start+1))
+	if err != nil {
+		return err
+	}
+	// A body cut short leaves a hole of zeros in the file
+	if n != end-start+1 {
+		return fmt.Errorf("range %d-%d: got %d of %d bytes", start, end, n, end-start+1)
+	}
+	return nil
+}
+
+func (m *Manager) offlineError() error {
+	return fmt.Errorf("%w: model not cached at %s; download it where network access is allowed (monocr download) or point %s at a cache holding it", ErrOffline, m.ModelPath(), CacheDirEnv)
+}
+
+// isTrue reports whether an environment variable's value turns a switch on.
+func isTrue(v string) bool {
+	on, err := strconv.ParseBool(v)
+	return err == nil && on
+}
+
+func resetFile(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
+
+func (m *Manager) client() *http.Client {
+	if m.Client != nil {
+		return m.Client
+	}
+	return http.DefaultClient
+}
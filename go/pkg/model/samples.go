@@ -0,0 +1,119 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SamplesURL is where the public Mon evaluation set is published: a
+// manifest.json listing line images and their ground truth, next to the
+// images.
+const SamplesURL = "https://huggingface.co/janakhpon/monocr/resolve/main/samples"
+
+// SamplesURLEnv overrides SamplesURL, e.g. with an internal mirror.
+const SamplesURLEnv = "MONOCR_SAMPLES_URL"
+
+// Sample is one entry of the samples manifest.
+type Sample struct {
+	// Image is the file name of the image, relative to the manifest.
+	Image string `json:"image"`
+	// Text is the ground truth.
+	Text string `json:"text"`
+}
+
+// SamplesDir returns where the evaluation samples are cached. Each image
+// sits next to its ground truth in NAME.gt.txt.
+func (m *Manager) SamplesDir() string {
+	return filepath.Join(m.CacheDir, "samples")
+}
+
+// DownloadSamples fetches the evaluation samples into SamplesDir,
+// replacing any previous download once all of them have arrived.
+func (m *Manager) DownloadSamples() (string, error) {
+	if m.Offline {
+		return "", fmt.Errorf("%w: cannot download samples", ErrOffline)
+	}
+	base := os.Getenv(SamplesURLEnv)
+	if base == "" {
+		base = SamplesURL
+	}
+	base = strings.TrimSuffix(base, "/")
+
+	var samples []Sample
+	if err := m.fetch(base+"/manifest.json", func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&samples)
+	}); err != nil {
+		return "", fmt.Errorf("failed to download samples manifest: %v", err)
+	}
+	if len(samples) == 0 {
+		return "", fmt.Errorf("samples manifest lists no samples")
+	}
+
+	if err := os.MkdirAll(m.CacheDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	tmp, err := os.MkdirTemp(m.CacheDir, "samples.*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, s := range samples {
+		// Names come from the network, so keep them inside the directory
+		name := filepath.Base(s.Image)
+		if name != s.Image || name == "." || name == ".." {
+			return "", fmt.Errorf("invalid sample name %q", s.Image)
+		}
+		err := m.fetch(base+"/"+name, func(r io.Reader) error {
+			return writeFile(filepath.Join(tmp, name), r)
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to download sample %s: %v", name, err)
+		}
+		truth := strings.TrimSuffix(name, filepath.Ext(name)) + ".gt.txt"
+		if err := os.WriteFile(filepath.Join(tmp, truth), []byte(s.Text+"\n"), 0o644); err != nil {
+			return "", err
+		}
+	}
+
+	dir := m.SamplesDir()
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(os.Stderr, "Downloaded %d samples to %s\n", len(samples), dir)
+	return dir, nil
+}
+
+// fetch GETs url and hands the body to read.
+func (m *Manager) fetch(url string, read func(io.Reader) error) error {
+	resp, err := m.client().Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return read(resp.Body)
+}
+
+func writeFile(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,189 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Limits on waiting out a source's rate limit before moving on.
+const (
+	rateLimitRetries = 3
+	maxRateLimitWait = 30 * time.Second
+	// defaultRateLimitWait is used when the server doesn't say how long
+	// to wait.
+	defaultRateLimitWait = 2 * time.Second
+)
+
+// healthTimeout bounds a source's health check.
+const healthTimeout = 5 * time.Second
+
+// Source is a place to download the model from.
+type Source struct {
+	// URL of the model. It may contain {version} and {variant}.
+	URL string
+	// Token is sent as a bearer token, for private mirrors and gated
+	// Hugging Face repositories.
+	Token string
+	// Header holds further request headers, such as an API key.
+	Header map[string]string
+	// Timeout bounds each request to the source, the download
+	// included; 0 means no limit.
+	Timeout time.Duration
+	// HealthURL, when set, is fetched before downloading, and the
+	// source is skipped unless it answers with a success status within
+	// a few seconds.
+	HealthURL string
+}
+
+// sourceFile is a Source as written in a sources file.
+type sourceFile struct {
+	URL string `json:"url"`
+	// TokenEnv names the environment variable holding the token, so the
+	// file needn't hold secrets.
+	Token     string            `json:"token"`
+	TokenEnv  string            `json:"token_env"`
+	Header    map[string]string `json:"header"`
+	Timeout   string            `json:"timeout"`
+	HealthURL string            `json:"health_url"`
+}
+
+// LoadSources reads a JSON list of model sources, such as
+//
+//	[
+//	  {"url": "https://models.internal/monocr/{version}/monocr.onnx",
+//	   "token_env": "MIRROR_TOKEN", "timeout": "2m",
+//	   "health_url": "https://models.internal/healthz"},
+//	  {"url": "https://huggingface.co/janakhpon/monocr/resolve/main/onnx/monocr.onnx",
+//	   "token_env": "HF_TOKEN"}
+//	]
+//
+// A token comes from "token" or, better, from the environment variable
+// "token_env" names, and header values may refer to environment variables
+// as $NAME. Timeouts are Go durations like "90s".
+func LoadSources(path string) ([]Source, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []sourceFile
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	sources := make([]Source, len(entries))
+	for i, e := range entries {
+		if e.URL == "" {
+			return nil, fmt.Errorf("%s: source %d has no url", path, i+1)
+		}
+		src := Source{URL: e.URL, Token: e.Token, HealthURL: e.HealthURL}
+		if e.TokenEnv != "" {
+			src.Token = os.Getenv(e.TokenEnv)
+		}
+		if len(e.Header) > 0 {
+			src.Header = make(map[string]string, len(e.Header))
+			for k, v := range e.Header {
+				src.Header[k] = os.ExpandEnv(v)
+			}
+		}
+		if e.Timeout != "" {
+			if src.Timeout, err = time.ParseDuration(e.Timeout); err != nil {
+				return nil, fmt.Errorf("%s: source %d: invalid timeout %q", path, i+1, e.Timeout)
+			}
+		}
+		sources[i] = src
+	}
+	return sources, nil
+}
+
+// get requests src's URL, for byteRange if set, with its credentials and
+// timeout. Responses saying the source is rate limited or briefly
+// unavailable are retried after the wait the server asks for, a few
+// times, before the last one is returned.
+func (m *Manager) get(src Source, byteRange string) (*http.Response, error) {
+	client := m.client()
+	if src.Timeout > 0 {
+		c := *client
+		c.Timeout = src.Timeout
+		client = &c
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := src.request(src.URL)
+		if err != nil {
+			return nil, err
+		}
+		if byteRange != "" {
+			req.Header.Set("Range", byteRange)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable || attempt == rateLimitRetries {
+			return resp, nil
+		}
+		wait := retryAfter(resp.Header.Get("Retry-After"))
+		resp.Body.Close()
+		if wait > maxRateLimitWait {
+			return nil, fmt.Errorf("rate limited for %v", wait.Round(time.Second))
+		}
+		fmt.Fprintf(os.Stderr, "%s is rate limited, retrying in %v...\n", src.URL, wait.Round(time.Second))
+		time.Sleep(wait)
+	}
+}
+
+// request builds a GET request for url with src's credentials.
+func (src Source) request(url string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if src.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+src.Token)
+	}
+	for k, v := range src.Header {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// retryAfter parses a Retry-After header, in seconds or as a date.
+func retryAfter(v string) time.Duration {
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return defaultRateLimitWait
+}
+
+// checkHealth fetches src's health URL, if it has one, and fails unless
+// it answers with a success status in time.
+func (m *Manager) checkHealth(src Source) error {
+	if src.HealthURL == "" {
+		return nil
+	}
+	req, err := src.request(src.HealthURL)
+	if err != nil {
+		return err
+	}
+	client := *m.client()
+	client.Timeout = healthTimeout
+	if src.Timeout > 0 {
+		client.Timeout = min(src.Timeout, healthTimeout)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
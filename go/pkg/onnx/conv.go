@@ -0,0 +1,333 @@
+package onnx
+
+import (
+	"fmt"
+	"math"
+)
+
+// window2d is the geometry of a convolution or pooling window over the
+// last two axes of an NCHW tensor. 1-D inputs are handled as height 1.
+type window2d struct {
+	kh, kw     int
+	sh, sw     int
+	dh, dw     int
+	pt, pl     int // leading pads
+	pb, pr     int // trailing pads
+	h, w       int // input size
+	outH, outW int
+}
+
+// newWindow reads the kernel_shape, strides, dilations, pads, auto_pad
+// and ceil_mode attributes of n for an input of shape [N,C,H,W] or
+// [N,C,W]. kernel, if not nil, is the kernel's spatial shape from the
+// weights.
+func newWindow(n *node, shape []int, kernel []int) (*window2d, error) {
+	spatial := len(shape) - 2
+	if spatial != 1 && spatial != 2 {
+		return nil, unsupported("%d-D windows", spatial)
+	}
+	pair := func(name string, def int64, count int) ([]int, error) {
+		vs, ok := n.ints(name)
+		if !ok {
+			vs = make([]int64, count)
+			for i := range vs {
+				vs[i] = def
+			}
+		}
+		if len(vs) != count {
+			return nil, fmt.Errorf("%s %v doesn't match %d spatial axes", name, vs, spatial)
+		}
+		out := make([]int, 0, 2*count/spatial)
+		// Pad 1-D attributes to 2-D with a leading unit axis
+		for i := 0; i < len(vs); i += spatial {
+			if spatial == 1 {
+				out = append(out, int(def))
+			}
+			for _, v := range vs[i : i+spatial] {
+				out = append(out, int(v))
+			}
+		}
+		return out, nil
+	}
+
+	if k, ok := n.ints("kernel_shape"); ok {
+		kernel = make([]int, len(k))
+		for i, v := range k {
+			kernel[i] = int(v)
+		}
+	}
+	if len(kernel) != spatial {
+		return nil, fmt.Errorf("kernel %v doesn't match %d spatial axes", kernel, spatial)
+	}
+	if spatial == 1 {
+		kernel = []int{1, kernel[0]}
+	}
+	strides, err := pair("strides", 1, spatial)
+	if err != nil {
+		return nil, err
+	}
+	dilations, err := pair("dilations", 1, spatial)
+	if err != nil {
+		return nil, err
+	}
+	pads, err := pair("pads", 0, 2*spatial)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &window2d{
+		kh: kernel[0], kw: kernel[1],
+		sh: strides[0], sw: strides[1],
+		dh: dilations[0], dw: dilations[1],
+		pt: pads[0], pl: pads[1], pb: pads[2], pr: pads[3],
+		h: 1, w: shape[len(shape)-1],
+	}
+	if spatial == 2 {
+		w.h = shape[2]
+	}
+
+	switch autoPad := n.string("auto_pad", "NOTSET"); autoPad {
+	case "NOTSET":
+	case "VALID":
+		w.pt, w.pl, w.pb, w.pr = 0, 0, 0, 0
+	case "SAME_UPPER", "SAME_LOWER":
+		same := func(in, k, s, d int) (int, int) {
+			total := max(0, ((in+s-1)/s-1)*s+(k-1)*d+1-in)
+			if autoPad == "SAME_UPPER" {
+				return total / 2, total - total/2
+			}
+			return total - total/2, total / 2
+		}
+		w.pt, w.pb = same(w.h, w.kh, w.sh, w.dh)
+		w.pl, w.pr = same(w.w, w.kw, w.sw, w.dw)
+	default:
+		return nil, unsupported("auto_pad %q", autoPad)
+	}
+
+	ceil := n.int("ceil_mode", 0) != 0
+	outSize := func(in, k, s, d, p0, p1 int) int {
+		span := in + p0 + p1 - (k-1)*d - 1
+		if span < 0 {
+			return 0
+		}
+		if !ceil {
+			return span/s + 1
+		}
+		out := (span+s-1)/s + 1
+		// The last window must start inside the input or its leading pad
+		if (out-1)*s >= in+p0 {
+			out--
+		}
+		return out
+	}
+	w.outH = outSize(w.h, w.kh, w.sh, w.dh, w.pt, w.pb)
+	w.outW = outSize(w.w, w.kw, w.sw, w.dw, w.pl, w.pr)
+	if w.outH <= 0 || w.outW <= 0 {
+		return nil, fmt.Errorf("input %s is smaller than the window", shapeString(shape))
+	}
+	return w, nil
+}
+
+// outShape returns the output shape for batch and channels, with the
+// input's number of spatial axes.
+func (w *window2d) outShape(batch, channels, rank int) []int {
+	if rank == 3 {
+		return []int{batch, channels, w.outW}
+	}
+	return []int{batch, channels, w.outH, w.outW}
+}
+
+// im2col lays out the windows of one image of c channels as the columns
+// of a (c·kh·kw)×(outH·outW) matrix, with zeros for padding.
+func (w *window2d) im2col(img []float32, c int, cols []float32) {
+	n := w.outH * w.outW
+	row := 0
+	for ch := 0; ch < c; ch++ {
+		plane := img[ch*w.h*w.w : (ch+1)*w.h*w.w]
+		for ky := 0; ky < w.kh; ky++ {
+			for kx := 0; kx < w.kw; kx++ {
+				dst := cols[row*n : (row+1)*n]
+				row++
+				for oy := 0; oy < w.outH; oy++ {
+					y := oy*w.sh - w.pt + ky*w.dh
+					out := dst[oy*w.outW : (oy+1)*w.outW]
+					if y < 0 || y >= w.h {
+						clear(out)
+						continue
+					}
+					src := plane[y*w.w : (y+1)*w.w]
+					x := -w.pl + kx*w.dw
+					for ox := range out {
+						if x >= 0 && x < w.w {
+							out[ox] = src[x]
+						} else {
+							out[ox] = 0
+						}
+						x += w.sw
+					}
+				}
+			}
+		}
+	}
+}
+
+func conv(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	x, weights := in[0], in[1]
+	rank := len(x.Shape)
+	if len(weights.Shape) != rank {
+		return nil, fmt.Errorf("weights %s don't match input %s", shapeString(weights.Shape), shapeString(x.Shape))
+	}
+	w, err := newWindow(n, x.Shape, weights.Shape[2:])
+	if err != nil {
+		return nil, err
+	}
+	batch, channels, filters := x.Shape[0], x.Shape[1], weights.Shape[0]
+	groups := int(n.int("group", 1))
+	if groups <= 0 || channels%groups != 0 || filters%groups != 0 || weights.Shape[1] != channels/groups {
+		return nil, fmt.Errorf("weights %s don't match %d channels in %d groups", shapeString(weights.Shape), channels, groups)
+	}
+
+	cg, fg := channels/groups, filters/groups
+	k := cg * w.kh * w.kw
+	cells := w.outH * w.outW
+	xv, wv := x.floats(), weights.floats()
+	out := newFloat(w.outShape(batch, filters, rank))
+	cols := make([]float32, k*cells)
+	for b := 0; b < batch; b++ {
+		for g := 0; g < groups; g++ {
+			img := xv[(b*channels+g*cg)*w.h*w.w : (b*channels+(g+1)*cg)*w.h*w.w]
+			dst := out.Data[(b*filters+g*fg)*cells : (b*filters+(g+1)*fg)*cells]
+			if w.kh == 1 && w.kw == 1 && w.sh == 1 && w.sw == 1 && w.pt+w.pl+w.pb+w.pr == 0 {
+				// A pointwise convolution needs no unfolding
+				gemmNN(r, fg, cells, k, wv[g*fg*k:(g+1)*fg*k], img, dst)
+				continue
+			}
+			w.im2col(img, cg, cols)
+			gemmNN(r, fg, cells, k, wv[g*fg*k:(g+1)*fg*k], cols, dst)
+		}
+	}
+
+	if bias := input(in, 2); bias != nil {
+		bv := bias.floats()
+		if len(bv) != filters {
+			return nil, fmt.Errorf("bias has %d values for %d filters", len(bv), filters)
+		}
+		for i := 0; i < batch*filters; i++ {
+			plane := out.Data[i*cells : (i+1)*cells]
+			for j := range plane {
+				plane[j] += bv[i%filters]
+			}
+		}
+	}
+	return []*Tensor{out}, nil
+}
+
+func batchNorm(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	x := in[0]
+	if len(x.Shape) < 2 {
+		return nil, fmt.Errorf("input %s has no channel axis", shapeString(x.Shape))
+	}
+	scale, bias, mean, variance := in[1].floats(), in[2].floats(), in[3].floats(), in[4].floats()
+	channels := x.Shape[1]
+	for _, p := range [][]float32{scale, bias, mean, variance} {
+		if len(p) != channels {
+			return nil, fmt.Errorf("parameters don't match %d channels", channels)
+		}
+	}
+	eps := float64(n.float("epsilon", 1e-5))
+
+	xv := x.floats()
+	out := newFloat(x.Shape)
+	inner := size(x.Shape[2:])
+	for i := 0; i < x.Shape[0]*channels; i++ {
+		c := i % channels
+		mul := scale[c] / float32(math.Sqrt(float64(variance[c])+eps))
+		add := bias[c] - mean[c]*mul
+		src, dst := xv[i*inner:(i+1)*inner], out.Data[i*inner:(i+1)*inner]
+		for j, v := range src {
+			dst[j] = v*mul + add
+		}
+	}
+	return []*Tensor{out}, nil
+}
+
+// pool computes MaxPool, or AveragePool when isMax is false.
+func pool(isMax bool) kernel {
+	return func(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+		if isMax && len(n.outputs) > 1 && n.outputs[1] != "" {
+			return nil, unsupported("max pool indices")
+		}
+		x := in[0]
+		w, err := newWindow(n, x.Shape, nil)
+		if err != nil {
+			return nil, err
+		}
+		includePad := n.int("count_include_pad", 0) != 0
+
+		planes := x.Shape[0] * x.Shape[1]
+		xv := x.floats()
+		out := newFloat(w.outShape(x.Shape[0], x.Shape[1], len(x.Shape)))
+		cells := w.outH * w.outW
+		for p := 0; p < planes; p++ {
+			src := xv[p*w.h*w.w : (p+1)*w.h*w.w]
+			dst := out.Data[p*cells : (p+1)*cells]
+			for oy := 0; oy < w.outH; oy++ {
+				for ox := 0; ox < w.outW; ox++ {
+					acc := float32(0)
+					if isMax {
+						acc = -math.MaxFloat32
+					}
+					count := 0
+					for ky := 0; ky < w.kh; ky++ {
+						y := oy*w.sh - w.pt + ky*w.dh
+						for kx := 0; kx < w.kw; kx++ {
+							x := ox*w.sw - w.pl + kx*w.dw
+							if y < 0 || y >= w.h || x < 0 || x >= w.w {
+								// Padding counts towards an average only
+								// inside the padded input
+								if includePad && y >= -w.pt && y < w.h+w.pb && x >= -w.pl && x < w.w+w.pr {
+									count++
+								}
+								continue
+							}
+							v := src[y*w.w+x]
+							if isMax {
+								acc = max(acc, v)
+							} else {
+								acc += v
+							}
+							count++
+						}
+					}
+					if !isMax && count > 0 {
+						acc /= float32(count)
+					}
+					dst[oy*w.outW+ox] = acc
+				}
+			}
+		}
+		return []*Tensor{out}, nil
+	}
+}
+
+func globalAveragePool(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	x := in[0]
+	if len(x.Shape) < 3 {
+		return nil, fmt.Errorf("input %s has no spatial axes", shapeString(x.Shape))
+	}
+	shape := []int{x.Shape[0], x.Shape[1]}
+	for range x.Shape[2:] {
+		shape = append(shape, 1)
+	}
+	inner := size(x.Shape[2:])
+	xv := x.floats()
+	out := newFloat(shape)
+	for i := range out.Data {
+		var sum float32
+		for _, v := range xv[i*inner : (i+1)*inner] {
+			sum += v
+		}
+		out.Data[i] = sum / float32(inner)
+	}
+	return []*Tensor{out}, nil
+}
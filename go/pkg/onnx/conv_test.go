@@ -0,0 +1,163 @@
+package onnx
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// convParams are the attributes of a 2-D convolution.
+type convParams struct {
+	kh, kw, sh, sw, dh, dw, pt, pl, pb, pr, groups int
+}
+
+func (p convParams) attrs() []attr {
+	return []attr{
+		intsAttr("strides", int64(p.sh), int64(p.sw)),
+		intsAttr("dilations", int64(p.dh), int64(p.dw)),
+		intsAttr("pads", int64(p.pt), int64(p.pl), int64(p.pb), int64(p.pr)),
+		intAttr("group", int64(p.groups)),
+	}
+}
+
+// naiveConv computes a grouped 2-D convolution of x, [n,c,h,w], with
+// weights, [f,c/groups,kh,kw], one output at a time.
+func naiveConv(x, weights, bias []float32, n, c, h, w, f int, p convParams) ([]float32, []int) {
+	outH := (h+p.pt+p.pb-(p.kh-1)*p.dh-1)/p.sh + 1
+	outW := (w+p.pl+p.pr-(p.kw-1)*p.dw-1)/p.sw + 1
+	cg, fg := c/p.groups, f/p.groups
+	out := make([]float32, n*f*outH*outW)
+	for b := 0; b < n; b++ {
+		for o := 0; o < f; o++ {
+			g := o / fg
+			for oy := 0; oy < outH; oy++ {
+				for ox := 0; ox < outW; ox++ {
+					var sum float64
+					if bias != nil {
+						sum = float64(bias[o])
+					}
+					for ci := 0; ci < cg; ci++ {
+						for ky := 0; ky < p.kh; ky++ {
+							for kx := 0; kx < p.kw; kx++ {
+								y := oy*p.sh - p.pt + ky*p.dh
+								xx := ox*p.sw - p.pl + kx*p.dw
+								if y < 0 || y >= h || xx < 0 || xx >= w {
+									continue
+								}
+								sum += float64(x[((b*c+g*cg+ci)*h+y)*w+xx]) * float64(weights[((o*cg+ci)*p.kh+ky)*p.kw+kx])
+							}
+						}
+					}
+					out[((b*f+o)*outH+oy)*outW+ox] = float32(sum)
+				}
+			}
+		}
+	}
+	return out, []int{n, f, outH, outW}
+}
+
+func TestConvKnown(t *testing.T) {
+	// A 2x2 box filter over a 3x3 ramp sums each window
+	x := NewTensor([]int{1, 1, 3, 3}, seq(9, 1, 1))
+	w := NewTensor([]int{1, 1, 2, 2}, []float32{1, 1, 1, 1})
+	out := runOp(t, "Conv", []*Tensor{x, w}, 1)[0]
+	assertClose(t, "box", out, []int{1, 1, 2, 2}, []float32{12, 16, 24, 28}, 0)
+
+	// With a pad of 1 and stride 2, corners see one pixel each
+	b := NewTensor([]int{1}, []float32{0.5})
+	out = runOp(t, "Conv", []*Tensor{x, w, b}, 1, intsAttr("pads", 1, 1, 1, 1), intsAttr("strides", 2, 2))[0]
+	assertClose(t, "padded", out, []int{1, 1, 2, 2}, []float32{1.5, 5.5, 11.5, 28.5}, 0)
+}
+
+func TestConv(t *testing.T) {
+	for _, p := range []convParams{
+		{kh: 3, kw: 3, sh: 1, sw: 1, dh: 1, dw: 1, pt: 1, pl: 1, pb: 1, pr: 1, groups: 1},
+		{kh: 3, kw: 3, sh: 2, sw: 1, dh: 1, dw: 1, pt: 1, pl: 0, pb: 0, pr: 2, groups: 1},
+		{kh: 2, kw: 3, sh: 1, sw: 2, dh: 2, dw: 1, pt: 0, pl: 1, pb: 1, pr: 1, groups: 1},
+		{kh: 3, kw: 3, sh: 1, sw: 1, dh: 1, dw: 1, pt: 1, pl: 1, pb: 1, pr: 1, groups: 2},
+		{kh: 3, kw: 3, sh: 1, sw: 1, dh: 1, dw: 1, pt: 1, pl: 1, pb: 1, pr: 1, groups: 4},
+		{kh: 1, kw: 1, sh: 1, sw: 1, dh: 1, dw: 1, groups: 1},
+		{kh: 1, kw: 1, sh: 1, sw: 1, dh: 1, dw: 1, groups: 2},
+	} {
+		t.Run(fmt.Sprintf("%+v", p), func(t *testing.T) {
+			const n, c, h, w, f = 2, 4, 7, 9, 8
+			x := seq(n*c*h*w, -1, 0.013)
+			weights := make([]float32, f*(c/p.groups)*p.kh*p.kw)
+			for i := range weights {
+				weights[i] = float32(math.Sin(float64(i)))
+			}
+			bias := seq(f, 0.1, 0.2)
+			want, shape := naiveConv(x, weights, bias, n, c, h, w, f, p)
+
+			out := runOp(t, "Conv", []*Tensor{
+				NewTensor([]int{n, c, h, w}, x),
+				NewTensor([]int{f, c / p.groups, p.kh, p.kw}, weights),
+				NewTensor([]int{f}, bias),
+			}, 1, p.attrs()...)[0]
+			assertClose(t, "Conv", out, shape, want, 1e-5)
+		})
+	}
+}
+
+func TestConv1D(t *testing.T) {
+	// A 1-D convolution is a 2-D one of height 1
+	const n, c, w, f = 1, 3, 20, 2
+	x := seq(n*c*w, 0, 0.1)
+	weights := seq(f*c*5, -1, 0.07)
+	p := convParams{kh: 1, kw: 5, sh: 1, sw: 2, dh: 1, dw: 1, pl: 2, pr: 2, groups: 1}
+	want, shape := naiveConv(x, weights, nil, n, c, 1, w, f, p)
+
+	out := runOp(t, "Conv", []*Tensor{NewTensor([]int{n, c, w}, x), NewTensor([]int{f, c, 5}, weights)}, 1,
+		intsAttr("strides", 2), intsAttr("pads", 2, 2))[0]
+	assertClose(t, "Conv", out, []int{n, f, shape[3]}, want, 1e-5)
+}
+
+func TestConvAutoPad(t *testing.T) {
+	// SAME_UPPER keeps the size at stride 1 and puts the odd pad last
+	x := NewTensor([]int{1, 1, 4, 4}, seq(16, 0, 1))
+	w := NewTensor([]int{1, 1, 2, 2}, []float32{1, 2, 3, 4})
+	want, _ := naiveConv(x.Data, w.Data, nil, 1, 1, 4, 4, 1, convParams{kh: 2, kw: 2, sh: 1, sw: 1, dh: 1, dw: 1, pb: 1, pr: 1, groups: 1})
+	out := runOp(t, "Conv", []*Tensor{x, w}, 1, stringAttr("auto_pad", "SAME_UPPER"))[0]
+	assertClose(t, "SAME_UPPER", out, []int{1, 1, 4, 4}, want, 0)
+
+	want, _ = naiveConv(x.Data, w.Data, nil, 1, 1, 4, 4, 1, convParams{kh: 2, kw: 2, sh: 1, sw: 1, dh: 1, dw: 1, pt: 1, pl: 1, groups: 1})
+	out = runOp(t, "Conv", []*Tensor{x, w}, 1, stringAttr("auto_pad", "SAME_LOWER"))[0]
+	assertClose(t, "SAME_LOWER", out, []int{1, 1, 4, 4}, want, 0)
+}
+
+func TestPool(t *testing.T) {
+	x := NewTensor([]int{1, 1, 4, 4}, []float32{
+		1, 2, 3, 4,
+		5, 6, 7, 8,
+		9, 10, 11, 12,
+		13, 14, 15, 16,
+	})
+	out := runOp(t, "MaxPool", []*Tensor{x}, 1, intsAttr("kernel_shape", 2, 2), intsAttr("strides", 2, 2))[0]
+	assertClose(t, "MaxPool", out, []int{1, 1, 2, 2}, []float32{6, 8, 14, 16}, 0)
+
+	// Windows in CRNNs often pool only the height
+	out = runOp(t, "MaxPool", []*Tensor{x}, 1, intsAttr("kernel_shape", 2, 1), intsAttr("strides", 2, 1))[0]
+	assertClose(t, "MaxPool 2x1", out, []int{1, 1, 2, 4}, []float32{5, 6, 7, 8, 13, 14, 15, 16}, 0)
+
+	out = runOp(t, "AveragePool", []*Tensor{x}, 1, intsAttr("kernel_shape", 3, 3), intsAttr("pads", 1, 1, 1, 1), intsAttr("strides", 3, 3))[0]
+	assertClose(t, "AveragePool", out, []int{1, 1, 2, 2}, []float32{3.5, 5.5, 11.5, 13.5}, 1e-6)
+
+	out = runOp(t, "AveragePool", []*Tensor{x}, 1, intsAttr("kernel_shape", 3, 3), intsAttr("pads", 1, 1, 1, 1), intsAttr("strides", 3, 3), intAttr("count_include_pad", 1))[0]
+	assertClose(t, "AveragePool with pads", out, []int{1, 1, 2, 2}, []float32{14.0 / 9, 22.0 / 9, 46.0 / 9, 54.0 / 9}, 1e-6)
+
+	out = runOp(t, "MaxPool", []*Tensor{x}, 1, intsAttr("kernel_shape", 3, 3), intsAttr("strides", 2, 2), intAttr("ceil_mode", 1))[0]
+	assertClose(t, "MaxPool ceil", out, []int{1, 1, 2, 2}, []float32{11, 12, 15, 16}, 0)
+
+	out = runOp(t, "GlobalAveragePool", []*Tensor{x}, 1)[0]
+	assertClose(t, "GlobalAveragePool", out, []int{1, 1, 1, 1}, []float32{8.5}, 0)
+}
+
+func TestBatchNorm(t *testing.T) {
+	x := NewTensor([]int{1, 2, 1, 2}, []float32{1, 2, 3, 4})
+	scale := NewTensor([]int{2}, []float32{2, 0.5})
+	bias := NewTensor([]int{2}, []float32{1, -1})
+	mean := NewTensor([]int{2}, []float32{1, 3})
+	variance := NewTensor([]int{2}, []float32{4, 0.25})
+	out := runOp(t, "BatchNormalization", []*Tensor{x, scale, bias, mean, variance}, 1, floatAttr("epsilon", 0))[0]
+	assertClose(t, "BatchNormalization", out, []int{1, 2, 1, 2}, []float32{1, 2, -1, 0}, 1e-6)
+}
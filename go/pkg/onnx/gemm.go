@@ -0,0 +1,211 @@
+package onnx
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// parallelWork is the number of multiply-adds below which a kernel runs
+// on one goroutine; splitting smaller ones costs more than it saves.
+const parallelWork = 1 << 18
+
+// parallel calls fn on consecutive chunks of [0, n), concurrently when
+// work, the kernel's total multiply-adds, is large enough.
+func parallel(r *run, n, work int, fn func(lo, hi int)) {
+	parts := min(r.threads, n)
+	if parts <= 1 || work < parallelWork {
+		fn(0, n)
+		return
+	}
+	var wg sync.WaitGroup
+	chunk := (n + parts - 1) / parts
+	for lo := 0; lo < n; lo += chunk {
+		hi := min(lo+chunk, n)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(lo, hi)
+		}()
+	}
+	wg.Wait()
+}
+
+// gemmNN adds a×b to c, where a is m×k, b is k×n and c is m×n, all
+// row-major.
+func gemmNN(r *run, m, n, k int, a, b, c []float32) {
+	// block computes rows [i0, i1) of c over columns [j0, j1)
+	block := func(i0, i1, j0, j1 int) {
+		for i := i0; i < i1; i++ {
+			ai := a[i*k : (i+1)*k]
+			ci := c[i*n+j0 : i*n+j1]
+			p := 0
+			// Four rows of b at a time load and store c a quarter as often
+			for ; p+4 <= k; p += 4 {
+				axpy4(ci, ai[p:p+4], b[p*n+j0:p*n+j1], b[(p+1)*n+j0:(p+1)*n+j1], b[(p+2)*n+j0:(p+2)*n+j1], b[(p+3)*n+j0:(p+3)*n+j1])
+			}
+			for ; p < k; p++ {
+				ap := ai[p]
+				for j, bv := range b[p*n+j0 : p*n+j1] {
+					ci[j] += ap * bv
+				}
+			}
+		}
+	}
+	if m >= n || m >= r.threads {
+		parallel(r, m, m*n*k, func(lo, hi int) { block(lo, hi, 0, n) })
+		return
+	}
+	// Few rows, as for a convolution with few filters over a wide line:
+	// split the columns instead
+	parallel(r, n, m*n*k, func(lo, hi int) { block(0, m, lo, hi) })
+}
+
+// axpy4 adds a[0]·b0 + … + a[3]·b3 to c.
+func axpy4(c, a, b0, b1, b2, b3 []float32) {
+	a0, a1, a2, a3 := a[0], a[1], a[2], a[3]
+	b0, b1, b2, b3 = b0[:len(c)], b1[:len(c)], b2[:len(c)], b3[:len(c)]
+	for j := range c {
+		c[j] += a0*b0[j] + a1*b1[j] + a2*b2[j] + a3*b3[j]
+	}
+}
+
+// gemmNT adds a×bᵀ to c, where a is m×k, b is n×k and c is m×n, all
+// row-major.
+func gemmNT(r *run, m, n, k int, a, b, c []float32) {
+	dots := func(i, lo, hi int) {
+		ai := a[i*k : (i+1)*k]
+		ci := c[i*n : (i+1)*n]
+		for j := lo; j < hi; j++ {
+			ci[j] += dot(ai, b[j*k:(j+1)*k])
+		}
+	}
+	if m >= n {
+		parallel(r, m, m*n*k, func(lo, hi int) {
+			for i := lo; i < hi; i++ {
+				dots(i, 0, n)
+			}
+		})
+		return
+	}
+	parallel(r, n, m*n*k, func(lo, hi int) {
+		for i := 0; i < m; i++ {
+			dots(i, lo, hi)
+		}
+	})
+}
+
+func matMul(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	a, b := in[0], in[1]
+	as, bs := a.Shape, b.Shape
+	if len(as) == 0 || len(bs) == 0 {
+		return nil, fmt.Errorf("scalar operand")
+	}
+	// 1-D operands are promoted to matrices and the added axis dropped
+	vecA, vecB := len(as) == 1, len(bs) == 1
+	if vecA {
+		as = []int{1, as[0]}
+	}
+	if vecB {
+		bs = []int{bs[0], 1}
+	}
+	m, k, k2, cols := as[len(as)-2], as[len(as)-1], bs[len(bs)-2], bs[len(bs)-1]
+	if k != k2 {
+		return nil, fmt.Errorf("cannot multiply %s by %s", shapeString(a.Shape), shapeString(b.Shape))
+	}
+	batch, err := broadcastShape(as[:len(as)-2], bs[:len(bs)-2])
+	if err != nil {
+		return nil, err
+	}
+
+	av := broadcastTo(NewTensor(as, a.floats()), slices.Concat(batch, []int{m, k})).Data
+	bv := b.floats()
+	shareB := size(bs[:len(bs)-2]) == 1
+	if !shareB {
+		bv = broadcastTo(NewTensor(bs, bv), slices.Concat(batch, []int{k, cols})).Data
+	}
+
+	out := newFloat(slices.Concat(batch, []int{m, cols}))
+	if shareB {
+		// One weight matrix for every batch: a single larger product
+		gemmNN(r, size(batch)*m, cols, k, av, bv, out.Data)
+	} else {
+		for i := range size(batch) {
+			gemmNN(r, m, cols, k, av[i*m*k:(i+1)*m*k], bv[i*k*cols:(i+1)*k*cols], out.Data[i*m*cols:(i+1)*m*cols])
+		}
+	}
+
+	shape := out.Shape
+	if vecB {
+		shape = shape[:len(shape)-1]
+	}
+	if vecA {
+		shape = slices.Delete(slices.Clone(shape), len(batch), len(batch)+1)
+	}
+	out.Shape = shape
+	return []*Tensor{out}, nil
+}
+
+func gemm(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	a, b := in[0], in[1]
+	if len(a.Shape) != 2 || len(b.Shape) != 2 {
+		return nil, fmt.Errorf("expected matrices, got %s and %s", shapeString(a.Shape), shapeString(b.Shape))
+	}
+	av := a.floats()
+	if n.int("transA", 0) != 0 {
+		av = permute(NewTensor(a.Shape, av), []int64{1, 0}).Data
+	}
+	m, k := a.Shape[0], a.Shape[1]
+	if n.int("transA", 0) != 0 {
+		m, k = k, m
+	}
+	transB := n.int("transB", 0) != 0
+	cols := b.Shape[1]
+	if transB {
+		cols = b.Shape[0]
+	}
+	if kb := b.Shape[0]; transB && b.Shape[1] != k || !transB && kb != k {
+		return nil, fmt.Errorf("cannot multiply %s by %s", shapeString(a.Shape), shapeString(b.Shape))
+	}
+
+	out := newFloat([]int{m, cols})
+	if transB {
+		gemmNT(r, m, cols, k, av, b.floats(), out.Data)
+	} else {
+		gemmNN(r, m, cols, k, av, b.floats(), out.Data)
+	}
+	if alpha := n.float("alpha", 1); alpha != 1 {
+		for i := range out.Data {
+			out.Data[i] *= alpha
+		}
+	}
+	if c := input(in, 2); c != nil {
+		if _, err := broadcastShape(out.Shape, c.Shape); err != nil {
+			return nil, err
+		}
+		beta := n.float("beta", 1)
+		cv := broadcastTo(NewTensor(c.Shape, c.floats()), out.Shape).Data
+		for i := range out.Data {
+			out.Data[i] += beta * cv[i]
+		}
+	}
+	return []*Tensor{out}, nil
+}
+
+// dot returns the dot product of a and b, which have the same length.
+// Four partial sums keep the additions from waiting on each other.
+func dot(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	p := 0
+	for ; p+4 <= len(a); p += 4 {
+		s0 += a[p] * b[p]
+		s1 += a[p+1] * b[p+1]
+		s2 += a[p+2] * b[p+2]
+		s3 += a[p+3] * b[p+3]
+	}
+	for ; p < len(a); p++ {
+		s0 += a[p] * b[p]
+	}
+	return s0 + s1 + s2 + s3
+}
@@ -0,0 +1,180 @@
+package onnx
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
+
+// naiveMatMul returns a×b, where a is m×k and b is k×n, all row-major.
+func naiveMatMul(m, n, k int, a, b []float32) []float32 {
+	out := make([]float32, m*n)
+	for i := 0; i < m; i++ {
+		for j := 0; j < n; j++ {
+			var sum float64
+			for p := 0; p < k; p++ {
+				sum += float64(a[i*k+p]) * float64(b[p*n+j])
+			}
+			out[i*n+j] = float32(sum)
+		}
+	}
+	return out
+}
+
+// transposed returns the m×n matrix a as n×m.
+func transposed(m, n int, a []float32) []float32 {
+	out := make([]float32, m*n)
+	for i := 0; i < m; i++ {
+		for j := 0; j < n; j++ {
+			out[j*m+i] = a[i*n+j]
+		}
+	}
+	return out
+}
+
+func wave(n int, phase float64) []float32 {
+	vs := make([]float32, n)
+	for i := range vs {
+		vs[i] = float32(math.Sin(float64(i)*0.37 + phase))
+	}
+	return vs
+}
+
+func TestGemmKernels(t *testing.T) {
+	// The large sizes go past parallelWork, so with several threads they
+	// split rows or, with few rows, columns
+	for _, size := range [][3]int{{1, 1, 1}, {3, 5, 7}, {17, 9, 13}, {64, 64, 64}, {2, 2048, 150}, {300, 40, 30}} {
+		for _, threads := range []int{1, 4} {
+			m, n, k := size[0], size[1], size[2]
+			t.Run(fmt.Sprintf("%dx%dx%d/%d", m, n, k, threads), func(t *testing.T) {
+				r := &run{threads: threads}
+				a, b := wave(m*k, 0), wave(k*n, 1)
+				// The kernels add to c, so start from something other than zero
+				c0 := wave(m*n, 2)
+				want := naiveMatMul(m, n, k, a, b)
+				for i := range want {
+					want[i] += c0[i]
+				}
+
+				c := slices.Clone(c0)
+				gemmNN(r, m, n, k, a, b, c)
+				assertClose(t, "gemmNN", NewTensor([]int{m, n}, c), []int{m, n}, want, 1e-5)
+
+				c = slices.Clone(c0)
+				gemmNT(r, m, n, k, a, transposed(k, n, b), c)
+				assertClose(t, "gemmNT", NewTensor([]int{m, n}, c), []int{m, n}, want, 1e-5)
+			})
+		}
+	}
+}
+
+func TestGemm(t *testing.T) {
+	const m, n, k = 3, 4, 5
+	a, b := wave(m*k, 0), wave(k*n, 1)
+	ab := naiveMatMul(m, n, k, a, b)
+	for _, test := range []struct {
+		name         string
+		transA       bool
+		transB       bool
+		alpha, beta  float32
+		c            *Tensor
+		cAt          func(i, j int) float32
+		omitDefaults bool
+	}{
+		{name: "plain", alpha: 1, omitDefaults: true},
+		{name: "transA", transA: true, alpha: 1},
+		{name: "transB", transB: true, alpha: 1},
+		{name: "both", transA: true, transB: true, alpha: 0.5},
+		{name: "bias", alpha: 1, beta: 1, c: NewTensor([]int{n}, seq(n, 1, 1)), cAt: func(i, j int) float32 { return float32(1 + j) }},
+		{name: "column", alpha: 2, beta: -0.5, c: NewTensor([]int{m, 1}, seq(m, 1, 1)), cAt: func(i, j int) float32 { return float32(1 + i) }},
+		{name: "full", transB: true, alpha: 1.5, beta: 2, c: NewTensor([]int{m, n}, seq(m*n, 0, 0.1)), cAt: func(i, j int) float32 { return float32(i*n+j) * 0.1 }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			av, bv := a, b
+			aShape, bShape := []int{m, k}, []int{k, n}
+			if test.transA {
+				av, aShape = transposed(m, k, a), []int{k, m}
+			}
+			if test.transB {
+				bv, bShape = transposed(k, n, b), []int{n, k}
+			}
+			in := []*Tensor{NewTensor(aShape, av), NewTensor(bShape, bv)}
+			var attrs []attr
+			if !test.omitDefaults {
+				attrs = append(attrs, floatAttr("alpha", test.alpha))
+				if test.transA {
+					attrs = append(attrs, intAttr("transA", 1))
+				}
+				if test.transB {
+					attrs = append(attrs, intAttr("transB", 1))
+				}
+			}
+			if test.c != nil {
+				in = append(in, test.c)
+				attrs = append(attrs, floatAttr("beta", test.beta))
+			}
+
+			want := make([]float32, m*n)
+			for i := 0; i < m; i++ {
+				for j := 0; j < n; j++ {
+					want[i*n+j] = test.alpha * ab[i*n+j]
+					if test.cAt != nil {
+						want[i*n+j] += test.beta * test.cAt(i, j)
+					}
+				}
+			}
+			out := runOp(t, "Gemm", in, 1, attrs...)[0]
+			assertClose(t, "Gemm", out, []int{m, n}, want, 1e-5)
+		})
+	}
+
+	if _, err := tryOp("Gemm", []*Tensor{NewTensor([]int{m, k}, a), NewTensor([]int{m, n}, b[:m*n])}, 1); err == nil {
+		t.Error("Gemm of mismatched matrices succeeded")
+	}
+}
+
+func TestMatMul(t *testing.T) {
+	for _, test := range []struct {
+		a, b, want []int
+	}{
+		{[]int{3, 4}, []int{4, 5}, []int{3, 5}},
+		// One weight matrix shared by a batch, as in a linear layer
+		{[]int{2, 3, 4}, []int{4, 5}, []int{2, 3, 5}},
+		{[]int{2, 3, 4}, []int{2, 4, 5}, []int{2, 3, 5}},
+		{[]int{2, 1, 3, 4}, []int{3, 4, 5}, []int{2, 3, 3, 5}},
+		{[]int{4}, []int{2, 4, 5}, []int{2, 5}},
+		{[]int{2, 3, 4}, []int{4}, []int{2, 3}},
+		{[]int{4}, []int{4}, []int{}},
+	} {
+		t.Run(fmt.Sprint(test.a, test.b), func(t *testing.T) {
+			a, b := wave(size(test.a), 0), wave(size(test.b), 1)
+			// Promote vectors and broadcast the batch by hand
+			as, bs := slices.Clone(test.a), slices.Clone(test.b)
+			if len(as) == 1 {
+				as = []int{1, as[0]}
+			}
+			if len(bs) == 1 {
+				bs = []int{bs[0], 1}
+			}
+			m, k, n := as[len(as)-2], as[len(as)-1], bs[len(bs)-1]
+			batch, err := broadcastShape(as[:len(as)-2], bs[:len(bs)-2])
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := make([]float32, 0, size(test.want))
+			for i := 0; i < size(batch); i++ {
+				ai := broadcastIndex(i, batch, as[:len(as)-2])
+				bi := broadcastIndex(i, batch, bs[:len(bs)-2])
+				want = append(want, naiveMatMul(m, n, k, a[ai*m*k:(ai+1)*m*k], b[bi*k*n:(bi+1)*k*n])...)
+			}
+
+			out := runOp(t, "MatMul", []*Tensor{NewTensor(test.a, a), NewTensor(test.b, b)}, 1)[0]
+			assertClose(t, "MatMul", out, test.want, want, 1e-5)
+		})
+	}
+
+	if _, err := tryOp("MatMul", []*Tensor{NewTensor([]int{2, 3}, seq(6, 0, 1)), NewTensor([]int{2, 3}, seq(6, 0, 1))}, 1); err == nil {
+		t.Error("MatMul of mismatched matrices succeeded")
+	}
+}
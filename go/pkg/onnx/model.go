@@ -0,0 +1,462 @@
+// Package onnx runs ONNX models in pure Go. It implements the operators
+// of convolutional and recurrent recognition models, such as CRNNs
+// exported from PyTorch, in float32 on the CPU. It is much slower than
+// ONNX Runtime, but needs neither cgo nor a native library.
+package onnx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ValueInfo describes a graph input or output.
+type ValueInfo struct {
+	Name string
+	// Shape holds each dimension, or -1 where it is dynamic.
+	Shape []int64
+}
+
+// Model is a parsed ONNX graph. It is safe for concurrent use by multiple
+// goroutines.
+type Model struct {
+	Inputs  []ValueInfo
+	Outputs []ValueInfo
+	// Threads bounds the goroutines a run splits its larger kernels
+	// across; 0 means GOMAXPROCS.
+	Threads int
+
+	nodes        []*node
+	initializers map[string]*Tensor
+	// lastUse is the index of the last node reading each value, after
+	// which the value is dropped.
+	lastUse map[string]int
+	opset   int64
+}
+
+type node struct {
+	op      string
+	name    string
+	inputs  []string
+	outputs []string
+	attrs   map[string]*attribute
+}
+
+type attribute struct {
+	f       float32
+	i       int64
+	s       string
+	t       *Tensor
+	floats  []float32
+	ints    []int64
+	strings []string
+	hasInts bool
+}
+
+// Load reads and parses the model file at path.
+func Load(path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parse(data, filepath.Dir(path))
+}
+
+// Parse parses a model from its bytes. Models that keep their weights in
+// external data files must be loaded with Load.
+func Parse(data []byte) (*Model, error) {
+	return parse(data, "")
+}
+
+func parse(data []byte, dir string) (*Model, error) {
+	m := &Model{initializers: make(map[string]*Tensor), lastUse: make(map[string]int)}
+	var graph []byte
+	d := decoder{b: data}
+	for {
+		field, wire, ok := d.next()
+		if !ok {
+			break
+		}
+		switch field {
+		case 7:
+			graph = d.bytes()
+		case 8:
+			domain, version := parseOpset(d.bytes())
+			if domain == "" || domain == "ai.onnx" {
+				m.opset = version
+			}
+		default:
+			d.skip(wire)
+		}
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("failed to parse model: %v", d.err)
+	}
+	if graph == nil {
+		return nil, fmt.Errorf("failed to parse model: no graph")
+	}
+	if err := m.parseGraph(graph, dir); err != nil {
+		return nil, fmt.Errorf("failed to parse model: %v", err)
+	}
+
+	for i, n := range m.nodes {
+		if ops[n.op] == nil {
+			return nil, fmt.Errorf("unsupported operator %s (node %q)", n.op, n.name)
+		}
+		for _, in := range n.inputs {
+			m.lastUse[in] = i
+		}
+	}
+	for _, out := range m.Outputs {
+		m.lastUse[out.Name] = len(m.nodes)
+	}
+	return m, nil
+}
+
+func parseOpset(b []byte) (string, int64) {
+	d := decoder{b: b}
+	var domain string
+	var version int64
+	for {
+		field, wire, ok := d.next()
+		if !ok {
+			break
+		}
+		switch field {
+		case 1:
+			domain = d.string()
+		case 2:
+			version = int64(d.varint())
+		default:
+			d.skip(wire)
+		}
+	}
+	return domain, version
+}
+
+func (m *Model) parseGraph(b []byte, dir string) error {
+	var inputs []ValueInfo
+	d := decoder{b: b}
+	for {
+		field, wire, ok := d.next()
+		if !ok {
+			break
+		}
+		switch field {
+		case 1:
+			n, err := parseNode(d.bytes(), dir)
+			if err != nil {
+				return err
+			}
+			m.nodes = append(m.nodes, n)
+		case 5:
+			name, t, err := parseTensor(d.bytes(), dir)
+			if err != nil {
+				return err
+			}
+			m.initializers[name] = t
+		case 11:
+			inputs = append(inputs, parseValueInfo(d.bytes()))
+		case 12:
+			m.Outputs = append(m.Outputs, parseValueInfo(d.bytes()))
+		case 15:
+			return fmt.Errorf("sparse initializers are not supported")
+		default:
+			d.skip(wire)
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+	// Older models also list their initializers as inputs
+	for _, in := range inputs {
+		if _, ok := m.initializers[in.Name]; !ok {
+			m.Inputs = append(m.Inputs, in)
+		}
+	}
+	return nil
+}
+
+func parseNode(b []byte, dir string) (*node, error) {
+	n := &node{attrs: make(map[string]*attribute)}
+	var domain string
+	d := decoder{b: b}
+	for {
+		field, wire, ok := d.next()
+		if !ok {
+			break
+		}
+		switch field {
+		case 1:
+			n.inputs = append(n.inputs, d.string())
+		case 2:
+			n.outputs = append(n.outputs, d.string())
+		case 3:
+			n.name = d.string()
+		case 4:
+			n.op = d.string()
+		case 5:
+			name, a, err := parseAttribute(d.bytes(), dir)
+			if err != nil {
+				return nil, fmt.Errorf("node %q: %v", n.name, err)
+			}
+			n.attrs[name] = a
+		case 7:
+			domain = d.string()
+		default:
+			d.skip(wire)
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if domain != "" && domain != "ai.onnx" {
+		return nil, fmt.Errorf("unsupported operator %s.%s (node %q)", domain, n.op, n.name)
+	}
+	return n, nil
+}
+
+func parseAttribute(b []byte, dir string) (string, *attribute, error) {
+	a := &attribute{}
+	var name string
+	d := decoder{b: b}
+	for {
+		field, wire, ok := d.next()
+		if !ok {
+			break
+		}
+		switch field {
+		case 1:
+			name = d.string()
+		case 2:
+			a.f = d.float32s(wire, nil)[0]
+		case 3:
+			a.i = int64(d.varint())
+		case 4:
+			a.s = d.string()
+		case 5:
+			_, t, err := parseTensor(d.bytes(), dir)
+			if err != nil {
+				return "", nil, err
+			}
+			a.t = t
+		case 6:
+			return "", nil, fmt.Errorf("attribute %q: subgraphs are not supported", name)
+		case 7:
+			a.floats = d.float32s(wire, a.floats)
+		case 8:
+			a.ints = d.int64s(wire, a.ints)
+			a.hasInts = true
+		case 9:
+			a.strings = append(a.strings, d.string())
+		default:
+			d.skip(wire)
+		}
+	}
+	return name, a, d.err
+}
+
+func parseValueInfo(b []byte) ValueInfo {
+	var v ValueInfo
+	d := decoder{b: b}
+	for {
+		field, wire, ok := d.next()
+		if !ok {
+			break
+		}
+		switch field {
+		case 1:
+			v.Name = d.string()
+		case 2:
+			v.Shape = parseTypeShape(d.bytes())
+		default:
+			d.skip(wire)
+		}
+	}
+	return v
+}
+
+// parseTypeShape reads the shape of a tensor TypeProto.
+func parseTypeShape(b []byte) []int64 {
+	var shape []int64
+	d := decoder{b: b}
+	for {
+		field, wire, ok := d.next()
+		if !ok {
+			break
+		}
+		if field != 1 {
+			d.skip(wire)
+			continue
+		}
+		tensor := decoder{b: d.bytes()}
+		for {
+			f, w, ok := tensor.next()
+			if !ok {
+				break
+			}
+			if f != 2 {
+				tensor.skip(w)
+				continue
+			}
+			dims := decoder{b: tensor.bytes()}
+			for {
+				f, w, ok := dims.next()
+				if !ok {
+					break
+				}
+				if f != 1 {
+					dims.skip(w)
+					continue
+				}
+				dim := decoder{b: dims.bytes()}
+				value := int64(-1)
+				for {
+					f, w, ok := dim.next()
+					if !ok {
+						break
+					}
+					if f == 1 {
+						value = int64(dim.varint())
+					} else {
+						dim.skip(w)
+					}
+				}
+				shape = append(shape, value)
+			}
+		}
+	}
+	return shape
+}
+
+// run carries the settings of one Run to the kernels.
+type run struct {
+	threads int
+	opset   int64
+}
+
+// Run runs the model on inputs, keyed by input name, and returns every
+// graph output by name.
+func (m *Model) Run(inputs map[string]*Tensor) (map[string]*Tensor, error) {
+	r := &run{threads: m.Threads, opset: m.opset}
+	if r.threads <= 0 {
+		r.threads = runtime.GOMAXPROCS(0)
+	}
+
+	values := make(map[string]*Tensor, len(inputs))
+	for _, in := range m.Inputs {
+		t, ok := inputs[in.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing input %q", in.Name)
+		}
+		if len(t.Data) != t.Len() && !t.isInt {
+			return nil, fmt.Errorf("input %q has %d values for shape %v", in.Name, len(t.Data), t.Shape)
+		}
+		values[in.Name] = t
+	}
+
+	lookup := func(name string) *Tensor {
+		if t, ok := values[name]; ok {
+			return t
+		}
+		return m.initializers[name]
+	}
+	for i, n := range m.nodes {
+		in := make([]*Tensor, len(n.inputs))
+		for j, name := range n.inputs {
+			if name == "" {
+				continue
+			}
+			if in[j] = lookup(name); in[j] == nil {
+				return nil, fmt.Errorf("node %q (%s): input %q is not defined", n.name, n.op, name)
+			}
+		}
+		out, err := ops[n.op](r, n, in)
+		if err != nil {
+			return nil, fmt.Errorf("node %q (%s): %v", n.name, n.op, err)
+		}
+		for j, name := range n.outputs {
+			if name != "" && j < len(out) {
+				values[name] = out[j]
+			}
+		}
+		for _, name := range n.inputs {
+			if m.lastUse[name] == i {
+				delete(values, name)
+			}
+		}
+	}
+
+	outputs := make(map[string]*Tensor, len(m.Outputs))
+	for _, out := range m.Outputs {
+		t := lookup(out.Name)
+		if t == nil {
+			return nil, fmt.Errorf("output %q is not computed", out.Name)
+		}
+		outputs[out.Name] = t
+	}
+	return outputs, nil
+}
+
+// Attribute accessors with ONNX defaults.
+
+func (n *node) int(name string, def int64) int64 {
+	if a, ok := n.attrs[name]; ok {
+		return a.i
+	}
+	return def
+}
+
+func (n *node) float(name string, def float32) float32 {
+	if a, ok := n.attrs[name]; ok {
+		return a.f
+	}
+	return def
+}
+
+func (n *node) string(name, def string) string {
+	if a, ok := n.attrs[name]; ok {
+		return a.s
+	}
+	return def
+}
+
+func (n *node) ints(name string) ([]int64, bool) {
+	if a, ok := n.attrs[name]; ok && a.hasInts {
+		return a.ints, true
+	}
+	return nil, false
+}
+
+// input returns input i, or nil if it is absent.
+func input(in []*Tensor, i int) *Tensor {
+	if i < len(in) {
+		return in[i]
+	}
+	return nil
+}
+
+func unsupported(format string, args ...any) error {
+	return fmt.Errorf("unsupported "+format, args...)
+}
+
+// normAxis maps a negative axis onto [0, rank).
+func normAxis(axis int64, rank int) (int, error) {
+	if axis < 0 {
+		axis += int64(rank)
+	}
+	if axis < 0 || axis >= int64(rank) {
+		return 0, fmt.Errorf("axis %d out of range for rank %d", axis, rank)
+	}
+	return int(axis), nil
+}
+
+func shapeString(shape []int) string {
+	parts := make([]string, len(shape))
+	for i, d := range shape {
+		parts[i] = fmt.Sprint(d)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
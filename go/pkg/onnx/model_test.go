@@ -0,0 +1,374 @@
+package onnx
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// The tests build their models with a small protobuf encoder, so each
+// graph is spelled out next to what it should compute.
+
+func appendKey(b []byte, field, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wire))
+}
+
+func appendVarint(b []byte, field int, v uint64) []byte {
+	return binary.AppendUvarint(appendKey(b, field, wireVarint), v)
+}
+
+func appendBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(appendKey(b, field, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendFloat(b []byte, field int, v float32) []byte {
+	return binary.LittleEndian.AppendUint32(appendKey(b, field, wireFixed32), math.Float32bits(v))
+}
+
+func packedFloats(vs []float32) []byte {
+	var b []byte
+	for _, v := range vs {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+	}
+	return b
+}
+
+func packedInts(vs []int64) []byte {
+	var b []byte
+	for _, v := range vs {
+		b = binary.AppendUvarint(b, uint64(v))
+	}
+	return b
+}
+
+// attr is an encoded AttributeProto.
+type attr []byte
+
+func intAttr(name string, v int64) attr {
+	return appendVarint(appendBytes(nil, 1, []byte(name)), 3, uint64(v))
+}
+
+func floatAttr(name string, v float32) attr {
+	return appendFloat(appendBytes(nil, 1, []byte(name)), 2, v)
+}
+
+func stringAttr(name, v string) attr {
+	return appendBytes(appendBytes(nil, 1, []byte(name)), 4, []byte(v))
+}
+
+func intsAttr(name string, vs ...int64) attr {
+	return appendBytes(appendBytes(nil, 1, []byte(name)), 8, packedInts(vs))
+}
+
+func stringsAttr(name string, vs ...string) attr {
+	b := appendBytes(nil, 1, []byte(name))
+	for _, v := range vs {
+		b = appendBytes(b, 9, []byte(v))
+	}
+	return b
+}
+
+// nodeProto encodes a node of op reading in and writing out.
+func nodeProto(op string, in, out []string, attrs ...attr) []byte {
+	var b []byte
+	for _, name := range in {
+		b = appendBytes(b, 1, []byte(name))
+	}
+	for _, name := range out {
+		b = appendBytes(b, 2, []byte(name))
+	}
+	b = appendBytes(b, 3, []byte(op+"_node"))
+	b = appendBytes(b, 4, []byte(op))
+	for _, a := range attrs {
+		b = appendBytes(b, 5, a)
+	}
+	return b
+}
+
+// floatTensor encodes a float TensorProto with its values in float_data.
+func floatTensor(name string, shape []int64, data []float32) []byte {
+	b := appendBytes(nil, 1, packedInts(shape))
+	b = appendVarint(b, 2, typeFloat)
+	b = appendBytes(b, 4, packedFloats(data))
+	return appendBytes(b, 8, []byte(name))
+}
+
+// valueInfo encodes a float ValueInfoProto; negative dimensions are
+// symbolic.
+func valueInfo(name string, shape ...int64) []byte {
+	var dims []byte
+	for _, d := range shape {
+		var dim []byte
+		if d < 0 {
+			dim = appendBytes(nil, 2, []byte("n"))
+		} else {
+			dim = appendVarint(nil, 1, uint64(d))
+		}
+		dims = appendBytes(dims, 1, dim)
+	}
+	tensor := appendVarint(nil, 1, typeFloat)
+	tensor = appendBytes(tensor, 2, dims)
+	return appendBytes(appendBytes(nil, 1, []byte(name)), 2, appendBytes(nil, 1, tensor))
+}
+
+// graph is the parts of a GraphProto, each encoded.
+type graph struct {
+	nodes, initializers, inputs, outputs [][]byte
+}
+
+// modelProto encodes a ModelProto importing the default domain at opset.
+func modelProto(opset int64, g graph) []byte {
+	var gb []byte
+	for _, n := range g.nodes {
+		gb = appendBytes(gb, 1, n)
+	}
+	gb = appendBytes(gb, 2, []byte("test"))
+	for _, t := range g.initializers {
+		gb = appendBytes(gb, 5, t)
+	}
+	for _, v := range g.inputs {
+		gb = appendBytes(gb, 11, v)
+	}
+	for _, v := range g.outputs {
+		gb = appendBytes(gb, 12, v)
+	}
+
+	b := appendVarint(nil, 1, 8)
+	b = appendBytes(b, 7, gb)
+	opsetID := appendBytes(nil, 1, nil)
+	return appendBytes(b, 8, appendVarint(opsetID, 2, uint64(opset)))
+}
+
+// runOp runs a single op node on in, named x0, x1 and so on with nil for
+// absent optional inputs, and returns its outputs outs.
+func runOp(t *testing.T, op string, in []*Tensor, outs int, attrs ...attr) []*Tensor {
+	t.Helper()
+	out, err := tryOp(op, in, outs, attrs...)
+	if err != nil {
+		t.Fatalf("%s: %v", op, err)
+	}
+	return out
+}
+
+func tryOp(op string, in []*Tensor, outs int, attrs ...attr) ([]*Tensor, error) {
+	var g graph
+	names := make([]string, len(in))
+	inputs := make(map[string]*Tensor)
+	for i, x := range in {
+		if x == nil {
+			continue
+		}
+		names[i] = "x" + string(rune('0'+i))
+		inputs[names[i]] = x
+		g.inputs = append(g.inputs, valueInfo(names[i]))
+	}
+	outNames := make([]string, outs)
+	for i := range outNames {
+		outNames[i] = "y" + string(rune('0'+i))
+		g.outputs = append(g.outputs, valueInfo(outNames[i]))
+	}
+	g.nodes = [][]byte{nodeProto(op, names, outNames, attrs...)}
+
+	m, err := Parse(modelProto(17, g))
+	if err != nil {
+		return nil, err
+	}
+	results, err := m.Run(inputs)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Tensor, outs)
+	for i, name := range outNames {
+		out[i] = results[name]
+	}
+	return out, nil
+}
+
+// assertClose fails unless got has shape and values within tol of want,
+// relative to their magnitude above 1.
+func assertClose(t *testing.T, name string, got *Tensor, shape []int, want []float32, tol float64) {
+	t.Helper()
+	if !slices.Equal(got.Shape, shape) {
+		t.Fatalf("%s: shape %v, want %v", name, got.Shape, shape)
+	}
+	if len(got.Data) != len(want) {
+		t.Fatalf("%s: %d values, want %d", name, len(got.Data), len(want))
+	}
+	for i := range want {
+		g, w := float64(got.Data[i]), float64(want[i])
+		if math.Abs(g-w) > tol*max(1, math.Abs(w)) {
+			t.Fatalf("%s: value %d is %v, want %v", name, i, g, w)
+		}
+	}
+}
+
+// seq returns n values counting up from start by step.
+func seq(n int, start, step float32) []float32 {
+	vs := make([]float32, n)
+	for i := range vs {
+		vs[i] = start + float32(i)*step
+	}
+	return vs
+}
+
+func TestDecoder(t *testing.T) {
+	var b []byte
+	b = appendVarint(b, 1, 300)
+	b = appendBytes(b, 2, packedInts([]int64{1, 2, -1}))
+	b = appendVarint(b, 2, 7)
+	b = appendBytes(b, 3, packedFloats([]float32{1.5, -2}))
+	b = appendFloat(b, 3, 0.25)
+	b = appendBytes(b, 4, []byte("name"))
+	b = binary.LittleEndian.AppendUint64(appendKey(b, 5, wireFixed64), 1)
+
+	var ints []int64
+	var floats []float32
+	d := decoder{b: b}
+	for {
+		field, wire, ok := d.next()
+		if !ok {
+			break
+		}
+		switch field {
+		case 1:
+			if v := d.varint(); v != 300 {
+				t.Errorf("varint = %d, want 300", v)
+			}
+		case 2:
+			ints = d.int64s(wire, ints)
+		case 3:
+			floats = d.float32s(wire, floats)
+		case 4:
+			if s := d.string(); s != "name" {
+				t.Errorf("string = %q, want name", s)
+			}
+		default:
+			d.skip(wire)
+		}
+	}
+	if d.err != nil {
+		t.Fatal(d.err)
+	}
+	if want := []int64{1, 2, -1, 7}; !slices.Equal(ints, want) {
+		t.Errorf("int64s = %v, want %v", ints, want)
+	}
+	if want := []float32{1.5, -2, 0.25}; !slices.Equal(floats, want) {
+		t.Errorf("float32s = %v, want %v", floats, want)
+	}
+
+	for _, bad := range [][]byte{
+		{0x0a, 0x05, 'a'},    // bytes past the end
+		{0x08, 0x80},         // unterminated varint
+		{0x0d, 0x01, 0x02},   // short fixed32
+		{0x0b, 0x00},         // group wire type
+		appendKey(nil, 1, 2), // missing length
+	} {
+		d := decoder{b: bad}
+		for {
+			_, wire, ok := d.next()
+			if !ok {
+				break
+			}
+			d.skip(wire)
+		}
+		if d.err == nil {
+			t.Errorf("decoding % x succeeded", bad)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	// Weights as float_data, raw float32, raw float16 and int64s
+	raw := appendBytes(nil, 1, packedInts([]int64{2}))
+	raw = appendVarint(raw, 2, typeFloat)
+	raw = appendBytes(raw, 8, []byte("raw"))
+	raw = appendBytes(raw, 9, packedFloats([]float32{3, 4}))
+	half := appendBytes(nil, 1, packedInts([]int64{3}))
+	half = appendVarint(half, 2, typeFloat16)
+	half = appendBytes(half, 8, []byte("half"))
+	half = appendBytes(half, 9, []byte{0x00, 0x3c, 0x00, 0xc0, 0x00, 0x38}) // 1, -2, 0.5
+	shape := appendBytes(nil, 1, packedInts([]int64{2}))
+	shape = appendVarint(shape, 2, typeInt64)
+	shape = appendBytes(shape, 7, packedInts([]int64{1, 2}))
+	shape = appendBytes(shape, 8, []byte("shape"))
+
+	g := graph{
+		initializers: [][]byte{floatTensor("w", []int64{2}, []float32{1, 2}), raw, half, shape},
+		// Older exporters list initializers as inputs too
+		inputs:  [][]byte{valueInfo("x", -1, 2), valueInfo("w", 2)},
+		outputs: [][]byte{valueInfo("y", -1, 2), valueInfo("h", 3)},
+		nodes: [][]byte{
+			nodeProto("Add", []string{"x", "w"}, []string{"s"}),
+			nodeProto("Mul", []string{"s", "raw"}, []string{"p"}),
+			nodeProto("Reshape", []string{"p", "shape"}, []string{"y"}),
+			nodeProto("Identity", []string{"half"}, []string{"h"}),
+		},
+	}
+	m, err := Parse(modelProto(13, g))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Inputs) != 1 || m.Inputs[0].Name != "x" || !slices.Equal(m.Inputs[0].Shape, []int64{-1, 2}) {
+		t.Errorf("inputs = %+v, want x [-1 2]", m.Inputs)
+	}
+	if len(m.Outputs) != 2 || m.Outputs[0].Name != "y" || !slices.Equal(m.Outputs[1].Shape, []int64{3}) {
+		t.Errorf("outputs = %+v, want y [-1 2] and h [3]", m.Outputs)
+	}
+	if m.opset != 13 {
+		t.Errorf("opset = %d, want 13", m.opset)
+	}
+
+	out, err := m.Run(map[string]*Tensor{"x": NewTensor([]int{2}, []float32{10, 20})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "y", out["y"], []int{1, 2}, []float32{33, 88}, 0)
+	assertClose(t, "h", out["h"], []int{3}, []float32{1, -2, 0.5}, 0)
+
+	if _, err := m.Run(nil); err == nil || !strings.Contains(err.Error(), `missing input "x"`) {
+		t.Errorf("Run without inputs: %v, want missing input", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		model []byte
+		want  string
+	}{
+		{"truncated", []byte{0x3a, 0x10, 0x01}, "malformed protobuf"},
+		{"no graph", appendVarint(nil, 1, 8), "no graph"},
+		{"unsupported op", modelProto(17, graph{nodes: [][]byte{nodeProto("Loop", nil, []string{"y"})}}), "unsupported operator Loop"},
+		{"short tensor", modelProto(17, graph{initializers: [][]byte{floatTensor("w", []int64{3}, []float32{1})}}), "expected 3 values"},
+	} {
+		_, err := Parse(test.model)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: %v, want an error containing %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestHalfBits(t *testing.T) {
+	for _, test := range []struct {
+		bits     uint16
+		dataType int
+		want     float32
+	}{
+		{0x3c00, typeFloat16, 1},
+		{0xc000, typeFloat16, -2},
+		{0x7bff, typeFloat16, 65504},
+		{0x0001, typeFloat16, 1.0 / (1 << 24)},
+		{0x3f80, typeBfloat16, 1},
+		{0xc040, typeBfloat16, -3},
+	} {
+		if got := halfBits(test.bits, test.dataType); got != test.want {
+			t.Errorf("halfBits(%#04x, %d) = %v, want %v", test.bits, test.dataType, got, test.want)
+		}
+	}
+	if got := halfBits(0x7c00, typeFloat16); !math.IsInf(float64(got), 1) {
+		t.Errorf("halfBits(0x7c00) = %v, want +Inf", got)
+	}
+}
@@ -0,0 +1,1002 @@
+package onnx
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// kernel computes a node's outputs from its inputs, which it must not
+// modify. Absent optional inputs are nil.
+type kernel func(r *run, n *node, in []*Tensor) ([]*Tensor, error)
+
+// ops are the supported operators of the default ONNX domain.
+var ops map[string]kernel
+
+func init() {
+	ops = map[string]kernel{
+		"Identity": identity,
+		"Dropout":  identity,
+
+		"Constant":        constant,
+		"ConstantOfShape": constantOfShape,
+		"Cast":            cast,
+		"Shape":           shapeOf,
+		"Size":            sizeOf,
+		"Gather":          gather,
+		"Unsqueeze":       unsqueeze,
+		"Squeeze":         squeeze,
+		"Reshape":         reshape,
+		"Flatten":         flatten,
+		"Concat":          concat,
+		"Split":           split,
+		"Transpose":       transpose,
+		"Slice":           slice,
+		"Expand":          expand,
+		"Pad":             pad,
+		"Range":           rangeOp,
+		"Where":           where,
+
+		"Add":     binaryOp(func(x, y float32) float32 { return x + y }, func(x, y int64) int64 { return x + y }),
+		"Sub":     binaryOp(func(x, y float32) float32 { return x - y }, func(x, y int64) int64 { return x - y }),
+		"Mul":     binaryOp(func(x, y float32) float32 { return x * y }, func(x, y int64) int64 { return x * y }),
+		"Div":     binaryOp(func(x, y float32) float32 { return x / y }, divInt),
+		"Pow":     binaryOp(func(x, y float32) float32 { return float32(math.Pow(float64(x), float64(y))) }, nil),
+		"Max":     binaryOp(func(x, y float32) float32 { return max(x, y) }, func(x, y int64) int64 { return max(x, y) }),
+		"Min":     binaryOp(func(x, y float32) float32 { return min(x, y) }, func(x, y int64) int64 { return min(x, y) }),
+		"Equal":   compareOp(func(x, y float64) bool { return x == y }),
+		"Less":    compareOp(func(x, y float64) bool { return x < y }),
+		"Greater": compareOp(func(x, y float64) bool { return x > y }),
+
+		"Relu":       unaryOp(func(x float32) float32 { return max(x, 0) }),
+		"Sigmoid":    unaryOp(sigmoid),
+		"Tanh":       unaryOp(tanh),
+		"Sqrt":       unaryOp(func(x float32) float32 { return float32(math.Sqrt(float64(x))) }),
+		"Exp":        unaryOp(func(x float32) float32 { return float32(math.Exp(float64(x))) }),
+		"Log":        unaryOp(func(x float32) float32 { return float32(math.Log(float64(x))) }),
+		"Neg":        unaryOp(func(x float32) float32 { return -x }),
+		"Abs":        unaryOp(func(x float32) float32 { return float32(math.Abs(float64(x))) }),
+		"Erf":        unaryOp(func(x float32) float32 { return float32(math.Erf(float64(x))) }),
+		"Reciprocal": unaryOp(func(x float32) float32 { return 1 / x }),
+		"LeakyRelu":  leakyRelu,
+		"Clip":       clip,
+		"Softmax":    softmax(false),
+		"LogSoftmax": softmax(true),
+		"ReduceMean": reduce(func(acc []float32, n int) {
+			for i := range acc {
+				acc[i] /= float32(n)
+			}
+		}),
+		"ReduceSum": reduce(nil),
+
+		"MatMul":             matMul,
+		"Gemm":               gemm,
+		"Conv":               conv,
+		"BatchNormalization": batchNorm,
+		"MaxPool":            pool(true),
+		"AveragePool":        pool(false),
+		"GlobalAveragePool":  globalAveragePool,
+		"LSTM":               lstm,
+		"GRU":                gru,
+	}
+}
+
+func identity(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	return []*Tensor{in[0]}, nil
+}
+
+func constant(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	if a, ok := n.attrs["value"]; ok && a.t != nil {
+		return []*Tensor{a.t}, nil
+	}
+	if a, ok := n.attrs["value_float"]; ok {
+		return []*Tensor{NewTensor(nil, []float32{a.f})}, nil
+	}
+	if a, ok := n.attrs["value_floats"]; ok {
+		return []*Tensor{NewTensor([]int{len(a.floats)}, a.floats)}, nil
+	}
+	if a, ok := n.attrs["value_int"]; ok {
+		return []*Tensor{newInts(nil, []int64{a.i})}, nil
+	}
+	if a, ok := n.attrs["value_ints"]; ok {
+		return []*Tensor{newInts([]int{len(a.ints)}, a.ints)}, nil
+	}
+	return nil, unsupported("constant value")
+}
+
+func constantOfShape(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	shape, err := dims(in[0])
+	if err != nil {
+		return nil, err
+	}
+	value := NewTensor(nil, []float32{0})
+	if a, ok := n.attrs["value"]; ok && a.t != nil {
+		value = a.t
+	}
+	out := value.like(shape)
+	if value.isInt {
+		v := value.ints[0]
+		for i := range out.ints {
+			out.ints[i] = v
+		}
+	} else {
+		v := value.Data[0]
+		for i := range out.Data {
+			out.Data[i] = v
+		}
+	}
+	return []*Tensor{out}, nil
+}
+
+func cast(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	t := in[0]
+	switch to := n.int("to", typeFloat); to {
+	case typeFloat, typeDouble, typeFloat16, typeBfloat16:
+		return []*Tensor{NewTensor(t.Shape, t.floats())}, nil
+	case typeBool:
+		vs := make([]int64, t.Len())
+		if t.isInt {
+			for i, v := range t.ints {
+				vs[i] = b2i(v != 0)
+			}
+		} else {
+			for i, f := range t.Data {
+				vs[i] = b2i(f != 0)
+			}
+		}
+		return []*Tensor{newInts(t.Shape, vs)}, nil
+	case typeUint8, typeInt8, typeUint16, typeInt16, typeInt32, typeInt64, typeUint32, typeUint64:
+		return []*Tensor{newInts(t.Shape, t.values())}, nil
+	default:
+		return nil, unsupported("cast to type %d", to)
+	}
+}
+
+func shapeOf(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	rank := len(in[0].Shape)
+	start, end := clampRange(n.int("start", 0), rank), rank
+	if _, ok := n.attrs["end"]; ok {
+		end = clampRange(n.int("end", 0), rank)
+	}
+	vs := []int64{}
+	for _, d := range in[0].Shape[start:max(start, end)] {
+		vs = append(vs, int64(d))
+	}
+	return []*Tensor{newInts([]int{len(vs)}, vs)}, nil
+}
+
+// clampRange maps an index that may be negative onto [0, rank].
+func clampRange(i int64, rank int) int {
+	if i < 0 {
+		i += int64(rank)
+	}
+	return int(min(max(i, 0), int64(rank)))
+}
+
+func sizeOf(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	return []*Tensor{newInts(nil, []int64{int64(in[0].Len())})}, nil
+}
+
+func gather(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	data, indices := in[0], in[1].values()
+	axis, err := normAxis(n.int("axis", 0), len(data.Shape))
+	if err != nil {
+		return nil, err
+	}
+	dim := data.Shape[axis]
+	outer := size(data.Shape[:axis])
+	inner := size(data.Shape[axis+1:])
+
+	shape := slices.Concat(data.Shape[:axis], in[1].Shape, data.Shape[axis+1:])
+	out := data.like(shape)
+	for o := 0; o < outer; o++ {
+		for j, k := range indices {
+			if k < 0 {
+				k += int64(dim)
+			}
+			if k < 0 || k >= int64(dim) {
+				return nil, fmt.Errorf("index %d out of range for dimension %d", indices[j], dim)
+			}
+			src := (o*dim + int(k)) * inner
+			dst := (o*len(indices) + j) * inner
+			if data.isInt {
+				copy(out.ints[dst:dst+inner], data.ints[src:src+inner])
+			} else {
+				copy(out.Data[dst:dst+inner], data.Data[src:src+inner])
+			}
+		}
+	}
+	return []*Tensor{out}, nil
+}
+
+// axesArg returns the axes of a node that takes them as an attribute in
+// older opsets and as input i in newer ones.
+func axesArg(n *node, in []*Tensor, i int) ([]int64, bool) {
+	if t := input(in, i); t != nil {
+		return t.values(), true
+	}
+	return n.ints("axes")
+}
+
+func unsqueeze(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	axes, ok := axesArg(n, in, 1)
+	if !ok {
+		return nil, fmt.Errorf("no axes")
+	}
+	rank := len(in[0].Shape) + len(axes)
+	insert := make([]bool, rank)
+	for _, a := range axes {
+		axis, err := normAxis(a, rank)
+		if err != nil {
+			return nil, err
+		}
+		insert[axis] = true
+	}
+	shape := make([]int, 0, rank)
+	rest := in[0].Shape
+	for _, ins := range insert {
+		if ins {
+			shape = append(shape, 1)
+		} else {
+			shape = append(shape, rest[0])
+			rest = rest[1:]
+		}
+	}
+	return []*Tensor{view(in[0], shape)}, nil
+}
+
+func squeeze(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	t := in[0]
+	drop := make([]bool, len(t.Shape))
+	if axes, ok := axesArg(n, in, 1); ok {
+		for _, a := range axes {
+			axis, err := normAxis(a, len(t.Shape))
+			if err != nil {
+				return nil, err
+			}
+			if t.Shape[axis] != 1 {
+				return nil, fmt.Errorf("cannot squeeze dimension %d of size %d", axis, t.Shape[axis])
+			}
+			drop[axis] = true
+		}
+	} else {
+		for i, d := range t.Shape {
+			drop[i] = d == 1
+		}
+	}
+	var shape []int
+	for i, d := range t.Shape {
+		if !drop[i] {
+			shape = append(shape, d)
+		}
+	}
+	return []*Tensor{view(t, shape)}, nil
+}
+
+// view returns t with another shape of the same size, sharing its data.
+func view(t *Tensor, shape []int) *Tensor {
+	v := *t
+	v.Shape = shape
+	return &v
+}
+
+// dims reads a shape from a 1-D integer tensor.
+func dims(t *Tensor) ([]int, error) {
+	vs := t.values()
+	shape := make([]int, len(vs))
+	for i, v := range vs {
+		if v < 0 {
+			return nil, fmt.Errorf("negative dimension %d", v)
+		}
+		shape[i] = int(v)
+	}
+	return shape, nil
+}
+
+func reshape(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	t := in[0]
+	target := in[1].values()
+	shape := make([]int, len(target))
+	infer := -1
+	known := 1
+	for i, d := range target {
+		switch {
+		case d == -1:
+			if infer >= 0 {
+				return nil, fmt.Errorf("more than one inferred dimension")
+			}
+			infer = i
+			continue
+		case d == 0 && n.int("allowzero", 0) == 0:
+			if i >= len(t.Shape) {
+				return nil, fmt.Errorf("cannot copy dimension %d of %s", i, shapeString(t.Shape))
+			}
+			shape[i] = t.Shape[i]
+		case d < 0:
+			return nil, fmt.Errorf("invalid dimension %d", d)
+		default:
+			shape[i] = int(d)
+		}
+		known *= shape[i]
+	}
+	if infer >= 0 {
+		if known == 0 || t.Len()%known != 0 {
+			return nil, fmt.Errorf("cannot reshape %s to %v", shapeString(t.Shape), target)
+		}
+		shape[infer] = t.Len() / known
+	}
+	if size(shape) != t.Len() {
+		return nil, fmt.Errorf("cannot reshape %s to %v", shapeString(t.Shape), target)
+	}
+	return []*Tensor{view(t, shape)}, nil
+}
+
+func flatten(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	t := in[0]
+	axis := n.int("axis", 1)
+	if axis < 0 {
+		axis += int64(len(t.Shape))
+	}
+	if axis < 0 || axis > int64(len(t.Shape)) {
+		return nil, fmt.Errorf("axis %d out of range", axis)
+	}
+	return []*Tensor{view(t, []int{size(t.Shape[:axis]), size(t.Shape[axis:])})}, nil
+}
+
+func concat(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	first := in[0]
+	axis, err := normAxis(n.int("axis", 0), len(first.Shape))
+	if err != nil {
+		return nil, err
+	}
+	shape := slices.Clone(first.Shape)
+	shape[axis] = 0
+	isInt := true
+	for _, t := range in {
+		if len(t.Shape) != len(shape) {
+			return nil, fmt.Errorf("rank mismatch: %s and %s", shapeString(first.Shape), shapeString(t.Shape))
+		}
+		for i, d := range t.Shape {
+			if i != axis && d != first.Shape[i] {
+				return nil, fmt.Errorf("shape mismatch: %s and %s", shapeString(first.Shape), shapeString(t.Shape))
+			}
+		}
+		shape[axis] += t.Shape[axis]
+		isInt = isInt && t.isInt
+	}
+
+	outer := size(shape[:axis])
+	inner := size(shape[axis+1:])
+	var out *Tensor
+	if isInt {
+		out = newInts(shape, make([]int64, 0, size(shape)))
+	} else {
+		out = &Tensor{Shape: shape, Data: make([]float32, 0, size(shape))}
+	}
+	for o := 0; o < outer; o++ {
+		for _, t := range in {
+			block := t.Shape[axis] * inner
+			if isInt {
+				out.ints = append(out.ints, t.ints[o*block:(o+1)*block]...)
+			} else {
+				out.Data = append(out.Data, t.floats()[o*block:(o+1)*block]...)
+			}
+		}
+	}
+	return []*Tensor{out}, nil
+}
+
+func split(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	t := in[0]
+	axis, err := normAxis(n.int("axis", 0), len(t.Shape))
+	if err != nil {
+		return nil, err
+	}
+	dim := t.Shape[axis]
+	var sizes []int64
+	if s := input(in, 1); s != nil {
+		sizes = s.values()
+	} else if s, ok := n.ints("split"); ok {
+		sizes = s
+	} else {
+		parts := len(n.outputs)
+		chunk := (dim + parts - 1) / parts
+		for left := dim; left > 0; left -= chunk {
+			sizes = append(sizes, int64(min(chunk, left)))
+		}
+	}
+
+	outs := make([]*Tensor, len(sizes))
+	start := 0
+	for i, s := range sizes {
+		starts := make([]int, len(t.Shape))
+		starts[axis] = start
+		shape := slices.Clone(t.Shape)
+		shape[axis] = int(s)
+		outs[i] = window(t, shape, starts)
+		start += int(s)
+	}
+	if start != dim {
+		return nil, fmt.Errorf("split sizes %v don't add up to %d", sizes, dim)
+	}
+	return outs, nil
+}
+
+// window copies the block of t of the given shape starting at starts.
+func window(t *Tensor, shape, starts []int) *Tensor {
+	st := strides(t.Shape)
+	offset := 0
+	for i, s := range starts {
+		offset += s * st[i]
+	}
+	return stridedCopy(t, shape, offset, st)
+}
+
+func transpose(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	t := in[0]
+	rank := len(t.Shape)
+	perm, ok := n.ints("perm")
+	if !ok {
+		perm = make([]int64, rank)
+		for i := range perm {
+			perm[i] = int64(rank - 1 - i)
+		}
+	}
+	if len(perm) != rank {
+		return nil, fmt.Errorf("perm %v doesn't match rank %d", perm, rank)
+	}
+	return []*Tensor{permute(t, perm)}, nil
+}
+
+// permute returns t with its dimensions reordered by perm.
+func permute(t *Tensor, perm []int64) *Tensor {
+	st := strides(t.Shape)
+	shape := make([]int, len(perm))
+	steps := make([]int, len(perm))
+	for i, p := range perm {
+		shape[i] = t.Shape[p]
+		steps[i] = st[p]
+	}
+	return stridedCopy(t, shape, 0, steps)
+}
+
+// stridedCopy returns a tensor of shape whose element at index idx is the
+// element of t at offset + sum(idx[i] * steps[i]).
+func stridedCopy(t *Tensor, shape []int, offset int, steps []int) *Tensor {
+	out := t.like(shape)
+	n := size(shape)
+	if n == 0 {
+		return out
+	}
+	rank := len(shape)
+	if rank == 0 {
+		if t.isInt {
+			out.ints[0] = t.ints[offset]
+		} else {
+			out.Data[0] = t.Data[offset]
+		}
+		return out
+	}
+
+	idx := make([]int, rank)
+	last := shape[rank-1]
+	step := steps[rank-1]
+	src := offset
+	for dst := 0; dst < n; dst += last {
+		if t.isInt {
+			for j, s := 0, src; j < last; j, s = j+1, s+step {
+				out.ints[dst+j] = t.ints[s]
+			}
+		} else if step == 1 {
+			copy(out.Data[dst:dst+last], t.Data[src:src+last])
+		} else {
+			row := out.Data[dst : dst+last]
+			for j, s := 0, src; j < len(row); j, s = j+1, s+step {
+				row[j] = t.Data[s]
+			}
+		}
+		// Advance the outer dimensions like an odometer
+		for d := rank - 2; d >= 0; d-- {
+			idx[d]++
+			src += steps[d]
+			if idx[d] < shape[d] {
+				break
+			}
+			src -= steps[d] * shape[d]
+			idx[d] = 0
+		}
+	}
+	return out
+}
+
+func slice(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	t := in[0]
+	rank := len(t.Shape)
+	var starts, ends, axes, steps []int64
+	if s, ok := n.ints("starts"); ok {
+		starts = s
+		ends, _ = n.ints("ends")
+		axes, _ = n.ints("axes")
+	} else {
+		starts, ends = in[1].values(), in[2].values()
+		if a := input(in, 3); a != nil {
+			axes = a.values()
+		}
+		if s := input(in, 4); s != nil {
+			steps = s.values()
+		}
+	}
+	if len(ends) != len(starts) {
+		return nil, fmt.Errorf("%d starts but %d ends", len(starts), len(ends))
+	}
+
+	shape := slices.Clone(t.Shape)
+	st := strides(t.Shape)
+	stepsOut := slices.Clone(st)
+	offset := 0
+	for i := range starts {
+		axis := i
+		if axes != nil {
+			a, err := normAxis(axes[i], rank)
+			if err != nil {
+				return nil, err
+			}
+			axis = a
+		}
+		step := int64(1)
+		if steps != nil {
+			step = steps[i]
+		}
+		if step == 0 {
+			return nil, fmt.Errorf("zero step")
+		}
+		dim := int64(t.Shape[axis])
+		start, end := starts[i], ends[i]
+		if start < 0 {
+			start += dim
+		}
+		if end < 0 {
+			end += dim
+		}
+		var count int64
+		if step > 0 {
+			start = min(max(start, 0), dim)
+			end = min(max(end, 0), dim)
+			count = max(0, (end-start+step-1)/step)
+		} else {
+			start = min(max(start, -1), dim-1)
+			end = min(max(end, -1), dim-1)
+			count = max(0, (start-end-step-1)/-step)
+		}
+		shape[axis] = int(count)
+		stepsOut[axis] = st[axis] * int(step)
+		if count > 0 {
+			offset += int(start) * st[axis]
+		}
+	}
+	return []*Tensor{stridedCopy(t, shape, offset, stepsOut)}, nil
+}
+
+// broadcastShape returns the shape a and b broadcast to.
+func broadcastShape(a, b []int) ([]int, error) {
+	rank := max(len(a), len(b))
+	shape := make([]int, rank)
+	for i := range shape {
+		da, db := 1, 1
+		if j := i - rank + len(a); j >= 0 {
+			da = a[j]
+		}
+		if j := i - rank + len(b); j >= 0 {
+			db = b[j]
+		}
+		switch {
+		case da == db || db == 1:
+			shape[i] = da
+		case da == 1:
+			shape[i] = db
+		default:
+			return nil, fmt.Errorf("cannot broadcast %s and %s", shapeString(a), shapeString(b))
+		}
+	}
+	return shape, nil
+}
+
+// broadcastTo returns t expanded to shape, which it broadcasts to.
+func broadcastTo(t *Tensor, shape []int) *Tensor {
+	if slices.Equal(t.Shape, shape) {
+		return t
+	}
+	st := strides(t.Shape)
+	steps := make([]int, len(shape))
+	for i := range shape {
+		if j := i - len(shape) + len(t.Shape); j >= 0 && t.Shape[j] != 1 {
+			steps[i] = st[j]
+		}
+	}
+	return stridedCopy(t, shape, 0, steps)
+}
+
+func expand(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	target, err := dims(in[1])
+	if err != nil {
+		return nil, err
+	}
+	shape, err := broadcastShape(in[0].Shape, target)
+	if err != nil {
+		return nil, err
+	}
+	return []*Tensor{broadcastTo(in[0], shape)}, nil
+}
+
+func binaryOp(f func(x, y float32) float32, fi func(x, y int64) int64) kernel {
+	return func(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+		a, b := in[0], in[1]
+		shape, err := broadcastShape(a.Shape, b.Shape)
+		if err != nil {
+			return nil, err
+		}
+
+		if a.isInt && b.isInt && fi != nil {
+			a, b = broadcastTo(a, shape), broadcastTo(b, shape)
+			out := newInts(shape, make([]int64, size(shape)))
+			for i := range out.ints {
+				out.ints[i] = fi(a.ints[i], b.ints[i])
+			}
+			return []*Tensor{out}, nil
+		}
+
+		out := newFloat(shape)
+		av, bv := a.floats(), b.floats()
+		switch {
+		case slices.Equal(a.Shape, b.Shape):
+			for i := range out.Data {
+				out.Data[i] = f(av[i], bv[i])
+			}
+		case len(bv) == 1:
+			y := bv[0]
+			for i := range out.Data {
+				out.Data[i] = f(av[i], y)
+			}
+		case suffixBroadcast(shape, a.Shape, b.Shape):
+			// b repeats over a, as for a bias along the last dimensions
+			for i := range out.Data {
+				out.Data[i] = f(av[i], bv[i%len(bv)])
+			}
+		default:
+			ea := broadcastTo(NewTensor(a.Shape, av), shape).Data
+			eb := broadcastTo(NewTensor(b.Shape, bv), shape).Data
+			for i := range out.Data {
+				out.Data[i] = f(ea[i], eb[i])
+			}
+		}
+		return []*Tensor{out}, nil
+	}
+}
+
+// suffixBroadcast reports whether a has shape out and b matches out's
+// trailing dimensions, so b's elements repeat in order.
+func suffixBroadcast(out, a, b []int) bool {
+	if !slices.Equal(a, out) {
+		return false
+	}
+	trimmed := b
+	for len(trimmed) > 0 && trimmed[0] == 1 {
+		trimmed = trimmed[1:]
+	}
+	return len(trimmed) <= len(out) && slices.Equal(trimmed, out[len(out)-len(trimmed):])
+}
+
+func divInt(x, y int64) int64 {
+	if y == 0 {
+		return 0
+	}
+	return x / y
+}
+
+func compareOp(f func(x, y float64) bool) kernel {
+	return func(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+		shape, err := broadcastShape(in[0].Shape, in[1].Shape)
+		if err != nil {
+			return nil, err
+		}
+		a, b := broadcastTo(in[0], shape), broadcastTo(in[1], shape)
+		out := newInts(shape, make([]int64, size(shape)))
+		for i := range out.ints {
+			out.ints[i] = b2i(f(element(a, i), element(b, i)))
+		}
+		return []*Tensor{out}, nil
+	}
+}
+
+func element(t *Tensor, i int) float64 {
+	if t.isInt {
+		return float64(t.ints[i])
+	}
+	return float64(t.Data[i])
+}
+
+func b2i(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func where(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	shape, err := broadcastShape(in[0].Shape, in[1].Shape)
+	if err == nil {
+		shape, err = broadcastShape(shape, in[2].Shape)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cond := broadcastTo(in[0], shape).values()
+	x, y := broadcastTo(in[1], shape), broadcastTo(in[2], shape)
+	if x.isInt && y.isInt {
+		out := newInts(shape, make([]int64, len(cond)))
+		for i, c := range cond {
+			out.ints[i] = y.ints[i]
+			if c != 0 {
+				out.ints[i] = x.ints[i]
+			}
+		}
+		return []*Tensor{out}, nil
+	}
+	xv, yv := x.floats(), y.floats()
+	out := newFloat(shape)
+	for i, c := range cond {
+		out.Data[i] = yv[i]
+		if c != 0 {
+			out.Data[i] = xv[i]
+		}
+	}
+	return []*Tensor{out}, nil
+}
+
+func rangeOp(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	start, limit, delta := in[0].scalar(), in[1].scalar(), in[2].scalar()
+	if delta == 0 {
+		return nil, fmt.Errorf("zero delta")
+	}
+	count := max(0, int(math.Ceil((limit-start)/delta)))
+	if in[0].isInt {
+		vs := make([]int64, count)
+		for i := range vs {
+			vs[i] = int64(start) + int64(i)*int64(delta)
+		}
+		return []*Tensor{newInts([]int{count}, vs)}, nil
+	}
+	out := newFloat([]int{count})
+	for i := range out.Data {
+		out.Data[i] = float32(start + float64(i)*delta)
+	}
+	return []*Tensor{out}, nil
+}
+
+func pad(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	t := in[0]
+	if mode := n.string("mode", "constant"); mode != "constant" {
+		return nil, unsupported("pad mode %q", mode)
+	}
+	if input(in, 3) != nil {
+		return nil, unsupported("pad axes")
+	}
+	pads, ok := n.ints("pads")
+	if p := input(in, 1); p != nil {
+		pads, ok = p.values(), true
+	}
+	rank := len(t.Shape)
+	if !ok || len(pads) != 2*rank {
+		return nil, fmt.Errorf("expected %d pads", 2*rank)
+	}
+	value := float64(n.float("value", 0))
+	if v := input(in, 2); v != nil && v.Len() > 0 {
+		value = v.scalar()
+	}
+
+	shape := make([]int, rank)
+	for i, d := range t.Shape {
+		if pads[i] < 0 || pads[i+rank] < 0 {
+			return nil, unsupported("negative pads")
+		}
+		shape[i] = d + int(pads[i]+pads[i+rank])
+	}
+	out := t.like(shape)
+	if t.isInt {
+		for i := range out.ints {
+			out.ints[i] = int64(value)
+		}
+	} else {
+		for i := range out.Data {
+			out.Data[i] = float32(value)
+		}
+	}
+	if t.Len() == 0 {
+		return []*Tensor{out}, nil
+	}
+
+	// Copy t's rows into place, one innermost row at a time
+	outStrides := strides(shape)
+	idx := make([]int, rank)
+	last := t.Shape[rank-1]
+	for src := 0; src < t.Len(); src += last {
+		dst := 0
+		for i := range idx {
+			dst += (idx[i] + int(pads[i])) * outStrides[i]
+		}
+		if t.isInt {
+			copy(out.ints[dst:dst+last], t.ints[src:src+last])
+		} else {
+			copy(out.Data[dst:dst+last], t.Data[src:src+last])
+		}
+		for d := rank - 2; d >= 0; d-- {
+			idx[d]++
+			if idx[d] < t.Shape[d] {
+				break
+			}
+			idx[d] = 0
+		}
+	}
+	return []*Tensor{out}, nil
+}
+
+func unaryOp(f func(x float32) float32) kernel {
+	return func(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+		x := in[0].floats()
+		out := newFloat(in[0].Shape)
+		for i, v := range x {
+			out.Data[i] = f(v)
+		}
+		return []*Tensor{out}, nil
+	}
+}
+
+func sigmoid(x float32) float32 {
+	return float32(1 / (1 + math.Exp(-float64(x))))
+}
+
+func tanh(x float32) float32 {
+	return float32(math.Tanh(float64(x)))
+}
+
+func leakyRelu(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	alpha := n.float("alpha", 0.01)
+	return unaryOp(func(x float32) float32 {
+		if x < 0 {
+			return alpha * x
+		}
+		return x
+	})(r, n, in)
+}
+
+func clip(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	lo, hi := n.float("min", -math.MaxFloat32), n.float("max", math.MaxFloat32)
+	if t := input(in, 1); t != nil {
+		lo = float32(t.scalar())
+	}
+	if t := input(in, 2); t != nil {
+		hi = float32(t.scalar())
+	}
+	return unaryOp(func(x float32) float32 { return min(max(x, lo), hi) })(r, n, in)
+}
+
+// softmax computes Softmax, or LogSoftmax when log is set. Before opset
+// 13 the input is treated as 2-D, flattened at the axis.
+func softmax(log bool) kernel {
+	return func(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+		t := in[0]
+		rank := len(t.Shape)
+		def := int64(-1)
+		if r.opset < 13 {
+			def = 1
+		}
+		axis, err := normAxis(n.int("axis", def), rank)
+		if err != nil {
+			return nil, err
+		}
+		outer, dim, inner := size(t.Shape[:axis]), t.Shape[axis], size(t.Shape[axis+1:])
+		if r.opset < 13 {
+			dim, inner = dim*inner, 1
+		}
+
+		x := t.floats()
+		out := newFloat(t.Shape)
+		for o := 0; o < outer; o++ {
+			for i := 0; i < inner; i++ {
+				base := o*dim*inner + i
+				m := float32(-math.MaxFloat32)
+				for k := 0; k < dim; k++ {
+					m = max(m, x[base+k*inner])
+				}
+				var sum float64
+				for k := 0; k < dim; k++ {
+					e := math.Exp(float64(x[base+k*inner] - m))
+					sum += e
+					if !log {
+						out.Data[base+k*inner] = float32(e)
+					}
+				}
+				for k := 0; k < dim; k++ {
+					j := base + k*inner
+					if log {
+						out.Data[j] = x[j] - m - float32(math.Log(sum))
+					} else {
+						out.Data[j] = float32(float64(out.Data[j]) / sum)
+					}
+				}
+			}
+		}
+		return []*Tensor{out}, nil
+	}
+}
+
+// reduce sums over the reduced axes, then applies finish with the number
+// of elements each output value summed.
+func reduce(finish func(acc []float32, n int)) kernel {
+	return func(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+		t := in[0]
+		rank := len(t.Shape)
+		axes, _ := axesArg(n, in, 1)
+		reduced := make([]bool, rank)
+		if len(axes) == 0 {
+			if n.int("noop_with_empty_axes", 0) != 0 {
+				return []*Tensor{t}, nil
+			}
+			for i := range reduced {
+				reduced[i] = true
+			}
+		}
+		for _, a := range axes {
+			axis, err := normAxis(a, rank)
+			if err != nil {
+				return nil, err
+			}
+			reduced[axis] = true
+		}
+
+		keep := n.int("keepdims", 1) != 0
+		var shape, kept []int
+		count := 1
+		for i, d := range t.Shape {
+			if reduced[i] {
+				count *= d
+				if keep {
+					shape = append(shape, 1)
+				}
+				kept = append(kept, 1)
+			} else {
+				shape = append(shape, d)
+				kept = append(kept, d)
+			}
+		}
+
+		// Accumulate each input element into the output it reduces to
+		out := newFloat(shape)
+		keptStrides := strides(kept)
+		steps := make([]int, rank)
+		for i := range steps {
+			if !reduced[i] {
+				steps[i] = keptStrides[i]
+			}
+		}
+		x := t.floats()
+		idx := make([]int, rank)
+		dst := 0
+		for _, v := range x {
+			out.Data[dst] += v
+			for d := rank - 1; d >= 0; d-- {
+				idx[d]++
+				dst += steps[d]
+				if idx[d] < t.Shape[d] {
+					break
+				}
+				dst -= steps[d] * t.Shape[d]
+				idx[d] = 0
+			}
+		}
+		if finish != nil {
+			finish(out.Data, count)
+		}
+		return []*Tensor{out}, nil
+	}
+}
@@ -0,0 +1,145 @@
+package onnx
+
+import (
+	"slices"
+	"testing"
+)
+
+// broadcastIndex maps the flat index i of a tensor of shape out onto the
+// flat index of a tensor of shape in broadcast to it, following the
+// numpy rules ONNX uses.
+func broadcastIndex(i int, out, in []int) int {
+	idx := 0
+	stride := 1
+	for d := len(out) - 1; d >= 0; d-- {
+		pos := i % out[d]
+		i /= out[d]
+		if j := d - len(out) + len(in); j >= 0 {
+			if in[j] != 1 {
+				idx += pos * stride
+			}
+			stride *= in[j]
+		}
+	}
+	return idx
+}
+
+func TestBroadcastShape(t *testing.T) {
+	for _, test := range []struct {
+		a, b, want []int
+	}{
+		{[]int{2, 3}, []int{2, 3}, []int{2, 3}},
+		{[]int{2, 3}, []int{3}, []int{2, 3}},
+		{[]int{2, 1}, []int{1, 3}, []int{2, 3}},
+		{[]int{3, 1, 2}, []int{4, 1}, []int{3, 4, 2}},
+		{[]int{}, []int{5, 2}, []int{5, 2}},
+		{[]int{1}, []int{0, 4}, []int{0, 4}},
+	} {
+		got, err := broadcastShape(test.a, test.b)
+		if err != nil || !slices.Equal(got, test.want) {
+			t.Errorf("broadcastShape(%v, %v) = %v, %v, want %v", test.a, test.b, got, err, test.want)
+		}
+	}
+	for _, bad := range [][2][]int{{{2, 3}, {2}}, {{4, 3}, {2, 3}}} {
+		if got, err := broadcastShape(bad[0], bad[1]); err == nil {
+			t.Errorf("broadcastShape(%v, %v) = %v, want an error", bad[0], bad[1], got)
+		}
+	}
+}
+
+func TestBinaryBroadcast(t *testing.T) {
+	// Each case goes through a different path of binaryOp: equal shapes,
+	// a scalar, a trailing bias and the general expansion
+	for _, test := range []struct {
+		a, b []int
+	}{
+		{[]int{2, 3}, []int{2, 3}},
+		{[]int{2, 3}, []int{}},
+		{[]int{2, 3, 4}, []int{1, 3, 4}},
+		{[]int{2, 3, 4}, []int{4}},
+		{[]int{3}, []int{2, 3}},
+		{[]int{2, 1}, []int{1, 3}},
+		{[]int{3, 1, 2}, []int{4, 1}},
+		{[]int{1}, []int{2, 2}},
+	} {
+		a := NewTensor(test.a, seq(size(test.a), 1, 1))
+		b := NewTensor(test.b, seq(size(test.b), 0.5, -0.25))
+		shape, err := broadcastShape(test.a, test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, op := range []struct {
+			name string
+			f    func(x, y float32) float32
+		}{
+			{"Add", func(x, y float32) float32 { return x + y }},
+			{"Sub", func(x, y float32) float32 { return x - y }},
+			{"Mul", func(x, y float32) float32 { return x * y }},
+			{"Div", func(x, y float32) float32 { return x / y }},
+		} {
+			want := make([]float32, size(shape))
+			for i := range want {
+				want[i] = op.f(a.Data[broadcastIndex(i, shape, test.a)], b.Data[broadcastIndex(i, shape, test.b)])
+			}
+			out := runOp(t, op.name, []*Tensor{a, b}, 1)
+			assertClose(t, op.name, out[0], shape, want, 0)
+		}
+	}
+}
+
+func TestIntBroadcast(t *testing.T) {
+	a := newInts([]int{2, 1}, []int64{10, 20})
+	b := newInts([]int{3}, []int64{1, 2, 3})
+	out := runOp(t, "Sub", []*Tensor{a, b}, 1)[0]
+	if !out.isInt || !slices.Equal(out.Shape, []int{2, 3}) || !slices.Equal(out.ints, []int64{9, 8, 7, 19, 18, 17}) {
+		t.Errorf("Sub = %v %v, want [2 3] [9 8 7 19 18 17]", out.Shape, out.ints)
+	}
+}
+
+func TestExpandWhere(t *testing.T) {
+	x := NewTensor([]int{3, 1}, []float32{1, 2, 3})
+	out := runOp(t, "Expand", []*Tensor{x, newInts([]int{3}, []int64{2, 1, 4})}, 1)[0]
+	rows := []float32{1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3}
+	assertClose(t, "Expand", out, []int{2, 3, 4}, slices.Concat(rows, rows), 0)
+
+	cond := newInts([]int{2, 1}, []int64{1, 0})
+	out = runOp(t, "Where", []*Tensor{cond, NewTensor([]int{3}, []float32{1, 2, 3}), NewTensor(nil, []float32{-1})}, 1)[0]
+	assertClose(t, "Where", out, []int{2, 3}, []float32{1, 2, 3, -1, -1, -1}, 0)
+}
+
+func TestShapeOps(t *testing.T) {
+	x := NewTensor([]int{2, 3, 4}, seq(24, 0, 1))
+
+	out := runOp(t, "Transpose", []*Tensor{x}, 1, intsAttr("perm", 2, 0, 1))[0]
+	want := make([]float32, 24)
+	for k := 0; k < 4; k++ {
+		for i := 0; i < 2; i++ {
+			for j := 0; j < 3; j++ {
+				want[(k*2+i)*3+j] = x.Data[(i*3+j)*4+k]
+			}
+		}
+	}
+	assertClose(t, "Transpose", out, []int{4, 2, 3}, want, 0)
+
+	out = runOp(t, "Reshape", []*Tensor{x, newInts([]int{3}, []int64{0, -1, 2})}, 1)[0]
+	assertClose(t, "Reshape", out, []int{2, 6, 2}, x.Data, 0)
+
+	out = runOp(t, "Slice", []*Tensor{x, newInts([]int{2}, []int64{1, -1}), newInts([]int{2}, []int64{3, -5}),
+		newInts([]int{2}, []int64{1, 2}), newInts([]int{2}, []int64{1, -2})}, 1)[0]
+	assertClose(t, "Slice", out, []int{2, 2, 2}, []float32{7, 5, 11, 9, 19, 17, 23, 21}, 0)
+
+	outs := runOp(t, "Split", []*Tensor{x, newInts([]int{2}, []int64{1, 3})}, 2, intAttr("axis", -1))
+	assertClose(t, "Split 0", outs[0], []int{2, 3, 1}, []float32{0, 4, 8, 12, 16, 20}, 0)
+	assertClose(t, "Split 1", outs[1], []int{2, 3, 3}, []float32{1, 2, 3, 5, 6, 7, 9, 10, 11, 13, 14, 15, 17, 18, 19, 21, 22, 23}, 0)
+
+	out = runOp(t, "Concat", []*Tensor{outs[1], outs[0]}, 1, intAttr("axis", 2))[0]
+	assertClose(t, "Concat", out, []int{2, 3, 4}, []float32{1, 2, 3, 0, 5, 6, 7, 4, 9, 10, 11, 8, 13, 14, 15, 12, 17, 18, 19, 16, 21, 22, 23, 20}, 0)
+}
+
+func TestSoftmax(t *testing.T) {
+	x := NewTensor([]int{2, 3}, []float32{1, 2, 3, 0, 0, 0})
+	out := runOp(t, "Softmax", []*Tensor{x}, 1)[0]
+	assertClose(t, "Softmax", out, []int{2, 3}, []float32{0.09003057, 0.24472847, 0.66524096, 1.0 / 3, 1.0 / 3, 1.0 / 3}, 1e-6)
+	out = runOp(t, "LogSoftmax", []*Tensor{x}, 1)[0]
+	assertClose(t, "LogSoftmax", out, []int{2, 3}, []float32{-2.4076059, -1.4076059, -0.40760595, -1.0986123, -1.0986123, -1.0986123}, 1e-6)
+}
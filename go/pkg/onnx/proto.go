@@ -0,0 +1,151 @@
+package onnx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// decoder reads the fields of one protobuf message. ONNX models only need
+// the wire format, so they are decoded by hand rather than with generated
+// code and a protobuf library.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+// next reads the next field's number and wire type. It returns false at
+// the end of the message or on malformed input, which sets d.err.
+func (d *decoder) next() (field int, wire int, ok bool) {
+	if d.err != nil || len(d.b) == 0 {
+		return 0, 0, false
+	}
+	key := d.varint()
+	if d.err != nil {
+		return 0, 0, false
+	}
+	return int(key >> 3), int(key & 7), true
+}
+
+func (d *decoder) varint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) fixed32() uint32 {
+	if len(d.b) < 4 {
+		d.fail()
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(d.b)
+	d.b = d.b[4:]
+	return v
+}
+
+func (d *decoder) fixed64() uint64 {
+	if len(d.b) < 8 {
+		d.fail()
+		return 0
+	}
+	v := binary.LittleEndian.Uint64(d.b)
+	d.b = d.b[8:]
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.varint()
+	if d.err != nil || n > uint64(len(d.b)) {
+		d.fail()
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+// skip skips a field of the given wire type.
+func (d *decoder) skip(wire int) {
+	switch wire {
+	case wireVarint:
+		d.varint()
+	case wireFixed64:
+		d.fixed64()
+	case wireBytes:
+		d.bytes()
+	case wireFixed32:
+		d.fixed32()
+	default:
+		d.err = fmt.Errorf("unsupported protobuf wire type %d", wire)
+	}
+}
+
+// int64s appends a repeated int64 field, packed or not, to vs.
+func (d *decoder) int64s(wire int, vs []int64) []int64 {
+	if wire != wireBytes {
+		return append(vs, int64(d.varint()))
+	}
+	packed := decoder{b: d.bytes()}
+	for len(packed.b) > 0 && packed.err == nil {
+		vs = append(vs, int64(packed.varint()))
+	}
+	if packed.err != nil {
+		d.err = packed.err
+	}
+	return vs
+}
+
+// float32s appends a repeated float field, packed or not, to vs.
+func (d *decoder) float32s(wire int, vs []float32) []float32 {
+	if wire != wireBytes {
+		return append(vs, math.Float32frombits(d.fixed32()))
+	}
+	packed := d.bytes()
+	if len(packed)%4 != 0 {
+		d.fail()
+		return vs
+	}
+	for i := 0; i < len(packed); i += 4 {
+		vs = append(vs, math.Float32frombits(binary.LittleEndian.Uint32(packed[i:])))
+	}
+	return vs
+}
+
+// float64s appends a repeated double field, packed or not, to vs.
+func (d *decoder) float64s(wire int, vs []float64) []float64 {
+	if wire != wireBytes {
+		return append(vs, math.Float64frombits(d.fixed64()))
+	}
+	packed := d.bytes()
+	if len(packed)%8 != 0 {
+		d.fail()
+		return vs
+	}
+	for i := 0; i < len(packed); i += 8 {
+		vs = append(vs, math.Float64frombits(binary.LittleEndian.Uint64(packed[i:])))
+	}
+	return vs
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = fmt.Errorf("malformed protobuf")
+	}
+	d.b = nil
+}
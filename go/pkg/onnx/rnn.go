@@ -0,0 +1,311 @@
+package onnx
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// recurrent holds what LSTM and GRU share: the input in [seq, batch,
+// features] order, the direction and the size of the hidden state.
+type recurrent struct {
+	x      []float32
+	seq    int
+	batch  int
+	inputs int
+	hidden int
+	dirs   int
+	// reverse reports whether direction d runs backwards in time.
+	reverse func(d int) bool
+	// batchFirst is set by layout 1, [batch, seq, ...] inputs and outputs.
+	batchFirst bool
+}
+
+// newRecurrent validates the common inputs and attributes of an RNN node
+// with gates gates per step and the given default activations.
+func newRecurrent(n *node, in []*Tensor, gates int, activations []string) (*recurrent, error) {
+	x := in[0]
+	if len(x.Shape) != 3 {
+		return nil, fmt.Errorf("input %s isn't 3-D", shapeString(x.Shape))
+	}
+	rc := &recurrent{x: x.floats(), hidden: int(n.int("hidden_size", 0))}
+	rc.batchFirst = n.int("layout", 0) != 0
+	rc.seq, rc.batch, rc.inputs = x.Shape[0], x.Shape[1], x.Shape[2]
+	if rc.batchFirst {
+		rc.seq, rc.batch = rc.batch, rc.seq
+		rc.x = permute(NewTensor(x.Shape, rc.x), []int64{1, 0, 2}).Data
+	}
+
+	switch dir := n.string("direction", "forward"); dir {
+	case "forward":
+		rc.dirs, rc.reverse = 1, func(int) bool { return false }
+	case "reverse":
+		rc.dirs, rc.reverse = 1, func(int) bool { return true }
+	case "bidirectional":
+		rc.dirs, rc.reverse = 2, func(d int) bool { return d == 1 }
+	default:
+		return nil, unsupported("direction %q", dir)
+	}
+
+	if a, ok := n.attrs["activations"]; ok {
+		want := slices.Repeat(activations, rc.dirs)
+		if !slices.EqualFunc(a.strings, want, strings.EqualFold) {
+			return nil, unsupported("activations %v", a.strings)
+		}
+	}
+	if _, ok := n.attrs["clip"]; ok {
+		return nil, unsupported("cell clipping")
+	}
+	if lens := input(in, 4); lens != nil {
+		for _, l := range lens.values() {
+			if l != int64(rc.seq) {
+				return nil, unsupported("sequences of different lengths")
+			}
+		}
+	}
+
+	w, r := in[1], in[2]
+	if w.Len() != rc.dirs*gates*rc.hidden*rc.inputs || r.Len() != rc.dirs*gates*rc.hidden*rc.hidden || rc.hidden <= 0 {
+		return nil, fmt.Errorf("weights %s and %s don't match %d inputs and hidden size %d",
+			shapeString(w.Shape), shapeString(r.Shape), rc.inputs, rc.hidden)
+	}
+	return rc, nil
+}
+
+// project returns the input's contribution to every step's gates for
+// direction d, x·Wᵀ plus the bias b, as a [seq·batch, gates·hidden]
+// matrix.
+func (rc *recurrent) project(r *run, w, b []float32, gates int) []float32 {
+	width := gates * rc.hidden
+	out := make([]float32, rc.seq*rc.batch*width)
+	if b != nil {
+		for i := 0; i < len(out); i += width {
+			copy(out[i:i+width], b)
+		}
+	}
+	gemmNT(r, rc.seq*rc.batch, width, rc.inputs, rc.x, w, out)
+	return out
+}
+
+// initial returns direction d's slice of an optional initial state, or
+// zeros.
+func (rc *recurrent) initial(t *Tensor, d int) ([]float32, error) {
+	state := make([]float32, rc.batch*rc.hidden)
+	if t == nil {
+		return state, nil
+	}
+	if t.Len() != rc.dirs*len(state) {
+		return nil, fmt.Errorf("initial state %s doesn't match hidden size %d", shapeString(t.Shape), rc.hidden)
+	}
+	v := t.floats()
+	if rc.batchFirst {
+		v = permute(NewTensor(t.Shape, v), []int64{1, 0, 2}).Data
+	}
+	copy(state, v[d*len(state):])
+	return state, nil
+}
+
+// outputs builds Y, [seq, dirs, batch, hidden], and the final states
+// [dirs, batch, hidden] from each direction's per-step states, in the
+// node's layout.
+func (rc *recurrent) outputs(steps [][]float32, finals ...[][]float32) []*Tensor {
+	state := rc.batch * rc.hidden
+	y := newFloat([]int{rc.seq, rc.dirs, rc.batch, rc.hidden})
+	for d, ys := range steps {
+		for t := 0; t < rc.seq; t++ {
+			copy(y.Data[(t*rc.dirs+d)*state:], ys[t*state:(t+1)*state])
+		}
+	}
+	out := []*Tensor{y}
+	for _, f := range finals {
+		last := newFloat([]int{rc.dirs, rc.batch, rc.hidden})
+		for d, s := range f {
+			copy(last.Data[d*state:], s)
+		}
+		out = append(out, last)
+	}
+	if rc.batchFirst {
+		out[0] = permute(y, []int64{2, 0, 1, 3})
+		for i := 1; i < len(out); i++ {
+			out[i] = permute(out[i], []int64{1, 0, 2})
+		}
+	}
+	return out
+}
+
+// biasSum returns direction d's input and recurrent biases added
+// together, or nil without a bias input.
+func biasSum(b *Tensor, d, width int) ([]float32, error) {
+	if b == nil {
+		return nil, nil
+	}
+	v := b.floats()
+	if len(v) < (d+1)*2*width {
+		return nil, fmt.Errorf("bias %s is too small", shapeString(b.Shape))
+	}
+	v = v[d*2*width : (d+1)*2*width]
+	sum := make([]float32, width)
+	for i := range sum {
+		sum[i] = v[i] + v[width+i]
+	}
+	return sum, nil
+}
+
+// lstm computes an LSTM with ONNX's iofc gate order and optional
+// peepholes.
+func lstm(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	if n.int("input_forget", 0) != 0 {
+		return nil, unsupported("coupled input and forget gates")
+	}
+	rc, err := newRecurrent(n, in, 4, []string{"Sigmoid", "Tanh", "Tanh"})
+	if err != nil {
+		return nil, err
+	}
+	h := rc.hidden
+	w, rw := in[1].floats(), in[2].floats()
+	peep := input(in, 7)
+	if peep != nil && peep.Len() != rc.dirs*3*h {
+		return nil, fmt.Errorf("peepholes %s don't match hidden size %d", shapeString(peep.Shape), h)
+	}
+
+	steps := make([][]float32, rc.dirs)
+	lastH := make([][]float32, rc.dirs)
+	lastC := make([][]float32, rc.dirs)
+	for d := 0; d < rc.dirs; d++ {
+		bias, err := biasSum(input(in, 3), d, 4*h)
+		if err != nil {
+			return nil, err
+		}
+		xw := rc.project(r, w[d*4*h*rc.inputs:(d+1)*4*h*rc.inputs], bias, 4)
+		hs, err := rc.initial(input(in, 5), d)
+		if err != nil {
+			return nil, err
+		}
+		cs, err := rc.initial(input(in, 6), d)
+		if err != nil {
+			return nil, err
+		}
+		var pi, po, pf []float32
+		if peep != nil {
+			p := peep.floats()[d*3*h : (d+1)*3*h]
+			pi, po, pf = p[:h], p[h:2*h], p[2*h:]
+		}
+
+		ys := make([]float32, rc.seq*rc.batch*h)
+		gates := make([]float32, rc.batch*4*h)
+		rd := rw[d*4*h*h : (d+1)*4*h*h]
+		for s := 0; s < rc.seq; s++ {
+			t := s
+			if rc.reverse(d) {
+				t = rc.seq - 1 - s
+			}
+			copy(gates, xw[t*rc.batch*4*h:(t+1)*rc.batch*4*h])
+			gemmNT(r, rc.batch, 4*h, h, hs, rd, gates)
+			for b := 0; b < rc.batch; b++ {
+				g := gates[b*4*h : (b+1)*4*h]
+				c := cs[b*h : (b+1)*h]
+				out := hs[b*h : (b+1)*h]
+				for j := 0; j < h; j++ {
+					gi, go_, gf, gc := g[j], g[h+j], g[2*h+j], g[3*h+j]
+					if peep != nil {
+						gi += pi[j] * c[j]
+						gf += pf[j] * c[j]
+					}
+					c[j] = sigmoid(gf)*c[j] + sigmoid(gi)*tanh(gc)
+					if peep != nil {
+						go_ += po[j] * c[j]
+					}
+					out[j] = sigmoid(go_) * tanh(c[j])
+				}
+			}
+			copy(ys[t*rc.batch*h:], hs)
+		}
+		steps[d], lastH[d], lastC[d] = ys, hs, cs
+	}
+	return rc.outputs(steps, lastH, lastC), nil
+}
+
+// gru computes a GRU with ONNX's zrh gate order.
+func gru(r *run, n *node, in []*Tensor) ([]*Tensor, error) {
+	rc, err := newRecurrent(n, in, 3, []string{"Sigmoid", "Tanh"})
+	if err != nil {
+		return nil, err
+	}
+	h := rc.hidden
+	w, rw := in[1].floats(), in[2].floats()
+	linearBeforeReset := n.int("linear_before_reset", 0) != 0
+
+	steps := make([][]float32, rc.dirs)
+	lastH := make([][]float32, rc.dirs)
+	for d := 0; d < rc.dirs; d++ {
+		// The recurrent bias of the h gate can't be folded into the input
+		// projection, since the reset gate scales it
+		var wb, rbh []float32
+		if b := input(in, 3); b != nil {
+			v := b.floats()
+			if len(v) < (d+1)*6*h {
+				return nil, fmt.Errorf("bias %s is too small", shapeString(b.Shape))
+			}
+			v = v[d*6*h : (d+1)*6*h]
+			wb = slices.Clone(v[:3*h])
+			for j := 0; j < 2*h; j++ {
+				wb[j] += v[3*h+j]
+			}
+			rbh = v[5*h:]
+		}
+		xw := rc.project(r, w[d*3*h*rc.inputs:(d+1)*3*h*rc.inputs], wb, 3)
+		hs, err := rc.initial(input(in, 5), d)
+		if err != nil {
+			return nil, err
+		}
+
+		ys := make([]float32, rc.seq*rc.batch*h)
+		rd := rw[d*3*h*h : (d+1)*3*h*h]
+		zr := make([]float32, rc.batch*2*h)
+		rh := make([]float32, rc.batch*h)
+		scaled := make([]float32, rc.batch*h)
+		for s := 0; s < rc.seq; s++ {
+			t := s
+			if rc.reverse(d) {
+				t = rc.seq - 1 - s
+			}
+			x := xw[t*rc.batch*3*h : (t+1)*rc.batch*3*h]
+			clear(zr)
+			gemmNT(r, rc.batch, 2*h, h, hs, rd[:2*h*h], zr)
+			for b := 0; b < rc.batch; b++ {
+				for j := 0; j < 2*h; j++ {
+					zr[b*2*h+j] = sigmoid(zr[b*2*h+j] + x[b*3*h+j])
+				}
+			}
+
+			clear(rh)
+			if linearBeforeReset {
+				gemmNT(r, rc.batch, h, h, hs, rd[2*h*h:], rh)
+			} else {
+				for b := 0; b < rc.batch; b++ {
+					for j := 0; j < h; j++ {
+						scaled[b*h+j] = zr[b*2*h+h+j] * hs[b*h+j]
+					}
+				}
+				gemmNT(r, rc.batch, h, h, scaled, rd[2*h*h:], rh)
+			}
+			for b := 0; b < rc.batch; b++ {
+				for j := 0; j < h; j++ {
+					z, reset := zr[b*2*h+j], zr[b*2*h+h+j]
+					rec := rh[b*h+j]
+					if rbh != nil {
+						rec += rbh[j]
+					}
+					if linearBeforeReset {
+						rec *= reset
+					}
+					candidate := tanh(x[b*3*h+2*h+j] + rec)
+					hs[b*h+j] = (1-z)*candidate + z*hs[b*h+j]
+				}
+			}
+			copy(ys[t*rc.batch*h:], hs)
+		}
+		steps[d], lastH[d] = ys, hs
+	}
+	return rc.outputs(steps, lastH), nil
+}
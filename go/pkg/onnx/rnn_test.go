@@ -0,0 +1,337 @@
+package onnx
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func sig64(x float64) float64 { return 1 / (1 + math.Exp(-x)) }
+
+// rnnCase holds the inputs of an LSTM or GRU in ONNX's default layout:
+// x [seq, batch, inputs], w [dirs, gates·hidden, inputs], r [dirs,
+// gates·hidden, hidden], b [dirs, 2·gates·hidden], initial states [dirs,
+// batch, hidden] and peepholes [dirs, 3·hidden].
+type rnnCase struct {
+	seq, batch, inputs, hidden, dirs int
+	x, w, r, b, h0, c0, p            []float32
+}
+
+func newRNNCase(seq, batch, inputs, hidden, dirs, gates int, bias, initial, peepholes bool) *rnnCase {
+	rc := &rnnCase{seq: seq, batch: batch, inputs: inputs, hidden: hidden, dirs: dirs}
+	rc.x = wave(seq*batch*inputs, 0)
+	rc.w = wave(dirs*gates*hidden*inputs, 1)
+	rc.r = wave(dirs*gates*hidden*hidden, 2)
+	if bias {
+		rc.b = wave(dirs*2*gates*hidden, 3)
+	}
+	if initial {
+		rc.h0 = wave(dirs*batch*hidden, 4)
+		rc.c0 = wave(dirs*batch*hidden, 5)
+	}
+	if peepholes {
+		rc.p = wave(dirs*3*hidden, 6)
+	}
+	return rc
+}
+
+// nodeInputs returns the node inputs, in layout 0 or 1.
+func (rc *rnnCase) nodeInputs(gates int, batchFirst, cell bool) []*Tensor {
+	x := NewTensor([]int{rc.seq, rc.batch, rc.inputs}, rc.x)
+	state := func(v []float32) *Tensor {
+		if v == nil {
+			return nil
+		}
+		t := NewTensor([]int{rc.dirs, rc.batch, rc.hidden}, v)
+		if batchFirst {
+			t = permute(t, []int64{1, 0, 2})
+		}
+		return t
+	}
+	if batchFirst {
+		x = permute(x, []int64{1, 0, 2})
+	}
+	in := []*Tensor{
+		x,
+		NewTensor([]int{rc.dirs, gates * rc.hidden, rc.inputs}, rc.w),
+		NewTensor([]int{rc.dirs, gates * rc.hidden, rc.hidden}, rc.r),
+		nil, nil, state(rc.h0),
+	}
+	if rc.b != nil {
+		in[3] = NewTensor([]int{rc.dirs, 2 * gates * rc.hidden}, rc.b)
+	}
+	if cell {
+		in = append(in, state(rc.c0), nil)
+		if rc.p != nil {
+			in[7] = NewTensor([]int{rc.dirs, 3 * rc.hidden}, rc.p)
+		}
+	}
+	return in
+}
+
+// run steps every direction with step, which updates the states of one
+// batch entry from its input at one time step, and returns Y [seq, dirs,
+// batch, hidden] and the final states [dirs, batch, hidden].
+func (rc *rnnCase) run(step func(d int, x, h, c []float64)) (y, lastH, lastC []float32) {
+	h := rc.hidden
+	y = make([]float32, rc.seq*rc.dirs*rc.batch*h)
+	lastH = make([]float32, rc.dirs*rc.batch*h)
+	lastC = make([]float32, rc.dirs*rc.batch*h)
+	for d := 0; d < rc.dirs; d++ {
+		for b := 0; b < rc.batch; b++ {
+			hs, cs := make([]float64, h), make([]float64, h)
+			if rc.h0 != nil {
+				for j := range hs {
+					hs[j] = float64(rc.h0[(d*rc.batch+b)*h+j])
+					cs[j] = float64(rc.c0[(d*rc.batch+b)*h+j])
+				}
+			}
+			for s := 0; s < rc.seq; s++ {
+				t := s
+				if d == 1 {
+					t = rc.seq - 1 - s
+				}
+				x := make([]float64, rc.inputs)
+				for i := range x {
+					x[i] = float64(rc.x[(t*rc.batch+b)*rc.inputs+i])
+				}
+				step(d, x, hs, cs)
+				for j := range hs {
+					y[((t*rc.dirs+d)*rc.batch+b)*h+j] = float32(hs[j])
+				}
+			}
+			for j := range hs {
+				lastH[(d*rc.batch+b)*h+j] = float32(hs[j])
+				lastC[(d*rc.batch+b)*h+j] = float32(cs[j])
+			}
+		}
+	}
+	return y, lastH, lastC
+}
+
+// gate returns row g·hidden+j of direction d's w·x + r·h plus both
+// biases, leaving out the recurrent term when h is nil.
+func (rc *rnnCase) gate(d, gates, g, j int, x, h []float64) float64 {
+	row := (d*gates+g)*rc.hidden + j
+	var sum float64
+	for i, v := range x {
+		sum += float64(rc.w[row*rc.inputs+i]) * v
+	}
+	for i, v := range h {
+		sum += float64(rc.r[row*rc.hidden+i]) * v
+	}
+	if rc.b != nil {
+		sum += float64(rc.b[d*2*gates*rc.hidden+g*rc.hidden+j])
+		if h != nil {
+			sum += float64(rc.b[(d*2*gates+gates+g)*rc.hidden+j])
+		}
+	}
+	return sum
+}
+
+// lstm computes the reference LSTM, with gates in iofc order.
+func (rc *rnnCase) lstm() (y, lastH, lastC []float32) {
+	return rc.run(func(d int, x, h, c []float64) {
+		next := make([]float64, len(h))
+		for j := range h {
+			gi, gf := rc.gate(d, 4, 0, j, x, h), rc.gate(d, 4, 2, j, x, h)
+			if rc.p != nil {
+				gi += float64(rc.p[d*3*rc.hidden+j]) * c[j]
+				gf += float64(rc.p[d*3*rc.hidden+2*rc.hidden+j]) * c[j]
+			}
+			cell := sig64(gf)*c[j] + sig64(gi)*math.Tanh(rc.gate(d, 4, 3, j, x, h))
+			gout := rc.gate(d, 4, 1, j, x, h)
+			if rc.p != nil {
+				gout += float64(rc.p[d*3*rc.hidden+rc.hidden+j]) * cell
+			}
+			next[j], c[j] = sig64(gout)*math.Tanh(cell), cell
+		}
+		copy(h, next)
+	})
+}
+
+// gru computes the reference GRU, with gates in zrh order.
+func (rc *rnnCase) gru(linearBeforeReset bool) (y, lastH []float32) {
+	y, lastH, _ = rc.run(func(d int, x, h, _ []float64) {
+		hid := rc.hidden
+		z, r := make([]float64, hid), make([]float64, hid)
+		for j := range h {
+			z[j] = sig64(rc.gate(d, 3, 0, j, x, h))
+			r[j] = sig64(rc.gate(d, 3, 1, j, x, h))
+		}
+		next := make([]float64, hid)
+		for j := range h {
+			// The h gate's input and recurrent terms, each with its bias
+			in := rc.gate(d, 3, 2, j, x, nil)
+			row := (d*3+2)*hid + j
+			var rec float64
+			for i := range h {
+				v := h[i]
+				if !linearBeforeReset {
+					v *= r[i]
+				}
+				rec += float64(rc.r[row*hid+i]) * v
+			}
+			if rc.b != nil {
+				rec += float64(rc.b[(d*6+5)*hid+j])
+			}
+			if linearBeforeReset {
+				rec *= r[j]
+			}
+			next[j] = (1-z[j])*math.Tanh(in+rec) + z[j]*h[j]
+		}
+		copy(h, next)
+	})
+	return y, lastH
+}
+
+func TestLSTMGateOrder(t *testing.T) {
+	// With no weights, each gate is its bias alone, so a mix-up of the
+	// iofc order changes the result
+	const bi, bo, bf, bc, c0 = 1, 2, -3, 0.5, 1.5
+	in := []*Tensor{
+		NewTensor([]int{1, 1, 1}, []float32{7}),
+		NewTensor([]int{1, 4, 1}, make([]float32, 4)),
+		NewTensor([]int{1, 4, 1}, make([]float32, 4)),
+		NewTensor([]int{1, 8}, []float32{bi, bo, bf, bc, 0, 0, 0, 0}),
+		nil,
+		NewTensor([]int{1, 1, 1}, []float32{0}),
+		NewTensor([]int{1, 1, 1}, []float32{c0}),
+	}
+	c := sig64(bf)*c0 + sig64(bi)*math.Tanh(bc)
+	h := sig64(bo) * math.Tanh(c)
+	out := runOp(t, "LSTM", in, 3, intAttr("hidden_size", 1))
+	assertClose(t, "Y", out[0], []int{1, 1, 1, 1}, []float32{float32(h)}, 1e-6)
+	assertClose(t, "Y_h", out[1], []int{1, 1, 1}, []float32{float32(h)}, 1e-6)
+	assertClose(t, "Y_c", out[2], []int{1, 1, 1}, []float32{float32(c)}, 1e-6)
+}
+
+func TestGRUGateOrder(t *testing.T) {
+	// Only the h gate has a recurrent weight, so the result tells the
+	// zrh order apart and where the reset gate applies
+	const bz, br, bh, rbh, rh, h0 = 1, 2, 0.5, 0.25, 1, 1.5
+	in := []*Tensor{
+		NewTensor([]int{1, 1, 1}, []float32{7}),
+		NewTensor([]int{1, 3, 1}, make([]float32, 3)),
+		NewTensor([]int{1, 3, 1}, []float32{0, 0, rh}),
+		NewTensor([]int{1, 6}, []float32{bz, br, bh, 0, 0, rbh}),
+		nil,
+		NewTensor([]int{1, 1, 1}, []float32{h0}),
+	}
+	z, r := sig64(bz), sig64(br)
+	for _, lbr := range []int64{0, 1} {
+		candidate := math.Tanh(bh + r*rh*h0 + rbh)
+		if lbr != 0 {
+			candidate = math.Tanh(bh + r*(rh*h0+rbh))
+		}
+		h := float32((1-z)*candidate + z*h0)
+		out := runOp(t, "GRU", in, 2, intAttr("hidden_size", 1), intAttr("linear_before_reset", lbr))
+		assertClose(t, fmt.Sprintf("Y linear_before_reset=%d", lbr), out[0], []int{1, 1, 1, 1}, []float32{h}, 1e-6)
+		assertClose(t, fmt.Sprintf("Y_h linear_before_reset=%d", lbr), out[1], []int{1, 1, 1}, []float32{h}, 1e-6)
+	}
+}
+
+// rnnAttrs returns the attributes shared by the LSTM and GRU tests.
+func rnnAttrs(hidden, dirs int, batchFirst bool) []attr {
+	attrs := []attr{intAttr("hidden_size", int64(hidden))}
+	if dirs == 2 {
+		attrs = append(attrs, stringAttr("direction", "bidirectional"))
+	}
+	if batchFirst {
+		attrs = append(attrs, intAttr("layout", 1))
+	}
+	return attrs
+}
+
+// checkRNN compares Y and the final states of a node run in layout 0 or
+// 1 with the reference.
+func checkRNN(t *testing.T, rc *rnnCase, out []*Tensor, batchFirst bool, y []float32, finals ...[]float32) {
+	t.Helper()
+	yShape := []int{rc.seq, rc.dirs, rc.batch, rc.hidden}
+	stateShape := []int{rc.dirs, rc.batch, rc.hidden}
+	if batchFirst {
+		y = permute(NewTensor(yShape, y), []int64{2, 0, 1, 3}).Data
+		yShape = []int{rc.batch, rc.seq, rc.dirs, rc.hidden}
+		for i, f := range finals {
+			finals[i] = permute(NewTensor(stateShape, f), []int64{1, 0, 2}).Data
+		}
+		stateShape = []int{rc.batch, rc.dirs, rc.hidden}
+	}
+	assertClose(t, "Y", out[0], yShape, y, 1e-5)
+	for i, f := range finals {
+		assertClose(t, fmt.Sprintf("output %d", i+1), out[i+1], stateShape, f, 1e-5)
+	}
+}
+
+func TestLSTM(t *testing.T) {
+	for _, test := range []struct {
+		dirs                            int
+		bias, initial, peep, batchFirst bool
+	}{
+		{1, false, false, false, false},
+		{1, true, true, false, false},
+		{1, true, true, true, false},
+		{2, true, false, false, false},
+		{2, true, true, true, false},
+		{2, true, true, true, true},
+	} {
+		t.Run(fmt.Sprintf("%+v", test), func(t *testing.T) {
+			rc := newRNNCase(4, 2, 3, 5, test.dirs, 4, test.bias, test.initial, test.peep)
+			y, lastH, lastC := rc.lstm()
+			out := runOp(t, "LSTM", rc.nodeInputs(4, test.batchFirst, true), 3, rnnAttrs(rc.hidden, rc.dirs, test.batchFirst)...)
+			checkRNN(t, rc, out, test.batchFirst, y, lastH, lastC)
+		})
+	}
+
+	// A single reverse direction runs like the backward half
+	rc := newRNNCase(4, 2, 3, 5, 1, 4, true, true, false)
+	out := runOp(t, "LSTM", rc.nodeInputs(4, false, true), 1, intAttr("hidden_size", 5), stringAttr("direction", "reverse"))
+	rc.dirs = 2
+	rc.w, rc.r, rc.b = append(make([]float32, len(rc.w)), rc.w...), append(make([]float32, len(rc.r)), rc.r...), append(make([]float32, len(rc.b)), rc.b...)
+	rc.h0, rc.c0 = append(make([]float32, len(rc.h0)), rc.h0...), append(make([]float32, len(rc.c0)), rc.c0...)
+	y, _, _ := rc.lstm()
+	backward := make([]float32, 0, len(y)/2)
+	for s := 0; s < rc.seq; s++ {
+		backward = append(backward, y[(s*2+1)*rc.batch*rc.hidden:(s*2+2)*rc.batch*rc.hidden]...)
+	}
+	assertClose(t, "reverse", out[0], []int{4, 1, 2, 5}, backward, 1e-5)
+}
+
+func TestGRU(t *testing.T) {
+	for _, test := range []struct {
+		dirs                           int
+		bias, initial, lbr, batchFirst bool
+	}{
+		{1, false, false, false, false},
+		{1, true, true, false, false},
+		{1, true, true, true, false},
+		{2, true, false, false, false},
+		{2, true, true, true, false},
+		{2, true, true, false, true},
+	} {
+		t.Run(fmt.Sprintf("%+v", test), func(t *testing.T) {
+			rc := newRNNCase(4, 2, 3, 5, test.dirs, 3, test.bias, test.initial, false)
+			y, lastH := rc.gru(test.lbr)
+			attrs := rnnAttrs(rc.hidden, rc.dirs, test.batchFirst)
+			if test.lbr {
+				attrs = append(attrs, intAttr("linear_before_reset", 1))
+			}
+			out := runOp(t, "GRU", rc.nodeInputs(3, test.batchFirst, false), 2, attrs...)
+			checkRNN(t, rc, out, test.batchFirst, y, lastH)
+		})
+	}
+}
+
+func TestRNNUnsupported(t *testing.T) {
+	rc := newRNNCase(2, 1, 2, 2, 1, 4, false, false, false)
+	for _, attrs := range [][]attr{
+		{intAttr("hidden_size", 2), floatAttr("clip", 1)},
+		{intAttr("hidden_size", 2), intAttr("input_forget", 1)},
+		{intAttr("hidden_size", 2), stringsAttr("activations", "Relu", "Tanh", "Tanh")},
+		{intAttr("hidden_size", 3)},
+	} {
+		if _, err := tryOp("LSTM", rc.nodeInputs(4, false, true), 1, attrs...); err == nil {
+			t.Errorf("LSTM with %d attributes succeeded", len(attrs))
+		}
+	}
+}
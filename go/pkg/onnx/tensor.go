@@ -0,0 +1,330 @@
+package onnx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ONNX tensor element types.
+const (
+	typeFloat    = 1
+	typeUint8    = 2
+	typeInt8     = 3
+	typeUint16   = 4
+	typeInt16    = 5
+	typeInt32    = 6
+	typeInt64    = 7
+	typeBool     = 9
+	typeFloat16  = 10
+	typeDouble   = 11
+	typeUint32   = 12
+	typeUint64   = 13
+	typeBfloat16 = 16
+)
+
+// Tensor is an n-dimensional array in row-major order. Floating-point
+// tensors of any precision are computed in float32 and hold Data; the
+// integer and boolean tensors models use for shapes and indices hold
+// int64 values internally.
+type Tensor struct {
+	Shape []int
+	Data  []float32
+	ints  []int64
+	isInt bool
+}
+
+// NewTensor returns a float tensor of shape holding data, which it does
+// not copy.
+func NewTensor(shape []int, data []float32) *Tensor {
+	return &Tensor{Shape: shape, Data: data}
+}
+
+func newFloat(shape []int) *Tensor {
+	return &Tensor{Shape: shape, Data: make([]float32, size(shape))}
+}
+
+func newInts(shape []int, ints []int64) *Tensor {
+	return &Tensor{Shape: shape, ints: ints, isInt: true}
+}
+
+// Len returns the number of elements.
+func (t *Tensor) Len() int {
+	return size(t.Shape)
+}
+
+// values returns the tensor's elements as int64s, truncating floats.
+func (t *Tensor) values() []int64 {
+	if t.isInt {
+		return t.ints
+	}
+	vs := make([]int64, len(t.Data))
+	for i, f := range t.Data {
+		vs[i] = int64(f)
+	}
+	return vs
+}
+
+// floats returns the tensor's elements as float32s.
+func (t *Tensor) floats() []float32 {
+	if !t.isInt {
+		return t.Data
+	}
+	fs := make([]float32, len(t.ints))
+	for i, v := range t.ints {
+		fs[i] = float32(v)
+	}
+	return fs
+}
+
+// scalar returns the first element as a float64.
+func (t *Tensor) scalar() float64 {
+	if t.isInt {
+		return float64(t.ints[0])
+	}
+	return float64(t.Data[0])
+}
+
+// like returns an empty tensor of shape with t's element kind.
+func (t *Tensor) like(shape []int) *Tensor {
+	if t.isInt {
+		return newInts(shape, make([]int64, size(shape)))
+	}
+	return newFloat(shape)
+}
+
+func size(shape []int) int {
+	n := 1
+	for _, d := range shape {
+		n *= d
+	}
+	return n
+}
+
+// strides returns the row-major strides of shape.
+func strides(shape []int) []int {
+	s := make([]int, len(shape))
+	n := 1
+	for i := len(shape) - 1; i >= 0; i-- {
+		s[i] = n
+		n *= shape[i]
+	}
+	return s
+}
+
+// parseTensor decodes a TensorProto. External data is read relative to
+// dir, the model's directory, which is empty when the model was given as
+// bytes.
+func parseTensor(b []byte, dir string) (string, *Tensor, error) {
+	d := decoder{b: b}
+	var (
+		name      string
+		dims      []int64
+		dataType  int
+		floats    []float32
+		doubles   []float64
+		ints      []int64
+		raw       []byte
+		external  bool
+		locations = map[string]string{}
+	)
+	for {
+		field, wire, ok := d.next()
+		if !ok {
+			break
+		}
+		switch field {
+		case 1:
+			dims = d.int64s(wire, dims)
+		case 2:
+			dataType = int(d.varint())
+		case 4:
+			floats = d.float32s(wire, floats)
+		case 5, 7, 11:
+			ints = d.int64s(wire, ints)
+		case 8:
+			name = d.string()
+		case 9:
+			raw = d.bytes()
+		case 10:
+			doubles = d.float64s(wire, doubles)
+		case 13:
+			entry := decoder{b: d.bytes()}
+			var key, value string
+			for {
+				f, w, ok := entry.next()
+				if !ok {
+					break
+				}
+				switch f {
+				case 1:
+					key = entry.string()
+				case 2:
+					value = entry.string()
+				default:
+					entry.skip(w)
+				}
+			}
+			locations[key] = value
+		case 14:
+			external = d.varint() == 1
+		default:
+			d.skip(wire)
+		}
+	}
+	if d.err != nil {
+		return "", nil, fmt.Errorf("tensor %q: %v", name, d.err)
+	}
+
+	shape := make([]int, len(dims))
+	for i, dim := range dims {
+		shape[i] = int(dim)
+	}
+	n := size(shape)
+
+	if external {
+		var err error
+		if raw, err = readExternal(dir, locations); err != nil {
+			return "", nil, fmt.Errorf("tensor %q: %v", name, err)
+		}
+	}
+
+	switch dataType {
+	case typeFloat, typeDouble, typeFloat16, typeBfloat16:
+		t := newFloat(shape)
+		switch {
+		case raw != nil:
+			if err := decodeRawFloats(t.Data, raw, dataType); err != nil {
+				return "", nil, fmt.Errorf("tensor %q: %v", name, err)
+			}
+		case len(floats) == n:
+			copy(t.Data, floats)
+		case len(doubles) == n:
+			for i, v := range doubles {
+				t.Data[i] = float32(v)
+			}
+		case len(ints) == n && (dataType == typeFloat16 || dataType == typeBfloat16):
+			// Half-precision values are stored as bits in int32_data
+			for i, v := range ints {
+				t.Data[i] = halfBits(uint16(v), dataType)
+			}
+		case n != 0:
+			return "", nil, fmt.Errorf("tensor %q: expected %d values", name, n)
+		}
+		return name, t, nil
+
+	case typeUint8, typeInt8, typeUint16, typeInt16, typeInt32, typeInt64, typeBool, typeUint32, typeUint64:
+		t := newInts(shape, make([]int64, n))
+		switch {
+		case raw != nil:
+			if err := decodeRawInts(t.ints, raw, dataType); err != nil {
+				return "", nil, fmt.Errorf("tensor %q: %v", name, err)
+			}
+		case len(ints) == n:
+			copy(t.ints, ints)
+		case n != 0:
+			return "", nil, fmt.Errorf("tensor %q: expected %d values", name, n)
+		}
+		return name, t, nil
+	}
+	return "", nil, fmt.Errorf("tensor %q: unsupported element type %d", name, dataType)
+}
+
+// readExternal reads tensor data stored outside the model file.
+func readExternal(dir string, locations map[string]string) ([]byte, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("external data needs the model to be loaded from a file")
+	}
+	location := locations["location"]
+	if !filepath.IsLocal(location) {
+		return nil, fmt.Errorf("invalid external data location %q", location)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, location))
+	if err != nil {
+		return nil, err
+	}
+	var offset, length int64
+	if v := locations["offset"]; v != "" {
+		if offset, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid external data offset %q", v)
+		}
+	}
+	length = int64(len(data)) - offset
+	if v := locations["length"]; v != "" {
+		if length, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid external data length %q", v)
+		}
+	}
+	if offset < 0 || length < 0 || offset+length > int64(len(data)) {
+		return nil, fmt.Errorf("external data out of range of %s", location)
+	}
+	return data[offset : offset+length], nil
+}
+
+func decodeRawFloats(dst []float32, raw []byte, dataType int) error {
+	width := map[int]int{typeFloat: 4, typeDouble: 8, typeFloat16: 2, typeBfloat16: 2}[dataType]
+	if len(raw) != len(dst)*width {
+		return fmt.Errorf("raw data is %d bytes, expected %d", len(raw), len(dst)*width)
+	}
+	for i := range dst {
+		switch dataType {
+		case typeFloat:
+			dst[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*i:]))
+		case typeDouble:
+			dst[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(raw[8*i:])))
+		default:
+			dst[i] = halfBits(binary.LittleEndian.Uint16(raw[2*i:]), dataType)
+		}
+	}
+	return nil
+}
+
+func decodeRawInts(dst []int64, raw []byte, dataType int) error {
+	width := map[int]int{typeUint8: 1, typeInt8: 1, typeBool: 1, typeUint16: 2, typeInt16: 2, typeInt32: 4, typeUint32: 4, typeInt64: 8, typeUint64: 8}[dataType]
+	if len(raw) != len(dst)*width {
+		return fmt.Errorf("raw data is %d bytes, expected %d", len(raw), len(dst)*width)
+	}
+	for i := range dst {
+		switch dataType {
+		case typeUint8, typeBool:
+			dst[i] = int64(raw[i])
+		case typeInt8:
+			dst[i] = int64(int8(raw[i]))
+		case typeUint16:
+			dst[i] = int64(binary.LittleEndian.Uint16(raw[2*i:]))
+		case typeInt16:
+			dst[i] = int64(int16(binary.LittleEndian.Uint16(raw[2*i:])))
+		case typeInt32:
+			dst[i] = int64(int32(binary.LittleEndian.Uint32(raw[4*i:])))
+		case typeUint32:
+			dst[i] = int64(binary.LittleEndian.Uint32(raw[4*i:]))
+		default:
+			dst[i] = int64(binary.LittleEndian.Uint64(raw[8*i:]))
+		}
+	}
+	return nil
+}
+
+// halfBits converts float16 or bfloat16 bits to float32.
+func halfBits(h uint16, dataType int) float32 {
+	if dataType == typeBfloat16 {
+		return math.Float32frombits(uint32(h) << 16)
+	}
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+	switch exp {
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	case 0:
+		f := float32(mant) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
@@ -0,0 +1,123 @@
+package orient
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/imgproc"
+)
+
+// maxSamples bounds the number of pixels inspected per axis when estimating
+// orientation, so detection cost does not grow with scan resolution.
+const maxSamples = 1000
+
+// Rotate returns img rotated clockwise by deg degrees, in grayscale, which
+// is all recognition needs and a quarter of the memory of RGBA. deg must be
+// a multiple of 90; 0 returns img unchanged.
+func Rotate(img image.Image, deg int) (image.Image, error) {
+	deg = ((deg % 360) + 360) % 360
+	if deg%90 != 0 {
+		return nil, fmt.Errorf("unsupported rotation %d: must be a multiple of 90", deg)
+	}
+	if deg == 0 {
+		return img, nil
+	}
+
+	src := imgproc.Gray(img)
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	dstW, dstH := w, h
+	if deg != 180 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewGray(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < h; y++ {
+		row := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):][:w]
+		switch deg {
+		case 90:
+			for x, v := range row {
+				dst.Pix[x*dst.Stride+h-1-y] = v
+			}
+		case 180:
+			out := dst.Pix[(h-1-y)*dst.Stride:][:w]
+			for x, v := range row {
+				out[w-1-x] = v
+			}
+		case 270:
+			for x, v := range row {
+				dst.Pix[(w-1-x)*dst.Stride+y] = v
+			}
+		}
+	}
+	return dst, nil
+}
+
+// DetectAxis estimates whether the text in img runs horizontally (0) or
+// vertically (90). It cannot tell upside-down text apart; callers that need
+// the full 0/90/180/270 answer should compare recognition results for the
+// returned angle and the angle plus 180.
+func DetectAxis(img image.Image) int {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return 0
+	}
+
+	// Single line crops are much wider than they are tall.
+	if h >= 3*w {
+		return 90
+	}
+	if w >= 3*h {
+		return 0
+	}
+
+	step := 1
+	if w > maxSamples || h > maxSamples {
+		step = int(math.Ceil(float64(max(w, h)) / maxSamples))
+	}
+
+	rows := make([]float64, (h+step-1)/step)
+	cols := make([]float64, (w+step-1)/step)
+	for y := 0; y < h; y += step {
+		for x := 0; x < w; x += step {
+			gray := color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray)
+			if gray.Y < 128 {
+				rows[y/step]++
+				cols[x/step]++
+			}
+		}
+	}
+
+	// Horizontal lines of text alternate with blank gaps, which gives the
+	// row profile a much higher spread than the column profile.
+	if variation(cols) > variation(rows)*1.2 {
+		return 90
+	}
+	return 0
+}
+
+// variation returns the coefficient of variation of profile.
+func variation(profile []float64) float64 {
+	if len(profile) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, v := range profile {
+		sum += v
+	}
+	mean := sum / float64(len(profile))
+	if mean == 0 {
+		return 0
+	}
+
+	var sq float64
+	for _, v := range profile {
+		sq += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sq/float64(len(profile))) / mean
+}
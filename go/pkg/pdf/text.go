@@ -0,0 +1,116 @@
+package pdf
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"unicode/utf8"
+)
+
+// TextBox is a run of recognized text and the rectangle it covers in the
+// page image.
+type TextBox struct {
+	Text string
+	Rect image.Rectangle
+}
+
+// glyphWidth is the advance of every glyph of the text layer font, in
+// thousandths of the font size.
+const glyphWidth = 500
+
+// textLayer returns content stream operators drawing boxes as invisible
+// text (render mode 3). scale converts image pixels to points; the image
+// origin is the top left, the PDF origin the bottom left.
+func textLayer(boxes []TextBox, bounds image.Rectangle, scale float64) string {
+	var b strings.Builder
+	b.WriteString("BT 3 Tr\n")
+	for _, box := range boxes {
+		n := utf8.RuneCountInString(box.Text)
+		r := box.Rect.Sub(bounds.Min)
+		if n == 0 || r.Dx() <= 0 || r.Dy() <= 0 {
+			continue
+		}
+
+		size := float64(r.Dy()) * scale
+		// Stretch the text horizontally to span the box exactly, so
+		// selections line up with what is visible in the image.
+		stretch := 100 * float64(r.Dx()) * scale / (float64(n) * size * glyphWidth / 1000)
+		x := float64(r.Min.X) * scale
+		// Put the baseline a fifth of the box above its bottom edge
+		y := (float64(bounds.Dy()-r.Max.Y) + float64(r.Dy())/5) * scale
+
+		fmt.Fprintf(&b, "/F0 %.2f Tf %.2f Tz 1 0 0 1 %.2f %.2f Tm <%s> Tj\n", size, stretch, x, y, encodeText(box.Text))
+	}
+	b.WriteString("ET\n")
+	return b.String()
+}
+
+// encodeText encodes s for the Identity-H font: one two-byte code per
+// rune, equal to its code point. Runes outside the Basic Multilingual
+// Plane become U+FFFD.
+func encodeText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 0xFFFF {
+			r = utf8.RuneError
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
+
+// writeFont writes the text layer font once: a glyphless CID font whose
+// codes are Unicode code points, with a ToUnicode map so viewers can
+// search and copy the text. Nothing is drawn with it, so it needs no
+// glyph data.
+func (pw *Writer) writeFont() error {
+	if pw.fontObj != 0 {
+		return nil
+	}
+
+	fontObj := pw.nextObj
+	cidObj := fontObj + 1
+	descObj := fontObj + 2
+	cmapObj := fontObj + 3
+	pw.nextObj += 4
+	pw.fontObj = fontObj
+
+	if err := pw.writeObject(fontObj, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /GlyphLessFont /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		cidObj, cmapObj)); err != nil {
+		return err
+	}
+	if err := pw.writeObject(cidObj, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /GlyphLessFont /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW %d /CIDToGIDMap /Identity >>",
+		descObj, glyphWidth)); err != nil {
+		return err
+	}
+	if err := pw.writeObject(descObj, "<< /Type /FontDescriptor /FontName /GlyphLessFont /Flags 5 /FontBBox [0 -200 500 800] /ItalicAngle 0 /Ascent 800 /Descent -200 /CapHeight 800 /StemV 80 >>"); err != nil {
+		return err
+	}
+
+	cmap := toUnicodeCMap()
+	return pw.writeStream(cmapObj, fmt.Sprintf("<< /Length %d >>", len(cmap)), []byte(cmap))
+}
+
+// toUnicodeCMap maps every two-byte code to the same code point. Ranges
+// may only vary in their last byte, so there is one per high byte.
+func toUnicodeCMap() string {
+	var b strings.Builder
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	b.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// At most 100 entries are allowed per block
+	for start := 0; start < 256; start += 100 {
+		end := start + 100
+		if end > 256 {
+			end = 256
+		}
+		fmt.Fprintf(&b, "%d beginbfrange\n", end-start)
+		for hi := start; hi < end; hi++ {
+			fmt.Fprintf(&b, "<%02X00> <%02XFF> <%02X00>\n", hi, hi, hi)
+		}
+		b.WriteString("endbfrange\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.String()
+}
@@ -0,0 +1,158 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+)
+
+// jpegQuality is the quality pages are encoded at.
+const jpegQuality = 90
+
+// Writer produces a PDF with one raster image per page. Pages are written
+// as they are added, so only the current page is held in memory.
+type Writer struct {
+	w       *countWriter
+	offsets map[int]int64
+	pages   []int
+	nextObj int
+	// fontObj is the text layer font, written with the first text page.
+	fontObj int
+}
+
+// Objects 1 and 2 are the catalog and the page tree, written on Close
+// once all pages are known.
+const (
+	catalogObj = 1
+	pagesObj   = 2
+)
+
+// NewWriter starts a PDF on w.
+func NewWriter(w io.Writer) (*Writer, error) {
+	pw := &Writer{
+		w:       &countWriter{w: w},
+		offsets: make(map[int]int64),
+		nextObj: pagesObj + 1,
+	}
+	if _, err := io.WriteString(pw.w, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n"); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// AddImage appends a page showing img, sized so the image prints at dpi.
+func (pw *Writer) AddImage(img image.Image, dpi int) error {
+	return pw.AddPage(img, dpi, nil)
+}
+
+// AddPage appends a page showing img with an invisible text layer over
+// it, which makes the page searchable and its text selectable. Each box
+// is stretched to cover its rectangle, given in pixels of img.
+func (pw *Writer) AddPage(img image.Image, dpi int, text []TextBox) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return fmt.Errorf("failed to encode page: %v", err)
+	}
+
+	colorSpace := "/DeviceRGB"
+	if _, ok := img.(*image.Gray); ok {
+		colorSpace = "/DeviceGray"
+	}
+
+	b := img.Bounds()
+	// Page size in points (1/72 inch)
+	width := float64(b.Dx()) * 72 / float64(dpi)
+	height := float64(b.Dy()) * 72 / float64(dpi)
+
+	imageObj := pw.nextObj
+	contentObj := imageObj + 1
+	pageObj := imageObj + 2
+	pw.nextObj += 3
+
+	header := fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>",
+		b.Dx(), b.Dy(), colorSpace, buf.Len())
+	if err := pw.writeStream(imageObj, header, buf.Bytes()); err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("q %.2f 0 0 %.2f 0 0 cm /Im0 Do Q\n", width, height)
+	fonts := ""
+	if len(text) > 0 {
+		if err := pw.writeFont(); err != nil {
+			return err
+		}
+		content += textLayer(text, b, 72/float64(dpi))
+		fonts = fmt.Sprintf(" /Font << /F0 %d 0 R >>", pw.fontObj)
+	}
+	if err := pw.writeStream(contentObj, fmt.Sprintf("<< /Length %d >>", len(content)), []byte(content)); err != nil {
+		return err
+	}
+
+	page := fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /XObject << /Im0 %d 0 R >>%s >> /Contents %d 0 R >>",
+		pagesObj, width, height, imageObj, fonts, contentObj)
+	if err := pw.writeObject(pageObj, page); err != nil {
+		return err
+	}
+
+	pw.pages = append(pw.pages, pageObj)
+	return nil
+}
+
+// Close writes the page tree, cross-reference table and trailer. It does
+// not close the underlying writer.
+func (pw *Writer) Close() error {
+	kids := new(bytes.Buffer)
+	for i, obj := range pw.pages {
+		if i > 0 {
+			kids.WriteByte(' ')
+		}
+		fmt.Fprintf(kids, "%d 0 R", obj)
+	}
+
+	if err := pw.writeObject(pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(pw.pages))); err != nil {
+		return err
+	}
+	if err := pw.writeObject(catalogObj, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj)); err != nil {
+		return err
+	}
+
+	xref := pw.w.n
+	fmt.Fprintf(pw.w, "xref\n0 %d\n0000000000 65535 f \n", pw.nextObj)
+	for obj := 1; obj < pw.nextObj; obj++ {
+		fmt.Fprintf(pw.w, "%010d 00000 n \n", pw.offsets[obj])
+	}
+	_, err := fmt.Fprintf(pw.w, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", pw.nextObj, catalogObj, xref)
+	return err
+}
+
+func (pw *Writer) writeObject(obj int, body string) error {
+	pw.offsets[obj] = pw.w.n
+	_, err := fmt.Fprintf(pw.w, "%d 0 obj\n%s\nendobj\n", obj, body)
+	return err
+}
+
+func (pw *Writer) writeStream(obj int, header string, data []byte) error {
+	pw.offsets[obj] = pw.w.n
+	if _, err := fmt.Fprintf(pw.w, "%d 0 obj\n%s\nstream\n", obj, header); err != nil {
+		return err
+	}
+	if _, err := pw.w.Write(data); err != nil {
+		return err
+	}
+	_, err := io.WriteString(pw.w, "\nendstream\nendobj\n")
+	return err
+}
+
+// countWriter tracks the byte offset needed for the xref table.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
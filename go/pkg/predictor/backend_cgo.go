@@ -0,0 +1,26 @@
+//go:build cgo
+
+package predictor
+
+var (
+	_ Recognizer            = (*Predictor)(nil)
+	_ ConstrainedRecognizer = (*Predictor)(nil)
+	_ BatchRecognizer       = (*Predictor)(nil)
+	_ Warmer                = (*Predictor)(nil)
+	_ CharsetExtender       = (*Predictor)(nil)
+)
+
+// DefaultBackend is the Backend used when none is configured: ONNXRuntime
+// in builds with cgo, PureGo in builds without.
+func DefaultBackend(modelPath, charset string, opts ...Option) (Recognizer, error) {
+	return ONNXRuntime(modelPath, charset, opts...)
+}
+
+// ONNXRuntime is the default Backend. It loads the model with NewPredictor.
+func ONNXRuntime(modelPath, charset string, opts ...Option) (Recognizer, error) {
+	pred, err := NewPredictor(modelPath, charset, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return pred, nil
+}
@@ -0,0 +1,20 @@
+//go:build !cgo
+
+package predictor
+
+import (
+	"fmt"
+)
+
+// DefaultBackend is the Backend used when none is configured: ONNXRuntime
+// in builds with cgo, PureGo in builds without.
+func DefaultBackend(modelPath, charset string, opts ...Option) (Recognizer, error) {
+	return PureGo(modelPath, charset, opts...)
+}
+
+// ONNXRuntime is the Backend for ONNX Runtime. It is reached through cgo,
+// so in builds without cgo it reports an error; PureGo runs the model
+// instead.
+func ONNXRuntime(modelPath, charset string, opts ...Option) (Recognizer, error) {
+	return nil, fmt.Errorf("the ONNX Runtime backend needs cgo: rebuild with CGO_ENABLED=1 or use the pure-Go backend")
+}
@@ -0,0 +1,101 @@
+//go:build cgo
+
+package predictor
+
+import (
+	"fmt"
+	"image"
+	"unicode/utf8"
+)
+
+// PredictBatch recognizes several line images in a single inference run:
+// they are padded to a common width, like WithWidthBucket pads single
+// lines, and stacked into one [N, C, H, W] tensor. Lines of similar width
+// waste the least work on padding. Models whose batch dimension is fixed
+// at 1 read the lines one at a time instead.
+func (p *Predictor) PredictBatch(imgs []image.Image) ([]Prediction, error) {
+	preds := make([]Prediction, len(imgs))
+	if !p.layout.Batched || len(imgs) == 1 {
+		for i, img := range imgs {
+			text, conf, chars, err := p.PredictChars(img)
+			if err != nil {
+				return nil, err
+			}
+			preds[i] = Prediction{Text: text, Confidence: conf, Chars: chars}
+		}
+		return preds, nil
+	}
+
+	outputs, err := p.runBatch(imgs)
+	if err != nil {
+		return nil, err
+	}
+	for i, out := range outputs {
+		decoded, seqLen, blankSum := p.decodeChars(out)
+		text, conf := joinChars(decoded, seqLen, blankSum)
+		preds[i] = Prediction{Text: text, Confidence: conf, Chars: p.spanChars(imgs[i], decoded, seqLen)}
+	}
+	return preds, nil
+}
+
+// runBatch runs imgs through the model as one batch and returns each
+// image's output scores, without the timesteps read from its padding.
+func (p *Predictor) runBatch(imgs []image.Image) ([][]float32, error) {
+	inputs := make([][]float32, len(imgs))
+	widths := make([]int, len(imgs))
+	widest := 0
+	for i, img := range imgs {
+		data, _, err := p.preprocess(img)
+		if err != nil {
+			return nil, err
+		}
+		inputs[i] = data
+		widths[i] = len(data) / (p.layout.Channels * p.layout.Height)
+		widest = max(widest, widths[i])
+	}
+
+	padded := bucketWidth(widest, p.widthStep)
+	shape := p.layout.Shape(padded)
+	shape[0] = int64(len(imgs))
+	stride := p.layout.Channels * p.layout.Height * padded
+	batch := make([]float32, len(imgs)*stride)
+	for i, data := range inputs {
+		padWidth(batch[i*stride:(i+1)*stride], data, p.layout, widths[i], padded)
+	}
+
+	input, err := p.newInput(shape, batch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create input tensor: %v", err)
+	}
+	defer input.Destroy()
+
+	output, err := p.runSession(input, nil)
+	if output != nil {
+		defer output.Destroy()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("inference failed: %v", err)
+	}
+	data, err := outputScores(output)
+	if err != nil {
+		return nil, err
+	}
+
+	// The output is [N, T, classes], batch first like the input
+	numClasses := utf8.RuneCountInString(p.charset) + 1
+	outShape := output.GetShape()
+	if len(outShape) != 3 || outShape[0] != int64(len(imgs)) || outShape[2] != int64(numClasses) {
+		return nil, fmt.Errorf("unexpected output shape %v for a batch of %d", outShape, len(imgs))
+	}
+	seqLen := int(outShape[1])
+
+	outputs := make([][]float32, len(imgs))
+	for i, width := range widths {
+		// Timesteps are spread evenly over the padded width
+		used := (seqLen*width + padded - 1) / padded
+		sample := data[i*seqLen*numClasses:]
+		outputs[i] = make([]float32, used*numClasses)
+		copy(outputs[i], sample)
+	}
+	return outputs, nil
+}
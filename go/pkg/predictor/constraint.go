@@ -0,0 +1,440 @@
+package predictor
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Constraint restricts what constrained decoding may read from a line,
+// such as a date or a number field on a form. Instead of the most likely
+// character at each step, the decoder then returns the most likely text
+// the constraint accepts.
+type Constraint interface {
+	// Allow reports whether r may follow prefix.
+	Allow(prefix []rune, r rune) bool
+	// Complete reports whether text is a whole value rather than the
+	// start of one.
+	Complete(text []rune) bool
+}
+
+// ConstrainedRecognizer is implemented by recognizers that can decode
+// under a Constraint, such as Predictor.
+type ConstrainedRecognizer interface {
+	PredictConstrained(img image.Image, c Constraint) (string, float64, []Char, error)
+}
+
+// beamWidth is the number of prefixes kept by beam search decoding.
+const beamWidth = 16
+
+// Digits accepts only digits, Mon and Burmese as well as ASCII.
+func Digits() Constraint {
+	return runeConstraint(unicode.IsDigit)
+}
+
+type runeConstraint func(r rune) bool
+
+func (f runeConstraint) Allow(prefix []rune, r rune) bool { return f(r) }
+func (f runeConstraint) Complete(text []rune) bool        { return true }
+
+// Mask accepts text of the mask's shape, one rune per mask rune. The
+// letters D, M, Y, H and S and the digit 9 stand for any digit, A for any
+// letter and ? for any character; a backslash makes the next rune
+// literal, and other runes stand for themselves. "DD-MM-YYYY" accepts
+// 07-03-2024 as well as ၀၇-၀၃-၂၀၂၄.
+func Mask(mask string) Constraint {
+	var slots []func(rune) bool
+	runes := []rune(mask)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case 'D', 'M', 'Y', 'H', 'S', '9':
+			slots = append(slots, unicode.IsDigit)
+		case 'A':
+			slots = append(slots, unicode.IsLetter)
+		case '?':
+			slots = append(slots, func(rune) bool { return true })
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				r = runes[i]
+			}
+			fallthrough
+		default:
+			slots = append(slots, func(c rune) bool { return c == r })
+		}
+	}
+	return maskConstraint(slots)
+}
+
+type maskConstraint []func(rune) bool
+
+func (m maskConstraint) Allow(prefix []rune, r rune) bool {
+	return len(prefix) < len(m) && m[len(prefix)](r)
+}
+
+func (m maskConstraint) Complete(text []rune) bool {
+	return len(text) == len(m)
+}
+
+// Pattern accepts text that re matches in full. Decoding only considers
+// the characters re can match, and picks the most likely text that
+// matches among the candidates it keeps.
+func Pattern(re *regexp.Regexp) (Constraint, error) {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	full, err := regexp.Compile(`^(?:` + re.String() + `)$`)
+	if err != nil {
+		return nil, err
+	}
+	return patternConstraint{re: full, alphabet: alphabet(parsed)}, nil
+}
+
+type patternConstraint struct {
+	re       *regexp.Regexp
+	alphabet func(rune) bool
+}
+
+func (p patternConstraint) Allow(prefix []rune, r rune) bool { return p.alphabet(r) }
+func (p patternConstraint) Complete(text []rune) bool        { return p.re.MatchString(string(text)) }
+
+// alphabet returns a test for the runes a parsed expression can match.
+func alphabet(re *syntax.Regexp) func(rune) bool {
+	switch re.Op {
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return func(rune) bool { return true }
+	case syntax.OpLiteral, syntax.OpCharClass:
+		ranges := re.Rune
+		if re.Op == syntax.OpLiteral {
+			ranges = nil
+			for _, r := range re.Rune {
+				ranges = append(ranges, r, r)
+			}
+		}
+		fold := re.Flags&syntax.FoldCase != 0
+		return func(r rune) bool {
+			for c := r; ; {
+				for i := 0; i+1 < len(ranges); i += 2 {
+					if ranges[i] <= c && c <= ranges[i+1] {
+						return true
+					}
+				}
+				if !fold {
+					return false
+				}
+				if c = unicode.SimpleFold(c); c == r {
+					return false
+				}
+			}
+		}
+	}
+	var subs []func(rune) bool
+	for _, sub := range re.Sub {
+		subs = append(subs, alphabet(sub))
+	}
+	return func(r rune) bool {
+		for _, sub := range subs {
+			if sub(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ParseConstraint parses a constraint as written in region manifests and
+// on the command line: "digits", "mask:DD-MM-YYYY" or "regex:[0-9]{2,4}".
+func ParseConstraint(s string) (Constraint, error) {
+	kind, arg, _ := strings.Cut(s, ":")
+	switch kind {
+	case "digits":
+		return Digits(), nil
+	case "mask":
+		if arg == "" {
+			return nil, fmt.Errorf("empty mask in constraint %q", s)
+		}
+		return Mask(arg), nil
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid constraint %q: %v", s, err)
+		}
+		return Pattern(re)
+	}
+	return nil, fmt.Errorf("unknown constraint %q: use digits, mask:MASK or regex:EXPR", s)
+}
+
+// Constrain returns a Recognizer that reads every line through rec under
+// c. rec must implement ConstrainedRecognizer. Closing the returned
+// Recognizer does not close rec.
+func Constrain(rec Recognizer, c Constraint) (Recognizer, error) {
+	cr, ok := rec.(ConstrainedRecognizer)
+	if !ok {
+		return nil, fmt.Errorf("recognizer %T does not support constrained decoding", rec)
+	}
+	return constrained{cr, c}, nil
+}
+
+type constrained struct {
+	rec ConstrainedRecognizer
+	c   Constraint
+}
+
+func (r constrained) Predict(img image.Image) (string, error) {
+	text, _, _, err := r.rec.PredictConstrained(img, r.c)
+	return text, err
+}
+
+func (r constrained) PredictWithConfidence(img image.Image) (string, float64, error) {
+	text, conf, _, err := r.rec.PredictConstrained(img, r.c)
+	return text, conf, err
+}
+
+func (r constrained) PredictChars(img image.Image) (string, float64, []Char, error) {
+	return r.rec.PredictConstrained(img, r.c)
+}
+
+func (r constrained) Close() error { return nil }
+
+// beamEntry is a prefix kept by beam search, with the log probabilities
+// of the paths reading it that end in a blank and in its last character,
+// and its weighted language model score.
+type beamEntry struct {
+	prefix          []rune
+	blank, nonBlank float64
+	lm              float64
+}
+
+func (b *beamEntry) total() float64 { return logAdd(b.blank, b.nonBlank) }
+
+// score ranks prefixes: the model's probability fused with the language
+// model's.
+func (b *beamEntry) score() float64 { return b.total() + b.lm }
+
+// decodeBeam runs CTC prefix beam search over preds and returns the most
+// likely complete text aligned to the timesteps like the greedy decoder's
+// output. A prefix grows only by characters c allows, and if no prefix is
+// complete the most likely one is used; c may be nil to allow any text.
+// With a language model, prefixes are ranked by their probability plus
+// lm's weighted score.
+func decodeBeam(preds []float32, charset []rune, c Constraint, lm *lmFusion) ([]decodedChar, int, float64) {
+	numClasses := len(charset) + 1
+	seqLen := len(preds) / numClasses
+	logProbs := logSoftmax(preds, numClasses)
+
+	var blankSum float64
+	beams := map[string]*beamEntry{"": {blank: 0, nonBlank: math.Inf(-1)}}
+	for t := 0; t < seqLen; t++ {
+		row := logProbs[t*numClasses : (t+1)*numClasses]
+		blankSum += math.Exp(maxFloat(row))
+		next := make(map[string]*beamEntry, len(beams)*2)
+		get := func(prefix []rune, lmScore func() float64) *beamEntry {
+			key := string(prefix)
+			e, ok := next[key]
+			if !ok {
+				e = &beamEntry{prefix: prefix, blank: math.Inf(-1), nonBlank: math.Inf(-1), lm: lmScore()}
+				next[key] = e
+			}
+			return e
+		}
+
+		for _, b := range beams {
+			// Staying on the same prefix: a blank, or a repeat of the
+			// last character without a blank in between
+			stay := get(b.prefix, func() float64 { return b.lm })
+			stay.blank = logAdd(stay.blank, b.total()+row[0])
+			var last rune = -1
+			if n := len(b.prefix); n > 0 {
+				last = b.prefix[n-1]
+				idx := runeIndex(charset, last)
+				stay.nonBlank = logAdd(stay.nonBlank, b.nonBlank+row[idx+1])
+			}
+
+			for k, r := range charset {
+				p := row[k+1]
+				// Unlikely characters can't change the outcome
+				if p < -20 || c != nil && !c.Allow(b.prefix, r) {
+					continue
+				}
+				grown := get(append(b.prefix[:len(b.prefix):len(b.prefix)], r), func() float64 {
+					return b.lm + lm.score(b.prefix, r)
+				})
+				if r == last {
+					// A doubled character needs a blank in between
+					grown.nonBlank = logAdd(grown.nonBlank, b.blank+p)
+				} else {
+					grown.nonBlank = logAdd(grown.nonBlank, b.total()+p)
+				}
+			}
+		}
+		beams = prune(next, beamWidth)
+	}
+
+	var best *beamEntry
+	for _, complete := range []bool{true, false} {
+		for _, b := range beams {
+			if complete && c != nil && !c.Complete(b.prefix) {
+				continue
+			}
+			if best == nil || b.score() > best.score() {
+				best = b
+			}
+		}
+		if best != nil {
+			break
+		}
+	}
+	return align(logProbs, numClasses, charset, best.prefix), seqLen, blankSum
+}
+
+// prune keeps the n most likely prefixes.
+func prune(beams map[string]*beamEntry, n int) map[string]*beamEntry {
+	if len(beams) <= n {
+		return beams
+	}
+	entries := make([]*beamEntry, 0, len(beams))
+	for _, b := range beams {
+		entries = append(entries, b)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].score() > entries[j].score() })
+	kept := make(map[string]*beamEntry, n)
+	for _, b := range entries[:n] {
+		kept[string(b.prefix)] = b
+	}
+	return kept
+}
+
+// align finds the most likely timesteps for each rune of text (a Viterbi
+// forced alignment over the CTC lattice) and returns the runes with the
+// timesteps they span and their best probability.
+func align(logProbs []float64, numClasses int, charset []rune, text []rune) []decodedChar {
+	seqLen := len(logProbs) / numClasses
+	if len(text) == 0 || seqLen == 0 {
+		return nil
+	}
+	// Lattice states alternate blank, text[0], blank, text[1], ..., blank
+	states := 2*len(text) + 1
+	class := func(s int) int {
+		if s%2 == 0 {
+			return 0
+		}
+		return runeIndex(charset, text[s/2]) + 1
+	}
+
+	score := make([]float64, states)
+	from := make([][]int, seqLen)
+	for s := range score {
+		score[s] = math.Inf(-1)
+	}
+	score[0] = logProbs[0]
+	score[1] = logProbs[class(1)]
+	from[0] = make([]int, states)
+	for t := 1; t < seqLen; t++ {
+		row := logProbs[t*numClasses : (t+1)*numClasses]
+		next := make([]float64, states)
+		from[t] = make([]int, states)
+		for s := 0; s < states; s++ {
+			best, arg := score[s], s
+			if s > 0 && score[s-1] > best {
+				best, arg = score[s-1], s-1
+			}
+			// Skipping a blank is allowed between different characters
+			if s > 1 && s%2 == 1 && text[s/2] != text[s/2-1] && score[s-2] > best {
+				best, arg = score[s-2], s-2
+			}
+			next[s] = best + row[class(s)]
+			from[t][s] = arg
+		}
+		score = next
+	}
+
+	// The path ends on the last character or the blank after it
+	s := states - 1
+	if score[states-2] > score[s] {
+		s = states - 2
+	}
+	if math.IsInf(score[s], -1) {
+		// Too few timesteps to read the text: spread it evenly
+		chars := make([]decodedChar, len(text))
+		for i, r := range text {
+			start := i * seqLen / len(text)
+			chars[i] = decodedChar{r: r, start: start, end: max(start+1, (i+1)*seqLen/len(text))}
+		}
+		return chars
+	}
+
+	chars := make([]decodedChar, len(text))
+	for t := seqLen - 1; t >= 0; t-- {
+		if s%2 == 1 {
+			c := &chars[s/2]
+			p := math.Exp(logProbs[t*numClasses+class(s)])
+			if c.end == 0 {
+				c.r, c.end = text[s/2], t+1
+			}
+			c.start = t
+			c.prob = max(c.prob, p)
+		}
+		if t > 0 {
+			s = from[t][s]
+		}
+	}
+	return chars
+}
+
+// logSoftmax normalizes each row of scores, raw logits or log
+// probabilities alike, into log probabilities.
+func logSoftmax(preds []float32, numClasses int) []float64 {
+	out := make([]float64, len(preds))
+	for i := 0; i+numClasses <= len(preds); i += numClasses {
+		row := preds[i : i+numClasses]
+		maxVal := row[0]
+		for _, v := range row {
+			maxVal = max(maxVal, v)
+		}
+		var sum float64
+		for _, v := range row {
+			sum += math.Exp(float64(v - maxVal))
+		}
+		norm := float64(maxVal) + math.Log(sum)
+		for k, v := range row {
+			out[i+k] = float64(v) - norm
+		}
+	}
+	return out
+}
+
+func logAdd(a, b float64) float64 {
+	if math.IsInf(a, -1) {
+		return b
+	}
+	if math.IsInf(b, -1) {
+		return a
+	}
+	if a < b {
+		a, b = b, a
+	}
+	return a + math.Log1p(math.Exp(b-a))
+}
+
+func maxFloat(row []float64) float64 {
+	m := math.Inf(-1)
+	for _, v := range row {
+		m = max(m, v)
+	}
+	return m
+}
+
+func runeIndex(charset []rune, r rune) int {
+	for i, c := range charset {
+		if c == r {
+			return i
+		}
+	}
+	return -1
+}
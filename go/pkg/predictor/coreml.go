@@ -0,0 +1,24 @@
+//go:build cgo
+
+package predictor
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/yalue/onnxruntime_go"
+)
+
+// coreMLFlags leaves CoreML free to pick the Neural Engine, the GPU or
+// the CPU for each part of the model.
+const coreMLFlags = 0
+
+// appendCoreML adds the CoreML execution provider to options. CoreML
+// only exists on Apple platforms, and only in ONNX Runtime builds that
+// include it.
+func appendCoreML(options *onnxruntime_go.SessionOptions) error {
+	if runtime.GOOS != "darwin" && runtime.GOOS != "ios" {
+		return fmt.Errorf("CoreML needs macOS")
+	}
+	return options.AppendExecutionProviderCoreML(coreMLFlags)
+}
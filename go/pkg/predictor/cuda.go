@@ -0,0 +1,56 @@
+//go:build cgo
+
+package predictor
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/yalue/onnxruntime_go"
+)
+
+// appendCUDA adds the CUDA execution provider for device to options. When
+// the device's memory size is known, the session is limited to its share
+// of the GPU memory budget, and appendCUDA returns the bytes reserved,
+// which must be released once the session is gone.
+func appendCUDA(options *onnxruntime_go.SessionOptions, device int) (uint64, error) {
+	cuda, err := onnxruntime_go.NewCUDAProviderOptions()
+	if err != nil {
+		return 0, err
+	}
+	defer cuda.Destroy()
+
+	settings := map[string]string{"device_id": strconv.Itoa(device)}
+	var reserved uint64
+	if total := gpuTotalMemory(device); total > 0 {
+		if reserved, err = reserveGPUMemory(total); err != nil {
+			return 0, err
+		}
+		settings["gpu_mem_limit"] = strconv.FormatUint(reserved, 10)
+	}
+	err = cuda.Update(settings)
+	if err == nil {
+		err = options.AppendExecutionProviderCUDA(cuda)
+	}
+	if err != nil {
+		releaseGPUMemory(reserved)
+		return 0, err
+	}
+	return reserved, nil
+}
+
+// gpuTotalMemory returns the memory of GPU device in bytes as reported by
+// nvidia-smi, or 0 if it can't be determined; the session then runs
+// without a memory limit.
+func gpuTotalMemory(device int) uint64 {
+	out, err := exec.Command("nvidia-smi", "--query-gpu=memory.total", "--format=csv,noheader,nounits", "-i", strconv.Itoa(device)).Output()
+	if err != nil {
+		return 0
+	}
+	mib, err := strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return mib << 20
+}
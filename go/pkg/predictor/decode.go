@@ -0,0 +1,31 @@
+package predictor
+
+import "strings"
+
+// joinChars returns the decoded text and the mean probability of its
+// characters.
+func joinChars(chars []decodedChar, seqLen int, blankSum float64) (string, float64) {
+	// With nothing emitted, report how sure the model was that the line is blank
+	if len(chars) == 0 {
+		if seqLen == 0 {
+			return "", 0
+		}
+		return "", blankSum / float64(seqLen)
+	}
+
+	var sb strings.Builder
+	var probSum float64
+	for _, c := range chars {
+		sb.WriteRune(c.r)
+		probSum += c.prob
+	}
+	return sb.String(), probSum / float64(len(chars))
+}
+
+// decodedChar is a character emitted by the greedy CTC decoder together
+// with its probability and the timesteps [start, end) it was read from.
+type decodedChar struct {
+	r          rune
+	prob       float64
+	start, end int
+}
@@ -0,0 +1,42 @@
+//go:build cgo
+
+package predictor
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/yalue/onnxruntime_go"
+)
+
+// The ONNX Runtime environment is process-wide. Every Predictor shares it,
+// so several models loaded in one process (e.g. a server with one Predictor
+// per model) use a single runtime and thread pool setup.
+var envMu sync.Mutex
+
+// initEnvironment initializes the shared ONNX Runtime environment once.
+// It is safe to call from concurrent NewPredictor calls.
+func initEnvironment() error {
+	envMu.Lock()
+	defer envMu.Unlock()
+
+	if onnxruntime_go.IsInitialized() {
+		return nil
+	}
+
+	// Try to find libonnxruntime on macOS if not set
+	if runtime.GOOS == "darwin" {
+		// Common Homebrew path
+		libPath := "/opt/homebrew/lib/libonnxruntime.dylib"
+		if _, err := os.Stat(libPath); err == nil {
+			onnxruntime_go.SetSharedLibraryPath(libPath)
+		}
+	}
+
+	if err := onnxruntime_go.InitializeEnvironment(); err != nil {
+		return fmt.Errorf("failed to initialize ONNX Runtime: %v. Make sure libonnxruntime.dylib (macOS) or libonnxruntime.so (Linux) is in your library path", err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+//go:build cgo
+
+package predictor
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/yalue/onnxruntime_go"
+)
+
+// float32Output is the name of the model's scores once castOutput has
+// converted them to float32.
+const float32Output = "output_float32"
+
+// newHalfTensor allocates a float16 tensor of shape.
+func newHalfTensor(shape onnxruntime_go.Shape) (*onnxruntime_go.CustomDataTensor, error) {
+	data := make([]byte, 2*shape.FlattenedSize())
+	return onnxruntime_go.NewCustomDataTensor(shape, data, onnxruntime_go.TensorElementDataTypeFloat16)
+}
+
+// newInput creates an input tensor of shape holding data, converted to
+// float16 for fp16 models.
+func (p *Predictor) newInput(shape []int64, data []float32) (onnxruntime_go.Value, error) {
+	if !p.layout.Float16 {
+		return onnxruntime_go.NewTensor(onnxruntime_go.Shape(shape), data)
+	}
+	input, err := newHalfTensor(shape)
+	if err != nil {
+		return nil, err
+	}
+	encodeHalf(input.GetData(), data)
+	return input, nil
+}
+
+// runSession runs the model on input and returns its output. A non-nil
+// output from an earlier run with the same input shape is filled again;
+// otherwise ONNX Runtime allocates one, which the caller must destroy,
+// even when the run fails.
+func (p *Predictor) runSession(input, output onnxruntime_go.Value) (onnxruntime_go.Value, error) {
+	outputs := []onnxruntime_go.Value{output}
+	err := p.session.Run([]onnxruntime_go.Value{input}, outputs)
+	return outputs[0], err
+}
+
+// castOutput returns the model with its float16 "output" cast to float32
+// as float32Output. The Go binding can't return float16 outputs it
+// allocates itself, and allocating them beforehand would mean knowing the
+// length of the time axis for every input width, so the conversion is
+// done in the graph instead and ONNX Runtime allocates float32 scores as
+// for any other model.
+func castOutput(model []byte) ([]byte, error) {
+	found := false
+	castGraph := func(graph []byte) ([]byte, error) {
+		var out []byte
+		err := eachField(graph, func(num int, raw, value []byte) {
+			// GraphProto.output
+			if num != 12 || string(fieldBytes(value, 1)) != "output" {
+				out = append(out, raw...)
+				return
+			}
+			found = true
+			tensor := appendVarint(nil, 1, onnxFloat)
+			if shape := fieldBytes(fieldBytes(fieldBytes(value, 2), 1), 2); shape != nil {
+				tensor = appendBytes(tensor, 2, shape)
+			}
+			info := appendString(nil, 1, float32Output)
+			out = appendBytes(out, 12, appendBytes(info, 2, appendBytes(nil, 1, tensor)))
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		cast := appendString(nil, 1, "output")
+		cast = appendString(cast, 2, float32Output)
+		cast = appendString(cast, 4, "Cast")
+		cast = appendBytes(cast, 5, intAttr("to", onnxFloat))
+		return appendBytes(out, 1, cast), nil
+	}
+
+	var out []byte
+	var graphErr error
+	err := eachField(model, func(num int, raw, value []byte) {
+		// ModelProto.graph
+		if num != 7 || graphErr != nil {
+			out = append(out, raw...)
+			return
+		}
+		var graph []byte
+		if graph, graphErr = castGraph(value); graphErr == nil {
+			out = appendBytes(out, 7, graph)
+		}
+	})
+	if err == nil {
+		err = graphErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model: %v", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("model has no graph output named \"output\"")
+	}
+	return out, nil
+}
+
+// eachField calls fn with every field of the protobuf message msg: its
+// number, its raw encoding and, for length-delimited fields, its value.
+func eachField(msg []byte, fn func(num int, raw, value []byte)) error {
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return fmt.Errorf("malformed protobuf")
+		}
+		size, value := n, []byte(nil)
+		switch key & 7 {
+		case 0:
+			_, m := binary.Uvarint(msg[n:])
+			if m <= 0 {
+				return fmt.Errorf("malformed protobuf")
+			}
+			size += m
+		case 1:
+			size += 8
+		case 2:
+			l, m := binary.Uvarint(msg[n:])
+			if m <= 0 || l > uint64(len(msg)-n-m) {
+				return fmt.Errorf("malformed protobuf")
+			}
+			value = msg[n+m : n+m+int(l)]
+			size += m + int(l)
+		case 5:
+			size += 4
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", key&7)
+		}
+		if size > len(msg) {
+			return fmt.Errorf("malformed protobuf")
+		}
+		fn(int(key>>3), msg[:size], value)
+		msg = msg[size:]
+	}
+	return nil
+}
+
+// fieldBytes returns the value of the first length-delimited field num in
+// msg, or nil.
+func fieldBytes(msg []byte, num int) []byte {
+	var found []byte
+	eachField(msg, func(n int, _, value []byte) {
+		if n == num && found == nil {
+			found = value
+		}
+	})
+	return found
+}
+
+// outputScores returns the float32 scores in output, sharing the
+// tensor's data.
+func outputScores(output onnxruntime_go.Value) ([]float32, error) {
+	if t, ok := output.(*onnxruntime_go.Tensor[float32]); ok {
+		return t.GetData(), nil
+	}
+	return nil, fmt.Errorf("unexpected output tensor type")
+}
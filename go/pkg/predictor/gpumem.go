@@ -0,0 +1,73 @@
+package predictor
+
+import (
+	"fmt"
+	"sync"
+)
+
+// The GPU memory budget is shared by every GPU session in the process.
+var (
+	gpuMu          sync.Mutex
+	gpuMemFraction = 1.0
+	gpuShares      = 1
+)
+
+// SetGPUMemoryFraction sets the share of device memory, in (0, 1], that all
+// GPU-backed predictors in the process may use together. The budget is
+// split evenly between the sessions declared with SetGPUSessions, so
+// models loaded side by side don't each try to claim the whole card.
+// It must be called before predictors are created; CPU sessions ignore it.
+func SetGPUMemoryFraction(fraction float64) error {
+	if fraction <= 0 || fraction > 1 {
+		return fmt.Errorf("invalid GPU memory fraction %v: must be in (0, 1]", fraction)
+	}
+
+	gpuMu.Lock()
+	defer gpuMu.Unlock()
+	gpuMemFraction = fraction
+	return nil
+}
+
+// SetGPUSessions declares how many GPU sessions the process will hold at
+// once, such as one per model and pool member, so each is limited to that
+// share of the GPU memory budget. The default is 1: a single session may
+// use the whole budget. A session created once the budget is spent fails,
+// and the predictor falls back to the CPU.
+func SetGPUSessions(n int) error {
+	if n < 1 {
+		return fmt.Errorf("invalid GPU session count %d: must be at least 1", n)
+	}
+
+	gpuMu.Lock()
+	defer gpuMu.Unlock()
+	gpuShares = n
+	return nil
+}
+
+// gpuReserved is the GPU memory held by live sessions, in bytes.
+var gpuReserved uint64
+
+// reserveGPUMemory reserves the memory limit of a new GPU session, in
+// bytes, given the device's total memory: an even share of the budget, or
+// what is left of it. The same amount must be passed to releaseGPUMemory
+// when the session is gone.
+func reserveGPUMemory(total uint64) (uint64, error) {
+	gpuMu.Lock()
+	defer gpuMu.Unlock()
+
+	budget := uint64(float64(total) * gpuMemFraction)
+	share := min(budget/uint64(gpuShares), budget-min(gpuReserved, budget))
+	if share == 0 {
+		return 0, fmt.Errorf("GPU memory budget of %d MiB is held by other sessions; raise the session count with SetGPUSessions", budget>>20)
+	}
+	gpuReserved += share
+	return share, nil
+}
+
+// releaseGPUMemory returns a session's reservation to the budget.
+func releaseGPUMemory(bytes uint64) {
+	gpuMu.Lock()
+	defer gpuMu.Unlock()
+
+	gpuReserved -= min(bytes, gpuReserved)
+}
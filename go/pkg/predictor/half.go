@@ -0,0 +1,76 @@
+package predictor
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// float32ToHalf converts f to an IEEE 754 half-precision value, rounding
+// to nearest even. Values too large for a half become infinities.
+func float32ToHalf(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23&0xff) - 127 + 15
+	mant := b & 0x7fffff
+
+	switch {
+	case b&0x7fffffff > 0x7f800000:
+		return sign | 0x7e00 // NaN
+	case exp >= 0x1f:
+		return sign | 0x7c00
+	case exp <= 0:
+		// Subnormal, or zero if even rounding can't reach the smallest
+		if exp < -10 {
+			return sign
+		}
+		return sign | roundShift(mant|0x800000, uint(14-exp))
+	}
+	// A carry out of the mantissa correctly bumps the exponent
+	return sign | (uint16(exp)<<10 + roundShift(mant, 13))
+}
+
+// roundShift returns v>>shift rounded to nearest even.
+func roundShift(v uint32, shift uint) uint16 {
+	h := v >> shift
+	rem := v & (1<<shift - 1)
+	half := uint32(1) << (shift - 1)
+	if rem > half || rem == half && h&1 == 1 {
+		h++
+	}
+	return uint16(h)
+}
+
+// halfToFloat32 converts an IEEE 754 half-precision value to float32,
+// which represents every half exactly.
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+	switch exp {
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	case 0:
+		f := float32(mant) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+// encodeHalf writes src to dst as halves in the machine's byte order, as
+// ONNX Runtime reads tensor data. dst must hold 2*len(src) bytes.
+func encodeHalf(dst []byte, src []float32) {
+	for i, f := range src {
+		binary.NativeEndian.PutUint16(dst[2*i:], float32ToHalf(f))
+	}
+}
+
+// decodeHalf converts the halves in src to dst, which must hold
+// len(src)/2 values.
+func decodeHalf(dst []float32, src []byte) {
+	for i := range dst {
+		dst[i] = halfToFloat32(binary.NativeEndian.Uint16(src[2*i:]))
+	}
+}
@@ -0,0 +1,29 @@
+package predictor
+
+// ModelInfo describes a model file as ONNX Runtime sees it.
+type ModelInfo struct {
+	Producer    string            `json:"producer,omitempty"`
+	Graph       string            `json:"graph,omitempty"`
+	Domain      string            `json:"domain,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Version     int64             `json:"version"`
+	Custom      map[string]string `json:"custom,omitempty"`
+	Inputs      []TensorInfo      `json:"inputs"`
+	Outputs     []TensorInfo      `json:"outputs"`
+}
+
+// TensorInfo is a model input or output. Dynamic dimensions are -1.
+type TensorInfo struct {
+	Name  string  `json:"name"`
+	Type  string  `json:"type"`
+	Shape []int64 `json:"shape"`
+}
+
+// RuntimeInfo describes the ONNX Runtime library the default backend
+// loads.
+type RuntimeInfo struct {
+	Version string `json:"version"`
+	// Providers lists the execution providers usable in this process,
+	// ProviderCPU first.
+	Providers []string `json:"providers"`
+}
@@ -0,0 +1,89 @@
+//go:build cgo
+
+package predictor
+
+import (
+	"fmt"
+
+	"github.com/yalue/onnxruntime_go"
+)
+
+// Inspect reads the metadata, inputs and outputs of the model at
+// modelPath without creating a session.
+func Inspect(modelPath string) (*ModelInfo, error) {
+	if err := initEnvironment(); err != nil {
+		return nil, err
+	}
+	inputs, outputs, err := onnxruntime_go.GetInputOutputInfo(modelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model metadata: %v", err)
+	}
+	meta, err := onnxruntime_go.GetModelMetadata(modelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model metadata: %v", err)
+	}
+	defer meta.Destroy()
+
+	info := &ModelInfo{Inputs: tensorInfos(inputs), Outputs: tensorInfos(outputs)}
+	// Missing fields are left empty rather than failing the whole report
+	info.Producer, _ = meta.GetProducerName()
+	info.Graph, _ = meta.GetGraphName()
+	info.Domain, _ = meta.GetDomain()
+	info.Description, _ = meta.GetDescription()
+	info.Version, _ = meta.GetVersion()
+	if keys, err := meta.GetCustomMetadataMapKeys(); err == nil && len(keys) > 0 {
+		info.Custom = make(map[string]string, len(keys))
+		for _, key := range keys {
+			if value, ok, err := meta.LookupCustomMetadataMap(key); err == nil && ok {
+				info.Custom[key] = value
+			}
+		}
+	}
+	return info, nil
+}
+
+func tensorInfos(infos []onnxruntime_go.InputOutputInfo) []TensorInfo {
+	tensors := make([]TensorInfo, len(infos))
+	for i, in := range infos {
+		tensors[i] = TensorInfo{Name: in.Name, Type: in.DataType.String(), Shape: []int64(in.Dimensions)}
+	}
+	return tensors
+}
+
+// inspectModel reads the model's input and output metadata. It works out
+// whether the input is NCHW or NHWC and how many channels it has, the
+// number of output classes and which tensors are float16, as in fp16
+// model variants. Dynamic dimensions are reported as -1 by ONNX Runtime.
+// When data is non-nil it holds the model's bytes and modelPath is not
+// read.
+func inspectModel(modelPath string, data []byte) (inputLayout, outputInfo, error) {
+	var inputs, outputs []onnxruntime_go.InputOutputInfo
+	var err error
+	if data != nil {
+		inputs, outputs, err = onnxruntime_go.GetInputOutputInfoWithONNXData(data)
+	} else {
+		inputs, outputs, err = onnxruntime_go.GetInputOutputInfo(modelPath)
+	}
+	if err != nil {
+		return inputLayout{}, outputInfo{}, fmt.Errorf("failed to read model metadata: %v", err)
+	}
+	if len(inputs) == 0 {
+		return inputLayout{}, outputInfo{}, fmt.Errorf("model has no inputs")
+	}
+
+	layout, err := layoutFromDims(inputs[0].Dimensions)
+	if err != nil {
+		return inputLayout{}, outputInfo{}, err
+	}
+	layout.Float16 = inputs[0].DataType == onnxruntime_go.TensorElementDataTypeFloat16
+
+	var output outputInfo
+	if len(outputs) > 0 {
+		dims := outputs[0].Dimensions
+		if len(dims) > 0 && dims[len(dims)-1] > 0 {
+			output.Classes = int(dims[len(dims)-1])
+		}
+		output.Float16 = outputs[0].DataType == onnxruntime_go.TensorElementDataTypeFloat16
+	}
+	return layout, output, nil
+}
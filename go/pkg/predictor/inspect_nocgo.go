@@ -0,0 +1,12 @@
+//go:build !cgo
+
+package predictor
+
+import (
+	"fmt"
+)
+
+// Inspect reads a model's metadata through ONNX Runtime, which needs cgo.
+func Inspect(modelPath string) (*ModelInfo, error) {
+	return nil, fmt.Errorf("inspecting models needs the ONNX Runtime backend: rebuild with CGO_ENABLED=1")
+}
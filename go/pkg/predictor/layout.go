@@ -0,0 +1,71 @@
+package predictor
+
+import (
+	"fmt"
+)
+
+const defaultHeight = 64
+
+// inputLayout describes the tensor layout the recognition model expects.
+type inputLayout struct {
+	Channels     int
+	ChannelsLast bool
+	Height       int
+	// Batched is set when the batch dimension isn't fixed at 1, so
+	// several lines can run in one tensor.
+	Batched bool
+	// Float16 is set when the input holds half-precision values
+	Float16 bool
+}
+
+// outputInfo describes the recognition model's output tensor.
+type outputInfo struct {
+	// Classes is the output class count, or 0 if the dimension is dynamic
+	Classes int
+	// Float16 is set when the output holds half-precision scores
+	Float16 bool
+}
+
+// defaultLayout matches the published monocr model: [N, 1, 64, W].
+var defaultLayout = inputLayout{Channels: 1, Height: defaultHeight}
+
+// Shape returns the input tensor shape for an image of the given width.
+func (l inputLayout) Shape(width int) []int64 {
+	if l.ChannelsLast {
+		return []int64{1, int64(l.Height), int64(width), int64(l.Channels)}
+	}
+	return []int64{1, int64(l.Channels), int64(l.Height), int64(width)}
+}
+
+// layoutFromDims works out the layout from the input's dimensions, with
+// -1 for dynamic ones.
+func layoutFromDims(dims []int64) (inputLayout, error) {
+	if len(dims) != 4 {
+		return inputLayout{}, fmt.Errorf("unsupported input rank %d, expected 4 (%v)", len(dims), dims)
+	}
+
+	isChannels := func(d int64) bool { return d == 1 || d == 3 }
+
+	layout := defaultLayout
+	layout.Batched = dims[0] != 1
+	switch {
+	case isChannels(dims[1]):
+		// [N, C, H, W]
+		layout.Channels = int(dims[1])
+		if dims[2] > 0 {
+			layout.Height = int(dims[2])
+		}
+	case isChannels(dims[3]):
+		// [N, H, W, C]
+		layout.Channels = int(dims[3])
+		layout.ChannelsLast = true
+		if dims[1] > 0 {
+			layout.Height = int(dims[1])
+		}
+	case dims[1] < 0 && dims[3] < 0:
+		// Fully dynamic; assume the default grayscale NCHW layout.
+	default:
+		return inputLayout{}, fmt.Errorf("unsupported input shape %v: expected 1 or 3 channels", dims)
+	}
+	return layout, nil
+}
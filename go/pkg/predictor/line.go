@@ -0,0 +1,202 @@
+package predictor
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"unicode/utf8"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/imgproc"
+	"golang.org/x/image/draw"
+)
+
+// lineModel holds what every backend needs around the model itself:
+// scaling line images to its input and decoding its CTC output.
+type lineModel struct {
+	charset string
+	// classes is the model's output class count, or 0 if it is dynamic
+	classes int
+	layout  inputLayout
+	scaler  draw.Scaler
+	// lm rescores beam search with WithLanguageModel, or is nil for
+	// greedy decoding
+	lm *lmFusion
+}
+
+// newLineModel checks charset, extended by WithExtraChars, against the
+// model's classes and returns the lineModel for it.
+func newLineModel(charset string, classes int, layout inputLayout, cfg *config) (lineModel, error) {
+	charset += cfg.extraChars
+	if want := utf8.RuneCountInString(charset) + 1; classes > 0 && want != classes {
+		switch {
+		case cfg.extraChars != "":
+			return lineModel{}, fmt.Errorf("charset mismatch: extended charset has %d characters (%d classes including blank) but the model outputs %d classes", want-1, want, classes)
+		case want < classes:
+			return lineModel{}, fmt.Errorf("charset mismatch: charset has %d characters (%d classes including blank) but the model outputs %d classes; map the extra classes with WithExtraChars", want-1, want, classes)
+		}
+		return lineModel{}, fmt.Errorf("charset mismatch: charset has %d characters (%d classes including blank) but the model outputs %d classes", want-1, want, classes)
+	}
+	m := lineModel{charset: charset, classes: classes, layout: layout, scaler: cfg.interpolation.scaler()}
+	if cfg.lm != nil {
+		m.lm = &lmFusion{lm: cfg.lm, weight: cfg.lmWeight}
+	}
+	return m, nil
+}
+
+// ExtendCharset appends extra characters to the charset, mapping the
+// model's additional output classes (e.g. from fine-tuning with Mon digits
+// or punctuation) in order. The resulting class count must match the
+// model's output dimension when it is known, which the charset already
+// does once the predictor exists, so it only extends models with a
+// dynamic class count; pass extra characters for the others with
+// WithExtraChars. Call it before recognizing any line.
+func (m *lineModel) ExtendCharset(extra string) error {
+	charset := m.charset + extra
+	if m.classes > 0 {
+		if want := utf8.RuneCountInString(charset) + 1; want != m.classes {
+			return fmt.Errorf("charset mismatch: extended charset has %d characters (%d classes including blank) but the model outputs %d classes", want-1, want, m.classes)
+		}
+	}
+	m.charset = charset
+	return nil
+}
+
+// spanChars maps decoded characters from timesteps to columns of img.
+func (m *lineModel) spanChars(img image.Image, decoded []decodedChar, seqLen int) []Char {
+	bounds := img.Bounds()
+	column := func(t int) int {
+		return bounds.Min.X + t*bounds.Dx()/seqLen
+	}
+	chars := make([]Char, len(decoded))
+	for i, c := range decoded {
+		chars[i] = Char{
+			Text:       string(c.r),
+			Confidence: c.prob,
+			Span:       Span{Start: column(c.start), End: column(c.end)},
+		}
+	}
+	return chars
+}
+
+// targetWidth returns the width img is scaled to so its height matches
+// the model's input while keeping the aspect ratio.
+func (m *lineModel) targetWidth(img image.Image) (int, error) {
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return 0, fmt.Errorf("empty image")
+	}
+	aspectRatio := float64(bounds.Dx()) / float64(bounds.Dy())
+	return max(1, int(math.Round(float64(m.layout.Height)*aspectRatio))), nil
+}
+
+// Preprocess converts img into the model's input tensor without running
+// inference, returning the flattened data and its shape. It is exposed so
+// the preprocessing can be compared with the Python training pipeline;
+// it always runs on the CPU, even with WithGPUPreprocessing.
+func (m *lineModel) Preprocess(img image.Image) ([]float32, []int64, error) {
+	targetWidth, err := m.targetWidth(img)
+	if err != nil {
+		return nil, nil, err
+	}
+	targetHeight := m.layout.Height
+	shape := m.layout.Shape(targetWidth)
+
+	if m.layout.Channels == 1 {
+		// Resize using the configured resampling filter
+		dst := image.NewGray(image.Rect(0, 0, targetWidth, targetHeight))
+		m.scaler.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+
+		// Normalize 0-255 -> 0.0-1.0
+		inputData := make([]float32, targetWidth*targetHeight)
+		imgproc.Normalize(inputData, dst.Pix)
+		return inputData, shape, nil
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	m.scaler.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+
+	// RGBA pixels -> planar (NCHW) or interleaved (NHWC) RGB
+	plane := targetWidth * targetHeight
+	inputData := make([]float32, plane*3)
+	for i := 0; i < plane; i++ {
+		for c := 0; c < 3; c++ {
+			v := float32(dst.Pix[i*4+c]) / 255.0
+			if m.layout.ChannelsLast {
+				inputData[i*3+c] = v
+			} else {
+				inputData[c*plane+i] = v
+			}
+		}
+	}
+	return inputData, shape, nil
+}
+
+func (m *lineModel) decode(preds []float32) string {
+	text, _ := m.decodeWithConfidence(preds)
+	return text
+}
+
+func (m *lineModel) decodeWithConfidence(preds []float32) (string, float64) {
+	return joinChars(m.decodeChars(preds))
+}
+
+// decodeChars runs greedy CTC decoding over preds, or beam search with
+// WithLanguageModel. It also returns the sequence length and the summed
+// max probability over all timesteps.
+func (m *lineModel) decodeChars(preds []float32) ([]decodedChar, int, float64) {
+	if m.lm != nil {
+		return decodeBeam(preds, []rune(m.charset), nil, m.lm)
+	}
+	prevIdx := -1
+
+	// numClasses = charset + blank
+	numClasses := utf8.RuneCountInString(m.charset) + 1
+	seqLen := len(preds) / numClasses
+
+	// Charset array for lookup (runes)
+	charsetRunes := []rune(m.charset)
+
+	var chars []decodedChar
+	var allSum float64
+
+	for t := 0; t < seqLen; t++ {
+		row := preds[t*numClasses : (t+1)*numClasses]
+		maxVal := float32(-math.MaxFloat32)
+		maxIdx := 0
+
+		for c, val := range row {
+			if val > maxVal {
+				maxVal = val
+				maxIdx = c
+			}
+		}
+
+		prob := maxProb(row, maxVal)
+		allSum += prob
+
+		if maxIdx != 0 && maxIdx == prevIdx && len(chars) > 0 {
+			// A repeat extends the character it continues
+			chars[len(chars)-1].end = t + 1
+		} else if maxIdx != 0 {
+			// maxIdx 0 is blank
+			// maxIdx 1..N maps to charset[0..N-1]
+			charIdx := maxIdx - 1
+			if charIdx < len(charsetRunes) {
+				chars = append(chars, decodedChar{r: charsetRunes[charIdx], prob: prob, start: t, end: t + 1})
+			}
+		}
+		prevIdx = maxIdx
+	}
+
+	return chars, seqLen, allSum
+}
+
+// maxProb returns the softmax probability of the highest score in row.
+// Works for both raw logits and log-probabilities.
+func maxProb(row []float32, maxVal float32) float64 {
+	var sum float64
+	for _, v := range row {
+		sum += math.Exp(float64(v - maxVal))
+	}
+	return 1 / sum
+}
@@ -0,0 +1,43 @@
+package predictor
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// benchLine draws a 1500x100 line of dark strokes on a light background,
+// about the size of a line segmented from a 300 DPI page.
+func benchLine() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 1500, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 1500; x++ {
+			c := color.NRGBA{R: 245, G: 243, B: 240, A: 255}
+			if y > 20 && y < 80 && x%37 < 6 && (x/37+y/10)%3 != 0 {
+				c = color.NRGBA{R: 20, G: 22, B: 25, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// BenchmarkPreprocess measures scaling a line to the model's input with
+// each interpolation filter.
+func BenchmarkPreprocess(b *testing.B) {
+	img := benchLine()
+	for _, filter := range []Interpolation{CatmullRom, Bilinear, NearestNeighbor} {
+		b.Run(filter.String(), func(b *testing.B) {
+			m, err := newLineModel("", 0, defaultLayout, &config{interpolation: filter})
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			for range b.N {
+				if _, _, err := m.Preprocess(img); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
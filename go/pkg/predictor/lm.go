@@ -0,0 +1,329 @@
+package predictor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// LanguageModel scores text during decoding. With WithLanguageModel the
+// predictor decodes by beam search and prefers readings the language
+// model finds likely, which corrects confusions between similar-looking
+// characters that the image alone doesn't settle.
+type LanguageModel interface {
+	// Score returns the natural log probability of r following prefix.
+	// For models over longer units than characters, it may be the change
+	// in the log probability of the units prefix completes, positive or
+	// negative, as long as the scores of a text's runes sum to its score.
+	Score(prefix []rune, r rune) float64
+}
+
+// DefaultLMWeight is a language model weight that corrects common
+// confusions without overriding clear readings.
+const DefaultLMWeight = 0.3
+
+// WithLanguageModel decodes by beam search rescored with lm: each reading
+// is ranked by its log probability under the recognition model plus
+// weight times lm's score, so weight trades the image evidence against
+// the language model. Beam search is slower than the default greedy
+// decoding. See LoadNGram for n-gram models.
+func WithLanguageModel(lm LanguageModel, weight float64) Option {
+	return func(c *config) {
+		c.lm = lm
+		c.lmWeight = weight
+	}
+}
+
+// lmFusion is a language model with its weight.
+type lmFusion struct {
+	lm     LanguageModel
+	weight float64
+}
+
+// score returns the weighted score of r following prefix, or 0 without a
+// language model.
+func (f *lmFusion) score(prefix []rune, r rune) float64 {
+	if f == nil {
+		return 0
+	}
+	return f.weight * f.lm.Score(prefix, r)
+}
+
+// NGram is a back-off n-gram language model over characters or
+// syllables, read from the ARPA format that tools like KenLM and SRILM
+// write. Its units are syllables if any unigram is longer than one
+// character: train it on text with syllables separated by spaces, as
+// Syllables splits them. Character models write spaces as <space>;
+// syllable models skip them, so they score the syllables on either side
+// of a space as neighbors.
+type NGram struct {
+	order     int
+	syllables bool
+	// entries maps space-joined n-grams to their log probability and
+	// back-off weight, converted to natural logs.
+	entries map[string]ngramEntry
+	// unknown is the log probability of units the model hasn't seen.
+	unknown float64
+}
+
+type ngramEntry struct {
+	prob, backoff float64
+}
+
+// Markers in ARPA files. Words can't contain spaces, so character
+// models write them as <space>.
+const (
+	arpaStart   = "<s>"
+	arpaEnd     = "</s>"
+	arpaUnknown = "<unk>"
+	arpaSpace   = "<space>"
+)
+
+// unknownLogProb is the log probability of unseen units when the model
+// has no <unk>: low enough that they never win on the model's account.
+const unknownLogProb = -10 * math.Ln10
+
+// LoadNGram reads an ARPA n-gram model from path.
+func LoadNGram(path string) (*NGram, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	lm, err := ReadNGram(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return lm, nil
+}
+
+// ReadNGram reads an ARPA n-gram model.
+func ReadNGram(r io.Reader) (*NGram, error) {
+	lm := &NGram{entries: map[string]ngramEntry{}, unknown: unknownLogProb}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	n := 0 // the order of the section being read, 0 before the first
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line == `\data\` || strings.HasPrefix(line, "ngram "):
+			continue
+		case line == `\end\`:
+			n = -1
+			continue
+		case strings.HasPrefix(line, `\`) && strings.HasSuffix(line, "-grams:"):
+			order, err := strconv.Atoi(strings.TrimSuffix(line[1:], "-grams:"))
+			if err != nil || order < 1 {
+				return nil, fmt.Errorf("line %d: bad section %q", lineNo, line)
+			}
+			n = order
+			lm.order = max(lm.order, n)
+			continue
+		}
+		if n <= 0 {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != n+1 && len(fields) != n+2 {
+			return nil, fmt.Errorf("line %d: expected %d words in a %d-gram", lineNo, n, n)
+		}
+		prob, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad probability %q", lineNo, fields[0])
+		}
+		e := ngramEntry{prob: prob * math.Ln10}
+		if len(fields) == n+2 {
+			backoff, err := strconv.ParseFloat(fields[n+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad back-off weight %q", lineNo, fields[n+1])
+			}
+			e.backoff = backoff * math.Ln10
+		}
+		words := fields[1 : n+1]
+		lm.entries[strings.Join(words, " ")] = e
+
+		if n == 1 {
+			switch w := words[0]; w {
+			case arpaUnknown:
+				lm.unknown = e.prob
+			default:
+				if !strings.HasPrefix(w, "<") && utf8.RuneCountInString(w) > 1 {
+					lm.syllables = true
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if lm.order == 0 {
+		return nil, fmt.Errorf("no n-grams found")
+	}
+	return lm, nil
+}
+
+// Order returns the longest n-gram the model has.
+func (lm *NGram) Order() int { return lm.order }
+
+// Syllables reports whether the model's units are syllables rather than
+// characters.
+func (lm *NGram) Syllables() bool { return lm.syllables }
+
+// lmContext is how many runes before the end of a prefix NGram looks at
+// to score syllables: enough for several syllables of history.
+const lmContext = 48
+
+// Score implements LanguageModel. A character model scores r given the
+// characters before it. A syllable model scores a syllable once the next
+// one starts, so Score is the change r makes to the score of prefix's
+// complete syllables, which is 0 until a syllable is known to be complete.
+func (lm *NGram) Score(prefix []rune, r rune) float64 {
+	if !lm.syllables {
+		history := make([]string, 0, lm.order)
+		if len(prefix) < lm.order-1 {
+			history = append(history, arpaStart)
+		}
+		for i := max(len(prefix)-(lm.order-1), 0); i < len(prefix); i++ {
+			history = append(history, charUnit(prefix[i]))
+		}
+		return lm.prob(history, charUnit(r))
+	}
+
+	// Syllables are found from nearby runes only, so a window of the
+	// prefix scores the same change as all of it, provided its cut first
+	// syllable is left out
+	start := max(len(prefix)-lmContext, 0)
+	text := append(prefix[start:len(prefix):len(prefix)], r)
+	return lm.completeScore(text, start > 0) - lm.completeScore(text[:len(text)-1], start > 0)
+}
+
+// completeScore returns the log probability of text's complete
+// syllables. The last syllable may still grow, and if it is a lone
+// consonant an asat or virama may yet join it to the one before, so
+// neither counts until the text goes on. With cut, text starts partway
+// into a syllable, which only serves as history.
+func (lm *NGram) completeScore(text []rune, cut bool) float64 {
+	var units []string
+	for _, u := range Syllables(string(text)) {
+		if strings.TrimSpace(u) != "" {
+			units = append(units, u)
+		}
+	}
+	complete := len(units) - 1
+	if complete > 0 && utf8.RuneCountInString(units[complete]) == 1 {
+		complete--
+	}
+
+	var history []string
+	if !cut {
+		history = []string{arpaStart}
+	}
+	var score float64
+	for i := 0; i < complete; i++ {
+		if i > 0 || !cut {
+			score += lm.prob(history, units[i])
+		}
+		history = append(history, units[i])
+	}
+	return score
+}
+
+// charUnit returns the ARPA word for r in a character model.
+func charUnit(r rune) string {
+	if r == ' ' {
+		return arpaSpace
+	}
+	return string(r)
+}
+
+// prob returns the log probability of unit following history, backing
+// off to shorter histories by their back-off weights.
+func (lm *NGram) prob(history []string, unit string) float64 {
+	if len(history) > lm.order-1 {
+		history = history[len(history)-(lm.order-1):]
+	}
+	var backoff float64
+	for i := 0; i <= len(history); i++ {
+		context := history[i:]
+		if e, ok := lm.entries[strings.Join(append(context[:len(context):len(context)], unit), " ")]; ok {
+			return backoff + e.prob
+		}
+		if len(context) > 0 {
+			// A context the model doesn't have weighs nothing
+			backoff += lm.entries[strings.Join(context, " ")].backoff
+		}
+	}
+	return backoff + lm.unknown
+}
+
+// Syllables splits text into syllables by the usual rule for the Myanmar
+// script that Mon is written in: a syllable starts at each consonant,
+// independent vowel or digit, except for a consonant stacked under the
+// one before (after a virama) or killed by a following asat or virama.
+// Spaces and runes outside the script stand on their own.
+func Syllables(text string) []string {
+	runes := []rune(text)
+	var units []string
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i < len(runes) && !syllableBreak(runes, i) {
+			continue
+		}
+		units = append(units, string(runes[start:i]))
+		start = i
+	}
+	return units
+}
+
+// Myanmar script code points the syllable rule looks at.
+const (
+	myanmarVirama = '္'
+	myanmarAsat   = '်'
+)
+
+// syllableBreak reports whether a syllable starts at runes[i].
+func syllableBreak(runes []rune, i int) bool {
+	r, prev := runes[i], runes[i-1]
+	if !isMyanmar(r) || !isMyanmar(prev) {
+		return true
+	}
+	if !startsSyllable(r) || prev == myanmarVirama {
+		return false
+	}
+	if i+1 < len(runes) && (runes[i+1] == myanmarAsat || runes[i+1] == myanmarVirama) {
+		return false
+	}
+	return true
+}
+
+func isMyanmar(r rune) bool {
+	return r >= 0x1000 && r <= 0x109F || r >= 0xAA60 && r <= 0xAA7F || r >= 0xA9E0 && r <= 0xA9FF
+}
+
+// startsSyllable reports whether r can begin a syllable: a consonant, an
+// independent vowel, a digit or a sign of its own like the section mark.
+func startsSyllable(r rune) bool {
+	switch {
+	case r >= 0x1000 && r <= 0x102A, // consonants and independent vowels
+		r >= 0x103F && r <= 0x104F, // great sa, digits and punctuation
+		r >= 0x1050 && r <= 0x1055, // Pali and Mon extra letters
+		r >= 0x105A && r <= 0x105D, // Mon consonants
+		r == 0x1061, r == 0x1065, r == 0x1066,
+		r >= 0x106E && r <= 0x1070,
+		r >= 0x1075 && r <= 0x1081, // Shan consonants
+		r == 0x108E,
+		r >= 0x1090 && r <= 0x1099,                                        // Shan digits
+		r >= 0xAA60 && r <= 0xAA76, r == 0xAA7A, r == 0xAA7E, r == 0xAA7F, // Extended-A
+		r >= 0xA9E0 && r <= 0xA9E4, r >= 0xA9E7 && r <= 0xA9FE: // Extended-B
+		return true
+	}
+	return false
+}
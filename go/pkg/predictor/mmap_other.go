@@ -0,0 +1,16 @@
+//go:build !unix
+
+package predictor
+
+import (
+	"os"
+)
+
+// mapModel falls back to reading the file where mmap is unavailable.
+func mapModel(path string) ([]byte, func(), error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() {}, nil
+}
@@ -1,3 +1,5 @@
+//go:build cgo
+
 package predictor
 
 import (
@@ -5,68 +7,188 @@ import (
 	"image"
 	_ "image/jpeg"
 	_ "image/png"
-	"math"
 	"os"
-	"runtime"
 	"unicode/utf8"
 
 	"github.com/yalue/onnxruntime_go"
-	"golang.org/x/image/draw"
 )
 
+// Predictor runs a CTC line recognition model with ONNX Runtime. It is the
+// default Recognizer.
 type Predictor struct {
+	lineModel
 	session *onnxruntime_go.DynamicAdvancedSession
-	charset string
+	output  outputInfo
+	// widthStep is the width lines are padded to a multiple of so pool
+	// can reuse their tensors; pool is nil when caching is off.
+	widthStep int
+	pool      *widthPool
+	// provider is the execution provider the session runs on; gpuBudget
+	// is the share of the GPU memory budget it holds, in bytes.
+	provider  string
+	gpuBudget uint64
+	// gpuPrep scales line images on the GPU with WithGPUPreprocessing
+	gpuPrep *gpuPreprocessor
 }
 
-func NewPredictor(modelPath, charset string) (*Predictor, error) {
-	// Initialize ONNX Runtime environment if not already initialized
-	// Note: SetSharedLibraryPath might be needed depending on system
-	// For now we assume the default or system library is available
-	if !onnxruntime_go.IsInitialized() {
-		// Try to find libonnxruntime on macOS if not set
-		if runtime.GOOS == "darwin" {
-			// Common Homebrew path
-			libPath := "/opt/homebrew/lib/libonnxruntime.dylib"
-			if _, err := os.Stat(libPath); err == nil {
-				onnxruntime_go.SetSharedLibraryPath(libPath)
-			} else {
-				// Fallback or check another location if needed
+func NewPredictor(modelPath, charset string, opts ...Option) (*Predictor, error) {
+	cfg := config{provider: ProviderCPU}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	// The environment is shared by every Predictor in the process
+	if err := initEnvironment(); err != nil {
+		return nil, err
+	}
+
+	// A mapped model is paged in from the file on demand instead of being
+	// read into memory up front; ORT builds its session from the mapping,
+	// which is released once the session exists.
+	var data []byte
+	if cfg.mmap {
+		mapped, unmap, err := mapModel(modelPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to map model: %v", err)
+		}
+		defer unmap()
+		data = mapped
+	}
+
+	layout, output, err := inspectModel(modelPath, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// A charset that doesn't match the model silently shifts every
+	// character, so refuse to run rather than produce wrong text.
+	line, err := newLineModel(charset, output.Classes, layout, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Float16 scores are cast to float32 in the graph; see castOutput
+	outputName := "output"
+	if output.Float16 {
+		if data == nil {
+			if data, err = os.ReadFile(modelPath); err != nil {
+				return nil, fmt.Errorf("failed to read model: %v", err)
 			}
 		}
+		if data, err = castOutput(data); err != nil {
+			return nil, err
+		}
+		output.Float16 = false
+		outputName = float32Output
+	}
 
-		if err := onnxruntime_go.InitializeEnvironment(); err != nil {
-			// Check if we can find the library from JS SDK node_modules as a fallback
-			return nil, fmt.Errorf("failed to initialize ONNX Runtime: %v. Make sure libonnxruntime.dylib (macOS) or libonnxruntime.so (Linux) is in your library path", err)
+	provider := cfg.provider
+	session, gpuBudget, err := newSession(modelPath, data, outputName, &cfg, provider)
+	if err != nil && provider != ProviderCPU {
+		// Without a usable accelerator, run on the CPU rather than fail
+		fmt.Fprintf(os.Stderr, "%s unavailable (%v), running on the CPU\n", providerName(provider), err)
+		provider = ProviderCPU
+		session, gpuBudget, err = newSession(modelPath, data, outputName, &cfg, provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Predictor{
+		lineModel: line,
+		session:   session,
+		provider:  provider,
+		gpuBudget: gpuBudget,
+		output:    output,
+		widthStep: cfg.widthBucket,
+	}
+	if cfg.widthBucket > 0 {
+		p.pool = newWidthPool(layout.Float16)
+	}
+	if cfg.gpuPreprocess && (provider == ProviderCUDA || provider == ProviderTensorRT) && layout.Channels == 1 {
+		p.gpuPrep, err = newGPUPreprocessor(cfg.interpolation, cfg.cudaDevice)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "GPU preprocessing unavailable (%v), preprocessing on the CPU\n", err)
 		}
 	}
+	return p, nil
+}
 
+// newSession creates an ONNX Runtime session for the model running on
+// provider, reading its scores from outputName. It returns the bytes of
+// the GPU memory budget the session reserved.
+func newSession(modelPath string, data []byte, outputName string, cfg *config, provider string) (*onnxruntime_go.DynamicAdvancedSession, uint64, error) {
 	options, err := onnxruntime_go.NewSessionOptions()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session options: %v", err)
+		return nil, 0, fmt.Errorf("failed to create session options: %v", err)
 	}
 	defer options.Destroy()
+	if cfg.intraThreads > 0 {
+		if err := options.SetIntraOpNumThreads(cfg.intraThreads); err != nil {
+			return nil, 0, fmt.Errorf("failed to set intra-op threads: %v", err)
+		}
+	}
+	if cfg.interThreads > 0 {
+		if err := options.SetInterOpNumThreads(cfg.interThreads); err != nil {
+			return nil, 0, fmt.Errorf("failed to set inter-op threads: %v", err)
+		}
+	}
+	var gpuBudget uint64
+	switch provider {
+	case ProviderCUDA:
+		gpuBudget, err = appendCUDA(options, cfg.cudaDevice)
+	case ProviderTensorRT:
+		gpuBudget, err = appendTensorRT(options, cfg.cudaDevice, cfg.trtCacheDir)
+	case ProviderCoreML:
+		err = appendCoreML(options)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
 
 	inputs := []string{"input"}
-	outputs := []string{"output"}
+	outputs := []string{outputName}
 
-	session, err := onnxruntime_go.NewDynamicAdvancedSession(
-		modelPath,
-		inputs,
-		outputs,
-		options,
-	)
+	var session *onnxruntime_go.DynamicAdvancedSession
+	if data != nil {
+		session, err = onnxruntime_go.NewDynamicAdvancedSessionWithONNXData(
+			data,
+			inputs,
+			outputs,
+			options,
+		)
+	} else {
+		session, err = onnxruntime_go.NewDynamicAdvancedSession(
+			modelPath,
+			inputs,
+			outputs,
+			options,
+		)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %v", err)
+		releaseGPUMemory(gpuBudget)
+		return nil, 0, fmt.Errorf("failed to create session: %v", err)
 	}
+	return session, gpuBudget, nil
+}
 
-	return &Predictor{
-		session: session,
-		charset: charset,
-	}, nil
+// Provider returns the execution provider the model runs on: the one
+// requested with WithCUDA or WithCoreML, or ProviderCPU when none was or
+// it isn't available.
+func (p *Predictor) Provider() string {
+	return p.provider
 }
 
 func (p *Predictor) Close() error {
+	if p.pool != nil {
+		p.pool.close()
+	}
+	if p.gpuPrep != nil {
+		p.gpuPrep.close()
+		p.gpuPrep = nil
+	}
+	releaseGPUMemory(p.gpuBudget)
+	p.gpuBudget = 0
 	if p.session != nil {
 		return p.session.Destroy()
 	}
@@ -74,110 +196,154 @@ func (p *Predictor) Close() error {
 }
 
 func (p *Predictor) Predict(img image.Image) (string, error) {
-	inputData, h, w, err := p.preprocess(img)
+	preds, err := p.run(img)
 	if err != nil {
 		return "", err
 	}
+	return p.decode(preds), nil
+}
 
-	// Correct usage of NewTensor based on original code and common usage
-	// It seems NewTensor takes shape []int64, then data
-	shape := []int64{1, 1, int64(h), int64(w)}
-	inputTensor, err := onnxruntime_go.NewTensor(shape, inputData)
+// PredictWithConfidence recognizes a line image and also returns the mean
+// probability of the emitted characters, in the range [0, 1].
+func (p *Predictor) PredictWithConfidence(img image.Image) (string, float64, error) {
+	preds, err := p.run(img)
 	if err != nil {
-		return "", fmt.Errorf("failed to create input tensor: %v", err)
+		return "", 0, err
 	}
-	defer inputTensor.Destroy()
-
-	// Run expects []Value, so we need to copy inputTensor into a []Value slice
-	inputValues := []onnxruntime_go.Value{inputTensor}
-	outputValues := make([]onnxruntime_go.Value, 1)
+	text, conf := p.decodeWithConfidence(preds)
+	return text, conf, nil
+}
 
-	err = p.session.Run(inputValues, outputValues)
+// PredictSpans recognizes a line image and returns, for each rune of the
+// text, the columns it was read from. Positions come from the CTC
+// timesteps, so they are approximate to within one timestep's width.
+func (p *Predictor) PredictSpans(img image.Image) (string, []Span, error) {
+	text, _, chars, err := p.PredictChars(img)
 	if err != nil {
-		return "", fmt.Errorf("inference failed: %v", err)
+		return "", nil, err
 	}
-
-	outputTensor := outputValues[0]
-	if outputTensor == nil {
-		return "", fmt.Errorf("output tensor is nil")
+	spans := make([]Span, len(chars))
+	for i, c := range chars {
+		spans[i] = c.Span
 	}
-	// outputTensor is a Value, we need to assert it to Tensor to GetData
-	defer outputTensor.Destroy()
+	return text, spans, nil
+}
 
-	// Assuming output is float32 tensor
-	// We need to type assert or use GetData() on the specific tensor type if generic
-	// Let's assume output[0] is *Tensor[float32] which implements Value?
-	// Actually NewDynamicAdvancedSession.Run returns []Value.
-	// We might need to cast output[0] via interface check or assume it's Tensor[float32]
+// PredictChars recognizes a line image and returns the text, the line's
+// mean confidence and every character with its own confidence and span.
+func (p *Predictor) PredictChars(img image.Image) (string, float64, []Char, error) {
+	preds, err := p.run(img)
+	if err != nil {
+		return "", 0, nil, err
+	}
 
-	// In original code: outputTensor := outputInfo[0]; preds := outputTensor.GetData()
-	// But that was checking return of Run?
+	decoded, seqLen, blankSum := p.decodeChars(preds)
+	text, conf := joinChars(decoded, seqLen, blankSum)
+	return text, conf, p.spanChars(img, decoded, seqLen), nil
+}
 
-	// Let's check the type assertion
-	outTensorFloat, ok := outputTensor.(*onnxruntime_go.Tensor[float32])
-	if !ok {
-		return "", fmt.Errorf("unexpected output tensor type")
+// PredictConstrained is PredictChars reading only text c allows: the
+// most likely text c accepts as complete, or the most likely text it
+// allows so far when the line holds nothing complete.
+func (p *Predictor) PredictConstrained(img image.Image, c Constraint) (string, float64, []Char, error) {
+	preds, err := p.run(img)
+	if err != nil {
+		return "", 0, nil, err
 	}
 
-	return p.decode(outTensorFloat.GetData()), nil
+	decoded, seqLen, blankSum := decodeBeam(preds, []rune(p.charset), c, p.lm)
+	text, conf := joinChars(decoded, seqLen, blankSum)
+	return text, conf, p.spanChars(img, decoded, seqLen), nil
 }
 
-func (p *Predictor) preprocess(img image.Image) ([]float32, int, int, error) {
-	bounds := img.Bounds()
-	width := bounds.Dx()
-	height := bounds.Dy()
-
-	targetHeight := 64
-	aspectRatio := float64(width) / float64(height)
-	targetWidth := int(math.Round(float64(targetHeight) * aspectRatio))
+// run executes the model on img and returns a copy of the raw output scores.
+func (p *Predictor) run(img image.Image) ([]float32, error) {
+	inputData, shape, err := p.preprocess(img)
+	if err != nil {
+		return nil, err
+	}
+	if p.pool != nil {
+		return p.runPooled(inputData)
+	}
 
-	// Resize using high quality resampling
-	dst := image.NewGray(image.Rect(0, 0, targetWidth, targetHeight))
-	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+	inputTensor, err := p.newInput(shape, inputData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create input tensor: %v", err)
+	}
+	defer inputTensor.Destroy()
 
-	// Normalize
-	inputData := make([]float32, targetWidth*targetHeight)
-	for i, v := range dst.Pix {
-		// 0-255 -> 0.0-1.0
-		inputData[i] = float32(v) / 255.0
+	output, err := p.runSession(inputTensor, nil)
+	if output != nil {
+		defer output.Destroy()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("inference failed: %v", err)
+	}
+	data, err := outputScores(output)
+	if err != nil {
+		return nil, err
 	}
 
-	return inputData, targetHeight, targetWidth, nil
+	// The tensor's data is released with it, so hand back a copy
+	preds := make([]float32, len(data))
+	copy(preds, data)
+	return preds, nil
 }
 
-func (p *Predictor) decode(preds []float32) string {
-	decodedText := ""
-	prevIdx := -1
+// runPooled runs the model like run, but pads the input to a bucketed
+// width so the tensors can come from the pool, and drops the output
+// timesteps read from the padding.
+func (p *Predictor) runPooled(inputData []float32) ([]float32, error) {
+	width := len(inputData) / (p.layout.Channels * p.layout.Height)
+	padded := bucketWidth(width, p.widthStep)
 
-	// numClasses = charset + blank
-	numClasses := utf8.RuneCountInString(p.charset) + 1
-	seqLen := len(preds) / numClasses
+	pair, err := p.pool.get(padded, p.layout.Shape(padded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create input tensor: %v", err)
+	}
+	padWidth(pair.data, inputData, p.layout, width, padded)
+	pair.load()
 
-	// Charset array for lookup (runes)
-	charsetRunes := []rune(p.charset)
+	pair.output, err = p.runSession(pair.input, pair.output)
+	if err != nil {
+		pair.destroy()
+		return nil, fmt.Errorf("inference failed: %v", err)
+	}
+	defer p.pool.put(padded, pair)
 
-	for t := 0; t < seqLen; t++ {
-		maxVal := float32(-math.MaxFloat32)
-		maxIdx := 0
+	data, err := outputScores(pair.output)
+	if err != nil {
+		return nil, err
+	}
 
-		for c := 0; c < numClasses; c++ {
-			val := preds[t*numClasses+c]
-			if val > maxVal {
-				maxVal = val
-				maxIdx = c
-			}
-		}
+	// Timesteps are spread evenly over the padded width
+	numClasses := utf8.RuneCountInString(p.charset) + 1
+	seqLen := len(data) / numClasses
+	used := (seqLen*width + padded - 1) / padded
+	preds := make([]float32, used*numClasses)
+	copy(preds, data)
+	return preds, nil
+}
 
-		if maxIdx != 0 && maxIdx != prevIdx {
-			// maxIdx 0 is blank
-			// maxIdx 1..N maps to charset[0..N-1]
-			charIdx := maxIdx - 1
-			if charIdx < len(charsetRunes) {
-				decodedText += string(charsetRunes[charIdx])
-			}
-		}
-		prevIdx = maxIdx
+// preprocess converts img into the model's input on the GPU with
+// WithGPUPreprocessing, or else on the CPU.
+func (p *Predictor) preprocess(img image.Image) ([]float32, []int64, error) {
+	if p.gpuPrep != nil {
+		return p.preprocessGPU(img)
 	}
+	return p.Preprocess(img)
+}
 
-	return decodedText
+// preprocessGPU is Preprocess for single-channel models done on the GPU.
+func (p *Predictor) preprocessGPU(img image.Image) ([]float32, []int64, error) {
+	targetWidth, err := p.targetWidth(img)
+	if err != nil {
+		return nil, nil, err
+	}
+	// A single channel is laid out the same whether it comes first or last
+	inputData, err := p.gpuPrep.run(img, targetWidth, p.layout.Height)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inputData, p.layout.Shape(targetWidth), nil
 }
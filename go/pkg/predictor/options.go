@@ -0,0 +1,220 @@
+package predictor
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Option configures a Predictor.
+type Option func(*config)
+
+type config struct {
+	interpolation Interpolation
+	mmap          bool
+	widthBucket   int
+	// intraThreads and interThreads are ONNX Runtime's thread counts; 0
+	// leaves the runtime's default.
+	intraThreads int
+	interThreads int
+	// provider is the execution provider to run on when available, with
+	// cudaDevice the GPU for ProviderCUDA and ProviderTensorRT, and
+	// trtCacheDir where TensorRT keeps its engines.
+	provider    string
+	cudaDevice  int
+	trtCacheDir string
+	// gpuPreprocess scales line images on the GPU too.
+	gpuPreprocess bool
+	// lm rescores beam search decoding, weighted by lmWeight.
+	lm       LanguageModel
+	lmWeight float64
+	// extraChars is appended to the charset before it is checked.
+	extraChars string
+}
+
+// Interpolation selects the resampling filter used to scale line images to
+// the model's input height.
+type Interpolation int
+
+const (
+	// CatmullRom is the highest quality and the default.
+	CatmullRom Interpolation = iota
+	// Bilinear is faster and avoids ringing on bilevel scans.
+	Bilinear
+	// NearestNeighbor is the fastest and lowest quality.
+	NearestNeighbor
+)
+
+func (i Interpolation) String() string {
+	switch i {
+	case Bilinear:
+		return "bilinear"
+	case NearestNeighbor:
+		return "nearest"
+	default:
+		return "catmullrom"
+	}
+}
+
+func (i Interpolation) scaler() draw.Scaler {
+	switch i {
+	case Bilinear:
+		return draw.BiLinear
+	case NearestNeighbor:
+		return draw.NearestNeighbor
+	default:
+		return draw.CatmullRom
+	}
+}
+
+// ParseInterpolation parses a filter name as accepted by the CLI.
+func ParseInterpolation(name string) (Interpolation, error) {
+	switch strings.ToLower(name) {
+	case "catmullrom", "catmull-rom", "":
+		return CatmullRom, nil
+	case "bilinear":
+		return Bilinear, nil
+	case "nearest", "nearestneighbor":
+		return NearestNeighbor, nil
+	}
+	return CatmullRom, fmt.Errorf("unknown interpolation %q: use catmullrom, bilinear or nearest", name)
+}
+
+// WithInterpolation sets the resampling filter used during preprocessing.
+func WithInterpolation(i Interpolation) Option {
+	return func(c *config) {
+		c.interpolation = i
+	}
+}
+
+// WithExtraChars maps the additional output classes of a fine-tuned
+// model (e.g. Mon digits or punctuation) to extra, in order, after the
+// charset. The extended charset is checked against the model's classes
+// when the predictor is created, like the charset alone is without it.
+func WithExtraChars(extra string) Option {
+	return func(c *config) {
+		c.extraChars = extra
+	}
+}
+
+// WithMemoryMap loads the model through a read-only memory mapping instead
+// of letting ONNX Runtime read the whole file into memory, which lowers the
+// startup peak on small devices. Platforms without mmap read the file
+// normally. It has no effect on models with a float16 output: casting the
+// output to float32 rewrites the graph, which copies the whole model onto
+// the heap.
+func WithMemoryMap() Option {
+	return func(c *config) {
+		c.mmap = true
+	}
+}
+
+// WithWidthBucket sets the step, in model input pixels, that line widths
+// are padded up to so lines of similar width share cached input and output
+// tensors instead of allocating them per line. The padding repeats the
+// line's background, but the model still sees the extra columns, so a
+// line may decode slightly differently than at its exact width. Zero or
+// less, the default, runs every line at its exact width; 32 suits most
+// models.
+func WithWidthBucket(step int) Option {
+	return func(c *config) {
+		c.widthBucket = step
+	}
+}
+
+// WithIntraOpThreads sets the number of threads ONNX Runtime uses within
+// each operator. Zero or less keeps the runtime's default, one thread per
+// core. See TuneThreads to pick a count for the host.
+func WithIntraOpThreads(n int) Option {
+	return func(c *config) {
+		c.intraThreads = n
+	}
+}
+
+// WithInterOpThreads sets the number of threads ONNX Runtime uses to run
+// independent operators in parallel. Zero or less keeps the runtime's
+// default. Sessions run operators in sequence, so this rarely matters;
+// WithIntraOpThreads is the count to lower when several predictors share
+// the CPU.
+//
+// The graph optimization level can't be chosen: the ONNX Runtime binding
+// doesn't expose it, so sessions use the runtime's default of all
+// optimizations.
+func WithInterOpThreads(n int) Option {
+	return func(c *config) {
+		c.interThreads = n
+	}
+}
+
+// Execution providers reported by Predictor.Provider.
+const (
+	ProviderCPU      = "cpu"
+	ProviderCUDA     = "cuda"
+	ProviderCoreML   = "coreml"
+	ProviderTensorRT = "tensorrt"
+)
+
+// providerName returns the display name of an execution provider.
+func providerName(provider string) string {
+	switch provider {
+	case ProviderCUDA:
+		return "CUDA"
+	case ProviderCoreML:
+		return "CoreML"
+	case ProviderTensorRT:
+		return "TensorRT"
+	}
+	return provider
+}
+
+// WithCUDA runs the model on the CUDA GPU with the given device ID, taking
+// a share of the memory budget set with SetGPUMemoryFraction. When ONNX
+// Runtime was built without CUDA or no GPU is usable, the predictor warns
+// and runs on the CPU instead of failing.
+func WithCUDA(deviceID int) Option {
+	return func(c *config) {
+		c.provider = ProviderCUDA
+		c.cudaDevice = deviceID
+	}
+}
+
+// WithTensorRT runs the model with NVIDIA TensorRT on the GPU with the
+// given device ID, with CUDA taking any operators TensorRT can't. Building
+// a TensorRT engine takes minutes, so with a cacheDir the engines are
+// saved there and loaded by later sessions; without one every session
+// builds them again. Engines are built per input shape range, so the
+// first lines wider than any seen before trigger another build. Like
+// WithCUDA, it falls back to the CPU with a warning when unavailable.
+func WithTensorRT(deviceID int, cacheDir string) Option {
+	return func(c *config) {
+		c.provider = ProviderTensorRT
+		c.cudaDevice = deviceID
+		c.trtCacheDir = cacheDir
+	}
+}
+
+// WithGPUPreprocessing scales and normalizes line images on the GPU
+// when the model runs on CUDA or TensorRT, through a small ONNX graph
+// with the same filter as WithInterpolation, so CPU-side resizing no
+// longer limits GPU throughput. Only the resize moves: the scaled pixels
+// still pass through host memory on their way into the model, so measure
+// with monocr bench whether it pays off. Results can differ from CPU
+// preprocessing in the last bit of a pixel. It is ignored on other
+// providers and for color models, and falls back to the CPU with a
+// warning if the graph can't be loaded.
+func WithGPUPreprocessing() Option {
+	return func(c *config) {
+		c.gpuPreprocess = true
+	}
+}
+
+// WithCoreML runs the model through Apple's CoreML, which uses the Neural
+// Engine or the GPU of the Mac where it can. Elsewhere, or when ONNX
+// Runtime was built without CoreML, the predictor logs a message and runs
+// on the CPU.
+func WithCoreML() Option {
+	return func(c *config) {
+		c.provider = ProviderCoreML
+	}
+}
@@ -0,0 +1,200 @@
+//go:build cgo
+
+package predictor
+
+import (
+	"encoding/binary"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// parityCharset sizes the parity model's output: 7 characters and the
+// CTC blank.
+const parityCharset = "abcdefg"
+
+// parityModel returns a small CRNN with random weights in the shape of a
+// real recognition model: two convolutions with batch normalization and
+// pooling, a bidirectional LSTM, a GRU and a softmax over the classes.
+// Its "input" is [1, 1, 32, w] and its "output" [1, t, 8].
+func parityModel() []byte {
+	var nodes, inits [][]byte
+	weights := func(name string, phase float64, scale float32, dims ...int64) {
+		n := int64(1)
+		for _, d := range dims {
+			n *= d
+		}
+		data := make([]float32, n)
+		for i := range data {
+			data[i] = scale * float32(math.Sin(float64(i)*0.71+phase))
+		}
+		inits = append(inits, floatTensor(name, dims, data))
+	}
+	node := func(op string, in, out []string, attrs ...[]byte) {
+		n := []byte{}
+		for _, s := range in {
+			n = appendString(n, 1, s)
+		}
+		for _, s := range out {
+			n = appendString(n, 2, s)
+		}
+		n = appendString(n, 4, op)
+		for _, a := range attrs {
+			n = appendBytes(n, 5, a)
+		}
+		nodes = append(nodes, n)
+	}
+
+	weights("conv1_w", 0, 0.5, 8, 1, 3, 3)
+	weights("conv1_b", 1, 0.1, 8)
+	weights("bn_scale", 2, 0.2, 8)
+	weights("bn_bias", 3, 0.1, 8)
+	weights("bn_mean", 4, 0.1, 8)
+	inits = append(inits, floatTensor("bn_var", []int64{8}, []float32{1, 0.5, 2, 1, 0.8, 1.2, 1, 0.6}))
+	node("Conv", []string{"input", "conv1_w", "conv1_b"}, []string{"c1"}, intsAttr("pads", 1, 1, 1, 1))
+	node("BatchNormalization", []string{"c1", "bn_scale", "bn_bias", "bn_mean", "bn_var"}, []string{"bn"})
+	node("Relu", []string{"bn"}, []string{"r1"})
+	node("MaxPool", []string{"r1"}, []string{"p1"}, intsAttr("kernel_shape", 2, 2), intsAttr("strides", 2, 2))
+
+	weights("conv2_w", 5, 0.2, 16, 8, 3, 3)
+	weights("conv2_b", 6, 0.1, 16)
+	node("Conv", []string{"p1", "conv2_w", "conv2_b"}, []string{"c2"}, intsAttr("pads", 1, 1, 1, 1))
+	node("Relu", []string{"c2"}, []string{"r2"})
+	node("MaxPool", []string{"r2"}, []string{"p2"}, intsAttr("kernel_shape", 16, 1), intsAttr("strides", 16, 1))
+
+	// [1, 16, 1, t] to the [t, 1, 16] sequence the LSTM reads
+	inits = append(inits, intTensor("height_axis", 2))
+	node("Squeeze", []string{"p2", "height_axis"}, []string{"features"})
+	node("Transpose", []string{"features"}, []string{"seq"}, intsAttr("perm", 2, 0, 1))
+
+	weights("lstm_w", 7, 0.3, 2, 32, 16)
+	weights("lstm_r", 8, 0.3, 2, 32, 8)
+	weights("lstm_b", 9, 0.1, 2, 64)
+	node("LSTM", []string{"seq", "lstm_w", "lstm_r", "lstm_b"}, []string{"lstm"},
+		intAttr("hidden_size", 8), stringAttr("direction", "bidirectional"))
+	inits = append(inits, intTensor("lstm_shape", 0, 0, -1))
+	node("Transpose", []string{"lstm"}, []string{"lstm_t"}, intsAttr("perm", 0, 2, 1, 3))
+	node("Reshape", []string{"lstm_t", "lstm_shape"}, []string{"both"})
+
+	weights("gru_w", 10, 0.3, 1, 24, 16)
+	weights("gru_r", 11, 0.3, 1, 24, 8)
+	weights("gru_b", 12, 0.1, 1, 48)
+	node("GRU", []string{"both", "gru_w", "gru_r", "gru_b"}, []string{"gru"},
+		intAttr("hidden_size", 8), intAttr("linear_before_reset", 1))
+	inits = append(inits, intTensor("dir_axis", 1))
+	node("Squeeze", []string{"gru", "dir_axis"}, []string{"hidden"})
+
+	weights("fc_w", 13, 3, 8, 8)
+	weights("fc_b", 14, 0.2, 8)
+	node("MatMul", []string{"hidden", "fc_w"}, []string{"logits_mm"})
+	node("Add", []string{"logits_mm", "fc_b"}, []string{"logits"})
+	node("Transpose", []string{"logits"}, []string{"logits_t"}, intsAttr("perm", 1, 0, 2))
+	node("Softmax", []string{"logits_t"}, []string{"output"}, intAttr("axis", -1))
+
+	graph := []byte{}
+	for _, n := range nodes {
+		graph = appendBytes(graph, 1, n)
+	}
+	graph = appendString(graph, 2, "parity")
+	for _, t := range inits {
+		graph = appendBytes(graph, 5, t)
+	}
+	graph = appendBytes(graph, 11, valueInfo("input", onnxFloat, 1, 1, 32, "w"))
+	graph = appendBytes(graph, 12, valueInfo("output", onnxFloat, 1, "t", 8))
+
+	var model []byte
+	model = appendVarint(model, 1, 8) // IR version 8
+	model = appendString(model, 2, "monocr")
+	model = appendBytes(model, 7, graph)
+	model = appendBytes(model, 8, appendVarint(nil, 2, 17))
+	return model
+}
+
+// floatTensor encodes a float TensorProto initializer.
+func floatTensor(name string, dims []int64, data []float32) []byte {
+	var t []byte
+	for _, d := range dims {
+		t = appendVarint(t, 1, uint64(d))
+	}
+	t = appendVarint(t, 2, onnxFloat)
+	t = appendString(t, 8, name)
+	raw := make([]byte, 0, 4*len(data))
+	for _, v := range data {
+		raw = binary.LittleEndian.AppendUint32(raw, math.Float32bits(v))
+	}
+	return appendBytes(t, 9, raw)
+}
+
+// intTensor encodes a 1-D int64 TensorProto initializer.
+func intTensor(name string, values ...int64) []byte {
+	t := appendVarint(nil, 1, uint64(len(values)))
+	t = appendVarint(t, 2, onnxInt64)
+	t = appendString(t, 8, name)
+	raw := make([]byte, 0, 8*len(values))
+	for _, v := range values {
+		raw = binary.LittleEndian.AppendUint64(raw, uint64(v))
+	}
+	return appendBytes(t, 9, raw)
+}
+
+func intsAttr(name string, vs ...int64) []byte {
+	a := appendString(nil, 1, name)
+	for _, v := range vs {
+		a = appendVarint(a, 8, uint64(v))
+	}
+	return appendVarint(a, 20, 7) // INTS
+}
+
+// TestGoPredictorParity checks that the pure-Go interpreter reads lines
+// like ONNX Runtime: the same scores, within float32 rounding, and the
+// same text. It is skipped where the ONNX Runtime library can't be
+// loaded.
+func TestGoPredictorParity(t *testing.T) {
+	if err := initEnvironment(); err != nil {
+		t.Skip(err)
+	}
+	modelPath := filepath.Join(t.TempDir(), "parity.onnx")
+	if err := os.WriteFile(modelPath, parityModel(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ort, err := NewPredictor(modelPath, parityCharset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ort.Close()
+	pure, err := NewGoPredictor(modelPath, parityCharset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pure.Close()
+
+	line := benchLine()
+	for _, img := range []image.Image{
+		line,
+		line.SubImage(image.Rect(100, 0, 400, 100)),
+		line.SubImage(image.Rect(0, 10, 40, 90)),
+	} {
+		want, err := ort.run(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := pure.run(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%v: %d scores, ONNX Runtime gave %d", img.Bounds(), len(got), len(want))
+		}
+		for i := range want {
+			if math.Abs(float64(got[i]-want[i])) > 1e-4 {
+				t.Fatalf("%v: score %d is %v, ONNX Runtime gave %v", img.Bounds(), i, got[i], want[i])
+			}
+		}
+		if got, want := pure.decode(got), ort.decode(want); got != want {
+			t.Errorf("%v: read %q, ONNX Runtime read %q", img.Bounds(), got, want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package predictor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+)
+
+// ErrPoolClosed is returned by Acquire once the pool is closed.
+var ErrPoolClosed = errors.New("predictor pool is closed")
+
+// Pool holds several Recognizers for the same model, each with its own
+// session, and hands each to one caller at a time. A session is safe to
+// share, but concurrent runs on one session contend for its thread pool
+// and buffers; an HTTP service gets steadier latency from a few sessions
+// with a share of the cores each.
+//
+// Pool is itself a Recognizer that borrows a member for every call, so it
+// can stand in wherever a single Predictor is used.
+type Pool struct {
+	free    chan Recognizer
+	members []Recognizer
+
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+// NewPool creates size recognizers with newRecognizer. If any fails, the
+// ones already created are closed.
+func NewPool(size int, newRecognizer func() (Recognizer, error)) (*Pool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("pool size must be at least 1, got %d", size)
+	}
+	p := &Pool{free: make(chan Recognizer, size), done: make(chan struct{})}
+	for i := 0; i < size; i++ {
+		rec, err := newRecognizer()
+		if err != nil {
+			for _, m := range p.members {
+				m.Close()
+			}
+			return nil, err
+		}
+		p.members = append(p.members, rec)
+		p.free <- rec
+	}
+	return p, nil
+}
+
+// Size returns the number of recognizers in the pool.
+func (p *Pool) Size() int {
+	return len(p.members)
+}
+
+// Acquire waits for a free recognizer. The caller has sole use of it until
+// passing it to Release, and must not close it.
+func (p *Pool) Acquire(ctx context.Context) (Recognizer, error) {
+	select {
+	case <-p.done:
+		return nil, ErrPoolClosed
+	default:
+	}
+	select {
+	case rec := <-p.free:
+		return rec, nil
+	case <-p.done:
+		return nil, ErrPoolClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Release returns a recognizer obtained from Acquire. After Close it
+// closes the recognizer instead.
+func (p *Pool) Release(rec Recognizer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		rec.Close()
+		return
+	}
+	p.free <- rec
+}
+
+// Close closes the idle recognizers; those still acquired are closed as
+// they are released. Waiting Acquire calls return ErrPoolClosed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+
+	var first error
+	for {
+		select {
+		case rec := <-p.free:
+			if err := rec.Close(); err != nil && first == nil {
+				first = err
+			}
+		default:
+			return first
+		}
+	}
+}
+
+// with runs f on a borrowed recognizer.
+func (p *Pool) with(f func(Recognizer) error) error {
+	rec, err := p.Acquire(context.Background())
+	if err != nil {
+		return err
+	}
+	defer p.Release(rec)
+	return f(rec)
+}
+
+func (p *Pool) Predict(img image.Image) (text string, err error) {
+	err = p.with(func(rec Recognizer) error {
+		text, err = rec.Predict(img)
+		return err
+	})
+	return text, err
+}
+
+func (p *Pool) PredictWithConfidence(img image.Image) (text string, conf float64, err error) {
+	err = p.with(func(rec Recognizer) error {
+		text, conf, err = rec.PredictWithConfidence(img)
+		return err
+	})
+	return text, conf, err
+}
+
+func (p *Pool) PredictChars(img image.Image) (text string, conf float64, chars []Char, err error) {
+	err = p.with(func(rec Recognizer) error {
+		text, conf, chars, err = rec.PredictChars(img)
+		return err
+	})
+	return text, conf, chars, err
+}
+
+// PredictConstrained fails if the members are not
+// ConstrainedRecognizers.
+func (p *Pool) PredictConstrained(img image.Image, c Constraint) (text string, conf float64, chars []Char, err error) {
+	err = p.with(func(rec Recognizer) error {
+		cr, ok := rec.(ConstrainedRecognizer)
+		if !ok {
+			return fmt.Errorf("recognizer %T does not support constrained decoding", rec)
+		}
+		text, conf, chars, err = cr.PredictConstrained(img, c)
+		return err
+	})
+	return text, conf, chars, err
+}
+
+// PredictBatch reads imgs in one run when the members are
+// BatchRecognizers, and one at a time on the same member otherwise.
+func (p *Pool) PredictBatch(imgs []image.Image) (preds []Prediction, err error) {
+	err = p.with(func(rec Recognizer) error {
+		if batch, ok := rec.(BatchRecognizer); ok {
+			preds, err = batch.PredictBatch(imgs)
+			return err
+		}
+		preds = make([]Prediction, len(imgs))
+		for i, img := range imgs {
+			text, conf, chars, err := rec.PredictChars(img)
+			if err != nil {
+				return err
+			}
+			preds[i] = Prediction{Text: text, Confidence: conf, Chars: chars}
+		}
+		return nil
+	})
+	return preds, err
+}
+
+// Warmup warms up every member that implements Warmer.
+func (p *Pool) Warmup() error {
+	for _, rec := range p.members {
+		if w, ok := rec.(Warmer); ok {
+			if err := w.Warmup(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	_ BatchRecognizer       = (*Pool)(nil)
+	_ ConstrainedRecognizer = (*Pool)(nil)
+	_ Warmer                = (*Pool)(nil)
+)
@@ -0,0 +1,163 @@
+//go:build cgo
+
+package predictor
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// preprocessGraph returns an ONNX model that scales a grayscale line image
+// to the model's input size on the device: it takes the image as uint8
+// "image" [1, 1, h, w] and the target size as int64 "sizes" [1, 1, H, W],
+// and returns the normalized float "pixels" [1, 1, H, W]. The resize
+// matches the filter i selects, antialiased when shrinking like the
+// kernels of golang.org/x/image/draw.
+//
+// The ONNX protobuf is small and fixed, so it is encoded by hand rather
+// than pulling in a protobuf library.
+func preprocessGraph(i Interpolation) []byte {
+	resize := []byte{}
+	resize = appendString(resize, 1, "float")
+	resize = appendString(resize, 1, "")
+	resize = appendString(resize, 1, "")
+	resize = appendString(resize, 1, "sizes")
+	resize = appendString(resize, 2, "scaled")
+	resize = appendString(resize, 4, "Resize")
+	resize = appendBytes(resize, 5, stringAttr("coordinate_transformation_mode", "half_pixel"))
+	switch i {
+	case Bilinear:
+		resize = appendBytes(resize, 5, stringAttr("mode", "linear"))
+		resize = appendBytes(resize, 5, intAttr("antialias", 1))
+	case NearestNeighbor:
+		resize = appendBytes(resize, 5, stringAttr("mode", "nearest"))
+		// Rounding half-pixel coordinates up picks the source pixel under
+		// each destination pixel's center, as draw.NearestNeighbor does
+		resize = appendBytes(resize, 5, stringAttr("nearest_mode", "round_prefer_ceil"))
+	default:
+		resize = appendBytes(resize, 5, stringAttr("mode", "cubic"))
+		resize = appendBytes(resize, 5, floatAttr("cubic_coeff_a", -0.5))
+		resize = appendBytes(resize, 5, intAttr("antialias", 1))
+	}
+
+	cast := []byte{}
+	cast = appendString(cast, 1, "image")
+	cast = appendString(cast, 2, "float")
+	cast = appendString(cast, 4, "Cast")
+	cast = appendBytes(cast, 5, intAttr("to", onnxFloat))
+
+	// The resize may overshoot [0, 255] with cubic filters, as draw's
+	// kernels clamp
+	clip := []byte{}
+	clip = appendString(clip, 1, "scaled")
+	clip = appendString(clip, 1, "zero")
+	clip = appendString(clip, 1, "full")
+	clip = appendString(clip, 2, "clipped")
+	clip = appendString(clip, 4, "Clip")
+
+	normalize := []byte{}
+	normalize = appendString(normalize, 1, "clipped")
+	normalize = appendString(normalize, 1, "full")
+	normalize = appendString(normalize, 2, "pixels")
+	normalize = appendString(normalize, 4, "Div")
+
+	graph := []byte{}
+	for _, node := range [][]byte{cast, resize, clip, normalize} {
+		graph = appendBytes(graph, 1, node)
+	}
+	graph = appendString(graph, 2, "monocr_preprocess")
+	graph = appendBytes(graph, 5, scalarTensor("zero", 0))
+	graph = appendBytes(graph, 5, scalarTensor("full", 255))
+	graph = appendBytes(graph, 11, valueInfo("image", onnxUint8, "n", "c", "h", "w"))
+	graph = appendBytes(graph, 11, valueInfo("sizes", onnxInt64, 4))
+	graph = appendBytes(graph, 12, valueInfo("pixels", onnxFloat, "n", "c", "H", "W"))
+
+	// Resize's antialias attribute needs opset 18
+	opset := appendVarint(nil, 2, 18)
+
+	var model []byte
+	model = appendVarint(model, 1, 8) // IR version 8
+	model = appendString(model, 2, "monocr")
+	model = appendBytes(model, 7, graph)
+	model = appendBytes(model, 8, opset)
+	return model
+}
+
+// ONNX TensorProto data types.
+const (
+	onnxFloat = 1
+	onnxUint8 = 2
+	onnxInt64 = 7
+)
+
+// valueInfo encodes a ValueInfoProto for a tensor whose dimensions are
+// given as int sizes or string names of dynamic ones.
+func valueInfo(name string, elemType int, dims ...any) []byte {
+	var shape []byte
+	for _, d := range dims {
+		var dim []byte
+		switch d := d.(type) {
+		case int:
+			dim = appendVarint(dim, 1, uint64(d))
+		case string:
+			dim = appendString(dim, 2, d)
+		}
+		shape = appendBytes(shape, 1, dim)
+	}
+	tensor := appendVarint(nil, 1, uint64(elemType))
+	tensor = appendBytes(tensor, 2, shape)
+	typ := appendBytes(nil, 1, tensor)
+
+	info := appendString(nil, 1, name)
+	return appendBytes(info, 2, typ)
+}
+
+// scalarTensor encodes a float scalar TensorProto initializer.
+func scalarTensor(name string, v float32) []byte {
+	t := appendVarint(nil, 2, onnxFloat)
+	t = appendString(t, 8, name)
+	return appendBytes(t, 9, binary.LittleEndian.AppendUint32(nil, math.Float32bits(v)))
+}
+
+// AttributeProto types.
+const (
+	attrFloat  = 1
+	attrInt    = 2
+	attrString = 3
+)
+
+func stringAttr(name, v string) []byte {
+	a := appendString(nil, 1, name)
+	a = appendString(a, 4, v)
+	return appendVarint(a, 20, attrString)
+}
+
+func intAttr(name string, v int64) []byte {
+	a := appendString(nil, 1, name)
+	a = appendVarint(a, 3, uint64(v))
+	return appendVarint(a, 20, attrInt)
+}
+
+func floatAttr(name string, v float32) []byte {
+	a := appendString(nil, 1, name)
+	a = binary.AppendUvarint(a, 2<<3|5) // field 2, fixed32
+	a = binary.LittleEndian.AppendUint32(a, math.Float32bits(v))
+	return appendVarint(a, 20, attrFloat)
+}
+
+// appendVarint appends protobuf field num as a varint.
+func appendVarint(b []byte, num int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3)
+	return binary.AppendUvarint(b, v)
+}
+
+// appendBytes appends protobuf field num as length-delimited data.
+func appendBytes(b []byte, num int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendString(b []byte, num int, s string) []byte {
+	return appendBytes(b, num, []byte(s))
+}
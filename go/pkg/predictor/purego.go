@@ -0,0 +1,142 @@
+package predictor
+
+import (
+	"fmt"
+	"image"
+	"unicode/utf8"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/onnx"
+)
+
+var (
+	_ Recognizer            = (*GoPredictor)(nil)
+	_ ConstrainedRecognizer = (*GoPredictor)(nil)
+	_ CharsetExtender       = (*GoPredictor)(nil)
+)
+
+// GoPredictor runs a CTC line recognition model with the pure-Go
+// interpreter in package onnx instead of ONNX Runtime. It needs neither
+// cgo nor a native library, so it is the default in builds without cgo,
+// but reads lines several times slower. Quantized (int8) models aren't
+// supported. Of the predictor options it honors the interpolation filter,
+// the language model and WithIntraOpThreads, which bounds the goroutines
+// each line runs on; the others only configure ONNX Runtime.
+type GoPredictor struct {
+	lineModel
+	model         *onnx.Model
+	input, output string
+}
+
+// NewGoPredictor loads the model at modelPath for the pure-Go runtime.
+func NewGoPredictor(modelPath, charset string, opts ...Option) (*GoPredictor, error) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	model, err := onnx.Load(modelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load model: %v", err)
+	}
+	if len(model.Inputs) == 0 || len(model.Outputs) == 0 {
+		return nil, fmt.Errorf("model has no inputs or outputs")
+	}
+	model.Threads = cfg.intraThreads
+
+	layout, err := layoutFromDims(model.Inputs[0].Shape)
+	if err != nil {
+		return nil, err
+	}
+	var classes int
+	if dims := model.Outputs[0].Shape; len(dims) > 0 && dims[len(dims)-1] > 0 {
+		classes = int(dims[len(dims)-1])
+	}
+	line, err := newLineModel(charset, classes, layout, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GoPredictor{
+		lineModel: line,
+		model:     model,
+		input:     model.Inputs[0].Name,
+		output:    model.Outputs[0].Name,
+	}, nil
+}
+
+// PureGo is the Backend for GoPredictor.
+func PureGo(modelPath, charset string, opts ...Option) (Recognizer, error) {
+	pred, err := NewGoPredictor(modelPath, charset, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return pred, nil
+}
+
+func (p *GoPredictor) Close() error {
+	return nil
+}
+
+func (p *GoPredictor) Predict(img image.Image) (string, error) {
+	preds, err := p.run(img)
+	if err != nil {
+		return "", err
+	}
+	return p.decode(preds), nil
+}
+
+// PredictWithConfidence recognizes a line image and also returns the mean
+// probability of the emitted characters, in the range [0, 1].
+func (p *GoPredictor) PredictWithConfidence(img image.Image) (string, float64, error) {
+	preds, err := p.run(img)
+	if err != nil {
+		return "", 0, err
+	}
+	text, conf := p.decodeWithConfidence(preds)
+	return text, conf, nil
+}
+
+// PredictChars recognizes a line image and returns the text, the line's
+// mean confidence and every character with its own confidence and span.
+func (p *GoPredictor) PredictChars(img image.Image) (string, float64, []Char, error) {
+	preds, err := p.run(img)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	decoded, seqLen, blankSum := p.decodeChars(preds)
+	text, conf := joinChars(decoded, seqLen, blankSum)
+	return text, conf, p.spanChars(img, decoded, seqLen), nil
+}
+
+// PredictConstrained is PredictChars reading only text c allows, as for
+// Predictor.
+func (p *GoPredictor) PredictConstrained(img image.Image, c Constraint) (string, float64, []Char, error) {
+	preds, err := p.run(img)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	decoded, seqLen, blankSum := decodeBeam(preds, []rune(p.charset), c, p.lm)
+	text, conf := joinChars(decoded, seqLen, blankSum)
+	return text, conf, p.spanChars(img, decoded, seqLen), nil
+}
+
+// run executes the model on img and returns the raw output scores.
+func (p *GoPredictor) run(img image.Image) ([]float32, error) {
+	data, shape, err := p.Preprocess(img)
+	if err != nil {
+		return nil, err
+	}
+	dims := make([]int, len(shape))
+	for i, d := range shape {
+		dims[i] = int(d)
+	}
+	outputs, err := p.model.Run(map[string]*onnx.Tensor{p.input: onnx.NewTensor(dims, data)})
+	if err != nil {
+		return nil, fmt.Errorf("inference failed: %v", err)
+	}
+	scores := outputs[p.output]
+	if numClasses := utf8.RuneCountInString(p.charset) + 1; len(scores.Data)%numClasses != 0 {
+		return nil, fmt.Errorf("inference failed: output shape %v doesn't match %d classes", scores.Shape, numClasses)
+	}
+	return scores.Data, nil
+}
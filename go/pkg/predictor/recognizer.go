@@ -0,0 +1,70 @@
+package predictor
+
+import (
+	"image"
+)
+
+// Recognizer reads the text of single line images. Predictor, backed by
+// ONNX Runtime, is the default implementation, and GoPredictor in builds
+// without cgo; other runtimes plug in through a Backend. Implementations
+// must be safe for concurrent use.
+type Recognizer interface {
+	// Predict returns the text of a line image.
+	Predict(img image.Image) (string, error)
+	// PredictWithConfidence also returns the mean probability of the
+	// emitted characters, in the range [0, 1].
+	PredictWithConfidence(img image.Image) (string, float64, error)
+	// PredictChars also returns every character with its own confidence
+	// and the columns of img it was read from.
+	PredictChars(img image.Image) (string, float64, []Char, error)
+	Close() error
+}
+
+// BatchRecognizer is a Recognizer that can also read several line images
+// in one inference run, which is much faster than one at a time on
+// multi-line pages. Callers bound how many images they pass at once.
+type BatchRecognizer interface {
+	Recognizer
+	// PredictBatch returns what PredictChars would for each image, in
+	// order.
+	PredictBatch(imgs []image.Image) ([]Prediction, error)
+}
+
+// Warmer is implemented by recognizers whose first inference is slow,
+// such as Predictor, so servers can pay that cost before taking traffic.
+type Warmer interface {
+	Warmup() error
+}
+
+// CharsetExtender is implemented by recognizers, such as Predictor and
+// GoPredictor, whose charset can be extended for the extra output classes
+// of a fine-tuned model with a dynamic class count. Models with a fixed
+// one take their extra characters with WithExtraChars instead.
+type CharsetExtender interface {
+	ExtendCharset(extra string) error
+}
+
+// Prediction is the reading of one line image.
+type Prediction struct {
+	Text       string
+	Confidence float64
+	Chars      []Char
+}
+
+// Backend loads a Recognizer for a model file and its charset.
+type Backend func(modelPath, charset string, opts ...Option) (Recognizer, error)
+
+// Span is the horizontal extent of one recognized character, in pixel
+// columns of the input image.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Char is one recognized character with its probability and the columns
+// of the input image it was read from.
+type Char struct {
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+	Span
+}
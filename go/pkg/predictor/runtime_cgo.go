@@ -0,0 +1,53 @@
+//go:build cgo
+
+package predictor
+
+import (
+	"github.com/yalue/onnxruntime_go"
+)
+
+// Runtime loads ONNX Runtime if needed and reports its version and the
+// execution providers it can run on. A provider counts as available when
+// it can be added to a session, which needs both an ONNX Runtime build
+// that includes it and, for CUDA and TensorRT, their libraries; it doesn't check
+// for a working device.
+func Runtime() (*RuntimeInfo, error) {
+	if err := initEnvironment(); err != nil {
+		return nil, err
+	}
+	info := &RuntimeInfo{Version: onnxruntime_go.GetVersion(), Providers: []string{ProviderCPU}}
+
+	probes := []struct {
+		provider string
+		add      func(*onnxruntime_go.SessionOptions) error
+	}{
+		{ProviderCUDA, func(options *onnxruntime_go.SessionOptions) error {
+			cuda, err := onnxruntime_go.NewCUDAProviderOptions()
+			if err != nil {
+				return err
+			}
+			defer cuda.Destroy()
+			return options.AppendExecutionProviderCUDA(cuda)
+		}},
+		{ProviderTensorRT, func(options *onnxruntime_go.SessionOptions) error {
+			trt, err := onnxruntime_go.NewTensorRTProviderOptions()
+			if err != nil {
+				return err
+			}
+			defer trt.Destroy()
+			return options.AppendExecutionProviderTensorRT(trt)
+		}},
+		{ProviderCoreML, appendCoreML},
+	}
+	for _, probe := range probes {
+		options, err := onnxruntime_go.NewSessionOptions()
+		if err != nil {
+			return nil, err
+		}
+		if probe.add(options) == nil {
+			info.Providers = append(info.Providers, probe.provider)
+		}
+		options.Destroy()
+	}
+	return info, nil
+}
@@ -0,0 +1,12 @@
+//go:build !cgo
+
+package predictor
+
+import (
+	"fmt"
+)
+
+// Runtime reports on the ONNX Runtime library, which needs cgo.
+func Runtime() (*RuntimeInfo, error) {
+	return nil, fmt.Errorf("the ONNX Runtime backend needs cgo: rebuild with CGO_ENABLED=1")
+}
@@ -0,0 +1,41 @@
+//go:build cgo
+
+package predictor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/yalue/onnxruntime_go"
+)
+
+// appendTensorRT adds the TensorRT execution provider for device to
+// options, followed by CUDA for the parts of the model TensorRT can't
+// take. Built engines are kept in cacheDir, if set, and reused by later
+// sessions with the same model, shapes and TensorRT version. It returns
+// the bytes of the GPU memory budget the CUDA provider reserved.
+func appendTensorRT(options *onnxruntime_go.SessionOptions, device int, cacheDir string) (uint64, error) {
+	trt, err := onnxruntime_go.NewTensorRTProviderOptions()
+	if err != nil {
+		return 0, err
+	}
+	defer trt.Destroy()
+
+	settings := map[string]string{"device_id": strconv.Itoa(device)}
+	if cacheDir != "" {
+		if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+			return 0, fmt.Errorf("failed to create engine cache: %v", err)
+		}
+		settings["trt_engine_cache_enable"] = "1"
+		settings["trt_engine_cache_path"] = cacheDir
+		settings["trt_timing_cache_enable"] = "1"
+	}
+	if err := trt.Update(settings); err != nil {
+		return 0, err
+	}
+	if err := options.AppendExecutionProviderTensorRT(trt); err != nil {
+		return 0, err
+	}
+	return appendCUDA(options, device)
+}
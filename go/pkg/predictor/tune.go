@@ -0,0 +1,88 @@
+package predictor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"runtime"
+	"time"
+)
+
+// DefaultTuneBudget is how long TuneThreads benchmarks in total, not
+// counting session creation.
+const DefaultTuneBudget = 2 * time.Second
+
+// TuneThreads loads the model with backend at a few intra-op thread
+// counts, recognizes a synthetic line with each for a share of budget and
+// returns the count that ran the most lines per second on this host.
+// opts are passed on to every load.
+func TuneThreads(backend Backend, modelPath, charset string, budget time.Duration, opts ...Option) (int, error) {
+	if backend == nil {
+		backend = DefaultBackend
+	}
+	counts := threadCandidates(runtime.NumCPU())
+	per := budget / time.Duration(len(counts))
+	line := tuningLine()
+
+	best, bestRate := 0, 0.0
+	for _, n := range counts {
+		rate, err := lineRate(backend, modelPath, charset, line, per, append(opts[:len(opts):len(opts)], WithIntraOpThreads(n)))
+		if err != nil {
+			return 0, fmt.Errorf("tuning with %d threads: %v", n, err)
+		}
+		if rate > bestRate {
+			best, bestRate = n, rate
+		}
+	}
+	return best, nil
+}
+
+// lineRate returns how many times per second a recognizer loaded with
+// opts reads line within d.
+func lineRate(backend Backend, modelPath, charset string, line image.Image, d time.Duration, opts []Option) (float64, error) {
+	rec, err := backend(modelPath, charset, opts...)
+	if err != nil {
+		return 0, err
+	}
+	defer rec.Close()
+
+	// The first run pays for allocation and graph optimization
+	if _, err := rec.Predict(line); err != nil {
+		return 0, err
+	}
+	runs := 0
+	start := time.Now()
+	for runs == 0 || time.Since(start) < d {
+		if _, err := rec.Predict(line); err != nil {
+			return 0, err
+		}
+		runs++
+	}
+	return float64(runs) / time.Since(start).Seconds(), nil
+}
+
+// threadCandidates returns the thread counts worth trying on a host with
+// cpus cores: powers of two up to it and the core count itself.
+func threadCandidates(cpus int) []int {
+	var counts []int
+	for n := 1; n < cpus; n *= 2 {
+		counts = append(counts, n)
+	}
+	return append(counts, max(cpus, 1))
+}
+
+// tuningLine draws a line-sized image of dark strokes on a light
+// background, close enough to text for timing.
+func tuningLine() image.Image {
+	img := image.NewGray(image.Rect(0, 0, 800, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 800; x++ {
+			v := uint8(235)
+			if y > 16 && y < 48 && (x*7+y*3)%23 < 4 {
+				v = 30
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
@@ -0,0 +1,37 @@
+//go:build cgo
+
+package predictor
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// warmupWidths are the line widths, in model input pixels, Warmup runs:
+// a short, a typical and a long line, so the width cache and, on GPUs,
+// the kernels for each size are ready.
+var warmupWidths = []int{128, 512, 1024}
+
+// Warmup runs blank lines of a few widths through the session, and a
+// batch of them for models that take batches. The first runs of a session
+// allocate its buffers and, on GPUs, pick kernels or build TensorRT
+// engines, which can take far longer than recognition itself; servers can
+// call Warmup after loading the model so the first requests don't wait.
+func (p *Predictor) Warmup() error {
+	lines := make([]image.Image, len(warmupWidths))
+	for i, width := range warmupWidths {
+		img := image.NewGray(image.Rect(0, 0, width, p.layout.Height))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{Y: 255}), image.Point{}, draw.Src)
+		if _, err := p.run(img); err != nil {
+			return err
+		}
+		lines[i] = img
+	}
+	if p.layout.Batched {
+		if _, err := p.runBatch(lines); err != nil {
+			return err
+		}
+	}
+	return nil
+}
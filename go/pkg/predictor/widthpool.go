@@ -0,0 +1,158 @@
+//go:build cgo
+
+package predictor
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/yalue/onnxruntime_go"
+)
+
+// Limits on the tensors a widthPool keeps: a few per width, for
+// concurrent callers, and enough widths for a page's worth of lines.
+const (
+	maxPerWidth = 4
+	maxWidths   = 32
+)
+
+// tensorPair is a preallocated input tensor for one padded width and the
+// output tensor ORT filled for it, reused as the output of later runs.
+type tensorPair struct {
+	input onnxruntime_go.Value
+	// data holds the input values: the tensor's own data, or for a
+	// float16 input a buffer that load converts into the tensor
+	data   []float32
+	output onnxruntime_go.Value
+}
+
+// load converts data into a float16 input tensor. Float32 tensors are
+// written in place and need no loading.
+func (t *tensorPair) load() {
+	if half, ok := t.input.(*onnxruntime_go.CustomDataTensor); ok {
+		encodeHalf(half.GetData(), t.data)
+	}
+}
+
+func (t *tensorPair) destroy() {
+	t.input.Destroy()
+	if t.output != nil {
+		t.output.Destroy()
+	}
+}
+
+// widthPool caches tensorPairs by padded input width. Allocating the input
+// and output tensors dominated per-line latency; lines padded to the same
+// bucketed width share them instead.
+type widthPool struct {
+	mu    sync.Mutex
+	free  map[int][]*tensorPair
+	total int
+	// half allocates float16 input tensors
+	half bool
+}
+
+func newWidthPool(half bool) *widthPool {
+	return &widthPool{free: make(map[int][]*tensorPair), half: half}
+}
+
+// get returns a free pair for width, allocating one with shape if none is
+// cached.
+func (p *widthPool) get(width int, shape []int64) (*tensorPair, error) {
+	p.mu.Lock()
+	if free := p.free[width]; len(free) > 0 {
+		t := free[len(free)-1]
+		p.free[width] = free[:len(free)-1]
+		p.total--
+		p.mu.Unlock()
+		return t, nil
+	}
+	p.mu.Unlock()
+
+	if p.half {
+		data := make([]float32, onnxruntime_go.Shape(shape).FlattenedSize())
+		input, err := newHalfTensor(shape)
+		if err != nil {
+			return nil, err
+		}
+		return &tensorPair{input: input, data: data}, nil
+	}
+	input, err := onnxruntime_go.NewEmptyTensor[float32](onnxruntime_go.Shape(shape))
+	if err != nil {
+		return nil, err
+	}
+	return &tensorPair{input: input, data: input.GetData()}, nil
+}
+
+// put returns t to the pool, or destroys it if the pool is full.
+func (p *widthPool) put(width int, t *tensorPair) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.free == nil || len(p.free[width]) >= maxPerWidth || p.total >= maxWidths*maxPerWidth {
+		t.destroy()
+		return
+	}
+	p.free[width] = append(p.free[width], t)
+	p.total++
+}
+
+// close destroys the cached tensors. Pairs put back afterwards are
+// destroyed.
+func (p *widthPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, free := range p.free {
+		for _, t := range free {
+			t.destroy()
+		}
+	}
+	p.free = nil
+	p.total = 0
+}
+
+// bucketWidth rounds width up to a multiple of step.
+func bucketWidth(width, step int) int {
+	if step <= 1 {
+		return width
+	}
+	return (width + step - 1) / step * step
+}
+
+// padWidth copies src, laid out by l for an image width pixels wide, into
+// dst laid out for padded pixels. The padding is filled with the median of
+// the last column, so it reads as the line's own background rather than
+// smearing a character cut by the crop.
+func padWidth(dst, src []float32, l inputLayout, width, padded int) {
+	c := l.Channels
+	fill := make([]float32, c)
+	column := make([]float32, l.Height)
+	for ch := range c {
+		for y := range l.Height {
+			if l.ChannelsLast {
+				column[y] = src[(y*width+width-1)*c+ch]
+			} else {
+				column[y] = src[(ch*l.Height+y)*width+width-1]
+			}
+		}
+		slices.Sort(column)
+		fill[ch] = column[len(column)/2]
+	}
+
+	if l.ChannelsLast {
+		for y := range l.Height {
+			row := dst[y*padded*c : (y+1)*padded*c]
+			copy(row, src[y*width*c:(y+1)*width*c])
+			for x := width; x < padded; x++ {
+				copy(row[x*c:(x+1)*c], fill)
+			}
+		}
+		return
+	}
+	for r := range c * l.Height {
+		row := dst[r*padded : (r+1)*padded]
+		copy(row, src[r*width:(r+1)*width])
+		for x := width; x < padded; x++ {
+			row[x] = fill[r/l.Height]
+		}
+	}
+}
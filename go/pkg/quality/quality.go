@@ -0,0 +1,148 @@
+package quality
+
+import (
+	"image"
+	"sort"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/imgproc"
+)
+
+// Thresholds below which a page is flagged. Sharpness is the variance of
+// the Laplacian, contrast the gap between mean ink and mean background gray
+// levels, and text height the median line height in pixels.
+const (
+	MinSharpness  = 100.0
+	MinContrast   = 80.0
+	MinTextHeight = 20
+)
+
+// Metrics describes how suitable a page image is for recognition.
+type Metrics struct {
+	Sharpness  float64  `json:"sharpness"`
+	Contrast   float64  `json:"contrast"`
+	TextHeight int      `json:"text_height"`
+	Score      float64  `json:"score"`
+	Issues     []string `json:"issues,omitempty"`
+}
+
+// NeedsRescan reports whether the page falls below any quality threshold.
+func (m Metrics) NeedsRescan() bool {
+	return len(m.Issues) > 0
+}
+
+// Assess measures blur, contrast and text resolution of img. lineHeights
+// are the heights of the segmented text lines; pass nil if unknown.
+func Assess(img image.Image, lineHeights []int) Metrics {
+	gray := imgproc.Gray(img)
+
+	m := Metrics{
+		Sharpness:  laplacianVariance(gray),
+		Contrast:   classContrast(gray),
+		TextHeight: median(lineHeights),
+	}
+
+	// Each component maps to [0, 1] at twice its threshold; the page is only
+	// as good as its weakest aspect.
+	m.Score = min(
+		clamp(m.Sharpness/(2*MinSharpness)),
+		clamp(m.Contrast/(2*MinContrast)),
+	)
+	if m.TextHeight > 0 {
+		m.Score = min(m.Score, clamp(float64(m.TextHeight)/(2*MinTextHeight)))
+	}
+
+	if m.Sharpness < MinSharpness {
+		m.Issues = append(m.Issues, "blurry")
+	}
+	if m.Contrast < MinContrast {
+		m.Issues = append(m.Issues, "low contrast")
+	}
+	if m.TextHeight > 0 && m.TextHeight < MinTextHeight {
+		m.Issues = append(m.Issues, "text resolution too low")
+	}
+	return m
+}
+
+// laplacianVariance applies the 4-neighbour Laplacian kernel and returns
+// the variance of the response. Sharp edges give a high variance.
+func laplacianVariance(g *image.Gray) float64 {
+	b := g.Bounds()
+	if b.Dx() < 3 || b.Dy() < 3 {
+		return 0
+	}
+
+	var sum, sq float64
+	n := 0
+	for y := b.Min.Y + 1; y < b.Max.Y-1; y++ {
+		for x := b.Min.X + 1; x < b.Max.X-1; x++ {
+			v := 4*float64(g.GrayAt(x, y).Y) -
+				float64(g.GrayAt(x-1, y).Y) - float64(g.GrayAt(x+1, y).Y) -
+				float64(g.GrayAt(x, y-1).Y) - float64(g.GrayAt(x, y+1).Y)
+			sum += v
+			sq += v * v
+			n++
+		}
+	}
+	mean := sum / float64(n)
+	return sq/float64(n) - mean*mean
+}
+
+// classContrast splits the gray levels into ink and background with Otsu's
+// method and returns the distance between the two class means. Percentile
+// spreads don't work here because ink covers only a few percent of a page.
+func classContrast(g *image.Gray) float64 {
+	var hist [256]float64
+	b := g.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			hist[g.GrayAt(x, y).Y]++
+		}
+	}
+
+	var total, sumAll float64
+	for v, count := range hist {
+		total += count
+		sumAll += float64(v) * count
+	}
+	if total == 0 {
+		return 0
+	}
+
+	var bestVar, contrast, wDark, sumDark float64
+	for t := 0; t < 255; t++ {
+		wDark += hist[t]
+		sumDark += float64(t) * hist[t]
+		wLight := total - wDark
+		if wDark == 0 || wLight == 0 {
+			continue
+		}
+
+		meanDark := sumDark / wDark
+		meanLight := (sumAll - sumDark) / wLight
+		between := wDark * wLight * (meanLight - meanDark) * (meanLight - meanDark)
+		if between > bestVar {
+			bestVar = between
+			contrast = meanLight - meanDark
+		}
+	}
+	return contrast
+}
+
+func median(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	return sorted[len(sorted)/2]
+}
+
+func clamp(v float64) float64 {
+	if v > 1 {
+		return 1
+	}
+	if v < 0 {
+		return 0
+	}
+	return v
+}
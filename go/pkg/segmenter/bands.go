@@ -0,0 +1,120 @@
+package segmenter
+
+import (
+	"image"
+)
+
+// subImager is implemented by all standard library image types.
+type subImager interface {
+	SubImage(r image.Rectangle) image.Image
+}
+
+// SegmentBands segments img one horizontal band at a time so the working
+// set stays proportional to bandHeight rather than the full page. Lines that
+// run into the bottom of a band are re-segmented as part of the next band,
+// so no line is split across a boundary.
+func (s *LineSegmenter) SegmentBands(img image.Image, bandHeight int) ([]SegmentResult, error) {
+	bounds := img.Bounds()
+	if bandHeight <= 0 || bandHeight >= bounds.Dy() {
+		return s.Segment(img)
+	}
+
+	si, ok := img.(subImager)
+	if !ok {
+		return s.Segment(img)
+	}
+
+	var results []SegmentResult
+	y := bounds.Min.Y
+	for y < bounds.Max.Y {
+		end := y + bandHeight
+		if end > bounds.Max.Y {
+			end = bounds.Max.Y
+		}
+
+		band := si.SubImage(image.Rect(bounds.Min.X, y, bounds.Max.X, end))
+		lines, err := s.Segment(band)
+		if err != nil {
+			return nil, err
+		}
+
+		next := end
+		if end < bounds.Max.Y && len(lines) > 0 {
+			last := lines[len(lines)-1]
+			// A line touching the band edge may continue below it. Retry it
+			// in the next band unless it alone fills the band.
+			if last.BBox.Max.Y >= end && last.BBox.Min.Y > y {
+				next = last.BBox.Min.Y
+				lines = lines[:len(lines)-1]
+			}
+		}
+
+		results = append(results, lines...)
+		y = next
+	}
+
+	return results, nil
+}
+
+// RowSource yields the rows of an image in grayscale from top to bottom,
+// for images too large to decode whole.
+type RowSource interface {
+	Bounds() image.Rectangle
+	// ReadRows fills dst, which spans the image's width, with the next
+	// dst.Rect.Dy() rows.
+	ReadRows(dst *image.Gray) error
+}
+
+// SegmentRows is SegmentBands for images read row by row: only one band
+// of bandHeight rows is in memory at a time. fn is called with each
+// band's lines, top to bottom, before the next band is read.
+func (s *LineSegmenter) SegmentRows(src RowSource, bandHeight int, fn func([]SegmentResult) error) error {
+	bounds := src.Bounds()
+	if bandHeight <= 0 || bandHeight > bounds.Dy() {
+		bandHeight = bounds.Dy()
+	}
+	width := bounds.Dx()
+	pix := make([]uint8, width*bandHeight)
+	// rows returns the buffer holding rows [top, bottom), which starts at
+	// row y
+	rows := func(y, top, bottom int) *image.Gray {
+		return &image.Gray{
+			Pix:    pix[(top-y)*width : (bottom-y)*width],
+			Stride: width,
+			Rect:   image.Rect(bounds.Min.X, top, bounds.Max.X, bottom),
+		}
+	}
+
+	// The buffer holds rows [y, read)
+	y, read := bounds.Min.Y, bounds.Min.Y
+	for y < bounds.Max.Y {
+		end := min(y+bandHeight, bounds.Max.Y)
+		if err := src.ReadRows(rows(y, read, end)); err != nil {
+			return err
+		}
+		read = end
+
+		lines, err := s.Segment(rows(y, y, end))
+		if err != nil {
+			return err
+		}
+
+		next := end
+		if end < bounds.Max.Y && len(lines) > 0 {
+			last := lines[len(lines)-1]
+			// As in SegmentBands, a line touching the band edge is read
+			// again at the top of the next band
+			if last.BBox.Max.Y >= end && last.BBox.Min.Y > y {
+				next = last.BBox.Min.Y
+				lines = lines[:len(lines)-1]
+			}
+		}
+		if err := fn(lines); err != nil {
+			return err
+		}
+
+		copy(pix, pix[(next-y)*width:(end-y)*width])
+		y = next
+	}
+	return nil
+}
@@ -2,9 +2,9 @@ package segmenter
 
 import (
 	"image"
-	"image/color"
-	"image/draw"
 	"math"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/imgproc"
 )
 
 type LineSegmenter struct {
@@ -17,6 +17,12 @@ type SegmentResult struct {
 	BBox image.Rectangle
 }
 
+// Stats reports what segmentation left out.
+type Stats struct {
+	// Discarded counts text bands shorter than MinLineH.
+	Discarded int
+}
+
 func NewLineSegmenter(minLineH, smoothWindow int) *LineSegmenter {
 	if minLineH == 0 {
 		minLineH = 10
@@ -31,6 +37,15 @@ func NewLineSegmenter(minLineH, smoothWindow int) *LineSegmenter {
 }
 
 func (s *LineSegmenter) Segment(img image.Image) ([]SegmentResult, error) {
+	results, _, err := s.SegmentWithStats(img)
+	return results, err
+}
+
+// SegmentWithStats is like Segment but also reports how many candidate
+// lines were discarded.
+func (s *LineSegmenter) SegmentWithStats(img image.Image) ([]SegmentResult, Stats, error) {
+	var stats Stats
+
 	// Convert to Grayscale if needed (conceptually, we just need luminance)
 	bounds := img.Bounds()
 	width, height := bounds.Dx(), bounds.Dy()
@@ -40,19 +55,10 @@ func (s *LineSegmenter) Segment(img image.Image) ([]SegmentResult, error) {
 	// hist[y] = sum(is_text(x, y) for x in width)
 	hist := make([]int, height)
 
-	// Accessing pixels via At() is slow, but compatible with all image types.
-	// For optimization later, type switch to *image.Gray, *image.RGBA etc.
-	// For now, simple implementation.
+	gray := imgproc.Gray(img)
 	for y := 0; y < height; y++ {
-		sum := 0
-		for x := 0; x < width; x++ {
-			c := img.At(bounds.Min.X+x, bounds.Min.Y+y)
-			gray := color.GrayModel.Convert(c).(color.Gray)
-			if gray.Y < 128 {
-				sum++
-			}
-		}
-		hist[y] = sum
+		row := gray.Pix[y*gray.Stride : y*gray.Stride+width]
+		hist[y] = imgproc.CountBelow(row, 128)
 	}
 
 	// 2. Smoothing
@@ -90,7 +96,7 @@ func (s *LineSegmenter) Segment(img image.Image) ([]SegmentResult, error) {
 	}
 
 	if len(nonZeroVals) == 0 {
-		return []SegmentResult{}, nil
+		return []SegmentResult{}, stats, nil
 	}
 
 	// Mean density
@@ -114,37 +120,40 @@ func (s *LineSegmenter) Segment(img image.Image) ([]SegmentResult, error) {
 		} else if !isText && start != nil {
 			end := y
 			if (end - *start) >= s.MinLineH {
-				s.extractLine(img, bounds, *start, end, &results)
+				s.extractLine(gray, *start, end, &results)
+			} else {
+				stats.Discarded++
 			}
 			start = nil
 		}
 	}
 
-	if start != nil && (height-*start) >= s.MinLineH {
-		s.extractLine(img, bounds, *start, height, &results)
+	if start != nil {
+		if (height - *start) >= s.MinLineH {
+			s.extractLine(gray, *start, height, &results)
+		} else {
+			stats.Discarded++
+		}
 	}
 
-	return results, nil
+	return results, stats, nil
 }
 
-func (s *LineSegmenter) extractLine(img image.Image, bounds image.Rectangle, rStart, rEnd int, results *[]SegmentResult) {
+func (s *LineSegmenter) extractLine(gray *image.Gray, rStart, rEnd int, results *[]SegmentResult) {
 	// Find horizontal bounds within strip
 	// strip corresponds to y inside [bounds.Min.Y + rStart, bounds.Min.Y + rEnd)
 	// We need to sum columns to find x range.
 
+	bounds := gray.Rect
 	width := bounds.Dx()
 	colSum := make([]int, width)
 
 	// Optimize: Only loop through the strip rows
 	for y := rStart; y < rEnd; y++ {
-		actualY := bounds.Min.Y + y
-		for x := 0; x < width; x++ {
-			actualX := bounds.Min.X + x
-			c := img.At(actualX, actualY)
-			gray := color.GrayModel.Convert(c).(color.Gray)
-			if gray.Y < 128 {
-				colSum[x]++
-			}
+		row := gray.Pix[y*gray.Stride : y*gray.Stride+width]
+		for x, v := range row {
+			// 1 for ink (v < 128), without a branch
+			colSum[x] += int((uint32(v) - 128) >> 31)
 		}
 	}
 
@@ -175,17 +184,11 @@ func (s *LineSegmenter) extractLine(img image.Image, bounds image.Rectangle, rSt
 	// Crop
 	rect := image.Rect(bounds.Min.X+x1, bounds.Min.Y+y1, bounds.Min.X+x2, bounds.Min.Y+y2)
 
-	// Since Go images merely reference the underlying buffer when sub-imaging,
-	// and we might want to resize/process these individually,
-	// we should probably copy to a new buffer or just return the SubImage.
-	// SubImage is safer for memory if original is large, but for processing we might want clean buffer.
-	// Let's return a SubImage if possible, or a Copy. LineSegmenter usually returns independent images
-	// in Python PIL (crop returns copy).
-	// onnx runtime preprocessing will maximize contrast etc anyway?
-	// Actually `image.NewGray` and draw is safest to ensure 'L' mode equivalent.
-
+	// Copy the crop so each line owns its pixels, like PIL's crop.
 	dst := image.NewGray(image.Rect(0, 0, x2-x1, y2-y1))
-	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	for y := y1; y < y2; y++ {
+		copy(dst.Pix[(y-y1)*dst.Stride:], gray.Pix[y*gray.Stride+x1:y*gray.Stride+x2])
+	}
 
 	*results = append(*results, SegmentResult{
 		Img:  dst,
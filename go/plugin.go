@@ -0,0 +1,151 @@
+package monocr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Writer encodes a recognition result in an output format. source is the
+// input file the result was read from. EncodeALTO is a Writer.
+type Writer func(w io.Writer, result *Result, source string) error
+
+// Preprocessor transforms a page image before it is segmented, for
+// example to remove a watermark or a scanner's border.
+type Preprocessor func(img image.Image) (image.Image, error)
+
+// Plugins register writers and preprocessors by name from an init
+// function, so a program importing them can select them by name, as the
+// CLI's --format and --preprocess do.
+var (
+	pluginMu      sync.RWMutex
+	writers       = make(map[string]Writer)
+	preprocessors = make(map[string]Preprocessor)
+)
+
+// RegisterWriter makes a Writer available by name. It panics if name is
+// registered twice.
+func RegisterWriter(name string, w Writer) {
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	if _, dup := writers[name]; dup {
+		panic("monocr: RegisterWriter called twice for " + name)
+	}
+	writers[name] = w
+}
+
+// LookupWriter returns the Writer registered as name, or nil.
+func LookupWriter(name string) Writer {
+	pluginMu.RLock()
+	defer pluginMu.RUnlock()
+	return writers[name]
+}
+
+// Writers returns the names of the registered writers, sorted.
+func Writers() []string {
+	pluginMu.RLock()
+	defer pluginMu.RUnlock()
+	return sortedKeys(writers)
+}
+
+// RegisterPreprocessor makes a Preprocessor available by name. It panics
+// if name is registered twice.
+func RegisterPreprocessor(name string, p Preprocessor) {
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	if _, dup := preprocessors[name]; dup {
+		panic("monocr: RegisterPreprocessor called twice for " + name)
+	}
+	preprocessors[name] = p
+}
+
+// LookupPreprocessor returns the Preprocessor registered as name, or nil.
+func LookupPreprocessor(name string) Preprocessor {
+	pluginMu.RLock()
+	defer pluginMu.RUnlock()
+	return preprocessors[name]
+}
+
+// Preprocessors returns the names of the registered preprocessors, sorted.
+func Preprocessors() []string {
+	pluginMu.RLock()
+	defer pluginMu.RUnlock()
+	return sortedKeys(preprocessors)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// WithPreprocessors runs p on every page image, in order, after any
+// rotation and before segmentation.
+func WithPreprocessors(p ...Preprocessor) Option {
+	return func(o *options) {
+		o.preprocessors = append(o.preprocessors, p...)
+	}
+}
+
+// ExecWriter returns a Writer that runs the program at path, in any
+// language, with the source file as its argument and the result as JSON
+// on its standard input, and copies its standard output to w.
+func ExecWriter(path string) Writer {
+	return func(w io.Writer, result *Result, source string) error {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command(path, source)
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Stdout = w
+		return runPlugin(cmd)
+	}
+}
+
+// ExecPreprocessor returns a Preprocessor that runs the program at path
+// with the page as a PNG on its standard input and decodes the image it
+// writes to its standard output.
+func ExecPreprocessor(path string) Preprocessor {
+	return func(img image.Image) (image.Image, error) {
+		var in, out bytes.Buffer
+		if err := png.Encode(&in, img); err != nil {
+			return nil, err
+		}
+		cmd := exec.Command(path)
+		cmd.Stdin = &in
+		cmd.Stdout = &out
+		if err := runPlugin(cmd); err != nil {
+			return nil, err
+		}
+		return decodeReader(&out)
+	}
+}
+
+// runPlugin runs cmd, reporting the last line the plugin wrote to its
+// error output if it fails.
+func runPlugin(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if i := strings.LastIndexByte(msg, '\n'); i >= 0 {
+			msg = msg[i+1:]
+		}
+		if msg != "" {
+			return fmt.Errorf("plugin %s failed: %v: %s", cmd.Path, err, msg)
+		}
+		return fmt.Errorf("plugin %s failed: %v", cmd.Path, err)
+	}
+	return nil
+}
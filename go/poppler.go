@@ -0,0 +1,46 @@
+package monocr
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// popplerEnv names a directory holding the poppler binaries, for installs
+// that are neither on PATH nor in a well-known location.
+const popplerEnv = "MONOCR_POPPLER_PATH"
+
+// findPoppler locates a poppler command-line tool such as pdftoppm. It
+// checks MONOCR_POPPLER_PATH, then PATH, then the platform's common install
+// locations.
+func findPoppler(tool string) (string, error) {
+	name := tool
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	if dir := os.Getenv(popplerEnv); dir != "" {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	if path, err := exec.LookPath(tool); err == nil {
+		return path, nil
+	}
+
+	for _, pattern := range popplerSearchDirs() {
+		dirs, _ := filepath.Glob(pattern)
+		for _, dir := range dirs {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("%s not found: %s", tool, popplerHint)
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package monocr
+
+const popplerHint = "please install poppler-utils"
+
+// popplerSearchDirs lists install locations that are often missing from
+// PATH, such as Homebrew's prefix for GUI-launched processes.
+func popplerSearchDirs() []string {
+	return []string{"/opt/homebrew/bin", "/usr/local/bin", "/opt/local/bin"}
+}
@@ -0,0 +1,37 @@
+package monocr
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const popplerHint = "install poppler (e.g. `scoop install poppler` or `choco install poppler`) or set MONOCR_POPPLER_PATH to its bin directory"
+
+// popplerSearchDirs lists glob patterns for where scoop, Chocolatey and the
+// poppler-windows release zips usually put the binaries.
+func popplerSearchDirs() []string {
+	var dirs []string
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs,
+			filepath.Join(home, "scoop", "shims"),
+			filepath.Join(home, "scoop", "apps", "poppler", "current", "Library", "bin"),
+			filepath.Join(home, "scoop", "apps", "poppler", "current", "bin"),
+		)
+	}
+	if data := os.Getenv("ProgramData"); data != "" {
+		dirs = append(dirs,
+			filepath.Join(data, "chocolatey", "bin"),
+			filepath.Join(data, "chocolatey", "lib", "poppler", "tools", "*", "Library", "bin"),
+		)
+	}
+	for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)", "LOCALAPPDATA"} {
+		if root := os.Getenv(env); root != "" {
+			dirs = append(dirs,
+				filepath.Join(root, "poppler*", "Library", "bin"),
+				filepath.Join(root, "poppler*", "bin"),
+			)
+		}
+	}
+	dirs = append(dirs, `C:\poppler*\Library\bin`, `C:\poppler*\bin`)
+	return dirs
+}
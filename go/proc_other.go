@@ -0,0 +1,11 @@
+//go:build !unix
+
+package monocr
+
+import (
+	"os/exec"
+)
+
+// isolateProcess relies on the default cancellation, which kills only the
+// renderer process itself.
+func isolateProcess(cmd *exec.Cmd) {}
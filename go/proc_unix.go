@@ -0,0 +1,18 @@
+//go:build unix
+
+package monocr
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// isolateProcess starts cmd in its own process group and makes
+// cancellation kill the whole group, so helpers spawned by the renderer
+// can't outlive it.
+func isolateProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
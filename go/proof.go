@@ -0,0 +1,248 @@
+package monocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/segmenter"
+)
+
+// DefaultProofThreshold is the confidence below which proof sheets
+// highlight a line and its words.
+const DefaultProofThreshold = 0.8
+
+// ProofSheet recognizes inputPath, an image or a PDF, and writes an HTML
+// proof sheet to outPath: every line's image next to its recognized text
+// and confidence, with lines and words below threshold highlighted. The
+// text cells are editable and a button saves the corrected text, so
+// proofreaders can work through a document in the browser; printing the
+// sheet from the browser gives a PDF version.
+func ProofSheet(inputPath, outPath string, threshold float64, opts ...Option) error {
+	if strings.EqualFold(filepath.Ext(inputPath), ".pdf") {
+		if _, err := findPoppler("pdftoppm"); err != nil {
+			return err
+		}
+	}
+
+	r, err := NewReader(opts...)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return r.ProofSheet(inputPath, outPath, threshold)
+}
+
+// ProofSheet is the Reader form of the package-level ProofSheet.
+func (r *Reader) ProofSheet(inputPath, outPath string, threshold float64) error {
+	// Word confidences are needed even if text-only output was asked for
+	o := *r.o
+	o.textOnly = false
+
+	seg := segmenter.NewLineSegmenter(10, 3)
+	sheet := proofSheet{Title: filepath.Base(inputPath)}
+
+	addPage := func(number int, img image.Image) error {
+		img, err := o.orientImage(r.pred, img)
+		if err != nil {
+			return err
+		}
+		page, _, err := recognizePage(context.Background(), r.pred, seg, img, &o)
+		if err != nil {
+			return err
+		}
+		page.Number = number
+		p, err := newProofPage(img, page, threshold)
+		if err != nil {
+			return fmt.Errorf("page %d: %v", number, err)
+		}
+		sheet.Pages = append(sheet.Pages, p)
+		return nil
+	}
+
+	if strings.EqualFold(filepath.Ext(inputPath), ".pdf") {
+		pageDir, cleanup, err := renderPDF(context.Background(), inputPath, &o)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		files, err := os.ReadDir(pageDir)
+		if err != nil {
+			return err
+		}
+		for i, file := range files {
+			if !strings.HasSuffix(file.Name(), ".png") {
+				continue
+			}
+			number := pageNumber(file.Name(), i+1)
+			img, err := decodeFile(filepath.Join(pageDir, file.Name()))
+			if err != nil {
+				return fmt.Errorf("page %d: %v", number, err)
+			}
+			if err := addPage(number, img); err != nil {
+				return err
+			}
+		}
+	} else {
+		img, err := decodeFile(inputPath)
+		if err != nil {
+			return err
+		}
+		if err := addPage(1, img); err != nil {
+			return err
+		}
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := proofTemplate.Execute(out, sheet); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+type proofSheet struct {
+	Title string
+	Pages []proofPage
+}
+
+type proofPage struct {
+	Number int
+	Lines  []proofLine
+}
+
+type proofLine struct {
+	Order int
+	// Image is the line crop as a data: URL, so the sheet is one file
+	Image      template.URL
+	Spans      []proofSpan
+	Confidence float64
+	Low        bool
+}
+
+// proofSpan is a run of a line's text, highlighted when Low.
+type proofSpan struct {
+	Text string
+	Low  bool
+}
+
+func newProofPage(img image.Image, page Page, threshold float64) (proofPage, error) {
+	sub, ok := img.(subImager)
+	if !ok {
+		return proofPage{}, fmt.Errorf("image type %T does not support cropping", img)
+	}
+
+	p := proofPage{Number: page.Number}
+	for _, line := range page.Lines {
+		rect := line.BBox.Intersect(img.Bounds())
+		if rect.Empty() {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, sub.SubImage(rect)); err != nil {
+			return proofPage{}, fmt.Errorf("failed to encode line image: %v", err)
+		}
+		p.Lines = append(p.Lines, proofLine{
+			Order:      line.Order + 1,
+			Image:      template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())),
+			Spans:      proofSpans(line, threshold),
+			Confidence: line.Confidence,
+			Low:        line.Confidence < threshold,
+		})
+	}
+	return p, nil
+}
+
+// proofSpans splits line's text into runs, with each word below threshold
+// in a run of its own, the same way Line.MarkUncertain marks them.
+func proofSpans(line Line, threshold float64) []proofSpan {
+	var spans []proofSpan
+	add := func(text string, low bool) {
+		if text == "" {
+			return
+		}
+		if n := len(spans); n > 0 && !low && !spans[n-1].Low {
+			spans[n-1].Text += text
+			return
+		}
+		spans = append(spans, proofSpan{Text: text, Low: low})
+	}
+
+	rest := line.Text
+	for _, w := range line.Words() {
+		i := strings.Index(rest, w.Text)
+		add(rest[:i], false)
+		add(w.Text, w.Confidence < threshold)
+		rest = rest[i+len(w.Text):]
+	}
+	add(rest, false)
+	return spans
+}
+
+var proofTemplate = template.Must(template.New("proof").Funcs(template.FuncMap{
+	"percent": func(c float64) string { return fmt.Sprintf("%.0f%%", c*100) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} – proof sheet</title>
+<style>
+body { font-family: sans-serif; margin: 1.5em; }
+table { border-collapse: collapse; width: 100%; }
+td, th { border-bottom: 1px solid #ddd; padding: 0.4em; text-align: left; vertical-align: middle; }
+td.image img { max-width: 100%; display: block; }
+td.text { font-size: 1.3em; width: 45%; }
+td.conf, td.num { color: #666; white-space: nowrap; }
+tr.low td.conf { color: #b00; font-weight: bold; }
+tr.low { background: #fff4e5; }
+mark { background: #ffd54f; }
+tr { page-break-inside: avoid; }
+@media print {
+  button { display: none; }
+  section { page-break-after: always; }
+}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p><button onclick="save()">Save corrected text</button></p>
+{{range .Pages}}<section data-page="{{.Number}}">
+<h2>Page {{.Number}}</h2>
+<table>
+<tr><th>#</th><th>Image</th><th>Text</th><th>Confidence</th></tr>
+{{range .Lines}}<tr{{if .Low}} class="low"{{end}}>
+<td class="num">{{.Order}}</td>
+<td class="image"><img src="{{.Image}}" alt="line {{.Order}}"></td>
+<td class="text" lang="mnw" contenteditable="true" spellcheck="false">{{range .Spans}}{{if .Low}}<mark>{{.Text}}</mark>{{else}}{{.Text}}{{end}}{{end}}</td>
+<td class="conf">{{percent .Confidence}}</td>
+</tr>
+{{end}}</table>
+</section>
+{{end}}<script>
+function save() {
+  var pages = [];
+  document.querySelectorAll("section").forEach(function (s) {
+    var lines = [];
+    s.querySelectorAll("td.text").forEach(function (td) { lines.push(td.innerText.replace(/\n/g, " ")); });
+    pages.push("--- Page " + s.dataset.page + " ---\n" + lines.join("\n") + "\n");
+  });
+  var a = document.createElement("a");
+  a.href = URL.createObjectURL(new Blob([pages.join("\n")], {type: "text/plain;charset=utf-8"}));
+  a.download = {{.Title}} + ".txt";
+  a.click();
+}
+</script>
+</body>
+</html>
+`))
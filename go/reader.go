@@ -0,0 +1,255 @@
+package monocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/segmenter"
+)
+
+// Reader holds a loaded model so that many images and PDFs can be read
+// without paying session construction cost on every call. A Reader is
+// safe for concurrent use; call Close when done with it.
+type Reader struct {
+	pred predictor.Recognizer
+	o    *options
+}
+
+// NewReader loads the default model, downloading it if not present.
+func NewReader(opts ...Option) (*Reader, error) {
+	modelPath, err := newOptions(opts).defaultModel()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReaderWithModel(modelPath, "", opts...)
+}
+
+// NewReaderWithModel loads a custom model and charset. modelPath may also
+// be a model card (.json, see model.Card) naming the model and its
+// charset. An empty charset is taken from WithCharsetFile, then from a
+// model card next to the model (MODEL.json), and otherwise is the bundled
+// one, for fine-tuned models with the same classes.
+func NewReaderWithModel(modelPath, charset string, opts ...Option) (*Reader, error) {
+	o := newOptions(opts)
+	modelPath, charset, err := o.resolveModel(modelPath, charset)
+	if err != nil {
+		return nil, err
+	}
+	pred, err := o.newPredictor(modelPath, charset)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{pred: pred, o: o}, nil
+}
+
+// With returns a Reader sharing r's model session that reads with r's
+// options followed by opts, for per-call settings such as WithDPI or
+// WithPages. Options that configure the model when it is loaded, such as
+// WithBackend, WithExtraChars or WithMemoryMap, have no effect. Closing
+// either Reader closes the shared session.
+func (r *Reader) With(opts ...Option) *Reader {
+	o := *r.o
+	o.extractors = append([]Extractor(nil), r.o.extractors...)
+	o.preprocessors = append([]Preprocessor(nil), r.o.preprocessors...)
+	o.predictor = append([]predictor.Option(nil), r.o.predictor...)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Reader{pred: r.pred, o: &o}
+}
+
+// Warmup runs the model once on dummy input, so the first real
+// recognition doesn't also pay for allocating buffers and preparing the
+// session. Servers can call it before accepting traffic. It does nothing
+// for recognizers that don't implement predictor.Warmer.
+func (r *Reader) Warmup() error {
+	if w, ok := r.pred.(predictor.Warmer); ok {
+		return w.Warmup()
+	}
+	return nil
+}
+
+// Close releases the model session.
+func (r *Reader) Close() error {
+	return r.pred.Close()
+}
+
+// ReadImage recognizes text from an image file.
+func (r *Reader) ReadImage(imagePath string) (string, error) {
+	return r.ReadImageContext(context.Background(), imagePath)
+}
+
+// ReadImageContext is ReadImage with cancellation. A line already being
+// recognized runs to completion; ctx is checked before inference starts.
+func (r *Reader) ReadImageContext(ctx context.Context, imagePath string) (string, error) {
+	return predictFile(ctx, r.pred, imagePath, r.o)
+}
+
+// RecognizeWithLayout is like Recognize but keeps the layout: each line
+// comes with its rectangle in img's coordinates, its text and confidence.
+// The page is numbered 1; warnings about degraded recognition are dropped.
+// It runs the default Pipeline.
+func (r *Reader) RecognizeWithLayout(img image.Image) (Page, error) {
+	doc, err := r.Pipeline().RunImage(context.Background(), img)
+	if err != nil {
+		return Page{}, err
+	}
+	return doc.Page, nil
+}
+
+// ReadImageFrom recognizes text from an encoded image read from src.
+func (r *Reader) ReadImageFrom(src io.Reader) (string, error) {
+	img, err := decodeReader(src)
+	if err != nil {
+		return "", err
+	}
+	return predictImage(context.Background(), r.pred, img, r.o)
+}
+
+// ReadImageBytes recognizes text from an encoded image.
+func (r *Reader) ReadImageBytes(data []byte) (string, error) {
+	return r.ReadImageFrom(bytes.NewReader(data))
+}
+
+// Recognize recognizes the text of an already decoded image, such as a
+// screenshot, a camera frame or a page from another renderer. The image is
+// segmented into lines, which are returned joined by newlines; an image
+// holding a single line is read as one.
+func (r *Reader) Recognize(img image.Image) (string, error) {
+	return r.RecognizeContext(context.Background(), img)
+}
+
+// RecognizeContext is Recognize with cancellation between lines.
+func (r *Reader) RecognizeContext(ctx context.Context, img image.Image) (string, error) {
+	textOnly := *r.o
+	textOnly.textOnly = true
+
+	doc, err := newPipeline(r.pred, &textOnly).RunImage(ctx, img)
+	if err != nil {
+		return "", err
+	}
+	return doc.Text, nil
+}
+
+// ReadImageResult recognizes an image file as a single line and returns a
+// one-page Result carrying the line's confidence.
+func (r *Reader) ReadImageResult(imagePath string) (*Result, error) {
+	img, err := decodeFile(imagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err = r.o.orientImage(r.pred, img)
+	if err != nil {
+		return nil, err
+	}
+
+	line, err := recognizeLine(r.pred, img, img.Bounds(), r.o)
+	if err != nil {
+		return nil, err
+	}
+
+	page := Page{Number: 1, Lines: r.o.keepLines([]Line{line}), Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if !r.o.rotates() {
+		page.Image = imagePath
+	}
+	page.setFontSizes(imageDPI(imagePath))
+	page.setLineIDs()
+	result := &Result{}
+	if !r.o.textOnly {
+		page.Quality = assessPage(img, page)
+		result.warnPage(page)
+	}
+	result.Pages = append(result.Pages, page)
+	result.Matches = r.o.extract(page)
+	return result, nil
+}
+
+// ReadLargeImage recognizes a very large page image by segmenting it in
+// horizontal bands of bandHeight pixels. PNGs are decoded a band at a
+// time in grayscale, so the full image is never in memory; other formats
+// are decoded whole first.
+func (r *Reader) ReadLargeImage(imagePath string, bandHeight int) (string, error) {
+	seg := segmenter.NewLineSegmenter(10, 3)
+	var pageLines []string
+	recognize := func(lines []segmenter.SegmentResult) error {
+		for _, line := range lines {
+			text, err := r.pred.Predict(line.Img)
+			if err == nil {
+				pageLines = append(pageLines, text)
+			}
+		}
+		return nil
+	}
+
+	// Rotating would need the whole page in memory, which band mode is
+	// there to avoid
+	if r.o.rotates() {
+		return "", fmt.Errorf("ReadLargeImage doesn't rotate pages; rotate the image beforehand or use ReadImage")
+	}
+	// Preprocessors work on the whole page
+	if len(r.o.preprocessors) > 0 {
+		img, err := decodeFile(imagePath)
+		if err != nil {
+			return "", err
+		}
+		if img, err = r.o.orientImage(r.pred, img); err != nil {
+			return "", err
+		}
+		lines, err := seg.SegmentBands(img, bandHeight)
+		if err != nil {
+			return "", err
+		}
+		recognize(lines)
+		return strings.Join(pageLines, "\n"), nil
+	}
+
+	src, closeSrc, err := openRows(imagePath)
+	if err != nil {
+		return "", err
+	}
+	defer closeSrc()
+	if err := seg.SegmentRows(src, bandHeight, recognize); err != nil {
+		return "", err
+	}
+	return strings.Join(pageLines, "\n"), nil
+}
+
+// ReadPDF recognizes text from a PDF file, one string per page.
+func (r *Reader) ReadPDF(pdfPath string) ([]string, error) {
+	return r.ReadPDFContext(context.Background(), pdfPath)
+}
+
+// ReadPDFContext is ReadPDF with cancellation: when ctx is done the PDF
+// renderer is killed, or recognition stops before the next line. The pages
+// read so far are returned along with a *CancelledError.
+func (r *Reader) ReadPDFContext(ctx context.Context, pdfPath string) ([]string, error) {
+	// Only the text is returned, so skip the structured extras.
+	textOnly := *r.o
+	textOnly.textOnly = true
+
+	result, err := readPDFResult(ctx, r.pred, pdfPath, &textOnly)
+	if result == nil {
+		return nil, err
+	}
+	return result.Texts(), err
+}
+
+// ReadPDFResult recognizes a PDF file and returns per-line text, confidence
+// and bounding boxes for every page. If a page fails, the pages before it
+// are returned along with a *PartialError.
+func (r *Reader) ReadPDFResult(pdfPath string) (*Result, error) {
+	return r.ReadPDFResultContext(context.Background(), pdfPath)
+}
+
+// ReadPDFResultContext is ReadPDFResult with cancellation. On cancellation
+// it returns the partial result along with a *CancelledError.
+func (r *Reader) ReadPDFResultContext(ctx context.Context, pdfPath string) (*Result, error) {
+	return readPDFResult(ctx, r.pred, pdfPath, r.o)
+}
@@ -0,0 +1,162 @@
+package monocr
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/pdf"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/segmenter"
+)
+
+// Redact finds every match of targets in the recognized text of inputPath
+// and writes a copy to outPath with those regions blacked out. Images are
+// written as PNG or JPEG depending on outPath's extension; PDFs are written
+// as image-only PDFs, so no text layer of the original survives. It returns
+// the number of regions redacted.
+//
+// Matches are located from the recognizer's character positions, so text
+// the OCR misreads is not found. Review the output before publishing.
+func Redact(inputPath, outPath string, targets []*regexp.Regexp, opts ...Option) (int, error) {
+	r, err := NewReader(opts...)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	pred, o := r.pred, r.o
+
+	seg := segmenter.NewLineSegmenter(10, 3)
+
+	if strings.EqualFold(filepath.Ext(inputPath), ".pdf") {
+		return redactPDF(pred, seg, inputPath, outPath, targets, o)
+	}
+
+	img, err := decodeFile(inputPath)
+	if err != nil {
+		return 0, err
+	}
+	img, err = o.orientImage(pred, img)
+	if err != nil {
+		return 0, err
+	}
+
+	redacted, count := redactImage(pred, seg, img, targets, o)
+	if err := encodeFile(outPath, redacted); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func redactPDF(pred predictor.Recognizer, seg *segmenter.LineSegmenter, pdfPath, outPath string, targets []*regexp.Regexp, o *options) (int, error) {
+	pageDir, cleanup, err := renderPDF(context.Background(), pdfPath, o)
+	if err != nil {
+		return 0, err
+	}
+	defer cleanup()
+
+	files, err := os.ReadDir(pageDir)
+	if err != nil {
+		return 0, err
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	w, err := pdf.NewWriter(out)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".png") {
+			continue
+		}
+
+		// A page that can't be checked must not be published unredacted.
+		img, err := decodeFile(filepath.Join(pageDir, file.Name()))
+		if err != nil {
+			return 0, fmt.Errorf("page %d: %v", pageNumber(file.Name(), 0), err)
+		}
+		img, err = o.orientImage(pred, img)
+		if err != nil {
+			return 0, err
+		}
+
+		redacted, count := redactImage(pred, seg, img, targets, o)
+		total += count
+		if err := w.AddImage(redacted, o.renderDPI()); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return total, out.Close()
+}
+
+// redactImage returns a copy of img with every target match blacked out,
+// and the number of regions covered.
+func redactImage(pred predictor.Recognizer, seg *segmenter.LineSegmenter, img image.Image, targets []*regexp.Regexp, o *options) (image.Image, int) {
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, img, b.Min, draw.Src)
+
+	// Character positions are needed even if text-only output was asked for
+	located := *o
+	located.textOnly = false
+	page, _, _ := recognizePage(context.Background(), pred, seg, img, &located)
+
+	count := 0
+	black := image.NewUniform(color.Black)
+	for _, line := range page.Lines {
+		// Character positions are approximate, so pad each box a little
+		pad := line.BBox.Dy() / 4
+
+		for _, target := range targets {
+			for _, m := range target.FindAllStringIndex(line.Text, -1) {
+				r := line.TextRect(m[0], m[1])
+				if r.Empty() {
+					continue
+				}
+				r = image.Rect(r.Min.X-pad, r.Min.Y, r.Max.X+pad, r.Max.Y).Intersect(b)
+				draw.Draw(dst, r, black, image.Point{}, draw.Src)
+				count++
+			}
+		}
+	}
+	return dst, count
+}
+
+// encodeFile writes img to path as JPEG for .jpg/.jpeg and PNG otherwise.
+func encodeFile(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: 95})
+	default:
+		err = png.Encode(f, img)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode image: %v", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,198 @@
+package monocr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/model"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/segmenter"
+)
+
+// Region is a rectangular area of interest on a page. Model and Charset
+// optionally override the default recognizer for this region, e.g. a
+// digits-only model for a page-number zone.
+type Region struct {
+	Name string
+	Rect image.Rectangle
+	// Model is the path to an ONNX model or its model card. Empty uses
+	// the default model.
+	Model string
+	// Charset is the path to the model's charset file or model card.
+	// Empty uses the model's card, if any, or the bundled charset.
+	Charset string
+	// Constraint, when set, restricts what is read in the region to text
+	// of a known format, such as predictor.Mask("DD-MM-YYYY") for a date
+	// field. The recognizer must implement predictor.ConstrainedRecognizer.
+	Constraint predictor.Constraint
+}
+
+// regionEntry is the manifest form of a Region.
+type regionEntry struct {
+	Name    string `json:"name"`
+	Box     [4]int `json:"box"` // x0, y0, x1, y1
+	Model   string `json:"model"`
+	Charset string `json:"charset"`
+	// Constraint is in the form predictor.ParseConstraint reads.
+	Constraint string `json:"constraint"`
+}
+
+// RegionResult holds the lines recognized inside a region.
+type RegionResult struct {
+	Name  string
+	Rect  image.Rectangle
+	Lines []Line
+}
+
+// Text returns the region's lines joined by newlines.
+func (r RegionResult) Text() string {
+	return Page{Lines: r.Lines}.Text()
+}
+
+// LoadRegions reads a JSON region manifest of the form
+//
+//	[{"name": "page-number", "box": [0, 3300, 2480, 3508], "model": "digits.onnx", "charset": "digits.txt"},
+//	 {"name": "date", "box": [1600, 400, 2300, 480], "constraint": "mask:DD-MM-YYYY"}]
+//
+// A constraint is "digits", "mask:" followed by a predictor.Mask or
+// "regex:" followed by a regular expression the whole field must match.
+func LoadRegions(manifestPath string) ([]Region, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []regionEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse region manifest: %v", err)
+	}
+
+	regions := make([]Region, len(entries))
+	for i, e := range entries {
+		regions[i] = Region{
+			Name:    e.Name,
+			Rect:    image.Rect(e.Box[0], e.Box[1], e.Box[2], e.Box[3]),
+			Model:   e.Model,
+			Charset: e.Charset,
+		}
+		if e.Constraint != "" {
+			c, err := predictor.ParseConstraint(e.Constraint)
+			if err != nil {
+				return nil, fmt.Errorf("region %q: %v", e.Name, err)
+			}
+			regions[i].Constraint = c
+		}
+	}
+	return regions, nil
+}
+
+// ReadRegions recognizes only the given regions of an image, using each
+// region's own model where one is configured.
+func ReadRegions(imagePath string, regions []Region, opts ...Option) ([]RegionResult, error) {
+	img, err := decodeFile(imagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load each distinct model once.
+	preds := make(map[string]predictor.Recognizer)
+	defer func() {
+		for _, pred := range preds {
+			pred.Close()
+		}
+	}()
+
+	o := newOptions(opts)
+	oriented := false
+	seg := segmenter.NewLineSegmenter(10, 3)
+
+	var results []RegionResult
+	for _, region := range regions {
+		pred, err := o.regionPredictor(preds, region)
+		if err != nil {
+			return nil, err
+		}
+
+		// Region coordinates refer to the upright page.
+		if !oriented {
+			img, err = o.orientImage(pred, img)
+			if err != nil {
+				return nil, err
+			}
+			oriented = true
+		}
+
+		rect := region.Rect.Intersect(img.Bounds())
+		if rect.Empty() {
+			return nil, fmt.Errorf("region %q lies outside the image", region.Name)
+		}
+
+		sub, ok := img.(subImager)
+		if !ok {
+			return nil, fmt.Errorf("image type %T does not support cropping", img)
+		}
+
+		if region.Constraint != nil {
+			if pred, err = predictor.Constrain(pred, region.Constraint); err != nil {
+				return nil, fmt.Errorf("region %q: %v", region.Name, err)
+			}
+		}
+		page, _, _ := recognizePage(context.Background(), pred, seg, sub.SubImage(rect), o)
+		results = append(results, RegionResult{Name: region.Name, Rect: rect, Lines: page.Lines})
+	}
+	return results, nil
+}
+
+type subImager interface {
+	SubImage(r image.Rectangle) image.Image
+}
+
+func (o *options) regionPredictor(preds map[string]predictor.Recognizer, region Region) (predictor.Recognizer, error) {
+	key := region.Model + "\x00" + region.Charset
+	if pred, ok := preds[key]; ok {
+		return pred, nil
+	}
+
+	modelPath := region.Model
+	if modelPath == "" {
+		var err error
+		modelPath, err = o.defaultModel()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var pred predictor.Recognizer
+	if region.Charset != "" {
+		// A custom charset describes its model completely, so the extra
+		// characters configured for the default model don't apply.
+		charset, err := model.LoadCharset(region.Charset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read charset for region %q: %v", region.Name, err)
+		}
+		modelPath, charset, err = o.resolveModel(modelPath, charset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load model for region %q: %v", region.Name, err)
+		}
+		pred, err = o.loadModel(modelPath, charset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load model for region %q: %v", region.Name, err)
+		}
+		preds[key] = pred
+		return pred, nil
+	}
+
+	modelPath, charset, err := o.resolveModel(modelPath, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load model for region %q: %v", region.Name, err)
+	}
+	pred, err = o.newPredictor(modelPath, charset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load model for region %q: %v", region.Name, err)
+	}
+	preds[key] = pred
+	return pred, nil
+}
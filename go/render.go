@@ -0,0 +1,204 @@
+package monocr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// defaultDPI is the resolution PDF pages are rasterized at.
+const defaultDPI = 300
+
+// lowDPI is the first-pass resolution with adaptive DPI.
+const lowDPI = 150
+
+// renderedMarker is written once all pages of a cached render are complete.
+const renderedMarker = ".complete"
+
+// RenderLimits bounds the resources the PDF renderer may use, so a
+// malformed PDF can't take down the host. Zero values mean no limit. CPU
+// and memory limits are enforced on Linux only.
+type RenderLimits struct {
+	Timeout     time.Duration
+	CPUSeconds  uint64
+	MemoryBytes uint64
+}
+
+// renderPDF rasterizes pdfPath into PNG pages and returns the directory
+// holding them. Without a render cache the pages go to a temp dir that
+// cleanup removes; with one, previously rendered pages of the same PDF at
+// the same DPI are reused.
+func renderPDF(ctx context.Context, pdfPath string, o *options) (dir string, cleanup func(), err error) {
+	noop := func() {}
+
+	// Partial renders would poison the cache, so they always use a temp dir.
+	if o.renderCache == "" || o.firstPage > 0 || o.lastPage > 0 {
+		tempDir, err := os.MkdirTemp("", "monocr-go-")
+		if err != nil {
+			return "", noop, err
+		}
+		cleanup := func() { os.RemoveAll(tempDir) }
+
+		if err := renderPages(ctx, pdfPath, tempDir, o); err != nil {
+			cleanup()
+			return "", noop, err
+		}
+		return tempDir, cleanup, nil
+	}
+
+	hash, err := fileHash(pdfPath)
+	if err != nil {
+		return "", noop, err
+	}
+
+	// The key covers everything that changes the rendered pixels.
+	dir = filepath.Join(o.renderCache, hash+"-"+strconv.Itoa(o.renderDPI()))
+	if _, err := os.Stat(filepath.Join(dir, renderedMarker)); err == nil {
+		return dir, noop, nil
+	}
+
+	// Discard any half-finished render from an interrupted run.
+	if err := os.RemoveAll(dir); err != nil {
+		return "", noop, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", noop, err
+	}
+	if err := renderPages(ctx, pdfPath, dir, o); err != nil {
+		os.RemoveAll(dir)
+		return "", noop, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, renderedMarker), nil, 0o644); err != nil {
+		return "", noop, err
+	}
+	return dir, noop, nil
+}
+
+// renderPages renders pdfPath into outDir. With several page workers the
+// page range is split between as many renderer processes, so rendering
+// keeps up with concurrent recognition. pdftoppm pads page numbers to the
+// document's page count, so the chunks' files sort as one render would.
+func renderPages(ctx context.Context, pdfPath, outDir string, o *options) error {
+	if o.pageWorkers <= 1 {
+		return runPdftoppm(ctx, pdfPath, outDir, o)
+	}
+	count, err := pdfPageCount(pdfPath)
+	if err != nil {
+		// Let the renderer report what is wrong with the file
+		return runPdftoppm(ctx, pdfPath, outDir, o)
+	}
+	first, last := max(o.firstPage, 1), count
+	if o.lastPage > 0 {
+		last = min(o.lastPage, count)
+	}
+	if last < first {
+		return runPdftoppm(ctx, pdfPath, outDir, o)
+	}
+
+	size := (last - first + o.pageWorkers) / o.pageWorkers
+	chunkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error)
+	chunks := 0
+	for start := first; start <= last; start += size {
+		chunk := *o
+		chunk.firstPage, chunk.lastPage = start, min(start+size-1, last)
+		chunks++
+		go func() {
+			errs <- runPdftoppm(chunkCtx, pdfPath, outDir, &chunk)
+		}()
+	}
+
+	var firstErr error
+	for range chunks {
+		if err := <-errs; err != nil && firstErr == nil {
+			// One failed chunk fails the render
+			firstErr = err
+			cancel()
+		}
+	}
+	if firstErr != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+// runPdftoppm renders pdfPath into outDir. The renderer's process group is
+// killed if ctx is cancelled or the render timeout expires.
+func runPdftoppm(parent context.Context, pdfPath, outDir string, o *options) error {
+	limits := o.renderLimits
+	ctx := parent
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+
+	pdftoppm, err := findPoppler("pdftoppm")
+	if err != nil {
+		return err
+	}
+
+	// Arguments are passed to the process individually, so paths with
+	// spaces need no quoting on any platform.
+	args := []string{"-png", "-r", strconv.Itoa(o.renderDPI())}
+	if o.firstPage > 0 {
+		args = append(args, "-f", strconv.Itoa(o.firstPage))
+	}
+	if o.lastPage > 0 {
+		args = append(args, "-l", strconv.Itoa(o.lastPage))
+	}
+	args = append(args, pdfPath, filepath.Join(outDir, "page"))
+	cmd := exec.CommandContext(ctx, pdftoppm, args...)
+	isolateProcess(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to convert PDF: %v", err)
+	}
+	if err := applyLimits(cmd.Process.Pid, limits); err != nil {
+		cmd.Cancel()
+		cmd.Wait()
+		return err
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if err := parent.Err(); err != nil {
+			return err
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("failed to convert PDF: renderer exceeded %v timeout", limits.Timeout)
+		}
+		return fmt.Errorf("failed to convert PDF: %v", err)
+	}
+	return nil
+}
+
+// fileHash returns the hex SHA-256 of the file at path.
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// renderDPI returns the resolution to rasterize PDF pages at.
+func (o *options) renderDPI() int {
+	if o.dpi > 0 {
+		return o.dpi
+	}
+	return defaultDPI
+}
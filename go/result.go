@@ -0,0 +1,300 @@
+package monocr
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/quality"
+)
+
+// lowConfidence is the mean page confidence below which a warning is added.
+const lowConfidence = 0.5
+
+// Result is the structured output of recognizing an image or document.
+type Result struct {
+	Pages []Page `json:"pages"`
+	// Warnings lists quality problems noticed along the way, so pipelines
+	// can audit degraded output without scraping logs.
+	Warnings []Warning `json:"warnings,omitempty"`
+	// Matches holds what the extractors configured with WithExtractors found.
+	Matches []Match `json:"matches,omitempty"`
+}
+
+// Warning describes something that degraded part of a result.
+type Warning struct {
+	// Page is the 1-based page number, or 0 for the whole document.
+	Page    int    `json:"page"`
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	if w.Page == 0 {
+		return w.Message
+	}
+	return fmt.Sprintf("page %d: %s", w.Page, w.Message)
+}
+
+// Page holds the recognized lines of a single page or image.
+type Page struct {
+	// Number is the 1-based page number within the source document.
+	Number int `json:"number"`
+	// Logical is the page number printed on the page, when one was
+	// detected; see Result.DetectPageNumbers.
+	Logical int    `json:"logical,omitempty"`
+	Lines   []Line `json:"lines"`
+	// Width and Height are the page image's size in pixels.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Image is the path of the page image the boxes refer to, when it
+	// outlives the call: the input image itself, or a page kept in the
+	// render cache. It is empty when the page was rotated.
+	Image string `json:"image,omitempty"`
+	// Quality is the page image assessment; nil in text-only mode.
+	Quality *quality.Metrics `json:"quality,omitempty"`
+	// Type is PageScanned, PageVector or PageMixed with WithPageTypes.
+	Type string `json:"type,omitempty"`
+	// TextLayer is set when the page was read from the PDF's own text
+	// layer with WithTextLayer instead of being recognized.
+	TextLayer bool `json:"text_layer,omitempty"`
+	// DPI is the resolution the page image was scanned or rendered at,
+	// when known.
+	DPI int `json:"dpi,omitempty"`
+}
+
+// Line is a single recognized text line.
+type Line struct {
+	Text string `json:"text"`
+	// Confidence is the mean probability of the line's characters, in [0, 1].
+	Confidence float64 `json:"confidence"`
+	// BBox is the line's location in the page image.
+	BBox image.Rectangle `json:"bbox"`
+	// ID identifies the line across runs over the same document: its page
+	// number and a hash of where it sits on the page, relative to the
+	// page size, so a newer model or another render resolution gives the
+	// line the same ID. A line whose box moves noticeably gets a new one.
+	// It is empty for lines without a box, as in text-only mode.
+	ID string `json:"id,omitempty"`
+	// Order is the line's 0-based position in reading order on its page,
+	// top to bottom and then left to right. With Page.Number it orders
+	// lines across a document; lines stripped afterwards leave gaps.
+	Order int `json:"order"`
+	// Chars holds each rune of Text with its own confidence and the page
+	// columns it was read from. It is empty in text-only mode.
+	Chars []predictor.Char `json:"chars,omitempty"`
+	// Variant names the alternate preprocessing ("inverted" or
+	// "rebinarized") that produced the text, or "" for the original image.
+	// Lines aren't retried upscaled, since the model sees every line scaled
+	// to its input height anyway.
+	Variant string `json:"variant,omitempty"`
+	// Running is RunningHeader or RunningFooter for a line repeated across
+	// pages, such as a book title or page number, when WithRunningLines
+	// tags them.
+	Running string `json:"running,omitempty"`
+	// Break is BreakNatural, BreakHyphen or BreakParagraph depending on
+	// how the line ends, for reflowing text; it is empty when there is no
+	// layout to judge by.
+	Break string `json:"break,omitempty"`
+	// FontSize is the estimated size of the line's type in points, from
+	// its height and the page's DPI, to tell headings from body text. It
+	// is 0 when the DPI is unknown.
+	FontSize float64 `json:"font_size,omitempty"`
+	// Escaped is Text rewritten for sinks that can't store Myanmar script,
+	// such as numeric character references or a romanization; it is set
+	// by Result.Escape.
+	Escaped string `json:"escaped,omitempty"`
+}
+
+// Text returns the page's lines joined by newlines.
+func (p Page) Text() string {
+	texts := make([]string, len(p.Lines))
+	for i, line := range p.Lines {
+		texts[i] = line.Text
+	}
+	return strings.Join(texts, "\n")
+}
+
+// MarkUncertain is Text with the uncertain words of each line marked; see
+// Line.MarkUncertain.
+func (p Page) MarkUncertain(threshold float64, open, close string) string {
+	texts := make([]string, len(p.Lines))
+	for i, line := range p.Lines {
+		texts[i] = line.MarkUncertain(threshold, open, close)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// Confidence returns the mean confidence of the page's lines.
+func (p Page) Confidence() float64 {
+	if len(p.Lines) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, line := range p.Lines {
+		sum += line.Confidence
+	}
+	return sum / float64(len(p.Lines))
+}
+
+// LowConfidence returns the lines whose confidence is below threshold, for
+// routing to human review.
+func (p Page) LowConfidence(threshold float64) []Line {
+	var lines []Line
+	for _, line := range p.Lines {
+		if line.Confidence < threshold {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func (r *Result) warn(page int, format string, args ...any) {
+	r.Warnings = append(r.Warnings, Warning{Page: page, Message: fmt.Sprintf(format, args...)})
+}
+
+// warnPage records confidence and quality warnings for a recognized page.
+func (r *Result) warnPage(page Page) {
+	if len(page.Lines) > 0 && page.Confidence() < lowConfidence {
+		r.warn(page.Number, "low confidence (%.2f)", page.Confidence())
+	}
+	if page.Quality != nil && page.Quality.NeedsRescan() {
+		r.warn(page.Number, "poor image quality: %s", strings.Join(page.Quality.Issues, ", "))
+	}
+}
+
+// Texts returns the text of every page, in page order.
+func (r *Result) Texts() []string {
+	texts := make([]string, len(r.Pages))
+	for i, page := range r.Pages {
+		texts[i] = page.Text()
+	}
+	return texts
+}
+
+// Merge folds the pages of newer into r, for example after re-running failed
+// pages at a higher DPI. Pages missing from r are added. When both sides
+// have a page, lines are compared one by one if the segmentations agree,
+// found as many lines with boxes overlapping pair by pair, otherwise the
+// page with the higher mean confidence wins.
+func (r *Result) Merge(newer *Result) {
+	if newer == nil {
+		return
+	}
+
+	byNumber := make(map[int]int, len(r.Pages))
+	for i, page := range r.Pages {
+		byNumber[page.Number] = i
+	}
+
+	// Warnings and matches for re-run pages are superseded by the newer run's.
+	rerun := make(map[int]bool, len(newer.Pages))
+	for _, page := range newer.Pages {
+		rerun[page.Number] = true
+	}
+	warnings := r.Warnings[:0:0]
+	for _, w := range r.Warnings {
+		if !rerun[w.Page] {
+			warnings = append(warnings, w)
+		}
+	}
+	r.Warnings = append(warnings, newer.Warnings...)
+
+	matches := r.Matches[:0:0]
+	for _, m := range r.Matches {
+		if !rerun[m.Page] {
+			matches = append(matches, m)
+		}
+	}
+	r.Matches = append(matches, newer.Matches...)
+
+	for _, page := range newer.Pages {
+		i, ok := byNumber[page.Number]
+		if !ok {
+			r.Pages = append(r.Pages, page)
+			continue
+		}
+		r.Pages[i] = mergePage(r.Pages[i], page)
+	}
+
+	sort.SliceStable(r.Pages, func(i, j int) bool {
+		return r.Pages[i].Number < r.Pages[j].Number
+	})
+}
+
+// mergeOverlap is the intersection over union two lines' boxes need for
+// the lines to count as the same line of the page.
+const mergeOverlap = 0.5
+
+func mergePage(old, newer Page) Page {
+	wholePage := func() Page {
+		if newer.Confidence() > old.Confidence() {
+			return newer
+		}
+		return old
+	}
+
+	// Lines can only be mixed if newer's boxes can be brought into old's
+	// pixels, which needs both page sizes. Lines taken from newer, which
+	// may have been rendered at another DPI, are scaled to old's page
+	// image, whose size, DPI and path the merged page keeps.
+	sameSize := old.Width == newer.Width && old.Height == newer.Height
+	scalable := old.Width > 0 && old.Height > 0 && newer.Width > 0 && newer.Height > 0
+	if len(old.Lines) != len(newer.Lines) || !sameSize && !scalable {
+		return wholePage()
+	}
+	lines := newer.Lines
+	if !sameSize {
+		lines = make([]Line, len(newer.Lines))
+		for i, line := range newer.Lines {
+			lines[i] = scaleLine(line, image.Pt(newer.Width, newer.Height), image.Pt(old.Width, old.Height))
+		}
+	}
+	// Both runs must have found the same lines, not just as many
+	for i, line := range lines {
+		if boxOverlap(line.BBox, old.Lines[i].BBox) < mergeOverlap {
+			return wholePage()
+		}
+	}
+
+	// Keep whichever side read each line better
+	merged := old
+	merged.Lines = make([]Line, len(old.Lines))
+	for i := range old.Lines {
+		if lines[i].Confidence > old.Lines[i].Confidence {
+			merged.Lines[i] = lines[i]
+		} else {
+			merged.Lines[i] = old.Lines[i]
+		}
+	}
+	return merged
+}
+
+// boxOverlap returns the intersection over union of a and b, which is 0
+// if either is empty.
+func boxOverlap(a, b image.Rectangle) float64 {
+	inter := a.Intersect(b)
+	if inter.Empty() {
+		return 0
+	}
+	area := func(r image.Rectangle) float64 { return float64(r.Dx()) * float64(r.Dy()) }
+	return area(inter) / (area(a) + area(b) - area(inter))
+}
+
+// scaleLine returns line with its box and character spans scaled from a
+// page image of size from to one of size to.
+func scaleLine(line Line, from, to image.Point) Line {
+	x := func(v int) int { return (v*to.X + from.X/2) / from.X }
+	y := func(v int) int { return (v*to.Y + from.Y/2) / from.Y }
+	line.BBox = image.Rect(x(line.BBox.Min.X), y(line.BBox.Min.Y), x(line.BBox.Max.X), y(line.BBox.Max.Y))
+	if len(line.Chars) > 0 {
+		chars := make([]predictor.Char, len(line.Chars))
+		for i, c := range line.Chars {
+			c.Span.Start, c.Span.End = x(c.Span.Start), x(c.Span.End)
+			chars[i] = c
+		}
+		line.Chars = chars
+	}
+	return line
+}
@@ -0,0 +1,135 @@
+package monocr
+
+import (
+	"image"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/imgproc"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+)
+
+// lineVariant is an alternate preprocessing applied to a line that came
+// back empty or below the retry floor.
+type lineVariant struct {
+	name  string
+	apply func(image.Image) image.Image
+}
+
+// retryVariants are tried in order; the best-scoring reading wins. There
+// is no 2x upscaled variant: the recognizer scales every line to the
+// model's fixed input height, keeping its aspect ratio, so an upscaled
+// line reaches the model at the same size as the original and would only
+// cost another inference run.
+var retryVariants = []lineVariant{
+	{"inverted", invertImage},
+	{"rebinarized", rebinarize},
+}
+
+// retryLine re-recognizes img with each alternate preprocessing and returns
+// the best of those readings and line. Variants are scored even in
+// text-only mode, where line has no confidence of its own, so only a
+// confident reading replaces it. Errors from a variant only drop that
+// variant.
+func retryLine(pred predictor.Recognizer, img image.Image, line Line, o *options) Line {
+	best := line
+	for _, v := range retryVariants {
+		alt := v.apply(img)
+		text, conf, chars, err := pred.PredictChars(alt)
+		if err != nil {
+			continue
+		}
+		candidate := Line{
+			Text:       text,
+			Confidence: conf,
+			BBox:       line.BBox,
+			Chars:      pageChars(chars, alt, line.BBox),
+			Variant:    v.name,
+		}
+		if o.betterLine(candidate, best) {
+			best = candidate
+		}
+	}
+	if !o.scored() {
+		return Line{Text: best.Text, Variant: best.Variant}
+	}
+	return best
+}
+
+// needsRetry reports whether line is empty or below the retry floor.
+func (o *options) needsRetry(line Line) bool {
+	if o.retryFloor < 0 {
+		return false
+	}
+	if line.Text == "" {
+		return true
+	}
+	return o.scored() && line.Confidence < o.retryFloor
+}
+
+// betterLine reports whether the retry reading a beats b. An empty
+// reading's confidence is how sure the model is that the line is blank,
+// and blank or noise segments rightly read as nothing, so text replaces
+// an empty reading only when it clears the retry floor, and never less
+// than lowConfidence: otherwise the inverted or thresholded noise would
+// come back as made-up text.
+func (o *options) betterLine(a, b Line) bool {
+	if b.Text == "" {
+		return a.Text != "" && a.Confidence >= max(o.retryFloor, lowConfidence)
+	}
+	return a.Text != "" && a.Confidence > b.Confidence
+}
+
+func invertImage(img image.Image) image.Image {
+	g := grayCopy(img)
+	imgproc.Invert(g.Pix, g.Pix)
+	return g
+}
+
+// rebinarize thresholds the line at its Otsu level, which recovers faint
+// strokes that the model's own scaling would wash out.
+func rebinarize(img image.Image) image.Image {
+	g := grayCopy(img)
+	imgproc.Threshold(g.Pix, g.Pix, otsuThreshold(g))
+	return g
+}
+
+func grayCopy(img image.Image) *image.Gray {
+	b := img.Bounds()
+	g := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	imgproc.ConvertGray(g, img)
+	return g
+}
+
+// otsuThreshold returns the gray level that best separates ink from
+// background in g.
+func otsuThreshold(g *image.Gray) uint8 {
+	var hist [256]float64
+	for _, v := range g.Pix {
+		hist[v]++
+	}
+
+	var sumAll float64
+	for v, count := range hist {
+		sumAll += float64(v) * count
+	}
+	total := float64(len(g.Pix))
+
+	var bestVar, wDark, sumDark float64
+	var best uint8
+	for t := 0; t < 255; t++ {
+		wDark += hist[t]
+		sumDark += float64(t) * hist[t]
+		wLight := total - wDark
+		if wDark == 0 || wLight == 0 {
+			continue
+		}
+
+		meanDark := sumDark / wDark
+		meanLight := (sumAll - sumDark) / wLight
+		between := wDark * wLight * (meanLight - meanDark) * (meanLight - meanDark)
+		if between > bestVar {
+			bestVar = between
+			best = uint8(t)
+		}
+	}
+	return best
+}
@@ -0,0 +1,397 @@
+package monocr
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/imgproc"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/segmenter"
+)
+
+// openRows opens an image file to be read row by row in grayscale.
+// Non-interlaced PNGs are decoded as their rows are read, so only the rows
+// asked for are ever in memory. Other images are decoded whole, since the
+// standard decoders can't stop part way, and converted a row at a time.
+func openRows(imagePath string) (segmenter.RowSource, func() error, error) {
+	f, err := os.Open(imagePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	br := bufio.NewReader(f)
+	if sig, _ := br.Peek(len(pngSignature)); bytes.Equal(sig, []byte(pngSignature)) {
+		src, err := newPNGRows(br)
+		if err == nil {
+			return src, f.Close, nil
+		}
+		if err != errInterlaced {
+			f.Close()
+			return nil, nil, fmt.Errorf("failed to decode image: %v", err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		br.Reset(f)
+	}
+
+	img, err := decodeReader(br)
+	f.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &imageRows{img: img, y: img.Bounds().Min.Y}, func() error { return nil }, nil
+}
+
+// imageRows reads a decoded image row by row.
+type imageRows struct {
+	img image.Image
+	y   int
+}
+
+func (r *imageRows) Bounds() image.Rectangle {
+	return r.img.Bounds()
+}
+
+func (r *imageRows) ReadRows(dst *image.Gray) error {
+	b := r.img.Bounds()
+	rect := image.Rect(b.Min.X, r.y, b.Max.X, r.y+dst.Rect.Dy())
+	if rect.Max.Y > b.Max.Y {
+		return io.ErrUnexpectedEOF
+	}
+	imgproc.ConvertGray(dst, r.img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}).SubImage(rect))
+	r.y = rect.Max.Y
+	return nil
+}
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// errInterlaced rejects interlaced PNGs, whose rows arrive in seven passes
+// over the whole image and so can't be read in order.
+var errInterlaced = fmt.Errorf("interlaced PNG")
+
+// PNG color types.
+const (
+	pngGray      = 0
+	pngRGB       = 2
+	pngPaletted  = 3
+	pngGrayAlpha = 4
+	pngRGBA      = 6
+)
+
+// pngRows decodes a non-interlaced PNG one row at a time. Pixels are
+// converted to gray as image/png and imgproc.Gray would convert the
+// decoded image.
+type pngRows struct {
+	width, height int
+	depth         int
+	colorType     int
+	palette       color.Palette
+	// bpp is the number of bytes per complete pixel, at least 1, which
+	// the filters look back by.
+	bpp      int
+	z        io.ReadCloser
+	cur      []byte
+	prev     []byte
+	nrgba    *image.NRGBA
+	nrgba64  *image.NRGBA64
+	bounds   image.Rectangle
+	rowsRead int
+}
+
+func newPNGRows(r *bufio.Reader) (*pngRows, error) {
+	if _, err := r.Discard(len(pngSignature)); err != nil {
+		return nil, err
+	}
+	p := &pngRows{}
+	var trns []byte
+	for {
+		length, typ, err := readChunkHeader(r)
+		if err != nil {
+			return nil, err
+		}
+		if typ == "IDAT" {
+			if p.width == 0 {
+				return nil, fmt.Errorf("missing IHDR chunk")
+			}
+			p.setPalette(trns)
+			z, err := zlib.NewReader(&idatReader{r: r, remaining: length})
+			if err != nil {
+				return nil, err
+			}
+			p.z = z
+			return p, nil
+		}
+
+		if length > 1<<20 {
+			// Only ancillary chunks such as text and ICC profiles get
+			// this large; skip them
+			if _, err := r.Discard(int(length) + 4); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		if _, err := r.Discard(4); err != nil { // CRC
+			return nil, err
+		}
+		switch typ {
+		case "IHDR":
+			if err := p.parseHeader(data); err != nil {
+				return nil, err
+			}
+		case "PLTE":
+			for i := 0; i+3 <= len(data); i += 3 {
+				p.palette = append(p.palette, color.NRGBA{data[i], data[i+1], data[i+2], 0xff})
+			}
+		case "tRNS":
+			trns = data
+		}
+	}
+}
+
+func readChunkHeader(r io.Reader) (uint32, string, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, "", err
+	}
+	return binary.BigEndian.Uint32(hdr[:4]), string(hdr[4:]), nil
+}
+
+func (p *pngRows) parseHeader(data []byte) error {
+	if len(data) != 13 {
+		return fmt.Errorf("bad IHDR length %d", len(data))
+	}
+	w, h := binary.BigEndian.Uint32(data[0:4]), binary.BigEndian.Uint32(data[4:8])
+	if w == 0 || h == 0 || w > 1<<30 || h > 1<<30 {
+		return fmt.Errorf("bad dimensions %dx%d", w, h)
+	}
+	p.width, p.height = int(w), int(h)
+	p.depth, p.colorType = int(data[8]), int(data[9])
+	if data[12] != 0 {
+		return errInterlaced
+	}
+
+	channels := map[int]int{pngGray: 1, pngRGB: 3, pngPaletted: 1, pngGrayAlpha: 2, pngRGBA: 4}[p.colorType]
+	valid := channels > 0 && (p.depth == 8 || p.depth == 16 ||
+		p.depth < 8 && (p.colorType == pngGray || p.colorType == pngPaletted) && (p.depth == 1 || p.depth == 2 || p.depth == 4))
+	if !valid || p.colorType == pngPaletted && p.depth == 16 {
+		return fmt.Errorf("unsupported bit depth %d for color type %d", p.depth, p.colorType)
+	}
+
+	bits := channels * p.depth
+	p.bpp = max(1, bits/8)
+	rowBytes := (p.width*bits + 7) / 8
+	p.cur = make([]byte, 1+rowBytes)
+	p.prev = make([]byte, 1+rowBytes)
+	p.bounds = image.Rect(0, 0, p.width, p.height)
+	switch {
+	case p.colorType == pngGray:
+	case p.depth == 16:
+		p.nrgba64 = image.NewNRGBA64(image.Rect(0, 0, p.width, 1))
+	default:
+		p.nrgba = image.NewNRGBA(image.Rect(0, 0, p.width, 1))
+	}
+	return nil
+}
+
+// setPalette applies the alpha values of a tRNS chunk to the palette.
+func (p *pngRows) setPalette(trns []byte) {
+	if p.colorType != pngPaletted {
+		return
+	}
+	for i, a := range trns {
+		if i < len(p.palette) {
+			c := p.palette[i].(color.NRGBA)
+			c.A = a
+			p.palette[i] = c
+		}
+	}
+}
+
+func (p *pngRows) Bounds() image.Rectangle {
+	return p.bounds
+}
+
+func (p *pngRows) ReadRows(dst *image.Gray) error {
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		if p.rowsRead == p.height {
+			return io.ErrUnexpectedEOF
+		}
+		if err := p.readRow(); err != nil {
+			return fmt.Errorf("failed to decode image: %v", err)
+		}
+		p.rowsRead++
+		p.convert(dst.Pix[y*dst.Stride : y*dst.Stride+p.width])
+	}
+	if p.rowsRead == p.height {
+		p.z.Close()
+	}
+	return nil
+}
+
+// readRow reads and unfilters the next row into p.cur.
+func (p *pngRows) readRow() error {
+	p.cur, p.prev = p.prev, p.cur
+	if _, err := io.ReadFull(p.z, p.cur); err != nil {
+		return err
+	}
+	cur, prev := p.cur[1:], p.prev[1:]
+	bpp := p.bpp
+	switch p.cur[0] {
+	case 0:
+	case 1: // Sub
+		for i := bpp; i < len(cur); i++ {
+			cur[i] += cur[i-bpp]
+		}
+	case 2: // Up
+		for i := range cur {
+			cur[i] += prev[i]
+		}
+	case 3: // Average
+		for i := range cur {
+			left := 0
+			if i >= bpp {
+				left = int(cur[i-bpp])
+			}
+			cur[i] += uint8((left + int(prev[i])) / 2)
+		}
+	case 4: // Paeth
+		for i := range cur {
+			var a, c int
+			if i >= bpp {
+				a, c = int(cur[i-bpp]), int(prev[i-bpp])
+			}
+			cur[i] += paeth(a, int(prev[i]), c)
+		}
+	default:
+		return fmt.Errorf("bad filter type %d", p.cur[0])
+	}
+	return nil
+}
+
+func paeth(a, b, c int) uint8 {
+	pa, pb, pc := abs(b-c), abs(a-c), abs(a+b-2*c)
+	switch {
+	case pa <= pb && pa <= pc:
+		return uint8(a)
+	case pb <= pc:
+		return uint8(b)
+	}
+	return uint8(c)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// sample returns the x-th sample of depth bits in row, for depths below 8.
+func sample(row []byte, x, depth int) int {
+	bit := x * depth
+	shift := 8 - depth - bit%8
+	return int(row[bit/8]>>shift) & (1<<depth - 1)
+}
+
+// convert writes the gray values of the row in p.cur to dst.
+func (p *pngRows) convert(dst []uint8) {
+	row := p.cur[1:]
+	switch {
+	case p.colorType == pngGray && p.depth == 8:
+		copy(dst, row)
+	case p.colorType == pngGray && p.depth == 16:
+		// Gray16 converts to Gray by its high byte
+		for x := range dst {
+			dst[x] = row[2*x]
+		}
+	case p.colorType == pngGray:
+		scale := 0xff / (1<<p.depth - 1)
+		for x := range dst {
+			dst[x] = uint8(sample(row, x, p.depth) * scale)
+		}
+	case p.depth == 16:
+		pix := p.nrgba64.Pix
+		for x := 0; x < p.width; x++ {
+			o := pix[8*x : 8*x+8]
+			switch p.colorType {
+			case pngRGB:
+				copy(o, row[6*x:6*x+6])
+				o[6], o[7] = 0xff, 0xff
+			case pngRGBA:
+				copy(o, row[8*x:8*x+8])
+			case pngGrayAlpha:
+				g := row[4*x : 4*x+4]
+				copy(o, []byte{g[0], g[1], g[0], g[1], g[0], g[1], g[2], g[3]})
+			}
+		}
+		imgproc.ConvertGray(&image.Gray{Pix: dst, Stride: p.width, Rect: image.Rect(0, 0, p.width, 1)}, p.nrgba64)
+	default:
+		pix := p.nrgba.Pix
+		for x := 0; x < p.width; x++ {
+			o := pix[4*x : 4*x+4]
+			switch p.colorType {
+			case pngRGB:
+				copy(o, row[3*x:3*x+3])
+				o[3] = 0xff
+			case pngRGBA:
+				copy(o, row[4*x:4*x+4])
+			case pngGrayAlpha:
+				o[0], o[1], o[2], o[3] = row[2*x], row[2*x], row[2*x], row[2*x+1]
+			case pngPaletted:
+				var i int
+				if p.depth < 8 {
+					i = sample(row, x, p.depth)
+				} else {
+					i = int(row[x])
+				}
+				// Out-of-range indices are black, as in image/png
+				c := color.NRGBA{A: 0xff}
+				if i < len(p.palette) {
+					c = p.palette[i].(color.NRGBA)
+				}
+				o[0], o[1], o[2], o[3] = c.R, c.G, c.B, c.A
+			}
+		}
+		imgproc.ConvertGray(&image.Gray{Pix: dst, Stride: p.width, Rect: image.Rect(0, 0, p.width, 1)}, p.nrgba)
+	}
+}
+
+// idatReader reads the image data split across consecutive IDAT chunks.
+// Chunk CRCs aren't checked; the zlib stream's own checksum covers the
+// data.
+type idatReader struct {
+	r         *bufio.Reader
+	remaining uint32
+}
+
+func (d *idatReader) Read(b []byte) (int, error) {
+	for d.remaining == 0 {
+		if _, err := d.r.Discard(4); err != nil {
+			return 0, err
+		}
+		length, typ, err := readChunkHeader(d.r)
+		if err != nil {
+			return 0, err
+		}
+		if typ != "IDAT" {
+			return 0, io.ErrUnexpectedEOF
+		}
+		d.remaining = length
+	}
+	n, err := d.r.Read(b[:min(len(b), int(d.remaining))])
+	d.remaining -= uint32(n)
+	return n, err
+}
@@ -0,0 +1,153 @@
+package monocr
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// runningDepth is how many lines at the top and bottom of a page are
+	// considered as running header or footer candidates.
+	runningDepth = 2
+	// runningMargin is the share of the page height, from either edge, a
+	// candidate must lie in.
+	runningMargin = 0.15
+	// runningPages is the number of pages a line must repeat on.
+	runningPages = 3
+)
+
+// Running line positions recorded in Line.Running.
+const (
+	RunningHeader = "header"
+	RunningFooter = "footer"
+)
+
+// RunningLines selects what WithRunningLines does with detected running
+// headers, footers and page numbers.
+type RunningLines int
+
+const (
+	// TagRunningLines marks them in Line.Running and leaves them in place.
+	TagRunningLines RunningLines = iota + 1
+	// StripRunningLines removes them from the pages.
+	StripRunningLines
+)
+
+// WithRunningLines detects running headers and footers, such as a book
+// title or page numbers repeated across the pages of a PDF, and tags or
+// strips them so concatenated text isn't littered with them.
+func WithRunningLines(action RunningLines) Option {
+	return func(o *options) {
+		o.runningLines = action
+	}
+}
+
+// applyRunningLines handles running lines as configured by o.
+func (o *options) applyRunningLines(r *Result) {
+	switch o.runningLines {
+	case TagRunningLines:
+		r.MarkRunningLines()
+	case StripRunningLines:
+		r.MarkRunningLines()
+		r.StripRunningLines()
+	}
+}
+
+// runningCandidate is a line near a page edge that may repeat.
+type runningCandidate struct {
+	page, line int
+	position   string
+	key        string
+}
+
+// MarkRunningLines sets Line.Running on lines at the top or bottom of a
+// page whose text, ignoring digits, repeats at the same edge on at least
+// three pages. Digits are ignored so page numbers and headers carrying
+// them ("Chapter 3 · 41") are recognized; close readings count as the
+// same text to tolerate OCR noise. It returns the number of lines marked.
+func (r *Result) MarkRunningLines() int {
+	var candidates []runningCandidate
+	for pi, page := range r.Pages {
+		for li, line := range page.Lines {
+			var position string
+			switch {
+			case li < runningDepth && float64(line.BBox.Max.Y) <= runningMargin*float64(page.Height):
+				position = RunningHeader
+			case li >= len(page.Lines)-runningDepth && float64(line.BBox.Min.Y) >= (1-runningMargin)*float64(page.Height):
+				position = RunningFooter
+			default:
+				continue
+			}
+			key := runningKey(line.Text)
+			if key == "" {
+				continue
+			}
+			candidates = append(candidates, runningCandidate{page: pi, line: li, position: position, key: key})
+		}
+	}
+
+	// Group candidates into clusters of matching text at the same edge
+	type cluster struct {
+		position string
+		key      string
+		pages    map[int]bool
+		members  []runningCandidate
+	}
+	var clusters []*cluster
+	for _, c := range candidates {
+		var match *cluster
+		for _, cl := range clusters {
+			if cl.position == c.position && (cl.key == c.key || similarity(cl.key, c.key) >= lineMatch) {
+				match = cl
+				break
+			}
+		}
+		if match == nil {
+			match = &cluster{position: c.position, key: c.key, pages: map[int]bool{}}
+			clusters = append(clusters, match)
+		}
+		match.pages[c.page] = true
+		match.members = append(match.members, c)
+	}
+
+	marked := 0
+	for _, cl := range clusters {
+		if len(cl.pages) < runningPages {
+			continue
+		}
+		for _, m := range cl.members {
+			r.Pages[m.page].Lines[m.line].Running = cl.position
+			marked++
+		}
+	}
+	return marked
+}
+
+// StripRunningLines removes the lines marked by MarkRunningLines.
+func (r *Result) StripRunningLines() {
+	for i, page := range r.Pages {
+		lines := page.Lines[:0]
+		for _, line := range page.Lines {
+			if line.Running == "" {
+				lines = append(lines, line)
+			}
+		}
+		r.Pages[i].Lines = lines
+	}
+}
+
+// runningKey normalizes a line for comparison across pages: whitespace is
+// dropped and every digit, Myanmar digits included, becomes '#'.
+func runningKey(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+		case unicode.IsDigit(r):
+			b.WriteRune('#')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package monocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ScanOptions configures a scan with Scan.
+type ScanOptions struct {
+	// Device is the SANE device name, as listed by ScanDevices; empty
+	// uses the first scanner SANE finds.
+	Device string
+	// DPI is the scan resolution; 0 means defaultDPI.
+	DPI int
+	// Color scans in color instead of grayscale.
+	Color bool
+}
+
+// ScanDevice is a scanner SANE can use.
+type ScanDevice struct {
+	Name  string `json:"name"`
+	Model string `json:"model"`
+}
+
+// scanHint tells users how to get scanimage.
+const scanHint = "install SANE's scanimage (e.g. sane-utils or sane-backends)"
+
+// Scan captures a page from a SANE scanner with the scanimage tool.
+func Scan(ctx context.Context, so ScanOptions) (image.Image, error) {
+	tool, err := exec.LookPath("scanimage")
+	if err != nil {
+		return nil, fmt.Errorf("scanimage not found: %s", scanHint)
+	}
+	dpi := so.DPI
+	if dpi == 0 {
+		dpi = defaultDPI
+	}
+	mode := "Gray"
+	if so.Color {
+		mode = "Color"
+	}
+	args := []string{"--format=png", "--resolution", strconv.Itoa(dpi), "--mode", mode}
+	if so.Device != "" {
+		args = append(args, "--device-name", so.Device)
+	}
+
+	out, err := runCapture(exec.CommandContext(ctx, tool, args...))
+	if err != nil {
+		return nil, fmt.Errorf("scan failed: %v", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(out))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode scan: %v", err)
+	}
+	return img, nil
+}
+
+// ScanDevices lists the scanners SANE finds.
+func ScanDevices(ctx context.Context) ([]ScanDevice, error) {
+	tool, err := exec.LookPath("scanimage")
+	if err != nil {
+		return nil, fmt.Errorf("scanimage not found: %s", scanHint)
+	}
+	out, err := runCapture(exec.CommandContext(ctx, tool, "--formatted-device-list=%d\t%v %m%n"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scanners: %v", err)
+	}
+	var devices []ScanDevice
+	for _, line := range strings.Split(string(out), "\n") {
+		name, model, ok := strings.Cut(line, "\t")
+		if !ok || name == "" {
+			continue
+		}
+		devices = append(devices, ScanDevice{Name: name, Model: strings.TrimSpace(model)})
+	}
+	return devices, nil
+}
+
+// runCapture runs a capture tool and returns its output, with its error
+// messages in the error when it fails.
+func runCapture(cmd *exec.Cmd) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
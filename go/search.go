@@ -0,0 +1,65 @@
+package monocr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SearchLine is a recognized line in a search index document.
+type SearchLine struct {
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+	// BBox is [x0, y0, x1, y1] in page image pixels.
+	BBox [4]int `json:"bbox"`
+}
+
+// SearchDoc is one page of a document as indexed for full-text search.
+type SearchDoc struct {
+	Document string       `json:"document"`
+	Page     int          `json:"page"`
+	Text     string       `json:"text"`
+	Lines    []SearchLine `json:"lines,omitempty"`
+}
+
+// SearchDocs converts result into one search document per page of the
+// document identified by docID.
+func (r *Result) SearchDocs(docID string) []SearchDoc {
+	docs := make([]SearchDoc, 0, len(r.Pages))
+	for _, page := range r.Pages {
+		doc := SearchDoc{Document: docID, Page: page.Number, Text: page.Text()}
+		for _, line := range page.Lines {
+			b := line.BBox
+			doc.Lines = append(doc.Lines, SearchLine{
+				Text:       line.Text,
+				Confidence: line.Confidence,
+				BBox:       [4]int{b.Min.X, b.Min.Y, b.Max.X, b.Max.Y},
+			})
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// WriteBulk writes result as an Elasticsearch bulk request body: an index
+// action followed by the page document, one JSON object per line. Pages
+// get the ID "<docID>-<page>" so re-running a document overwrites its
+// pages. The document lines alone are valid NDJSON for Bleve and other
+// engines that ingest plain JSON documents.
+func (r *Result) WriteBulk(w io.Writer, index, docID string) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	for _, doc := range r.SearchDocs(docID) {
+		action := map[string]map[string]string{
+			"index": {"_index": index, "_id": fmt.Sprintf("%s-%d", docID, doc.Page)},
+		}
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
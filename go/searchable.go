@@ -0,0 +1,116 @@
+package monocr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/pdf"
+	"github.com/MonDevHub/monocr-onnx/go/pkg/segmenter"
+)
+
+// SearchablePDF renders every page of pdfPath and writes them to outPath
+// with an invisible text layer of the recognized text, so the scan can be
+// searched and its text selected in any PDF viewer.
+func SearchablePDF(pdfPath, outPath string, opts ...Option) error {
+	if _, err := findPoppler("pdftoppm"); err != nil {
+		return err
+	}
+
+	r, err := NewReader(opts...)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return r.SearchablePDF(pdfPath, outPath)
+}
+
+// SearchablePDF is the Reader form of the package-level SearchablePDF.
+func (r *Reader) SearchablePDF(pdfPath, outPath string) error {
+	// Word positions are needed even if text-only output was asked for
+	o := *r.o
+	o.textOnly = false
+
+	pageDir, cleanup, err := renderPDF(context.Background(), pdfPath, &o)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	files, err := os.ReadDir(pageDir)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w, err := pdf.NewWriter(out)
+	if err != nil {
+		return err
+	}
+
+	seg := segmenter.NewLineSegmenter(10, 3)
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".png") {
+			continue
+		}
+
+		img, err := decodeFile(filepath.Join(pageDir, file.Name()))
+		if err != nil {
+			return fmt.Errorf("page %d: %v", pageNumber(file.Name(), 0), err)
+		}
+		img, err = o.orientImage(r.pred, img)
+		if err != nil {
+			return err
+		}
+
+		page, _, err := recognizePage(context.Background(), r.pred, seg, img, &o)
+		if err != nil {
+			return err
+		}
+		if err := w.AddPage(img, o.renderDPI(), textBoxes(page)); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// textBoxes lays out a page's text for the PDF text layer, word by word
+// where character positions are known and by whole lines otherwise.
+func textBoxes(page Page) []pdf.TextBox {
+	var boxes []pdf.TextBox
+	for _, line := range page.Lines {
+		words := line.Words()
+		located := len(words) > 0
+		for _, word := range words {
+			if word.BBox.Empty() {
+				located = false
+				break
+			}
+		}
+		if !located {
+			boxes = append(boxes, pdf.TextBox{Text: line.Text, Rect: line.BBox})
+			continue
+		}
+
+		for i, word := range words {
+			text := word.Text
+			// Keep the space so copied text keeps its word breaks
+			if i < len(words)-1 {
+				text += " "
+			}
+			boxes = append(boxes, pdf.TextBox{Text: text, Rect: word.BBox})
+		}
+	}
+	return boxes
+}
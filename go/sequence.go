@@ -0,0 +1,80 @@
+package monocr
+
+import (
+	"strings"
+)
+
+// lineMatch is the similarity two line readings need to count as the same
+// line seen in two frames.
+const lineMatch = 0.8
+
+// ReadSequence recognizes a series of overlapping screenshots, such as a
+// long chat or web page captured while scrolling, and returns the text
+// with the lines repeated in consecutive frames kept only once. Frames
+// must be given in scroll order.
+func ReadSequence(paths []string, opts ...Option) (string, error) {
+	r, err := NewReader(opts...)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	return r.ReadSequence(paths)
+}
+
+// ReadSequence is the Reader form of the package-level ReadSequence.
+func (r *Reader) ReadSequence(paths []string) (string, error) {
+	var merged []string
+	for _, path := range paths {
+		img, err := decodeFile(path)
+		if err != nil {
+			return "", err
+		}
+		page, err := r.RecognizeWithLayout(img)
+		if err != nil {
+			return "", err
+		}
+
+		var lines []string
+		for _, line := range page.Lines {
+			if text := strings.TrimSpace(line.Text); text != "" {
+				lines = append(lines, text)
+			}
+		}
+		merged = appendFrame(merged, lines)
+	}
+	return strings.Join(merged, "\n"), nil
+}
+
+// appendFrame appends the lines of a new frame to merged, dropping the
+// leading lines that repeat the end of merged. The first line of the
+// overlap may differ, since scrolling often cuts it off at the frame edge.
+func appendFrame(merged, frame []string) []string {
+	for k := min(len(merged), len(frame)); k > 0; k-- {
+		tail := merged[len(merged)-k:]
+		if overlaps(tail, frame[:k]) {
+			return append(merged, frame[k:]...)
+		}
+	}
+	return append(merged, frame...)
+}
+
+func overlaps(tail, head []string) bool {
+	for i := range tail {
+		if similarity(tail[i], head[i]) >= lineMatch {
+			continue
+		}
+		// Only a clipped first line may mismatch, and not in a one-line
+		// overlap, where it would be the only evidence.
+		if i == 0 && len(tail) > 1 {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// similarity is 1 minus the normalized edit distance between a and b.
+func similarity(a, b string) float64 {
+	return calculateAccuracy(a, b) / 100
+}
@@ -0,0 +1,63 @@
+package monocr
+
+import (
+	"context"
+	"math"
+	"unicode"
+)
+
+// maxUnmapped is the share of unusable characters above which a page's
+// text layer is ignored. Fonts without a Unicode mapping, common in older
+// Mon typesetting, extract as replacement, private use or control
+// characters.
+const maxUnmapped = 0.1
+
+// readTextLayer reads the text of the pages o selects from the PDF, with
+// boxes at the render resolution so they line up with recognized pages.
+// It returns the pages with usable text by number, and whether that is
+// every page.
+func readTextLayer(ctx context.Context, pdfPath string, o *options) (map[int]Page, bool, error) {
+	layouts, err := analyzePDF(ctx, pdfPath, o, true)
+	if err != nil {
+		return nil, false, err
+	}
+
+	scale := float64(o.renderDPI()) / 72
+	pages := make(map[int]Page)
+	for number, layout := range layouts {
+		page := Page{
+			Number:    number,
+			Width:     int(math.Round(layout.Width * scale)),
+			Height:    int(math.Round(layout.Height * scale)),
+			TextLayer: true,
+		}
+		for _, t := range layout.Texts {
+			if text := t.text(); text != "" {
+				page.Lines = append(page.Lines, Line{Text: text, Confidence: 1, BBox: t.rect(scale)})
+			}
+		}
+		if len(page.Lines) == 0 || !usableText(page.Lines) {
+			continue
+		}
+		orderLines(page.Lines)
+		page.setFontSizes(o.renderDPI())
+		page.setLineIDs()
+		pages[number] = page
+	}
+	return pages, len(layouts) > 0 && len(pages) == len(layouts), nil
+}
+
+// usableText reports whether at most maxUnmapped of the lines' characters
+// are replacement, private use or control characters.
+func usableText(lines []Line) bool {
+	total, bad := 0, 0
+	for _, line := range lines {
+		for _, r := range line.Text {
+			total++
+			if r == unicode.ReplacementChar || unicode.Is(unicode.Co, r) || (unicode.IsControl(r) && !unicode.IsSpace(r)) {
+				bad++
+			}
+		}
+	}
+	return float64(bad) <= maxUnmapped*float64(total)
+}
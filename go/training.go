@@ -0,0 +1,135 @@
+package monocr
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TrainingLine pairs a line's location on a page image with its corrected
+// transcription.
+type TrainingLine struct {
+	// Image is the path of the page image.
+	Image string
+	BBox  image.Rectangle
+	Text  string
+}
+
+// TrainingLines returns the lines of r as training pairs. r is typically a
+// JSON result whose line texts were corrected by hand. Pages without an
+// image path are skipped.
+func (r *Result) TrainingLines() []TrainingLine {
+	var lines []TrainingLine
+	for _, page := range r.Pages {
+		if page.Image == "" {
+			continue
+		}
+		for _, line := range page.Lines {
+			lines = append(lines, TrainingLine{Image: page.Image, BBox: line.BBox, Text: line.Text})
+		}
+	}
+	return lines
+}
+
+// LoadLabelStudioExport reads a Label Studio JSON export and returns the
+// corrected transcription of every annotated region. imagePath maps a
+// task's image URL back to the local file, e.g. by stripping the prefix
+// used on export. Tasks nobody annotated are skipped.
+func LoadLabelStudioExport(exportPath string, imagePath func(url string) string) ([]TrainingLine, error) {
+	data, err := os.ReadFile(exportPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []LabelStudioTask
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return nil, fmt.Errorf("failed to parse Label Studio export: %v", err)
+	}
+
+	var lines []TrainingLine
+	for _, task := range tasks {
+		if len(task.Annotations) == 0 {
+			continue
+		}
+		// The last submission is the most recent correction
+		annotation := task.Annotations[len(task.Annotations)-1]
+		for _, region := range annotation.Result {
+			if region.Type != "textarea" || len(region.Value.Text) == 0 {
+				continue
+			}
+			v := region.Value
+			w, h := float64(region.OriginalWidth), float64(region.OriginalHeight)
+			lines = append(lines, TrainingLine{
+				Image: imagePath(task.Data.Image),
+				BBox: image.Rect(
+					int(v.X*w/100+0.5), int(v.Y*h/100+0.5),
+					int((v.X+v.Width)*w/100+0.5), int((v.Y+v.Height)*h/100+0.5),
+				),
+				Text: strings.Join(v.Text, " "),
+			})
+		}
+	}
+	return lines, nil
+}
+
+// WriteTrainingSet crops each line from its page image into dir as
+// NNNNNN.png with the transcription beside it in NNNNNN.gt.txt, the
+// line-image + text layout used to fine-tune the model. A labels.tsv
+// manifest ("file<TAB>text" per line) is written as well. Lines with empty
+// text are skipped. It returns the number of pairs written.
+func WriteTrainingSet(dir string, lines []TrainingLine) (int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+
+	manifest, err := os.Create(filepath.Join(dir, "labels.tsv"))
+	if err != nil {
+		return 0, err
+	}
+	defer manifest.Close()
+
+	// Lines come grouped by page, so keep only the current page decoded
+	var pagePath string
+	var page image.Image
+
+	written := 0
+	for _, line := range lines {
+		text := strings.TrimSpace(strings.ReplaceAll(line.Text, "\n", " "))
+		if text == "" {
+			continue
+		}
+
+		if line.Image != pagePath {
+			page, err = decodeFile(line.Image)
+			if err != nil {
+				return written, fmt.Errorf("%s: %v", line.Image, err)
+			}
+			pagePath = line.Image
+		}
+
+		sub, ok := page.(subImager)
+		if !ok {
+			return written, fmt.Errorf("image type %T does not support cropping", page)
+		}
+		rect := line.BBox.Intersect(page.Bounds())
+		if rect.Empty() {
+			continue
+		}
+
+		name := fmt.Sprintf("%06d", written+1)
+		if err := encodeFile(filepath.Join(dir, name+".png"), sub.SubImage(rect)); err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".gt.txt"), []byte(text+"\n"), 0o644); err != nil {
+			return written, err
+		}
+		if _, err := fmt.Fprintf(manifest, "%s.png\t%s\n", name, text); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, manifest.Close()
+}
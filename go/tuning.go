@@ -0,0 +1,90 @@
+package monocr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/MonDevHub/monocr-onnx/go/pkg/predictor"
+)
+
+// tuningMu serializes access to the tuning cache within the process.
+var tuningMu sync.Mutex
+
+// WithThreadTuning picks the ONNX Runtime thread count for the host when
+// the model is loaded: the first time, predictor.TuneThreads benchmarks a
+// few counts for about two seconds, and the fastest is cached in the
+// user's config directory (threads.json under monocr) for later runs. The
+// cache entry is tied to the model file and the number of CPUs, so a new
+// model or a resized container tunes again.
+func WithThreadTuning() Option {
+	return func(o *options) {
+		o.tuneThreads = true
+	}
+}
+
+// tunedThreads returns the thread count for modelPath on this host, from
+// the tuning cache or by benchmarking and caching the result.
+func (o *options) tunedThreads(modelPath, charset string) (int, error) {
+	info, err := os.Stat(modelPath)
+	if err != nil {
+		return 0, err
+	}
+	abs, err := filepath.Abs(modelPath)
+	if err != nil {
+		return 0, err
+	}
+	key := fmt.Sprintf("%s %d %d %s/%d", abs, info.Size(), info.ModTime().Unix(), runtime.GOARCH, runtime.NumCPU())
+
+	tuningMu.Lock()
+	defer tuningMu.Unlock()
+
+	path := tuningCachePath()
+	cache := map[string]int{}
+	if data, err := os.ReadFile(path); err == nil {
+		// A corrupt cache is rebuilt
+		json.Unmarshal(data, &cache)
+	}
+	if n, ok := cache[key]; ok && n > 0 {
+		return n, nil
+	}
+
+	n, err := predictor.TuneThreads(o.backend, modelPath, charset, predictor.DefaultTuneBudget, o.predictor...)
+	if err != nil {
+		return 0, err
+	}
+	cache[key] = n
+	// Failing to cache only means tuning again next time
+	if path != "" {
+		writeTuningCache(path, cache)
+	}
+	return n, nil
+}
+
+// tuningCachePath returns where tuned thread counts are kept, or "" if
+// the platform has no config directory.
+func tuningCachePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "monocr", "threads.json")
+}
+
+func writeTuningCache(path string, cache map[string]int) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,169 @@
+package monocr
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ValidationLimits bounds the inputs Validate accepts.
+type ValidationLimits struct {
+	// MinHeight is the smallest image height that can hold a text line.
+	MinHeight int
+	// MaxPixels caps width*height of images, to reject decompression bombs.
+	MaxPixels int
+	// MaxPages caps the page count of PDFs; 0 means no limit.
+	MaxPages int
+}
+
+// DefaultValidationLimits are used by Validate.
+var DefaultValidationLimits = ValidationLimits{
+	MinHeight: 10,
+	MaxPixels: 256 << 20,
+}
+
+// FileInfo describes an input that passed validation.
+type FileInfo struct {
+	Format string // "png", "jpeg" or "pdf"
+	Width  int    // images only
+	Height int    // images only
+	Pages  int    // 1 for images
+}
+
+// ValidationError explains why an input was rejected.
+type ValidationError struct {
+	Path   string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Reason)
+}
+
+// Validate checks that path is a supported, decodable image or PDF of a
+// usable size without running OCR. Rejections are *ValidationError values
+// with a specific reason.
+func Validate(path string) (*FileInfo, error) {
+	return ValidateWithLimits(path, DefaultValidationLimits)
+}
+
+// ValidateWithLimits is Validate with custom limits.
+func ValidateWithLimits(path string, limits ValidationLimits) (*FileInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 5)
+	n, _ := io.ReadFull(f, header)
+	if bytes.Equal(header[:n], []byte("%PDF-")) {
+		return validatePDF(path, limits)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return validateImage(path, f, limits)
+}
+
+func validateImage(path string, r io.ReadSeeker, limits ValidationLimits) (*FileInfo, error) {
+	reject := func(format string, args ...any) (*FileInfo, error) {
+		return nil, &ValidationError{Path: path, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	cfg, format, err := image.DecodeConfig(r)
+	if err == image.ErrFormat {
+		return reject("unsupported format: expected PNG, JPEG or PDF")
+	}
+	if err != nil {
+		return reject("corrupt image header: %v", err)
+	}
+
+	if cfg.Width == 0 || cfg.Height == 0 {
+		return reject("image is empty")
+	}
+	if cfg.Height < limits.MinHeight {
+		return reject("image height %dpx is below the minimum of %dpx", cfg.Height, limits.MinHeight)
+	}
+	if limits.MaxPixels > 0 && cfg.Width*cfg.Height > limits.MaxPixels {
+		return reject("image is %dx%d, larger than the %d pixel limit", cfg.Width, cfg.Height, limits.MaxPixels)
+	}
+
+	// The header can be intact while the pixel data is truncated.
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, _, err := image.Decode(r); err != nil {
+		return reject("image data cannot be decoded: %v", err)
+	}
+
+	return &FileInfo{Format: format, Width: cfg.Width, Height: cfg.Height, Pages: 1}, nil
+}
+
+func validatePDF(path string, limits ValidationLimits) (*FileInfo, error) {
+	reject := func(format string, args ...any) (*FileInfo, error) {
+		return nil, &ValidationError{Path: path, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	pages, err := pdfPageCount(path)
+	if err != nil {
+		return reject("unreadable PDF: %v", err)
+	}
+	if pages == 0 {
+		return reject("PDF has no pages")
+	}
+	if limits.MaxPages > 0 && pages > limits.MaxPages {
+		return reject("PDF has %d pages, more than the limit of %d", pages, limits.MaxPages)
+	}
+
+	return &FileInfo{Format: "pdf", Pages: pages}, nil
+}
+
+// pdfPageCount reads the page count reported by pdfinfo.
+func pdfPageCount(path string) (int, error) {
+	info, err := pdfInfo(path)
+	if err != nil {
+		return 0, err
+	}
+	pages, ok := info["Pages"]
+	if !ok {
+		return 0, fmt.Errorf("pdfinfo did not report a page count")
+	}
+	return strconv.Atoi(pages)
+}
+
+// pdfInfo runs pdfinfo on path and returns its "Key: value" fields.
+func pdfInfo(path string) (map[string]string, error) {
+	pdfinfo, err := findPoppler("pdfinfo")
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(pdfinfo, path)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return nil, err
+	}
+
+	info := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok {
+			info[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,57 @@
+//go:build webcam
+
+package monocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"os/exec"
+	"runtime"
+)
+
+// CaptureWebcam grabs a frame from a webcam with ffmpeg. device is the
+// camera as the platform's capture API names it: /dev/video0 on Linux, an
+// index such as 0 on macOS or a device name on Windows. Empty uses the
+// first camera.
+func CaptureWebcam(ctx context.Context, device string) (image.Image, error) {
+	tool, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg not found: install it to capture from webcams")
+	}
+
+	var input []string
+	switch runtime.GOOS {
+	case "linux":
+		if device == "" {
+			device = "/dev/video0"
+		}
+		input = []string{"-f", "v4l2", "-i", device}
+	case "darwin":
+		if device == "" {
+			device = "0"
+		}
+		input = []string{"-f", "avfoundation", "-framerate", "30", "-i", device + ":none"}
+	case "windows":
+		if device == "" {
+			return nil, fmt.Errorf("name the camera to capture from, as listed by ffmpeg -list_devices true -f dshow -i dummy")
+		}
+		input = []string{"-f", "dshow", "-i", "video=" + device}
+	default:
+		return nil, fmt.Errorf("webcam capture is not supported on %s", runtime.GOOS)
+	}
+
+	// Cameras adjust exposure over the first frames, so keep a later one
+	args := append([]string{"-hide_banner", "-loglevel", "error"}, input...)
+	args = append(args, "-vf", "select=gte(n\\,10)", "-frames:v", "1", "-f", "image2pipe", "-vcodec", "png", "-")
+	out, err := runCapture(exec.CommandContext(ctx, tool, args...))
+	if err != nil {
+		return nil, fmt.Errorf("webcam capture failed: %v", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(out))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode webcam frame: %v", err)
+	}
+	return img, nil
+}
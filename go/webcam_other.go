@@ -0,0 +1,15 @@
+//go:build !webcam
+
+package monocr
+
+import (
+	"context"
+	"fmt"
+	"image"
+)
+
+// CaptureWebcam grabs a frame from a webcam. This build has no webcam
+// support: build with -tags webcam to add it.
+func CaptureWebcam(ctx context.Context, device string) (image.Image, error) {
+	return nil, fmt.Errorf("webcam capture is not built in: rebuild with -tags webcam")
+}
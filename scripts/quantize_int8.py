@@ -0,0 +1,17 @@
+"""Quantize monocr.onnx to the int8 variant published as monocr-int8.onnx.
+
+Usage: python scripts/quantize_int8.py [model/monocr.onnx] [model/monocr-int8.onnx]
+
+Weights are quantized to int8 ahead of time and activations dynamically at
+run time, which needs no calibration data and suits the recognizer's
+LSTM and linear layers.
+"""
+import sys
+
+from onnxruntime.quantization import QuantType, quantize_dynamic
+
+src = sys.argv[1] if len(sys.argv) > 1 else "model/monocr.onnx"
+dst = sys.argv[2] if len(sys.argv) > 2 else "model/monocr-int8.onnx"
+
+quantize_dynamic(src, dst, weight_type=QuantType.QInt8)
+print(f"Wrote {dst}")